@@ -1,38 +1,81 @@
 module github.com/danieldin95/openlan-go
 
-go 1.12
+go 1.24
 
 require (
-	github.com/Sirupsen/logrus v0.11.0 // indirect
-	github.com/akavel/rsrc v0.8.0 // indirect
 	github.com/armon/go-socks5 v0.0.0-20160902184237-e75332964ef5
 	github.com/chzyer/readline v0.0.0-20180603132655-2972be24d48e
 	github.com/coreos/go-systemd/v22 v22.0.0
-	github.com/docker/libnetwork v0.5.6 // indirect
 	github.com/go-ldap/ldap v3.0.3+incompatible
-	github.com/godbus/dbus v4.1.0+incompatible // indirect
 	github.com/gorilla/mux v1.7.4
 	github.com/moby/libnetwork v0.5.6
 	github.com/shadowsocks/go-shadowsocks2 v0.1.5
 	github.com/songgao/water v0.0.0-20190725173103-fd331bda3f4b
-	github.com/stretchr/testify v1.5.1
-	github.com/urfave/cli v1.22.4 // indirect
+	github.com/stretchr/testify v1.11.1
 	github.com/urfave/cli/v2 v2.3.0
 	github.com/vishvananda/netlink v1.0.0
-	github.com/vishvananda/netns v0.0.0-20191106174202-0a2b9b5464df // indirect
 	github.com/xtaci/kcp-go/v5 v5.5.12
-	github.com/xtaci/kcptun v0.0.0-20200520151335-912a97993e20 // indirect
-	golang.org/x/crypto v0.0.0 // indirect
-	golang.org/x/net v0.0.0
-	golang.org/x/sys v0.0.0 // indirect
+	golang.org/x/net v0.43.0
 	golang.org/x/time v0.0.0
-	gopkg.in/asn1-ber.v1 v1.0.0-20181015200546-f715ec2f112d // indirect
 	gopkg.in/yaml.v2 v2.2.3
 )
 
-replace (
-	golang.org/x/crypto => github.com/golang/crypto v0.0.0-20200604202706-70a84ac30bf9
-	golang.org/x/net => github.com/golang/net v0.0.0-20190812203447-cdfb69ac37fc
-	golang.org/x/sys => github.com/golang/sys v0.0.0-20190209173611-3b5209105503
-	golang.org/x/time => github.com/golang/time v0.0.0-20210220033141-f8bda1e9f3ba
+require (
+	github.com/BurntSushi/toml v0.3.1 // indirect
+	github.com/Sirupsen/logrus v0.11.0 // indirect
+	github.com/akavel/rsrc v0.8.0 // indirect
+	github.com/coreos/go-iptables v0.4.2 // indirect
+	github.com/cpuguy83/go-md2man/v2 v2.0.0-20190314233015-f79a8a8ca69d // indirect
+	github.com/creack/pty v1.1.9 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/docker/libnetwork v0.5.6 // indirect
+	github.com/godbus/dbus v4.1.0+incompatible // indirect
+	github.com/godbus/dbus/v5 v5.0.3 // indirect
+	github.com/golang/snappy v0.0.1 // indirect
+	github.com/google/go-cmp v0.6.0 // indirect
+	github.com/google/gopacket v1.1.17 // indirect
+	github.com/jordanlewis/gcassert v0.0.0-20250430164644-389ef753e22e // indirect
+	github.com/klauspost/cpuid v1.2.3 // indirect
+	github.com/klauspost/reedsolomon v1.9.3 // indirect
+	github.com/kr/pretty v0.3.1 // indirect
+	github.com/kr/pty v1.1.1 // indirect
+	github.com/kr/text v0.2.0 // indirect
+	github.com/pkg/diff v0.0.0-20210226163009-20ebb0f2a09e // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/quic-go/qpack v0.6.0 // indirect
+	github.com/quic-go/quic-go v0.59.1 // indirect
+	github.com/riobard/go-bloom v0.0.0-20200614022211-cdc8013cb5b3 // indirect
+	github.com/rogpeppe/go-internal v1.10.0 // indirect
+	github.com/russross/blackfriday/v2 v2.0.1 // indirect
+	github.com/shurcooL/sanitized_anchor_name v1.0.0 // indirect
+	github.com/stretchr/objx v0.5.2 // indirect
+	github.com/templexxx/cpu v0.0.1 // indirect
+	github.com/templexxx/cpufeat v0.0.0-20180724012125-cef66df7f161 // indirect
+	github.com/templexxx/xor v0.0.0-20191217153810-f85b25db303b // indirect
+	github.com/templexxx/xorsimd v0.4.1 // indirect
+	github.com/tjfoc/gmsm v1.3.0 // indirect
+	github.com/urfave/cli v1.22.4 // indirect
+	github.com/vishvananda/netns v0.0.0-20191106174202-0a2b9b5464df // indirect
+	github.com/xtaci/kcp-go v5.4.20+incompatible // indirect
+	github.com/xtaci/kcptun v0.0.0-20200520151335-912a97993e20 // indirect
+	github.com/xtaci/lossyconn v0.0.0-20190602105132-8df528c0c9ae // indirect
+	github.com/xtaci/smux v1.5.14 // indirect
+	github.com/xtaci/tcpraw v1.2.25 // indirect
+	github.com/yuin/goldmark v1.4.13 // indirect
+	go.uber.org/mock v0.5.2 // indirect
+	golang.org/x/crypto v0.41.0 // indirect
+	golang.org/x/mod v0.27.0 // indirect
+	golang.org/x/sync v0.16.0 // indirect
+	golang.org/x/sys v0.35.0 // indirect
+	golang.org/x/telemetry v0.0.0-20250807160809-1a19826ec488 // indirect
+	golang.org/x/term v0.34.0 // indirect
+	golang.org/x/text v0.28.0 // indirect
+	golang.org/x/tools v0.36.0 // indirect
+	golang.org/x/xerrors v0.0.0-20190717185122-a985d3407aa7 // indirect
+	gopkg.in/asn1-ber.v1 v1.0.0-20181015200546-f715ec2f112d // indirect
+	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace golang.org/x/time => github.com/golang/time v0.0.0-20210220033141-f8bda1e9f3ba
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/danieldin95/openlan-go/src/libol"
+	"net"
+	"runtime"
+	"time"
+)
+
+// go run udpbatch.go -batch=false
+// go run udpbatch.go -batch=true
+func main() {
+	batch := flag.Bool("batch", true, "read by batch with pool")
+	seconds := flag.Int("seconds", 5, "duration to receive")
+	size := flag.Int("size", 1400, "size of datagram")
+	flag.Parse()
+
+	listener, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	_ = listener.SetReadBuffer(4 * 1024 * 1024)
+	sender, err := net.DialUDP("udp", nil, listener.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	go func() {
+		data := make([]byte, *size)
+		for {
+			if _, err := sender.Write(data); err != nil {
+				return
+			}
+		}
+	}()
+
+	// queue to a reader likes a session of XDP.
+	pool := libol.NewBufferPool(libol.MaxBuf)
+	queue := make(chan []byte, 1024)
+	go func() {
+		frame := make([]byte, libol.MaxBuf)
+		for data := range queue {
+			copy(frame, data)
+			if *batch {
+				pool.Put(data)
+			}
+		}
+	}()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	_ = listener.SetReadDeadline(start.Add(time.Duration(*seconds) * time.Second))
+	count, bytes := 0, 0
+	if *batch {
+		reader := libol.NewUdpReader(listener, pool, libol.UdpBatch)
+		for {
+			err := reader.Read(func(data []byte, addr *net.UDPAddr) {
+				count++
+				bytes += len(data)
+				queue <- data
+			})
+			if err != nil {
+				break
+			}
+		}
+	} else {
+		for {
+			data := make([]byte, libol.MaxBuf)
+			n, _, err := listener.ReadFromUDP(data)
+			if err != nil {
+				break
+			}
+			count++
+			bytes += n
+			queue <- data[:n]
+		}
+	}
+	elapsed := time.Since(start).Seconds()
+	runtime.ReadMemStats(&after)
+	fmt.Printf("batch: %t\n", *batch)
+	fmt.Printf("recv: %.0f pps, %.2f MiB/s\n", float64(count)/elapsed, float64(bytes)/elapsed/1024/1024)
+	fmt.Printf("gc: %d times, %.2f MiB allocated\n", after.NumGC-before.NumGC,
+		float64(after.TotalAlloc-before.TotalAlloc)/1024/1024)
+}
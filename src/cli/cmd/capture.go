@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/urfave/cli/v2"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+type Capture struct {
+	Cmd
+}
+
+func (u Capture) Url(prefix string, c *cli.Context) string {
+	query := url.Values{}
+	for _, name := range []string{"network", "point", "filter"} {
+		if value := c.String(name); value != "" {
+			query.Set(name, value)
+		}
+	}
+	for _, name := range []string{"count", "size", "timeout"} {
+		if value := c.Int(name); value > 0 {
+			query.Set(name, strconv.Itoa(value))
+		}
+	}
+	return prefix + "/api/capture?" + query.Encode()
+}
+
+// Start writes frames captured as pcapng to output file, or stdout if not
+// given, likes 'openlan capture --network x --filter "udp port 53" | wireshark -k -i -'.
+func (u Capture) Start(c *cli.Context) error {
+	clt := u.NewClient(c)
+	r, err := clt.NewRequest(u.Url(c.String("url"), c)).Do()
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return libol.NewErr("%s", r.Status)
+	}
+	var out io.Writer = os.Stdout
+	if name := c.String("output"); name != "" && name != "-" {
+		fp, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		defer fp.Close()
+		out = fp
+	}
+	_, err = io.Copy(out, r.Body)
+	return err
+}
+
+func (u Capture) Commands(app *cli.App) cli.Commands {
+	return append(app.Commands, &cli.Command{
+		Name:    "capture",
+		Aliases: []string{"cap"},
+		Usage:   "Capture frames of network or point as pcapng",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "network"},
+			&cli.StringFlag{Name: "point", Usage: "UUID or alias of point"},
+			&cli.StringFlag{Name: "filter", Usage: "Filter likes 'udp port 53' or 'host 1.1.1.1 and not arp'"},
+			&cli.IntFlag{Name: "count", Value: libol.CaptureCount, Usage: "Maximum frames"},
+			&cli.IntFlag{Name: "size", Usage: "Maximum bytes"},
+			&cli.IntFlag{Name: "timeout", Value: libol.CaptureTimeout, Usage: "Maximum seconds"},
+			&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "Output file, and - is stdout"},
+		},
+		Action: u.Start,
+	})
+}
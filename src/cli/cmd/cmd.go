@@ -5,12 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/urfave/cli/v2"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"text/template"
+	"time"
 )
 
 type Client struct {
@@ -37,7 +40,7 @@ func (cl Client) GetBody(url string) ([]byte, error) {
 		return nil, err
 	}
 	if r.StatusCode != http.StatusOK {
-		return nil, libol.NewErr(r.Status)
+		return nil, libol.NewErr("%s", r.Status)
 	}
 	defer r.Body.Close()
 	body, err := ioutil.ReadAll(r.Body)
@@ -55,7 +58,7 @@ func (cl Client) GetJSON(url string, v interface{}) error {
 		return err
 	}
 	if r.StatusCode != http.StatusOK {
-		return libol.NewErr(r.Status)
+		return libol.NewErr("%s", r.Status)
 	}
 	defer r.Body.Close()
 	body, err := ioutil.ReadAll(r.Body)
@@ -80,7 +83,7 @@ func (cl Client) SetJSON(client *libol.HttpClient, v interface{}) error {
 	if r, err := client.Do(); err != nil {
 		return err
 	} else if r.StatusCode != http.StatusOK {
-		return libol.NewErr(r.Status)
+		return libol.NewErr("%s", r.Status)
 	}
 	return nil
 }
@@ -91,6 +94,31 @@ func (cl Client) PostJSON(url string, v interface{}) error {
 	return cl.SetJSON(client, v)
 }
 
+// PostJSONOut posts v and decodes response into out.
+func (cl Client) PostJSONOut(url string, v, out interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	client := cl.NewRequest(url)
+	client.Method = "POST"
+	client.Payload = bytes.NewReader(data)
+	r, err := client.Do()
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return libol.NewErr("%s", r.Status)
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	cl.Log().Debug("Client.PostJSONOut %s", body)
+	return json.Unmarshal(body, out)
+}
+
 func (cl Client) PutJSON(url string, v interface{}) error {
 	client := cl.NewRequest(url)
 	client.Method = "PUT"
@@ -119,6 +147,25 @@ func (c Cmd) NewHttp(token string) Client {
 	return client
 }
 
+// NewCtrlHttp returns client to controller, and its token is user:password.
+func (c Cmd) NewCtrlHttp(token string) Client {
+	client := c.NewHttp(token)
+	if values := strings.SplitN(token, ":", 2); len(values) == 2 {
+		client.Auth.Username = values[0]
+		client.Auth.Password = values[1]
+	}
+	return client
+}
+
+// NewClient returns client by the token flag, which is of controller if
+// the switch is proxied by it.
+func (c Cmd) NewClient(x *cli.Context) Client {
+	if x.Bool("ctrl-proxy") {
+		return c.NewCtrlHttp(x.String("token"))
+	}
+	return c.NewHttp(x.String("token"))
+}
+
 func (c Cmd) Url(prefix, name string) string {
 	return ""
 }
@@ -164,6 +211,12 @@ func (c Cmd) OutTable(data interface{}, tmpl string) error {
 		"pt": func(value int64) string {
 			return libol.PrettyTime(value)
 		},
+		"ut": func(value int64) string {
+			if value == 0 {
+				return "-"
+			}
+			return time.Unix(value, 0).Format("2006-01-02")
+		},
 		"p2": func(space int, format, key1, key2 string) string {
 			value := fmt.Sprintf(format, key1, key2)
 			format = "%" + strconv.Itoa(space) + "s"
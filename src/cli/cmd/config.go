@@ -19,7 +19,7 @@ func (u Config) Url(prefix, name string) string {
 func (u Config) List(c *cli.Context) error {
 	url := u.Url(c.String("url"), "")
 	url += "?format=" + c.String("format")
-	clt := u.NewHttp(c.String("token"))
+	clt := u.NewClient(c)
 	if data, err := clt.GetBody(url); err == nil {
 		fmt.Println(string(data))
 		return nil
@@ -0,0 +1,311 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/urfave/cli/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type Endpoint struct {
+	Name  string `json:"name"`
+	Url   string `json:"url"`
+	Token string `json:"token"` // user:password for controller
+	Proxy bool   `json:"-"`     // switch is proxied by controller.
+}
+
+// Context holds multiple switches and controllers with credentials,
+// and it is loaded from ~/.openlan/context.json by default.
+type Context struct {
+	Current     string     `json:"current,omitempty"`
+	Controllers []Endpoint `json:"controllers,omitempty"`
+	Switches    []Endpoint `json:"switches,omitempty"`
+}
+
+func DefaultContextFile() string {
+	if file := os.Getenv("OL_CONTEXT"); file != "" {
+		return file
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".openlan", "context.json")
+	}
+	return ""
+}
+
+func LoadContext(file string) (*Context, error) {
+	ctx := &Context{}
+	if file == "" || libol.FileExist(file) != nil {
+		return ctx, nil
+	}
+	if err := libol.UnmarshalLoad(ctx, file); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+func (x *Context) Save(file string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	if err := libol.MarshalSave(x, file, true); err != nil {
+		return err
+	}
+	return os.Chmod(file, 0600)
+}
+
+func (x *Context) Switch(name string) (Endpoint, bool) {
+	for _, e := range x.Switches {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return Endpoint{}, false
+}
+
+func (x *Context) Controller(name string) (Endpoint, bool) {
+	for _, e := range x.Controllers {
+		if e.Name == name || name == "" {
+			return e, true
+		}
+	}
+	return Endpoint{}, false
+}
+
+func (x *Context) SetSwitch(e Endpoint) {
+	for i, o := range x.Switches {
+		if o.Name == e.Name {
+			x.Switches[i] = e
+			return
+		}
+	}
+	x.Switches = append(x.Switches, e)
+}
+
+func (x *Context) SetController(e Endpoint) {
+	for i, o := range x.Controllers {
+		if o.Name == e.Name {
+			x.Controllers[i] = e
+			return
+		}
+	}
+	x.Controllers = append(x.Controllers, e)
+}
+
+type ContextCmd struct {
+	Cmd
+}
+
+func (u ContextCmd) Tmpl() string {
+	return `# total {{ len . }}
+{{ps -2 ""}} {{ps -10 "type"}} {{ps -16 "name"}} {{ps -32 "url"}}
+{{- range . }}
+{{ps -2 .Current}} {{ps -10 .Type}} {{ps -16 .Name}} {{ps -32 .Url}}
+{{- end }}
+`
+}
+
+func (u ContextCmd) List(c *cli.Context) error {
+	ctx, err := LoadContext(c.String("context"))
+	if err != nil {
+		return err
+	}
+	type item struct {
+		Current string `json:"current"`
+		Type    string `json:"type"`
+		Name    string `json:"name"`
+		Url     string `json:"url"`
+	}
+	items := make([]item, 0, 32)
+	for _, e := range ctx.Controllers {
+		items = append(items, item{Type: "controller", Name: e.Name, Url: e.Url})
+	}
+	for _, e := range ctx.Switches {
+		it := item{Type: "switch", Name: e.Name, Url: e.Url}
+		if e.Name == ctx.Current {
+			it.Current = "*"
+		}
+		items = append(items, it)
+	}
+	return u.Out(items, c.String("format"), u.Tmpl())
+}
+
+func (u ContextCmd) Set(c *cli.Context) error {
+	file := c.String("context")
+	ctx, err := LoadContext(file)
+	if err != nil {
+		return err
+	}
+	e := Endpoint{
+		Name:  c.String("name"),
+		Url:   c.String("url"),
+		Token: c.String("token"),
+	}
+	if e.Name == "" || e.Url == "" {
+		return libol.NewErr("name or url is empty")
+	}
+	if c.String("type") == "controller" {
+		ctx.SetController(e)
+	} else {
+		ctx.SetSwitch(e)
+	}
+	return ctx.Save(file)
+}
+
+func (u ContextCmd) Use(c *cli.Context) error {
+	file := c.String("context")
+	ctx, err := LoadContext(file)
+	if err != nil {
+		return err
+	}
+	name := c.String("name")
+	if _, ok := ctx.Switch(name); !ok {
+		return libol.NewErr("switch %s notFound", name)
+	}
+	ctx.Current = name
+	return ctx.Save(file)
+}
+
+func (u ContextCmd) Commands(app *cli.App) cli.Commands {
+	return append(app.Commands, &cli.Command{
+		Name:    "context",
+		Aliases: []string{"ctx"},
+		Usage:   "Switch and controller endpoints",
+		Subcommands: []*cli.Command{
+			{
+				Name:    "list",
+				Usage:   "Display all endpoints",
+				Aliases: []string{"ls"},
+				Action:  u.List,
+			},
+			{
+				Name:  "set",
+				Usage: "Add or update an endpoint",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "type", Value: "switch"},
+					&cli.StringFlag{Name: "name"},
+					&cli.StringFlag{Name: "url"},
+					&cli.StringFlag{Name: "token"},
+				},
+				Action: u.Set,
+			},
+			{
+				Name:  "use",
+				Usage: "Set the current switch",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "name"},
+				},
+				Action: u.Use,
+			},
+		},
+	})
+}
+
+// Targets resolves switches that a command is applied to. The switch is
+// found in context firstly, otherwise by the proxy of controller.
+func Targets(c *cli.Context) ([]Endpoint, error) {
+	ctx, err := LoadContext(c.String("context"))
+	if err != nil {
+		return nil, err
+	}
+	names := c.String("switch")
+	if names == "" {
+		e := Endpoint{Url: c.String("url"), Token: c.String("token")}
+		if os.Getenv("OL_URL") == "" && !c.IsSet("url") && ctx.Current != "" {
+			if cur, ok := ctx.Switch(ctx.Current); ok {
+				e = cur
+			}
+		}
+		return []Endpoint{e}, nil
+	}
+	ctrl := Endpoint{Url: c.String("ctrl"), Token: c.String("ctrl-token")}
+	if ctrl.Url == "" {
+		ctrl, _ = ctx.Controller("")
+	}
+	var values []string
+	if names == "all" {
+		for _, e := range ctx.Switches {
+			values = append(values, e.Name)
+		}
+		if ctrl.Url != "" {
+			items, err := Switch{}.ListFrom(ctrl)
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range items {
+				if _, ok := ctx.Switch(s.Alias); !ok {
+					values = append(values, s.Alias)
+				}
+			}
+		}
+	} else {
+		values = strings.Split(names, ",")
+	}
+	targets := make([]Endpoint, 0, len(values))
+	for _, name := range values {
+		name = strings.TrimSpace(name)
+		if e, ok := ctx.Switch(name); ok {
+			targets = append(targets, e)
+		} else if ctrl.Url != "" {
+			targets = append(targets, Endpoint{
+				Name:  name,
+				Url:   ctrl.Url + "/api/switch/" + name + "/proxy",
+				Token: ctrl.Token,
+				Proxy: true,
+			})
+		} else {
+			return nil, libol.NewErr("switch %s notFound", name)
+		}
+	}
+	return targets, nil
+}
+
+// SetFlag sets value of the flag on the context which defines it.
+func SetFlag(c *cli.Context, name, value string) {
+	for _, ctx := range c.Lineage() {
+		if err := ctx.Set(name, value); err == nil {
+			return
+		}
+	}
+}
+
+// FanOut applies an action on all targets by resetting url and token.
+func FanOut(action cli.ActionFunc) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		targets, err := Targets(c)
+		if err != nil {
+			return err
+		}
+		var errs []string
+		for _, e := range targets {
+			SetFlag(c, "url", e.Url)
+			SetFlag(c, "token", e.Token)
+			SetFlag(c, "ctrl-proxy", strconv.FormatBool(e.Proxy))
+			if len(targets) > 1 || c.IsSet("switch") {
+				fmt.Printf("# switch %s\n", e.Name)
+			}
+			if err := action(c); err != nil {
+				if len(targets) == 1 {
+					return err
+				}
+				errs = append(errs, e.Name+": "+err.Error())
+			}
+		}
+		if len(errs) > 0 {
+			return libol.NewErr("%s", strings.Join(errs, "; "))
+		}
+		return nil
+	}
+}
+
+// FanOutAll wraps actions of commands and their subcommands.
+func FanOutAll(commands cli.Commands) {
+	for _, cmd := range commands {
+		if cmd.Action != nil {
+			cmd.Action = FanOut(cmd.Action)
+		}
+		FanOutAll(cmd.Subcommands)
+	}
+}
@@ -28,7 +28,7 @@ func (u Device) Tmpl() string {
 
 func (u Device) List(c *cli.Context) error {
 	url := u.Url(c.String("url"), "")
-	clt := u.NewHttp(c.String("token"))
+	clt := u.NewClient(c)
 	var items []schema.Device
 	if err := clt.GetJSON(url, &items); err != nil {
 		return err
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/danieldin95/openlan-go/src/schema"
+	"github.com/urfave/cli/v2"
+	"strings"
+)
+
+type LDAP struct {
+	Cmd
+}
+
+func (u LDAP) Url(prefix, name string) string {
+	if name == "" {
+		return prefix + "/api/ldap"
+	}
+	return prefix + "/api/ldap/" + name
+}
+
+func (u LDAP) Tmpl() string {
+	return `# total {{ len . }}
+{{ps -24 "server"}} {{ps -6 "conns"}} {{ps -6 "idle"}} {{ps -6 "groups"}}
+{{- range . }}
+{{ps -24 .Server}} {{pi -6 .Conns}} {{pi -6 .Idle}} {{pi -6 .Groups}}
+{{- end }}
+`
+}
+
+func (u LDAP) TestTmpl() string {
+	return `{{- range . }}
+{{ps -8 "name"}} {{.Name}}
+{{ps -8 "dn"}} {{.DN}}
+{{ps -8 "groups"}} {{range .Groups}}{{.}}; {{end}}
+{{ps -8 "role"}} {{.Role}}
+{{ps -8 "allowed"}} {{.Allowed}}
+{{- if .Error }}
+{{ps -8 "error"}} {{.Error}}
+{{- end }}
+{{- end }}
+`
+}
+
+func (u LDAP) List(c *cli.Context) error {
+	url := u.Url(c.String("url"), "")
+	clt := u.NewClient(c)
+	items := []schema.LDAP{{}}
+	if err := clt.GetJSON(url, &items[0]); err != nil {
+		return err
+	}
+	return u.Out(items, c.String("format"), u.Tmpl())
+}
+
+func (u LDAP) Test(c *cli.Context) error {
+	name := c.String("name")
+	if !strings.Contains(name, "@") {
+		return libol.NewErr("name not contains network")
+	}
+	pass, err := ReadPassword("Password")
+	if err != nil {
+		return err
+	}
+	values := strings.SplitN(name, "@", 2)
+	user := &schema.User{
+		Name:     values[0],
+		Network:  values[1],
+		Password: pass,
+	}
+	url := u.Url(c.String("url"), "test")
+	clt := u.NewClient(c)
+	items := []schema.LDAPTest{{}}
+	if err := clt.PostJSONOut(url, user, &items[0]); err != nil {
+		return err
+	}
+	return u.Out(items, c.String("format"), u.TestTmpl())
+}
+
+func (u LDAP) Commands(app *cli.App) cli.Commands {
+	return append(app.Commands, &cli.Command{
+		Name:  "ldap",
+		Usage: "LDAP authentication",
+		Subcommands: []*cli.Command{
+			{
+				Name:    "list",
+				Usage:   "Display connections to server",
+				Aliases: []string{"ls"},
+				Action:  u.List,
+			},
+			{
+				Name:  "test",
+				Usage: "Test login and groups of an user",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "name", Usage: "user likes name@network"},
+				},
+				Action: u.Test,
+			},
+		},
+	})
+}
@@ -28,7 +28,7 @@ func (u Lease) Tmpl() string {
 
 func (u Lease) List(c *cli.Context) error {
 	url := u.Url(c.String("url"), "")
-	clt := u.NewHttp(c.String("token"))
+	clt := u.NewClient(c)
 	var items []schema.Lease
 	if err := clt.GetJSON(url, &items); err != nil {
 		return err
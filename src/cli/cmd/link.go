@@ -28,7 +28,7 @@ func (u Link) Tmpl() string {
 
 func (u Link) List(c *cli.Context) error {
 	url := u.Url(c.String("url"), "")
-	clt := u.NewHttp(c.String("token"))
+	clt := u.NewClient(c)
 	var items []schema.Link
 	if err := clt.GetJSON(url, &items); err != nil {
 		return err
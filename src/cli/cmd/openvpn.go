@@ -28,7 +28,7 @@ func (u VPNClient) Tmpl() string {
 
 func (u VPNClient) List(c *cli.Context) error {
 	url := u.Url(c.String("url"), c.String("network"))
-	clt := u.NewHttp(c.String("token"))
+	clt := u.NewClient(c)
 	var items []schema.VPNClient
 	if err := clt.GetJSON(url, &items); err != nil {
 		return err
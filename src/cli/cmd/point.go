@@ -28,7 +28,7 @@ func (u Point) Tmpl() string {
 
 func (u Point) List(c *cli.Context) error {
 	url := u.Url(c.String("url"), "")
-	clt := u.NewHttp(c.String("token"))
+	clt := u.NewClient(c)
 	var items []schema.Point
 	if err := clt.GetJSON(url, &items); err != nil {
 		return err
@@ -23,7 +23,7 @@ func (u PProf) Add(c *cli.Context) error {
 		return libol.NewErr("listen value is empty")
 	}
 	url := u.Url(c.String("url"), "")
-	clt := u.NewHttp(c.String("token"))
+	clt := u.NewClient(c)
 	if err := clt.PostJSON(url, pp); err != nil {
 		return err
 	}
@@ -32,7 +32,7 @@ func (u PProf) Add(c *cli.Context) error {
 
 func (u PProf) Del(c *cli.Context) error {
 	url := u.Url(c.String("url"), "")
-	clt := u.NewHttp(c.String("token"))
+	clt := u.NewClient(c)
 	if err := clt.DeleteJSON(url, nil); err != nil {
 		return err
 	}
@@ -41,7 +41,7 @@ func (u PProf) Del(c *cli.Context) error {
 
 func (u PProf) List(c *cli.Context) error {
 	url := u.Url(c.String("url"), "")
-	clt := u.NewHttp(c.String("token"))
+	clt := u.NewClient(c)
 	var pp schema.PProf
 	if err := clt.GetJSON(url, &pp); err != nil {
 		return err
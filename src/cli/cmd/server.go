@@ -16,7 +16,7 @@ func (u Server) Url(prefix, name string) string {
 func (u Server) List(c *cli.Context) error {
 	url := u.Url(c.String("url"), "")
 	url += "?format=" + c.String("format")
-	clt := u.NewHttp(c.String("token"))
+	clt := u.NewClient(c)
 	if data, err := clt.GetBody(url); err == nil {
 		fmt.Println(string(data))
 		return nil
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/danieldin95/openlan-go/src/schema"
+	"github.com/urfave/cli/v2"
+)
+
+type Switch struct {
+	Cmd
+}
+
+func (u Switch) Url(prefix, name string) string {
+	return prefix + "/api/switch"
+}
+
+func (u Switch) Tmpl() string {
+	return `# total {{ len . }}
+{{ps -16 "alias"}} {{ps -16 "uuid"}} {{ps -24 "address"}} {{ps -8 "uptime"}}
+{{- range . }}
+{{ps -16 .Alias}} {{ps -16 .UUID}} {{ps -24 .Address}} {{pt .Uptime | ps -8}}
+{{- end }}
+`
+}
+
+func (u Switch) ListFrom(ctrl Endpoint) ([]schema.Switch, error) {
+	var items []schema.Switch
+	clt := u.NewCtrlHttp(ctrl.Token)
+	if err := clt.GetJSON(u.Url(ctrl.Url, ""), &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (u Switch) List(c *cli.Context) error {
+	ctrl := Endpoint{Url: c.String("ctrl"), Token: c.String("ctrl-token")}
+	if ctrl.Url == "" {
+		ctx, err := LoadContext(c.String("context"))
+		if err != nil {
+			return err
+		}
+		ctrl, _ = ctx.Controller("")
+	}
+	if ctrl.Url == "" {
+		return libol.NewErr("controller is not specified")
+	}
+	items, err := u.ListFrom(ctrl)
+	if err != nil {
+		return err
+	}
+	return u.Out(items, c.String("format"), u.Tmpl())
+}
+
+func (u Switch) Commands(app *cli.App) cli.Commands {
+	return append(app.Commands, &cli.Command{
+		Name:    "switch",
+		Aliases: []string{"sw"},
+		Usage:   "Switches on controller",
+		Subcommands: []*cli.Command{
+			{
+				Name:    "list",
+				Usage:   "Display all switches",
+				Aliases: []string{"ls"},
+				Action:  u.List,
+			},
+		},
+	})
+}
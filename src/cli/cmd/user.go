@@ -1,12 +1,16 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"github.com/chzyer/readline"
 	"github.com/danieldin95/openlan-go/src/libol"
 	"github.com/danieldin95/openlan-go/src/schema"
 	"github.com/urfave/cli/v2"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type User struct {
@@ -21,6 +25,59 @@ func (u User) Url(prefix, name string) string {
 	}
 }
 
+// ReadPassword prompts password without echo on terminal, otherwise
+// reads a line from stdin.
+func ReadPassword(prompt string) (string, error) {
+	if !readline.DefaultIsTerminal() {
+		reader := bufio.NewReader(os.Stdin)
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	rl, err := readline.New("")
+	if err != nil {
+		return "", err
+	}
+	defer rl.Close()
+	first, err := rl.ReadPassword(prompt + ": ")
+	if err != nil {
+		return "", err
+	}
+	again, err := rl.ReadPassword("Retype " + strings.ToLower(prompt) + ": ")
+	if err != nil {
+		return "", err
+	}
+	if string(first) != string(again) {
+		return "", libol.NewErr("passwords do not match")
+	}
+	return string(first), nil
+}
+
+// Password reads or generates password before fan-out, so it's the
+// same on all switches.
+func (u User) Password(c *cli.Context) error {
+	if c.String("name") == "" {
+		return libol.NewErr("name is empty")
+	}
+	var password string
+	if c.Bool("generate") {
+		password = libol.GenRandom(24)
+		fmt.Printf("password: %s\n", password)
+	} else {
+		pass, err := ReadPassword("Password")
+		if err != nil {
+			return err
+		}
+		password = pass
+	}
+	if password == "" {
+		return libol.NewErr("password is empty")
+	}
+	return c.Set("password", password)
+}
+
 func (u User) Add(c *cli.Context) error {
 	username := c.String("name")
 	user := &schema.User{
@@ -41,7 +98,7 @@ func (u User) Add(c *cli.Context) error {
 	user.Name = values[0]
 	user.Network = values[1]
 	url := u.Url(c.String("url"), user.Name)
-	clt := u.NewHttp(c.String("token"))
+	clt := u.NewClient(c)
 	if err := clt.PostJSON(url, user); err != nil {
 		return err
 	}
@@ -51,25 +108,152 @@ func (u User) Add(c *cli.Context) error {
 func (u User) Remove(c *cli.Context) error {
 	username := c.String("name")
 	url := u.Url(c.String("url"), username)
-	clt := u.NewHttp(c.String("token"))
+	clt := u.NewClient(c)
 	if err := clt.DeleteJSON(url, nil); err != nil {
 		return err
 	}
 	return nil
 }
 
+// ParseDate parses unix time from date likes 2006-01-02 or RFC3339,
+// and zero or empty is none.
+func ParseDate(value string) (int64, error) {
+	if value == "" || value == "0" {
+		return 0, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t.Unix(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
+// ParseSize parses bytes from size likes 1024, 512K, 100M or 10G.
+func ParseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	unit := int64(1)
+	if strings.HasSuffix(value, "B") {
+		value = value[:len(value)-1]
+	}
+	if n := len(value); n > 0 {
+		switch value[n-1] {
+		case 'K':
+			unit = 1 << 10
+		case 'M':
+			unit = 1 << 20
+		case 'G':
+			unit = 1 << 30
+		case 'T':
+			unit = 1 << 40
+		}
+		if unit > 1 {
+			value = value[:n-1]
+		}
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return size * unit, nil
+}
+
+// put updates attributes given of an user, and the others are kept.
+func (u User) put(c *cli.Context, attrs map[string]interface{}) error {
+	username := c.String("name")
+	if username == "" {
+		return libol.NewErr("name is empty")
+	}
+	url := u.Url(c.String("url"), username)
+	client := u.NewClient(c)
+	return client.PutJSON(url, attrs)
+}
+
+func (u User) Set(c *cli.Context) error {
+	attrs := make(map[string]interface{}, 8)
+	if c.IsSet("not-before") {
+		value, err := ParseDate(c.String("not-before"))
+		if err != nil {
+			return err
+		}
+		attrs["notBefore"] = value
+	}
+	if c.IsSet("not-after") {
+		value, err := ParseDate(c.String("not-after"))
+		if err != nil {
+			return err
+		}
+		attrs["notAfter"] = value
+	}
+	if c.IsSet("max-session") {
+		attrs["maxSession"] = c.Int("max-session")
+	}
+	if c.IsSet("quota") {
+		value, err := ParseSize(c.String("quota"))
+		if err != nil {
+			return err
+		}
+		attrs["quota"] = value
+	}
+	if c.Bool("reset") {
+		attrs["used"] = 0
+	}
+	if c.IsSet("vlan") {
+		attrs["vlan"] = c.String("vlan")
+	}
+	if c.IsSet("promisc") {
+		attrs["promisc"] = c.Bool("promisc")
+	}
+	if c.IsSet("security") {
+		attrs["security"] = c.String("security")
+	}
+	return u.put(c, attrs)
+}
+
+func (u User) Enable(c *cli.Context) error {
+	return u.put(c, map[string]interface{}{"disabled": false})
+}
+
+func (u User) Disable(c *cli.Context) error {
+	return u.put(c, map[string]interface{}{"disabled": true})
+}
+
+func (u User) EnableOtp(c *cli.Context) error {
+	url := u.Url(c.String("url"), c.String("name")) + "/otp"
+	clt := u.NewClient(c)
+	otp := &schema.UserOtp{}
+	if err := clt.PostJSONOut(url, nil, otp); err != nil {
+		return err
+	}
+	return u.Out(otp, c.String("format"), `secret: {{ .Secret }}
+uri: {{ .Uri }}
+recovery:
+{{- range .Recovery }}
+  {{ . }}
+{{- end }}
+`)
+}
+
+func (u User) DisableOtp(c *cli.Context) error {
+	url := u.Url(c.String("url"), c.String("name")) + "/otp"
+	clt := u.NewClient(c)
+	return clt.DeleteJSON(url, nil)
+}
+
 func (u User) Tmpl() string {
 	return `# total {{ len . }}
-{{ps -24 "username"}} {{ps -24 "password"}} {{ps -6 "role"}}
+{{ps -24 "username"}} {{ps -6 "role"}} {{ps -8 "disabled"}} {{ps -10 "expire"}} {{ps -10 "not after"}} {{ps -8 "sessions"}} {{ps -12 "used"}} {{ps -12 "quota"}} {{ps -4 "otp"}}
 {{- range . }}
-{{p2 -24 "%s@%s" .Name .Network}} {{ps -24 .Password}} {{ps -6 .Role}}
+{{p2 -24 "%s@%s" .Name .Network}} {{ps -6 .Role}} {{printf "%v" .Disabled | ps -8}} {{ut .PassExpire | ps -10}} {{ut .NotAfter | ps -10}} {{pi -8 .MaxSession}} {{pi -12 .Used}} {{pi -12 .Quota}} {{printf "%v" .Otp | ps -4}}
 {{- end }}
 `
 }
 
 func (u User) List(c *cli.Context) error {
 	url := u.Url(c.String("url"), "")
-	clt := u.NewHttp(c.String("token"))
+	clt := u.NewClient(c)
 	var items []schema.User
 	if err := clt.GetJSON(url, &items); err != nil {
 		return err
@@ -80,7 +264,7 @@ func (u User) List(c *cli.Context) error {
 func (u User) Get(c *cli.Context) error {
 	username := c.String("name")
 	url := u.Url(c.String("url"), username)
-	client := u.NewHttp(c.String("token"))
+	client := u.NewClient(c)
 	items := []schema.User{{}}
 	if err := client.GetJSON(url, &items[0]); err != nil {
 		return err
@@ -113,11 +297,12 @@ func (u User) Check(c *cli.Context) error {
 	}
 	url := u.Url(c.String("url"), fullName)
 	url += "/check"
-	client := u.NewHttp(c.String("token"))
+	client := u.NewClient(c)
 	user := &schema.User{
 		Name:     fullName,
 		Password: passFromE,
 		Alias:    alias,
+		Code:     c.String("code"),
 	}
 	if err := client.PostJSON(url, user); err != nil {
 		return err
@@ -138,11 +323,45 @@ func (u User) Commands(app *cli.App) cli.Commands {
 				Usage: "Add a new user",
 				Flags: []cli.Flag{
 					&cli.StringFlag{Name: "name"},
-					&cli.StringFlag{Name: "password", Value: libol.GenRandom(24)},
+					&cli.BoolFlag{Name: "generate", Usage: "Generate a random password"},
 					&cli.StringFlag{Name: "role", Value: "guest"},
+					&cli.StringFlag{Name: "password", Hidden: true},
 				},
+				Before: u.Password,
 				Action: u.Add,
 			},
+			{
+				Name:  "set",
+				Usage: "Set validity, sessions, quota and vlan of an user",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "name"},
+					&cli.StringFlag{Name: "not-before", Usage: "Valid from date, and 0 is none"},
+					&cli.StringFlag{Name: "not-after", Usage: "Valid until date, and 0 is none"},
+					&cli.IntFlag{Name: "max-session", Usage: "Maximum concurrent sessions, and 0 is unlimited"},
+					&cli.StringFlag{Name: "quota", Usage: "Transfer quota likes 10G, and 0 is unlimited"},
+					&cli.BoolFlag{Name: "reset", Usage: "Reset used bytes"},
+					&cli.StringFlag{Name: "vlan", Usage: "Vlan of port likes access/10 or trunk/1/10,20"},
+					&cli.BoolFlag{Name: "promisc", Usage: "Reach all points of isolated network"},
+					&cli.StringFlag{Name: "security", Usage: "Port security likes max/drop|alert|disconnect[/sticky[/macs]]"},
+				},
+				Action: u.Set,
+			},
+			{
+				Name:  "enable",
+				Usage: "Enable an user",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "name"},
+				},
+				Action: u.Enable,
+			},
+			{
+				Name:  "disable",
+				Usage: "Disable an user",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "name"},
+				},
+				Action: u.Disable,
+			},
 			{
 				Name:    "remove",
 				Usage:   "Remove an existing user",
@@ -176,10 +395,33 @@ func (u User) Commands(app *cli.App) cli.Commands {
 				Flags: []cli.Flag{
 					&cli.StringFlag{Name: "name"},
 					&cli.StringFlag{Name: "password"},
+					&cli.StringFlag{Name: "code", Usage: "One time password if two-factor enabled"},
 					&cli.StringFlag{Name: "network"},
 				},
 				Action: u.Check,
 			},
+			{
+				Name:  "otp",
+				Usage: "Two-factor authentication by TOTP",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "enable",
+						Usage: "Enable and output provisioning uri and recovery codes",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "name"},
+						},
+						Action: u.EnableOtp,
+					},
+					{
+						Name:  "disable",
+						Usage: "Disable two-factor authentication",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "name"},
+						},
+						Action: u.DisableOtp,
+					},
+				},
+			},
 		},
 	})
 }
@@ -15,8 +15,10 @@ const (
 )
 
 type App struct {
-	Token string
-	Url   string
+	Token   string
+	Url     string
+	Ctrl    string
+	Context string
 }
 
 func (a App) Flags() []cli.Flag {
@@ -33,6 +35,30 @@ func (a App) Flags() []cli.Flag {
 			Usage:   "server url",
 			Value:   a.Url,
 		},
+		&cli.StringFlag{
+			Name:  "ctrl",
+			Usage: "controller url",
+			Value: a.Ctrl,
+		},
+		&cli.StringFlag{
+			Name:  "ctrl-token",
+			Usage: "controller token as user:password",
+			Value: os.Getenv("OL_CTRL_TOKEN"),
+		},
+		&cli.BoolFlag{
+			Name:   "ctrl-proxy",
+			Hidden: true,
+		},
+		&cli.StringFlag{
+			Name:    "switch",
+			Aliases: []string{"s"},
+			Usage:   "switch names separated by comma, or all",
+		},
+		&cli.StringFlag{
+			Name:  "context",
+			Usage: "context file of endpoints",
+			Value: a.Context,
+		},
 		&cli.StringFlag{
 			Name:    "format",
 			Aliases: []string{"f"},
@@ -83,8 +109,14 @@ func main() {
 	}
 	token := os.Getenv("OL_TOKEN")
 
-	app := App{Url: url, Token: token}.New()
+	app := App{
+		Url:     url,
+		Token:   token,
+		Ctrl:    os.Getenv("OL_CTRL"),
+		Context: cmd.DefaultContextFile(),
+	}.New()
 	app.Commands = cmd.User{}.Commands(app)
+	app.Commands = cmd.LDAP{}.Commands(app)
 	app.Commands = cmd.ACL{}.Commands(app)
 	app.Commands = cmd.Device{}.Commands(app)
 	app.Commands = cmd.Lease{}.Commands(app)
@@ -95,6 +127,11 @@ func main() {
 	app.Commands = cmd.Server{}.Commands(app)
 	app.Commands = cmd.Network{}.Commands(app)
 	app.Commands = cmd.PProf{}.Commands(app)
+	// apply to switches in context or by controller.
+	cmd.FanOutAll(app.Commands)
+	app.Commands = cmd.Capture{}.Commands(app)
+	app.Commands = cmd.Switch{}.Commands(app)
+	app.Commands = cmd.ContextCmd{}.Commands(app)
 
 	err := app.Run(os.Args)
 	if err != nil {
@@ -0,0 +1,29 @@
+package config
+
+type Cluster struct {
+	Network  string `json:"network"`
+	Mode     string `json:"mode"` // active-standby or active-active
+	Peer     string `json:"peer"` // https url of peer switch
+	Token    string `json:"token"`
+	CaFile   string `json:"caFile,omitempty"` // ca to verify peer, or system's
+	Priority int    `json:"priority"`
+	Interval int    `json:"interval"` // in seconds
+	Timeout  int    `json:"timeout"`
+	Address  string `json:"address,omitempty"` // virtual ip for active-standby
+	Device   string `json:"device,omitempty"`  // device of virtual ip
+}
+
+func (c *Cluster) Correct() {
+	if c.Mode == "" {
+		c.Mode = "active-standby"
+	}
+	if c.Priority == 0 {
+		c.Priority = 100
+	}
+	if c.Interval == 0 {
+		c.Interval = 5
+	}
+	if c.Timeout == 0 {
+		c.Timeout = c.Interval * 3
+	}
+}
@@ -23,6 +23,8 @@ type Queue struct {
 	TapRd  int `json:"trd"` // per frames
 	VirSnd int `json:"vsd"`
 	VirWrt int `json:"vwr"`
+	TapQus int `json:"tqs"` // queues of kernel tap
+	Worker int `json:"wks"` // goroutines to process frames read from tap by flow
 }
 
 var (
@@ -32,6 +34,8 @@ var (
 	QdTrd = 2
 	QdVsd = 1024 * 8
 	QdVWr = 1024 * 4
+	QdTqs = 1
+	QdWks = 1
 )
 
 func (q *Queue) Default() {
@@ -53,6 +57,12 @@ func (q *Queue) Default() {
 	if q.VirWrt == 0 {
 		q.VirWrt = QdVWr
 	}
+	if q.TapQus == 0 {
+		q.TapQus = QdTqs
+	}
+	if q.Worker == 0 {
+		q.Worker = QdWks
+	}
 	libol.Debug("Queue.Default %v", q)
 }
 
@@ -73,6 +83,33 @@ type Http struct {
 	Public string `json:"public,omitempty"`
 }
 
+// Health is thresholds to mark a link degraded.
+type Health struct {
+	Rtt    int `json:"rtt"`    // ms
+	Jitter int `json:"jitter"` // ms
+	Loss   int `json:"loss"`   // percent
+}
+
+func (h *Health) Correct() {
+	if h.Rtt == 0 {
+		h.Rtt = 300
+	}
+	if h.Jitter == 0 {
+		h.Jitter = 50
+	}
+	if h.Loss == 0 {
+		h.Loss = 5
+	}
+}
+
+func (h *Health) Threshold() *libol.HealthThreshold {
+	return &libol.HealthThreshold{
+		Rtt:    int64(h.Rtt),
+		Jitter: int64(h.Jitter),
+		Loss:   h.Loss,
+	}
+}
+
 type Crypt struct {
 	Algo   string `json:"algo,omitempty"`
 	Secret string `json:"secret,omitempty"`
@@ -153,6 +190,14 @@ type Bridge struct {
 	Provider string `json:"provider"`
 	Stp      string `json:"stp"`
 	Delay    int    `json:"delay"`
+	TcpMss   int    `json:"tcpMss,omitempty"`   // maximum mss of tcp syn to and from points.
+	ClampMss bool   `json:"clampMss,omitempty"` // clamp mss by mtu probed to point.
+	Vlan     *Vlan  `json:"vlan,omitempty"`     // default of points accessed.
+	Storm    int    `json:"storm,omitempty"`    // broadcast and multicast frames per second of a port.
+	Snooping bool   `json:"snooping,omitempty"`
+	ArpProxy bool   `json:"arpProxy,omitempty"` // answered by neighbors of switch.
+	Ovsdb    string `json:"ovsdb,omitempty"`    // likes unix:/var/run/openvswitch/db.sock
+	OvsCtl   string `json:"ovsctl,omitempty"`   // unixctl of ovs-vswitchd.
 }
 
 func (br *Bridge) Correct() {
@@ -169,7 +214,28 @@ func (br *Bridge) Correct() {
 		br.Delay = 2
 	}
 	if br.Stp == "" {
-		br.Stp = "on"
+		br.Stp = "off"
+	}
+	if br.Vlan != nil {
+		br.Vlan.Correct()
+	}
+}
+
+// Vlan assigns a port of bridge to access with pvid, or trunk carries
+// allowed vlans.
+type Vlan struct {
+	Mode    string `json:"mode,omitempty"` // access or trunk.
+	Pvid    int    `json:"pvid,omitempty"`
+	Allowed []int  `json:"allowed,omitempty"`
+}
+
+func (v *Vlan) Correct() {
+	if v.Mode == "" {
+		if len(v.Allowed) > 0 {
+			v.Mode = "trunk"
+		} else {
+			v.Mode = "access"
+		}
 	}
 }
 
@@ -269,3 +335,32 @@ func GetBlock(cfg *Crypt) kcp.BlockCrypt {
 	}
 	return block
 }
+
+// FlowExport exports flows inspected online to collectors.
+type FlowExport struct {
+	Protocol   string   `json:"protocol,omitempty"` // ipfix or netflow9.
+	Collectors []string `json:"collectors"`         // host:port
+	Active     int      `json:"active,omitempty"`   // seconds to export a long flow.
+	Inactive   int      `json:"inactive,omitempty"` // seconds to expire an idle flow.
+	Domain     uint32   `json:"domain,omitempty"`   // observation domain or source id.
+	Enterprise uint32   `json:"enterprise,omitempty"`
+}
+
+func (f *FlowExport) Correct() {
+	if f.Protocol == "" {
+		f.Protocol = libol.FlowIpfix
+	}
+	port := 4739
+	if f.Protocol == libol.FlowNetflow9 {
+		port = 2055
+	}
+	for i := range f.Collectors {
+		CorrectAddr(&f.Collectors[i], port)
+	}
+	if f.Active == 0 {
+		f.Active = 60
+	}
+	if f.Inactive == 0 {
+		f.Inactive = 15
+	}
+}
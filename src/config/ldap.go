@@ -1,11 +1,29 @@
 package config
 
+type LDAPGroup struct {
+	Name     string   `json:"name"`     // DN or common name, and * is any user.
+	Networks []string `json:"networks"` // * is any network.
+	Role     string   `json:"role"`     // admin or guest.
+}
+
 type LDAP struct {
-	Server    string `json:"server"`
-	BindDN    string `json:"bindDN"`
-	Password  string `json:"password"`
-	BaseDN    string `json:"baseDN"`
-	Attribute string `json:"attribute"`
-	Filter    string `json:"filter"`
-	EnableTls bool   `json:"enableTLS"`
+	Server      string      `json:"server"` // host:port, or ldaps://host:port.
+	BindDN      string      `json:"bindDN"`
+	Password    string      `json:"password"`
+	BaseDN      string      `json:"baseDN"`
+	Attribute   string      `json:"attribute"`
+	Filter      string      `json:"filter"`
+	EnableTls   bool        `json:"enableTLS"`
+	CaFile      string      `json:"caFile,omitempty"`
+	Insecure    bool        `json:"insecure,omitempty"`
+	PoolSize    int         `json:"poolSize,omitempty"`
+	GroupBase   string      `json:"groupBase,omitempty"`
+	GroupFilter string      `json:"groupFilter,omitempty"`
+	Groups      []LDAPGroup `json:"groups,omitempty"`
+}
+
+type PassPolicy struct {
+	MinLength int `json:"minLength"`
+	Classes   int `json:"classes"` // kinds of lower, upper, digit and symbol.
+	MaxAge    int `json:"maxAge"`  // days.
 }
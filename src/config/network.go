@@ -16,6 +16,8 @@ type Network struct {
 	Acl       string        `json:"acl,omitempty"`
 	Interface interface{}   `json:"interface,omitempty"`
 	Crypt     *Crypt        `json:"crypt,omitempty"`
+	Cluster   *Cluster      `json:"cluster,omitempty"`
+	Isolation bool          `json:"isolation,omitempty"` // points talk to bridge and links only.
 }
 
 func (n *Network) Correct() {
@@ -58,5 +60,9 @@ func (n *Network) Correct() {
 			obj := DefaultOpenVPN()
 			n.OpenVPN.Correct(obj)
 		}
+		if n.Cluster != nil {
+			n.Cluster.Network = n.Name
+			n.Cluster.Correct()
+		}
 	}
 }
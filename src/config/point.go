@@ -7,24 +7,92 @@ import (
 	"strings"
 )
 
+type Endpoint struct {
+	Connection string `json:"connection"`
+	Protocol   string `json:"protocol,omitempty"`
+	Proxy      string `json:"proxy,omitempty"`
+	Source     string `json:"source,omitempty"` // local address to bind.
+}
+
+func (e Endpoint) String() string {
+	return e.Protocol + "://" + e.Connection
+}
+
+// BondLink is an uplink of bonding, and may go out by an interface.
+type BondLink struct {
+	Endpoint
+	Interface string `json:"interface,omitempty"` // bind to address of it if no source.
+	Weight    int    `json:"weight,omitempty"`
+}
+
+type Bond struct {
+	Mode  string     `json:"mode,omitempty"` // wrr or latency.
+	Links []BondLink `json:"links"`
+}
+
+func (b *Bond) Correct(ap *Point) {
+	if b.Mode == "" {
+		b.Mode = "wrr"
+	}
+	for i := range b.Links {
+		ln := &b.Links[i]
+		if ln.Connection == "" {
+			ln.Connection = ap.Connection
+		}
+		if ln.Protocol == "" {
+			ln.Protocol = ap.Protocol
+		}
+		if ln.Weight == 0 {
+			ln.Weight = 1
+		}
+		CorrectAddr(&ln.Connection, 10002)
+	}
+}
+
+type FailOver struct {
+	Retries  int  `json:"retries"`  // failed reconnects before switching path.
+	Probe    int  `json:"probe"`    // interval(s) to probe all endpoints.
+	Hold     int  `json:"hold"`     // time(s) a failed path is not selected back.
+	FailBack bool `json:"failback"` // switch back to preferred path when it is alive.
+}
+
+func (f *FailOver) Correct() {
+	if f.Retries == 0 {
+		f.Retries = 2
+	}
+	if f.Probe == 0 {
+		f.Probe = 30
+	}
+	if f.Hold == 0 {
+		f.Hold = 300
+	}
+}
+
 type Point struct {
-	Alias       string    `json:"alias,omitempty"`
-	Connection  string    `json:"connection"`
-	Timeout     int       `json:"timeout"`
-	Username    string    `json:"username,omitempty"`
-	Network     string    `json:"network"`
-	Password    string    `json:"password,omitempty"`
-	Protocol    string    `json:"protocol,omitempty"`
-	Interface   Interface `json:"interface"`
-	Log         Log       `json:"log"`
-	Http        *Http     `json:"http,omitempty"`
-	Crypt       *Crypt    `json:"crypt,omitempty"`
-	PProf       string    `json:"pprof,omitempty"`
-	RequestAddr bool      `json:"-"`
-	SaveFile    string    `json:"-"`
-	Queue       *Queue    `json:"queue"`
-	Terminal    string    `json:"-"`
-	Cert        *Cert     `json:"cert"`
+	Alias       string     `json:"alias,omitempty"`
+	Connection  string     `json:"connection"`
+	Timeout     int        `json:"timeout"`
+	Username    string     `json:"username,omitempty"`
+	Network     string     `json:"network"`
+	Password    string     `json:"password,omitempty"`
+	Protocol    string     `json:"protocol,omitempty"`
+	Interface   Interface  `json:"interface"`
+	Log         Log        `json:"log"`
+	Http        *Http      `json:"http,omitempty"`
+	Crypt       *Crypt     `json:"crypt,omitempty"`
+	PProf       string     `json:"pprof,omitempty"`
+	RequestAddr bool       `json:"-"`
+	SaveFile    string     `json:"-"`
+	Queue       *Queue     `json:"queue"`
+	Terminal    string     `json:"-"`
+	Cert        *Cert      `json:"cert"`
+	Endpoints   []Endpoint `json:"endpoints,omitempty"`
+	FailOver    *FailOver  `json:"failover,omitempty"`
+	Proxy       string     `json:"proxy,omitempty"` // http, https or socks5 url, and direct to disable.
+	Otp         string     `json:"-"`               // one time password, or prompt to read it.
+	Bond        *Bond      `json:"bond,omitempty"`  // connects by several uplinks at same time.
+	Health      *Health    `json:"health,omitempty"`
+	Vlan        *Vlan      `json:"vlan,omitempty"` // of tap on bridge.
 }
 
 func DefaultPoint() *Point {
@@ -32,7 +100,7 @@ func DefaultPoint() *Point {
 		Alias:      "",
 		Connection: "openlan.net",
 		Network:    "default",
-		Protocol:   "tcp", // udp, kcp, tcp, tls, ws and wss etc.
+		Protocol:   "tcp", // udp, kcp, tcp, tls, ws, wss and quic etc.
 		Timeout:    60,
 		Log: Log{
 			File:    LogFile("openlan-point.log"),
@@ -76,7 +144,9 @@ func (ap *Point) Flags() {
 	flag.StringVar(&ap.Connection, "conn", obj.Connection, "Connection access to")
 	flag.StringVar(&ap.Username, "user", obj.Username, "User access to by <username>@<network>")
 	flag.StringVar(&ap.Password, "pass", obj.Password, "Password for authentication")
+	flag.StringVar(&ap.Otp, "otp", obj.Otp, "One time password, or prompt to read it from terminal")
 	flag.StringVar(&ap.Protocol, "proto", obj.Protocol, "IP Protocol for connection")
+	flag.StringVar(&ap.Proxy, "proxy", obj.Proxy, "Proxy url for stream connection")
 	flag.StringVar(&ap.Log.File, "log:file", obj.Log.File, "Log saved to file")
 	flag.StringVar(&ap.Interface.Name, "if:name", obj.Interface.Name, "Configure interface name")
 	flag.StringVar(&ap.Interface.Address, "if:addr", obj.Interface.Address, "Configure interface address")
@@ -118,11 +188,15 @@ func (ap *Point) Correct(obj *Point) {
 			ap.Network = obj.Network
 		}
 	}
+	if len(ap.Endpoints) > 0 && ap.Connection == "" {
+		ap.Connection = ap.Endpoints[0].Connection
+		ap.Protocol = ap.Endpoints[0].Protocol
+	}
 	CorrectAddr(&ap.Connection, 10002)
 	if runtime.GOOS == "darwin" {
 		ap.Interface.Provider = "tun"
 	}
-	if ap.Protocol == "tls" || ap.Protocol == "wss" {
+	if ap.Protocol == "tls" || ap.Protocol == "wss" || ap.Protocol == "quic" {
 		if ap.Cert == nil && obj != nil {
 			ap.Cert = obj.Cert
 		}
@@ -136,6 +210,37 @@ func (ap *Point) Correct(obj *Point) {
 	if ap.Protocol == "" {
 		ap.Protocol = "tcp"
 	}
+	for i := range ap.Endpoints {
+		ep := &ap.Endpoints[i]
+		if ep.Protocol == "" {
+			ep.Protocol = ap.Protocol
+		}
+		CorrectAddr(&ep.Connection, 10002)
+	}
+	if len(ap.Endpoints) > 1 && ap.FailOver == nil {
+		ap.FailOver = &FailOver{}
+	}
+	if ap.FailOver != nil {
+		ap.FailOver.Correct()
+	}
+	if ap.Bond != nil {
+		ap.Bond.Correct(ap)
+	}
+	if ap.Health == nil {
+		ap.Health = &Health{}
+	}
+	ap.Health.Correct()
+	if ap.Vlan != nil {
+		ap.Vlan.Correct()
+	}
+}
+
+// GetEndpoints returns ordered paths to switch, the first is preferred.
+func (ap *Point) GetEndpoints() []Endpoint {
+	if len(ap.Endpoints) > 0 {
+		return ap.Endpoints
+	}
+	return []Endpoint{{Connection: ap.Connection, Protocol: ap.Protocol}}
 }
 
 func (ap *Point) Default() {
@@ -0,0 +1,24 @@
+package config
+
+type Radius struct {
+	Servers     []string `json:"servers"` // host:port, and port 1812 by default.
+	AcctServers []string `json:"acctServers,omitempty"`
+	Secret      string   `json:"secret"`
+	Auth        string   `json:"auth,omitempty"` // pap or mschapv2.
+	NasId       string   `json:"nasId,omitempty"`
+	Timeout     int      `json:"timeout,omitempty"`
+	Retries     int      `json:"retries,omitempty"`
+	Interim     int      `json:"interim,omitempty"` // seconds.
+}
+
+func (r *Radius) Correct() {
+	for i := range r.Servers {
+		CorrectAddr(&r.Servers[i], 1812)
+	}
+	for i := range r.AcctServers {
+		CorrectAddr(&r.AcctServers[i], 1813)
+	}
+	if r.Auth == "" {
+		r.Auth = "pap"
+	}
+}
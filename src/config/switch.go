@@ -43,25 +43,29 @@ func (p *Perf) Correct(obj *Perf) {
 }
 
 type Switch struct {
-	Alias     string     `json:"alias"`
-	Perf      Perf       `json:"perf,omitempty"`
-	Protocol  string     `json:"protocol"` // tcp, tls, udp, kcp, ws and wss.
-	Listen    string     `json:"listen"`
-	Timeout   int        `json:"timeout"`
-	Http      *Http      `json:"http,omitempty"`
-	Log       Log        `json:"log"`
-	Cert      *Cert      `json:"cert,omitempty"`
-	Crypt     *Crypt     `json:"crypt,omitempty"`
-	Network   []*Network `json:"network,omitempty"`
-	Acl       []*ACL     `json:"acl"`
-	FireWall  []FlowRule `json:"firewall,omitempty"`
-	Inspect   []string   `json:"inspect"`
-	Queue     Queue      `json:"queue"`
-	Password  string     `json:"password"`
-	Ldap      *LDAP      `json:"ldap"`
-	ConfDir   string     `json:"-"`
-	TokenFile string     `json:"-"`
-	SaveFile  string     `json:"-"`
+	Alias     string      `json:"alias"`
+	Perf      Perf        `json:"perf,omitempty"`
+	Protocol  string      `json:"protocol"` // tcp, tls, udp, kcp, ws, wss and quic.
+	Listen    string      `json:"listen"`
+	Timeout   int         `json:"timeout"`
+	Http      *Http       `json:"http,omitempty"`
+	Log       Log         `json:"log"`
+	Cert      *Cert       `json:"cert,omitempty"`
+	Crypt     *Crypt      `json:"crypt,omitempty"`
+	Network   []*Network  `json:"network,omitempty"`
+	Acl       []*ACL      `json:"acl"`
+	FireWall  []FlowRule  `json:"firewall,omitempty"`
+	Inspect   []string    `json:"inspect"`
+	Queue     Queue       `json:"queue"`
+	Password  string      `json:"password"`
+	Ldap      *LDAP       `json:"ldap"`
+	Policy    *PassPolicy `json:"passPolicy,omitempty"`
+	Radius    *Radius     `json:"radius,omitempty"`
+	Health    *Health     `json:"health,omitempty"` // thresholds of points.
+	Flow      *FlowExport `json:"flow,omitempty"`
+	ConfDir   string      `json:"-"`
+	TokenFile string      `json:"-"`
+	SaveFile  string      `json:"-"`
 }
 
 func DefaultSwitch() *Switch {
@@ -133,6 +137,16 @@ func (s *Switch) Correct(obj *Switch) {
 	if s.Protocol == "" {
 		s.Protocol = "tcp"
 	}
+	if s.Health == nil {
+		s.Health = &Health{}
+	}
+	s.Health.Correct()
+	if s.Radius != nil {
+		s.Radius.Correct()
+	}
+	if s.Flow != nil {
+		s.Flow.Correct()
+	}
 }
 
 func (s *Switch) LoadNetwork() {
@@ -0,0 +1,161 @@
+package libol
+
+import (
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"net"
+	"sync"
+)
+
+const UdpBatch = 32 // datagrams read or written by once.
+
+type batchConn interface {
+	ReadBatch(ms []ipv4.Message, flags int) (int, error)
+	WriteBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
+func newBatchConn(conn *net.UDPConn) batchConn {
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok && addr.IP.To4() != nil {
+		return ipv4.NewPacketConn(conn)
+	}
+	return ipv6.NewPacketConn(conn)
+}
+
+// UdpReader reads datagrams in batch by recvmmsg on linux, and one by one
+// on others. The buffers are from pool, and owned by caller after read.
+type UdpReader struct {
+	udp  *net.UDPConn
+	conn batchConn
+	pool *BufferPool
+	msgs []ipv4.Message
+}
+
+func NewUdpReader(conn *net.UDPConn, pool *BufferPool, size int) *UdpReader {
+	if size <= 0 {
+		size = UdpBatch
+	}
+	r := &UdpReader{
+		udp:  conn,
+		conn: newBatchConn(conn),
+		pool: pool,
+		msgs: make([]ipv4.Message, size),
+	}
+	for i := range r.msgs {
+		r.msgs[i].Buffers = [][]byte{pool.Get()}
+	}
+	return r
+}
+
+// Read calls fn with datagram and address of source for every one read, and
+// the buffer of datagram is owned by fn.
+func (r *UdpReader) Read(fn func(data []byte, addr *net.UDPAddr)) error {
+	n, err := r.conn.ReadBatch(r.msgs, 0)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		m := &r.msgs[i]
+		data := m.Buffers[0]
+		m.Buffers[0] = r.pool.Get()
+		if addr, ok := m.Addr.(*net.UDPAddr); ok {
+			fn(data[:m.N], addr)
+		} else {
+			r.pool.Put(data)
+		}
+	}
+	return nil
+}
+
+// UdpWriter writes datagrams in batch by sendmmsg on linux, and one by one
+// on others. A datagram is copied to buffer of pool when queued, and Loop
+// sends it with others queued at that time.
+type UdpWriter struct {
+	udp   *net.UDPConn
+	conn  batchConn
+	pool  *BufferPool
+	msgs  []ipv4.Message
+	queue chan ipv4.Message
+	done  chan struct{}
+	once  sync.Once
+}
+
+func NewUdpWriter(conn *net.UDPConn, pool *BufferPool, size int) *UdpWriter {
+	if size <= 0 {
+		size = UdpBatch
+	}
+	return &UdpWriter{
+		udp:   conn,
+		conn:  newBatchConn(conn),
+		pool:  pool,
+		msgs:  make([]ipv4.Message, size),
+		queue: make(chan ipv4.Message, size*32),
+		done:  make(chan struct{}),
+	}
+}
+
+// WriteToUDP queues datagram to addr, and it's sent at once if larger
+// than buffer of pool.
+func (w *UdpWriter) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	if len(b) > w.pool.Size() {
+		return w.udp.WriteToUDP(b, addr)
+	}
+	select {
+	case <-w.done:
+		return 0, NewErr("writer closed")
+	default:
+	}
+	data := w.pool.Get()
+	n := copy(data, b)
+	select {
+	case w.queue <- ipv4.Message{Buffers: [][]byte{data[:n]}, Addr: addr}:
+		return n, nil
+	case <-w.done:
+		w.pool.Put(data)
+		return 0, NewErr("writer closed")
+	}
+}
+
+// Loop sends datagrams queued until closed.
+func (w *UdpWriter) Loop() {
+	for {
+		select {
+		case m := <-w.queue:
+			w.msgs[0] = m
+		case <-w.done:
+			return
+		}
+		n := 1
+	more:
+		for n < len(w.msgs) {
+			select {
+			case m := <-w.queue:
+				w.msgs[n] = m
+				n++
+			default:
+				break more
+			}
+		}
+		w.flush(w.msgs[:n])
+	}
+}
+
+func (w *UdpWriter) flush(msgs []ipv4.Message) {
+	for sent := 0; sent < len(msgs); {
+		n, err := w.conn.WriteBatch(msgs[sent:], 0)
+		if err != nil {
+			Debug("UdpWriter.flush: %s", err)
+			n++ // skip the failed one.
+		}
+		sent += n
+	}
+	for i := range msgs {
+		w.pool.Put(msgs[i].Buffers[0])
+		msgs[i] = ipv4.Message{}
+	}
+}
+
+func (w *UdpWriter) Close() {
+	w.once.Do(func() {
+		close(w.done)
+	})
+}
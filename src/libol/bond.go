@@ -0,0 +1,247 @@
+package libol
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	BondWrr     = "wrr"     // weighted round-robin.
+	BondLatency = "latency" // lowest latency.
+	BondSticky  = 30        // seconds a flow sticks to its member.
+)
+
+type BondMember struct {
+	Client  SocketClient
+	Weight  int
+	Latency int64 // ms
+	current int   // for smooth weighted round-robin.
+}
+
+type bondFlow struct {
+	member *BondMember
+	active int64
+}
+
+// Bond schedules frames of a point across several connections, and frames
+// of a flow stick to the same member to avoid reordering.
+type Bond struct {
+	Id      string
+	Mode    string
+	lock    sync.Mutex
+	members []*BondMember
+	flows   map[uint32]*bondFlow
+	sweepAt int64
+	left    map[string]int64 // statistics of removed members.
+}
+
+func NewBond(id, mode string) *Bond {
+	if mode == "" {
+		mode = BondWrr
+	}
+	return &Bond{
+		Id:      id,
+		Mode:    mode,
+		members: make([]*BondMember, 0, 4),
+		flows:   make(map[uint32]*bondFlow, 1024),
+		left:    make(map[string]int64, 8),
+	}
+}
+
+func (b *Bond) Add(client SocketClient, weight int) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if weight <= 0 {
+		weight = 1
+	}
+	for _, m := range b.members {
+		if m.Client == client {
+			m.Weight = weight
+			return
+		}
+	}
+	b.members = append(b.members, &BondMember{Client: client, Weight: weight})
+}
+
+// Del removes member of client, and returns number of remaining members.
+func (b *Bond) Del(client SocketClient) int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	members := make([]*BondMember, 0, len(b.members))
+	for _, m := range b.members {
+		if m.Client != client {
+			members = append(members, m)
+			continue
+		}
+		for k, v := range client.Statistics() {
+			b.left[k] += v
+		}
+	}
+	b.members = members
+	for k, f := range b.flows {
+		if f.member.Client == client {
+			delete(b.flows, k)
+		}
+	}
+	return len(b.members)
+}
+
+func (b *Bond) Size() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.members)
+}
+
+func (b *Bond) Members() []BondMember {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	members := make([]BondMember, 0, len(b.members))
+	for _, m := range b.members {
+		members = append(members, *m)
+	}
+	return members
+}
+
+// Statistics returns sum of all members includes removed.
+func (b *Bond) Statistics() map[string]int64 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	sts := make(map[string]int64, len(b.left))
+	for k, v := range b.left {
+		sts[k] = v
+	}
+	for _, m := range b.members {
+		for k, v := range m.Client.Statistics() {
+			sts[k] += v
+		}
+	}
+	return sts
+}
+
+func (b *Bond) SetLatency(client SocketClient, latency int64) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, m := range b.members {
+		if m.Client == client {
+			m.Latency = latency
+		}
+	}
+}
+
+func bondAlive(m *BondMember) bool {
+	return m.Client.IsOk() && m.Client.Have(ClAuth)
+}
+
+func (b *Bond) next() *BondMember {
+	var best *BondMember
+	if b.Mode == BondLatency {
+		for _, m := range b.members {
+			if !bondAlive(m) {
+				continue
+			}
+			if best == nil || m.Latency < best.Latency {
+				best = m
+			}
+		}
+		return best
+	}
+	total := 0
+	for _, m := range b.members {
+		if !bondAlive(m) {
+			continue
+		}
+		m.current += m.Weight
+		total += m.Weight
+		if best == nil || m.current > best.current {
+			best = m
+		}
+	}
+	if best != nil {
+		best.current -= total
+	}
+	return best
+}
+
+func (b *Bond) sweep(now int64) {
+	if now-b.sweepAt < BondSticky {
+		return
+	}
+	b.sweepAt = now
+	for k, f := range b.flows {
+		if now-f.active > BondSticky {
+			delete(b.flows, k)
+		}
+	}
+}
+
+// Select returns client to send the ethernet frame, and nil if no member
+// is alive.
+func (b *Bond) Select(frame []byte) SocketClient {
+	key := FlowHash(frame)
+	now := time.Now().Unix()
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.sweep(now)
+	if f, ok := b.flows[key]; ok && bondAlive(f.member) {
+		f.active = now
+		return f.member.Client
+	}
+	m := b.next()
+	if m == nil {
+		return nil
+	}
+	b.flows[key] = &bondFlow{member: m, active: now}
+	return m.Client
+}
+
+// Write sends frame by a selected member, and returns the member if failed.
+func (b *Bond) Write(frame *FrameMessage) (SocketClient, error) {
+	client := b.Select(frame.Frame())
+	if client == nil {
+		return nil, NewErr("bond %s has no member", b.Id)
+	}
+	return client, client.WriteMsg(frame)
+}
+
+// FlowHash returns hash of addresses and ports for ipv4 frame, otherwise
+// of ethernet addresses.
+func FlowHash(frame []byte) uint32 {
+	h := fnv.New32a()
+	if len(frame) < EtherLen {
+		return 0
+	}
+	proto := binary.BigEndian.Uint16(frame[12:14])
+	if proto != EthIp4 || len(frame) < EtherLen+Ipv4Len {
+		_, _ = h.Write(frame[:12])
+		return h.Sum32()
+	}
+	ip := frame[EtherLen:]
+	_, _ = h.Write(ip[12:20]) // source and destination
+	_, _ = h.Write(ip[9:10])  // protocol
+	size := int(ip[0]&0x0f) * 4
+	if (ip[9] == IpTcp || ip[9] == IpUdp) && len(ip) >= size+4 {
+		_, _ = h.Write(ip[size : size+4])
+	}
+	return h.Sum32()
+}
+
+// InterfaceAddr returns the first ipv4 address of interface.
+func InterfaceAddr(name string) string {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return ""
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return ""
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return ipNet.IP.String()
+		}
+	}
+	return ""
+}
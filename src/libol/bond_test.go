@@ -0,0 +1,57 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func bondClient(addr string) SocketClient {
+	c := NewUdpClient(addr, nil)
+	conn, _ := net.Pipe()
+	c.SetConnection(conn)
+	c.SetStatus(ClAuth)
+	return c
+}
+
+func bondFrame(port byte) []byte {
+	frame := make([]byte, EtherLen+Ipv4Len+UdpLen)
+	frame[12], frame[13] = 0x08, 0x00
+	frame[EtherLen] = 0x45
+	frame[EtherLen+9] = IpUdp
+	frame[EtherLen+Ipv4Len+1] = port
+	return frame
+}
+
+func TestBond_Select(t *testing.T) {
+	a, b := bondClient("a"), bondClient("b")
+	bond := NewBond("hi", BondWrr)
+	bond.Add(a, 3)
+	bond.Add(b, 1)
+	count := map[SocketClient]int{}
+	for i := 0; i < 100; i++ {
+		count[bond.Select(bondFrame(byte(i)))]++
+	}
+	assert.Equal(t, 75, count[a], "be weighted.")
+	assert.Equal(t, 25, count[b], "be weighted.")
+	// sticky by flow.
+	client := bond.Select(bondFrame(7))
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, client, bond.Select(bondFrame(7)), "be sticky.")
+	}
+	// lost a member.
+	b.SetStatus(ClClosed)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, a, bond.Select(bondFrame(byte(i))), "be alive one.")
+	}
+	assert.Equal(t, 1, bond.Del(a), "be the same.")
+	assert.Nil(t, bond.Select(bondFrame(1)), "no alive.")
+
+	bond = NewBond("hi", BondLatency)
+	bond.Add(a, 1)
+	bond.Add(b, 1)
+	b.SetStatus(ClAuth)
+	bond.SetLatency(a, 30)
+	bond.SetLatency(b, 10)
+	assert.Equal(t, b, bond.Select(bondFrame(200)), "be lowest latency.")
+}
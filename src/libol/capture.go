@@ -0,0 +1,179 @@
+package libol
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	CaptureCount   = 1000
+	CaptureSize    = 64 << 20 // bytes
+	CaptureTimeout = 60       // seconds
+	CaptureMaxTime = 540      // seconds, and streamed within write timeout of http.
+	CaptureQueue   = 1024
+)
+
+type captured struct {
+	at   time.Time
+	data []byte
+}
+
+// Capture taps frames of a network or a point until the count, size or
+// time limit is reached.
+type Capture struct {
+	Id      string
+	Network string
+	Point   string // uuid of point, and empty for all.
+	Filter  *Filter
+	Count   int
+	Size    int64
+	Timeout time.Duration
+	frames  chan captured
+	done    chan bool
+	once    sync.Once
+	Dropped int64
+}
+
+func NewCapture(network, point, filter string) (*Capture, error) {
+	f, err := NewFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	return &Capture{
+		Id:      GenRandom(8),
+		Network: network,
+		Point:   point,
+		Filter:  f,
+		Count:   CaptureCount,
+		Size:    CaptureSize,
+		Timeout: CaptureTimeout * time.Second,
+		frames:  make(chan captured, CaptureQueue),
+		done:    make(chan bool),
+	}, nil
+}
+
+// Limit sets limits, and ignores zero or out of range values.
+func (c *Capture) Limit(count int, size int64, timeout int) {
+	if count > 0 {
+		c.Count = count
+	}
+	if size > 0 && size < CaptureSize {
+		c.Size = size
+	}
+	if timeout > 0 && timeout <= CaptureMaxTime {
+		c.Timeout = time.Duration(timeout) * time.Second
+	}
+}
+
+func (c *Capture) Match(network, point string) bool {
+	if c.Network != "" && c.Network != network {
+		return false
+	}
+	return c.Point == "" || c.Point == point
+}
+
+// Input copies the frame if matched, and drops it if queue is full.
+func (c *Capture) Input(frame []byte) {
+	if !c.Filter.Match(frame) {
+		return
+	}
+	data := make([]byte, len(frame))
+	copy(data, frame)
+	select {
+	case c.frames <- captured{at: time.Now(), data: data}:
+	default:
+		atomic.AddInt64(&c.Dropped, 1)
+	}
+}
+
+func (c *Capture) Stop() {
+	c.once.Do(func() {
+		close(c.done)
+	})
+}
+
+// Dump writes frames captured as pcapng to w, and calls flush after every
+// frame if not nil.
+func (c *Capture) Dump(w io.Writer, flush func()) error {
+	writer, err := NewPcapWriter(w, c.Network)
+	if err != nil {
+		return err
+	}
+	if flush != nil {
+		flush()
+	}
+	timer := time.NewTimer(c.Timeout)
+	defer timer.Stop()
+	count, size := 0, int64(0)
+	for count < c.Count && size < c.Size {
+		select {
+		case f := <-c.frames:
+			if err := writer.Write(f.at, f.data); err != nil {
+				return err
+			}
+			count++
+			size += int64(len(f.data))
+			if flush != nil {
+				flush()
+			}
+		case <-timer.C:
+			return nil
+		case <-c.done:
+			return nil
+		}
+	}
+	return nil
+}
+
+type captures struct {
+	lock  sync.RWMutex
+	items map[string]*Capture
+	size  int32
+}
+
+func (cs *captures) Add(c *Capture) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.items[c.Id] = c
+	atomic.StoreInt32(&cs.size, int32(len(cs.items)))
+}
+
+func (cs *captures) Del(c *Capture) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	delete(cs.items, c.Id)
+	atomic.StoreInt32(&cs.size, int32(len(cs.items)))
+}
+
+func (cs *captures) List() <-chan *Capture {
+	c := make(chan *Capture, 16)
+	go func() {
+		cs.lock.RLock()
+		defer cs.lock.RUnlock()
+		for _, v := range cs.items {
+			c <- v
+		}
+		c <- nil //Finish channel by nil.
+	}()
+	return c
+}
+
+// Input taps frame of point in network to captures matched.
+func (cs *captures) Input(network, point string, frame []byte) {
+	if atomic.LoadInt32(&cs.size) == 0 {
+		return
+	}
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	for _, c := range cs.items {
+		if c.Match(network, point) {
+			c.Input(frame)
+		}
+	}
+}
+
+var Captures = &captures{
+	items: make(map[string]*Capture, 8),
+}
@@ -0,0 +1,73 @@
+package libol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func captureFrame(proto byte, dport uint16) []byte {
+	frame := make([]byte, EtherLen+Ipv4Len+UdpLen)
+	binary.BigEndian.PutUint16(frame[12:14], EthIp4)
+	ip := frame[EtherLen:]
+	ip[0] = 0x45
+	ip[9] = proto
+	copy(ip[12:16], []byte{192, 168, 1, 1})
+	copy(ip[16:20], []byte{192, 168, 1, 2})
+	binary.BigEndian.PutUint16(ip[Ipv4Len+2:], dport)
+	return frame
+}
+
+func TestFilter(t *testing.T) {
+	dns := captureFrame(IpUdp, 53)
+	web := captureFrame(IpTcp, 80)
+	cases := []struct {
+		expr string
+		dns  bool
+		web  bool
+	}{
+		{"", true, true},
+		{"udp port 53", true, false},
+		{"tcp or udp", true, true},
+		{"dst port 80", false, true},
+		{"src port 80", false, false},
+		{"host 192.168.1.2 and not tcp", true, false},
+		{"src net 192.168.1.0/24 and arp or tcp", false, true},
+	}
+	for _, c := range cases {
+		f, err := NewFilter(c.expr)
+		assert.Nil(t, err, c.expr)
+		assert.Equal(t, c.dns, f.Match(dns), c.expr)
+		assert.Equal(t, c.web, f.Match(web), c.expr)
+	}
+	_, err := NewFilter("port x")
+	assert.NotNil(t, err, "be invalid.")
+	_, err = NewFilter("udp and")
+	assert.NotNil(t, err, "be invalid.")
+}
+
+func TestCapture_Dump(t *testing.T) {
+	c, err := NewCapture("hi", "", "udp")
+	assert.Nil(t, err, "be nil.")
+	c.Limit(2, 0, 3600)
+	assert.Equal(t, CaptureTimeout*time.Second, c.Timeout, "be ignored.")
+	c.Limit(2, 0, CaptureMaxTime)
+	assert.Equal(t, CaptureMaxTime*time.Second, c.Timeout, "be the same.")
+	Captures.Add(c)
+	defer Captures.Del(c)
+	Captures.Input("hi", "p1", captureFrame(IpTcp, 80))
+	Captures.Input("other", "p1", captureFrame(IpUdp, 53))
+	Captures.Input("hi", "p1", captureFrame(IpUdp, 53))
+	Captures.Input("hi", "p2", captureFrame(IpUdp, 53))
+	out := &bytes.Buffer{}
+	assert.Nil(t, c.Dump(out, nil), "be nil.")
+	data := out.Bytes()
+	assert.Equal(t, uint32(pcapShbType), binary.LittleEndian.Uint32(data[0:4]), "be the same.")
+	shb := binary.LittleEndian.Uint32(data[4:8])
+	idb := binary.LittleEndian.Uint32(data[shb+4 : shb+8])
+	size := len(captureFrame(IpUdp, 53))
+	epb := 20 + 12 + size + pcapPad(size)
+	assert.Equal(t, int(shb+idb)+epb*2, len(data), "be two frames.")
+}
@@ -0,0 +1,166 @@
+package libol
+
+import (
+	"sync"
+)
+
+const (
+	HealthSamples = 32    // recent samples to summary.
+	HealthTimeout = 10000 // ms to wait for response.
+)
+
+type HealthStats struct {
+	Rtt      int64 `json:"rtt"`    // ms, average of round trip time.
+	Jitter   int64 `json:"jitter"` // ms, average variation of rtt.
+	Loss     int   `json:"loss"`   // percent of lost.
+	Score    int   `json:"score"`  // from 0 to 100, and higher is healthier.
+	Degraded bool  `json:"degraded"`
+}
+
+type HealthThreshold struct {
+	Rtt    int64 // ms
+	Jitter int64 // ms
+	Loss   int   // percent
+}
+
+// Health measures round trip time and loss of pings on a link, or saves
+// the stats reported from peer.
+type Health struct {
+	lock     sync.Mutex
+	samples  []int64 // rtt in ms, and -1 if lost.
+	index    int
+	pending  map[uint64]int64 // sent time in ms by sequence.
+	seq      uint64
+	reported *HealthStats
+	degraded bool
+}
+
+func NewHealth() *Health {
+	return &Health{
+		samples: make([]int64, 0, HealthSamples),
+		pending: make(map[uint64]int64, 4),
+	}
+}
+
+func (h *Health) add(rtt int64) {
+	if len(h.samples) < HealthSamples {
+		h.samples = append(h.samples, rtt)
+		return
+	}
+	h.samples[h.index] = rtt
+	h.index = (h.index + 1) % HealthSamples
+}
+
+// Send returns sequence of a new ping at now, and pings not responded in
+// timeout are taken as lost.
+func (h *Health) Send(now int64) uint64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for seq, at := range h.pending {
+		if now-at >= HealthTimeout {
+			delete(h.pending, seq)
+			h.add(-1)
+		}
+	}
+	h.seq++
+	h.pending[h.seq] = now
+	return h.seq
+}
+
+// Recv returns rtt of the ping responded at now, and -1 if unknown.
+func (h *Health) Recv(seq uint64, now int64) int64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	at, ok := h.pending[seq]
+	if !ok {
+		return -1
+	}
+	delete(h.pending, seq)
+	rtt := now - at
+	h.add(rtt)
+	return rtt
+}
+
+// Report saves stats measured by peer.
+func (h *Health) Report(stats HealthStats) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	stats.Score = HealthScore(stats)
+	h.reported = &stats
+}
+
+func (h *Health) stats() HealthStats {
+	if h.reported != nil {
+		stats := *h.reported
+		stats.Degraded = h.degraded
+		return stats
+	}
+	stats := HealthStats{Score: 100, Degraded: h.degraded}
+	if len(h.samples) == 0 {
+		return stats
+	}
+	var sum, vary, last, count, varies, lost int64
+	last = -1
+	// from oldest to newest.
+	for i := 0; i < len(h.samples); i++ {
+		rtt := h.samples[(h.index+i)%len(h.samples)]
+		if rtt < 0 {
+			lost++
+			continue
+		}
+		sum += rtt
+		count++
+		if last >= 0 {
+			if rtt > last {
+				vary += rtt - last
+			} else {
+				vary += last - rtt
+			}
+			varies++
+		}
+		last = rtt
+	}
+	if count > 0 {
+		stats.Rtt = sum / count
+	}
+	if varies > 0 {
+		stats.Jitter = vary / varies
+	}
+	stats.Loss = int(lost * 100 / int64(len(h.samples)))
+	stats.Score = HealthScore(stats)
+	return stats
+}
+
+func (h *Health) Stats() HealthStats {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.stats()
+}
+
+// Check updates whether degraded by threshold, and returns true if changed.
+func (h *Health) Check(th *HealthThreshold) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	stats := h.stats()
+	degraded := false
+	if th != nil {
+		degraded = (th.Rtt > 0 && stats.Rtt > th.Rtt) ||
+			(th.Jitter > 0 && stats.Jitter > th.Jitter) ||
+			(th.Loss > 0 && stats.Loss > th.Loss)
+	}
+	if degraded == h.degraded {
+		return false
+	}
+	h.degraded = degraded
+	return true
+}
+
+// HealthScore returns 100 minus penalties, that 1 point for every 10ms
+// rtt, every 5ms jitter and every half percent of loss.
+func HealthScore(stats HealthStats) int {
+	score := 100 - int(stats.Rtt/10) - int(stats.Jitter/5) - stats.Loss*2
+	if score < 0 {
+		return 0
+	}
+	return score
+}
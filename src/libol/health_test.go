@@ -0,0 +1,35 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestHealth_Stats(t *testing.T) {
+	h := NewHealth()
+	assert.Equal(t, 100, h.Stats().Score, "be the same.")
+	now := int64(1000)
+	for i, rtt := range []int64{10, 30, 10, 30} {
+		seq := h.Send(now)
+		assert.Equal(t, uint64(i+1), seq, "be the same.")
+		assert.Equal(t, rtt, h.Recv(seq, now+rtt), "be the same.")
+		now += 1000
+	}
+	assert.Equal(t, int64(-1), h.Recv(99, now), "be unknown.")
+	sts := h.Stats()
+	assert.Equal(t, int64(20), sts.Rtt, "be the same.")
+	assert.Equal(t, int64(20), sts.Jitter, "be the same.")
+	assert.Equal(t, 0, sts.Loss, "be the same.")
+	// a ping without response.
+	h.Send(now)
+	h.Send(now + HealthTimeout)
+	assert.Equal(t, 20, h.Stats().Loss, "be the same.")
+
+	th := &HealthThreshold{Loss: 10}
+	assert.True(t, h.Check(th), "be changed.")
+	assert.True(t, h.Stats().Degraded, "be degraded.")
+	assert.False(t, h.Check(th), "not changed.")
+	h.Report(HealthStats{Rtt: 5})
+	assert.True(t, h.Check(th), "be recovered.")
+	assert.Equal(t, 100, h.Stats().Score, "be the same.")
+}
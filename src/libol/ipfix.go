@@ -0,0 +1,324 @@
+package libol
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	FlowIpfix    = "ipfix"
+	FlowNetflow9 = "netflow9"
+)
+
+const (
+	FlowEnterprise = 32473 // documentation, and should be replaced by yours.
+	FlowTemplate   = 256   // id of template.
+	FlowMaxSize    = 1400  // bytes of a message.
+	FlowRefresh    = 60    // seconds to resend template.
+	FlowStrSize    = 32    // bytes of string fields in netflow9.
+)
+
+// reasons of flow end.
+const (
+	FlowIdle   = 0x01
+	FlowActive = 0x02
+	FlowEnd    = 0x03
+	FlowForced = 0x04
+	FlowLack   = 0x05
+)
+
+// elements in enterprise of flow.
+const (
+	FlowIeUser    = 1
+	FlowIeNetwork = 2
+)
+
+type FlowRecord struct {
+	Source     net.IP
+	Dest       net.IP
+	Protocol   uint8
+	PortSource uint16
+	PortDest   uint16
+	Packets    uint64
+	Bytes      uint64
+	Start      int64 // ms
+	End        int64 // ms
+	Reason     uint8
+	User       string
+	Network    string
+}
+
+type flowField struct {
+	id     uint16
+	size   uint16
+	vendor bool // in enterprise.
+}
+
+// fields of ipfix, and string is variable-length.
+var ipfixFields = []flowField{
+	{id: 8, size: 4},   // sourceIPv4Address
+	{id: 12, size: 4},  // destinationIPv4Address
+	{id: 4, size: 1},   // protocolIdentifier
+	{id: 7, size: 2},   // sourceTransportPort
+	{id: 11, size: 2},  // destinationTransportPort
+	{id: 2, size: 8},   // packetDeltaCount
+	{id: 1, size: 8},   // octetDeltaCount
+	{id: 152, size: 8}, // flowStartMilliseconds
+	{id: 153, size: 8}, // flowEndMilliseconds
+	{id: 136, size: 1}, // flowEndReason
+	{id: FlowIeUser, size: 0xffff, vendor: true},
+	{id: FlowIeNetwork, size: 0xffff, vendor: true},
+}
+
+// fields of netflow9, and enterprise one is with the high bit.
+var netflow9Fields = []flowField{
+	{id: 8, size: 4},  // IPV4_SRC_ADDR
+	{id: 12, size: 4}, // IPV4_DST_ADDR
+	{id: 4, size: 1},  // PROTOCOL
+	{id: 7, size: 2},  // L4_SRC_PORT
+	{id: 11, size: 2}, // L4_DST_PORT
+	{id: 2, size: 8},  // IN_PKTS
+	{id: 1, size: 8},  // IN_BYTES
+	{id: 22, size: 4}, // FIRST_SWITCHED
+	{id: 21, size: 4}, // LAST_SWITCHED
+	{id: FlowIeUser, size: FlowStrSize, vendor: true},
+	{id: FlowIeNetwork, size: FlowStrSize, vendor: true},
+}
+
+// FlowEncoder encodes records to messages of ipfix or netflow9.
+type FlowEncoder struct {
+	Protocol   string
+	Domain     uint32 // observation domain or source id.
+	Enterprise uint32
+	boot       int64 // ms
+	sequence   uint32
+	refresh    int64 // ms of template sent.
+}
+
+func NewFlowEncoder(protocol string, domain, enterprise uint32) *FlowEncoder {
+	if enterprise == 0 {
+		enterprise = FlowEnterprise
+	}
+	return &FlowEncoder{
+		Protocol:   protocol,
+		Domain:     domain,
+		Enterprise: enterprise,
+		boot:       time.Now().UnixNano() / 1e6,
+	}
+}
+
+func putString(data []byte, value string) []byte {
+	if len(value) > 254 {
+		value = value[:254]
+	}
+	data = append(data, byte(len(value)))
+	return append(data, value...)
+}
+
+func putFixed(data []byte, value string, size int) []byte {
+	buf := make([]byte, size)
+	copy(buf, value)
+	return append(data, buf...)
+}
+
+func putIp4(data []byte, ip net.IP) []byte {
+	if ip4 := ip.To4(); ip4 != nil {
+		return append(data, ip4...)
+	}
+	return append(data, 0, 0, 0, 0)
+}
+
+func (e *FlowEncoder) uptime(ms int64) uint32 {
+	if ms < e.boot {
+		return 0
+	}
+	return uint32(ms - e.boot)
+}
+
+func (e *FlowEncoder) record(data []byte, r *FlowRecord) []byte {
+	data = putIp4(data, r.Source)
+	data = putIp4(data, r.Dest)
+	data = append(data, r.Protocol)
+	data = append(data, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(data[len(data)-4:], r.PortSource)
+	binary.BigEndian.PutUint16(data[len(data)-2:], r.PortDest)
+	data = append(data, make([]byte, 16)...)
+	binary.BigEndian.PutUint64(data[len(data)-16:], r.Packets)
+	binary.BigEndian.PutUint64(data[len(data)-8:], r.Bytes)
+	if e.Protocol == FlowNetflow9 {
+		data = append(data, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(data[len(data)-8:], e.uptime(r.Start))
+		binary.BigEndian.PutUint32(data[len(data)-4:], e.uptime(r.End))
+		data = putFixed(data, r.User, FlowStrSize)
+		return putFixed(data, r.Network, FlowStrSize)
+	}
+	data = append(data, make([]byte, 16)...)
+	binary.BigEndian.PutUint64(data[len(data)-16:], uint64(r.Start))
+	binary.BigEndian.PutUint64(data[len(data)-8:], uint64(r.End))
+	data = append(data, r.Reason)
+	data = putString(data, r.User)
+	return putString(data, r.Network)
+}
+
+func (e *FlowEncoder) template() []byte {
+	fields := ipfixFields
+	id := uint16(2)
+	if e.Protocol == FlowNetflow9 {
+		fields = netflow9Fields
+		id = 0
+	}
+	data := make([]byte, 8, 128)
+	binary.BigEndian.PutUint16(data[0:], id)
+	binary.BigEndian.PutUint16(data[4:], FlowTemplate)
+	binary.BigEndian.PutUint16(data[6:], uint16(len(fields)))
+	for _, f := range fields {
+		fid := f.id
+		if f.vendor {
+			fid |= 0x8000
+		}
+		data = append(data, byte(fid>>8), byte(fid), byte(f.size>>8), byte(f.size))
+		if f.vendor && e.Protocol != FlowNetflow9 {
+			data = append(data, 0, 0, 0, 0)
+			binary.BigEndian.PutUint32(data[len(data)-4:], e.Enterprise)
+		}
+	}
+	binary.BigEndian.PutUint16(data[2:], uint16(len(data)))
+	return data
+}
+
+// message returns message of sets, and count is number of records with
+// templates.
+func (e *FlowEncoder) message(now int64, sets []byte, count, templates int) []byte {
+	var data []byte
+	if e.Protocol == FlowNetflow9 {
+		data = make([]byte, 20, 20+len(sets))
+		binary.BigEndian.PutUint16(data[0:], 9)
+		binary.BigEndian.PutUint16(data[2:], uint16(count))
+		binary.BigEndian.PutUint32(data[4:], e.uptime(now))
+		binary.BigEndian.PutUint32(data[8:], uint32(now/1000))
+		e.sequence++ // sequence of messages.
+		binary.BigEndian.PutUint32(data[12:], e.sequence)
+		binary.BigEndian.PutUint32(data[16:], e.Domain)
+		return append(data, sets...)
+	}
+	data = make([]byte, 16, 16+len(sets))
+	binary.BigEndian.PutUint16(data[0:], 10)
+	binary.BigEndian.PutUint32(data[4:], uint32(now/1000))
+	binary.BigEndian.PutUint32(data[8:], e.sequence)
+	binary.BigEndian.PutUint32(data[12:], e.Domain)
+	e.sequence += uint32(count - templates) // sequence of data records.
+	data = append(data, sets...)
+	binary.BigEndian.PutUint16(data[2:], uint16(len(data)))
+	return data
+}
+
+// Encode returns messages of records, and template is in the first one
+// if it's due to refresh.
+func (e *FlowEncoder) Encode(records []*FlowRecord) [][]byte {
+	now := time.Now().UnixNano() / 1e6
+	header := 16
+	if e.Protocol == FlowNetflow9 {
+		header = 20
+	}
+	messages := make([][]byte, 0, 4)
+	var tmpl []byte
+	if now-e.refresh >= FlowRefresh*1000 {
+		e.refresh = now
+		tmpl = e.template()
+	}
+	for len(records) > 0 || tmpl != nil {
+		sets := make([]byte, 0, FlowMaxSize)
+		count, templates := 0, 0
+		if tmpl != nil {
+			sets = append(sets, tmpl...)
+			count, templates = 1, 1
+			tmpl = nil
+		}
+		set := make([]byte, 4, FlowMaxSize)
+		binary.BigEndian.PutUint16(set[0:], FlowTemplate)
+		for len(records) > 0 {
+			data := e.record(nil, records[0])
+			if count > templates && header+len(sets)+len(set)+len(data) > FlowMaxSize {
+				break
+			}
+			set = append(set, data...)
+			records = records[1:]
+			count++
+		}
+		if count > templates {
+			if e.Protocol == FlowNetflow9 {
+				for len(set)%4 != 0 {
+					set = append(set, 0)
+				}
+			}
+			binary.BigEndian.PutUint16(set[2:], uint16(len(set)))
+			sets = append(sets, set...)
+		}
+		messages = append(messages, e.message(now, sets, count, templates))
+	}
+	return messages
+}
+
+// FlowExporter sends records to collectors by udp.
+type FlowExporter struct {
+	lock       sync.Mutex
+	encoder    *FlowEncoder
+	collectors []string
+	conns      map[string]net.Conn
+	out        *SubLogger
+}
+
+func NewFlowExporter(protocol string, collectors []string, domain, enterprise uint32) *FlowExporter {
+	return &FlowExporter{
+		encoder:    NewFlowEncoder(protocol, domain, enterprise),
+		collectors: collectors,
+		conns:      make(map[string]net.Conn, len(collectors)),
+		out:        NewSubLogger(protocol),
+	}
+}
+
+func (x *FlowExporter) conn(addr string) (net.Conn, error) {
+	if c, ok := x.conns[addr]; ok {
+		return c, nil
+	}
+	c, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	x.conns[addr] = c
+	return c, nil
+}
+
+// Export sends records to every collector.
+func (x *FlowExporter) Export(records []*FlowRecord) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if len(records) == 0 {
+		return
+	}
+	messages := x.encoder.Encode(records)
+	for _, addr := range x.collectors {
+		c, err := x.conn(addr)
+		if err != nil {
+			x.out.Warn("FlowExporter.Export: %s %s", addr, err)
+			continue
+		}
+		for _, data := range messages {
+			if _, err := c.Write(data); err != nil {
+				x.out.Debug("FlowExporter.Export: %s %s", addr, err)
+			}
+		}
+	}
+	x.out.Debug("FlowExporter.Export: %d records", len(records))
+}
+
+func (x *FlowExporter) Close() {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	for addr, c := range x.conns {
+		_ = c.Close()
+		delete(x.conns, addr)
+	}
+}
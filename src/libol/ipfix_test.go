@@ -0,0 +1,101 @@
+package libol
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func flowRecords(n int) []*FlowRecord {
+	records := make([]*FlowRecord, 0, n)
+	for i := 0; i < n; i++ {
+		records = append(records, &FlowRecord{
+			Source:     net.IPv4(192, 168, 1, byte(i)),
+			Dest:       net.IPv4(10, 0, 0, 1),
+			Protocol:   IpTcp,
+			PortSource: 40000,
+			PortDest:   443,
+			Packets:    10,
+			Bytes:      1500,
+			Start:      1000,
+			End:        2000,
+			Reason:     FlowIdle,
+			User:       "hi",
+			Network:    "example",
+		})
+	}
+	return records
+}
+
+func TestFlowEncoder_Ipfix(t *testing.T) {
+	e := NewFlowEncoder(FlowIpfix, 7, 0)
+	messages := e.Encode(flowRecords(1))
+	assert.Equal(t, 1, len(messages), "be the same.")
+	data := messages[0]
+	assert.Equal(t, uint16(10), binary.BigEndian.Uint16(data[0:2]), "be version.")
+	assert.Equal(t, len(data), int(binary.BigEndian.Uint16(data[2:4])), "be length.")
+	assert.Equal(t, uint32(0), binary.BigEndian.Uint32(data[8:12]), "be sequence.")
+	assert.Equal(t, uint32(7), binary.BigEndian.Uint32(data[12:16]), "be domain.")
+	// template set.
+	tmpl := data[16:]
+	size := int(binary.BigEndian.Uint16(tmpl[2:4]))
+	assert.Equal(t, uint16(2), binary.BigEndian.Uint16(tmpl[0:2]), "be template.")
+	assert.Equal(t, uint16(FlowTemplate), binary.BigEndian.Uint16(tmpl[4:6]), "be the same.")
+	assert.Equal(t, uint16(len(ipfixFields)), binary.BigEndian.Uint16(tmpl[6:8]), "be the same.")
+	assert.Equal(t, 8+10*4+2*8, size, "be the same.")
+	assert.Equal(t, uint32(FlowEnterprise), binary.BigEndian.Uint32(tmpl[size-4:size]), "be enterprise.")
+	// data set.
+	set := tmpl[size:]
+	assert.Equal(t, uint16(FlowTemplate), binary.BigEndian.Uint16(set[0:2]), "be the same.")
+	assert.Equal(t, len(set), int(binary.BigEndian.Uint16(set[2:4])), "be length.")
+	assert.Equal(t, []byte{192, 168, 1, 0}, set[4:8], "be source.")
+	assert.Equal(t, uint16(443), binary.BigEndian.Uint16(set[15:17]), "be port.")
+	assert.Equal(t, uint64(1500), binary.BigEndian.Uint64(set[25:33]), "be bytes.")
+	assert.Equal(t, "\x02hi\x07example", string(set[50:]), "be user and network.")
+	// template is refreshed later, and sequence is of records.
+	messages = e.Encode(flowRecords(100))
+	assert.True(t, len(messages) > 1, "be split.")
+	for _, data := range messages {
+		assert.True(t, len(data) <= FlowMaxSize, "not larger than max.")
+		assert.Equal(t, uint16(FlowTemplate), binary.BigEndian.Uint16(data[16:18]), "be data.")
+	}
+	assert.Equal(t, uint32(1), binary.BigEndian.Uint32(messages[0][8:12]), "be sequence.")
+	assert.Equal(t, uint32(101), e.sequence, "be the same.")
+}
+
+func TestFlowEncoder_Netflow9(t *testing.T) {
+	e := NewFlowEncoder(FlowNetflow9, 7, 0)
+	messages := e.Encode(flowRecords(2))
+	assert.Equal(t, 1, len(messages), "be the same.")
+	data := messages[0]
+	assert.Equal(t, uint16(9), binary.BigEndian.Uint16(data[0:2]), "be version.")
+	assert.Equal(t, uint16(3), binary.BigEndian.Uint16(data[2:4]), "be count.")
+	assert.Equal(t, uint32(1), binary.BigEndian.Uint32(data[12:16]), "be sequence.")
+	tmpl := data[20:]
+	size := int(binary.BigEndian.Uint16(tmpl[2:4]))
+	assert.Equal(t, uint16(0), binary.BigEndian.Uint16(tmpl[0:2]), "be template.")
+	assert.Equal(t, 8+11*4, size, "be the same.")
+	assert.Equal(t, uint16(0x8000|FlowIeUser), binary.BigEndian.Uint16(tmpl[size-8:size-6]), "be user.")
+	set := tmpl[size:]
+	assert.Equal(t, len(set), int(binary.BigEndian.Uint16(set[2:4])), "be length.")
+	assert.Equal(t, 0, len(set)%4, "be padded.")
+	assert.Equal(t, 4+2*(37+2*FlowStrSize)+2, len(set), "be the same.")
+}
+
+func TestFlowExporter(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+	x := NewFlowExporter(FlowIpfix, []string{conn.LocalAddr().String()}, 1, 0)
+	defer x.Close()
+	x.Export(flowRecords(3))
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	data := make([]byte, 2048)
+	n, err := conn.Read(data)
+	assert.Nil(t, err, "be received.")
+	assert.Equal(t, n, int(binary.BigEndian.Uint16(data[2:4])), "be length.")
+}
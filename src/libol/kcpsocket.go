@@ -8,6 +8,7 @@ import (
 
 type KcpConfig struct {
 	Block        kcp.BlockCrypt
+	Local        string        // source address to bind.
 	DataShards   int           // default 1024
 	ParityShards int           // default 3
 	Timeout      time.Duration // ns
@@ -26,6 +27,7 @@ type KcpServer struct {
 	*SocketServerImpl
 	kcpCfg   *KcpConfig
 	listener *kcp.Listener
+	conn     *RoamListener
 }
 
 func NewKcpServer(listen string, cfg *KcpConfig) *KcpServer {
@@ -41,12 +43,22 @@ func NewKcpServer(listen string, cfg *KcpConfig) *KcpServer {
 }
 
 func (k *KcpServer) Listen() (err error) {
-	k.listener, err = kcp.ListenWithOptions(
-		k.address,
+	addr, err := net.ResolveUDPAddr("udp", k.address)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return err
+	}
+	k.conn = NewRoamListener(conn)
+	k.listener, err = kcp.ServeConn(
 		k.kcpCfg.Block,
 		k.kcpCfg.DataShards,
-		k.kcpCfg.ParityShards)
+		k.kcpCfg.ParityShards,
+		k.conn)
 	if err != nil {
+		_ = k.conn.Close()
 		k.listener = nil
 		return err
 	}
@@ -57,6 +69,7 @@ func (k *KcpServer) Listen() (err error) {
 func (k *KcpServer) Close() {
 	if k.listener != nil {
 		_ = k.listener.Close()
+		_ = k.conn.Close()
 		Info("KcpServer.Close: %s", k.address)
 		k.listener = nil
 	}
@@ -85,7 +98,9 @@ func (k *KcpServer) Accept() {
 		conn.SetStreamMode(true)
 		conn.SetWriteDelay(false)
 		conn.SetACKNoDelay(false)
-		k.onClients <- NewKcpClientFromConn(conn, k.kcpCfg)
+		c := NewKcpClientFromConn(conn, k.kcpCfg)
+		c.roam = k.conn
+		k.onClients <- c
 	}
 }
 
@@ -94,6 +109,7 @@ func (k *KcpServer) Accept() {
 type KcpClient struct {
 	*SocketClientImpl
 	kcpCfg *KcpConfig
+	roam   *RoamListener // of server.
 }
 
 func NewKcpClient(addr string, cfg *KcpConfig) *KcpClient {
@@ -130,18 +146,31 @@ func (c *KcpClient) Connect() error {
 		return nil
 	}
 	c.out.Info("KcpClient.Connect: kcp://%s", c.address)
-	conn, err := kcp.DialWithOptions(
-		c.address,
+	addr, err := net.ResolveUDPAddr("udp", c.address)
+	if err != nil {
+		return err
+	}
+	local := LocalUDPAddr(c.kcpCfg.Local)
+	udpConn, err := net.DialUDP("udp", local, addr)
+	if err != nil {
+		return err
+	}
+	roam := NewRoamConn(udpConn)
+	roam.local = local
+	conn, err := kcp.NewConn2(
+		addr,
 		c.kcpCfg.Block,
 		c.kcpCfg.DataShards,
-		c.kcpCfg.DataShards)
+		c.kcpCfg.DataShards,
+		roam)
 	if err != nil {
+		_ = roam.Close()
 		return err
 	}
 	conn.SetStreamMode(true)
 	conn.SetWriteDelay(false)
 	conn.SetACKNoDelay(false)
-	c.SetConnection(conn)
+	c.SetConnection(&kcpConn{UDPSession: conn, roam: roam})
 	if c.listener.OnConnected != nil {
 		_ = c.listener.OnConnected(c)
 	}
@@ -182,3 +211,46 @@ func (c *KcpClient) SetStatus(v SocketStatus) {
 		c.status = v
 	}
 }
+
+func (c *KcpClient) RoamOffer() *RoamHello {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if conn, ok := c.connection.(*kcpConn); ok {
+		return conn.roam.Offer()
+	}
+	return nil
+}
+
+func (c *KcpClient) RoamAccept(answer *RoamHello) error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if conn, ok := c.connection.(*kcpConn); ok {
+		return conn.roam.Accept(answer)
+	}
+	return NewErr("operation notSupport")
+}
+
+func (c *KcpClient) RoamAnswer(offer *RoamHello) (*RoamHello, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.roam == nil || c.connection == nil {
+		return nil, NewErr("operation notSupport")
+	}
+	addr, ok := c.connection.RemoteAddr().(*net.UDPAddr)
+	if !ok {
+		return nil, NewErr("operation notSupport")
+	}
+	return c.roam.Answer(addr, offer)
+}
+
+// kcpConn closes udp connection with session, which's not owned by kcp.
+type kcpConn struct {
+	*kcp.UDPSession
+	roam *RoamConn
+}
+
+func (c *kcpConn) Close() error {
+	err := c.UDPSession.Close()
+	_ = c.roam.Close()
+	return err
+}
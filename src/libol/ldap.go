@@ -2,69 +2,330 @@ package libol
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"github.com/go-ldap/ldap"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"time"
 )
 
+type LDAPGroup struct {
+	Name     string   // DN or common name of group.
+	Networks []string // networks allowed to join, and * is any.
+	Role     string   // admin or guest.
+}
+
 type LDAPConfig struct {
-	Server    string
-	BindDN    string
-	Password  string
-	BaseDN    string
-	Attr      string
-	Filter    string
-	EnableTls bool
-	Timeout   int64
+	Server      string
+	BindDN      string
+	Password    string
+	BaseDN      string
+	Attr        string
+	Filter      string
+	EnableTls   bool
+	CaFile      string
+	Insecure    bool
+	Timeout     int64
+	PoolSize    int
+	GroupBase   string // search groups from here, otherwise by memberOf.
+	GroupFilter string // likes (member=%s) and formatted by user's DN.
+	Groups      []LDAPGroup
+}
+
+// Authorize returns role of the groups in the network. It's denied if no
+// group matched, and the group * matches any user.
+func (c *LDAPConfig) Authorize(groups []string, network string) (string, bool) {
+	role := ""
+	allowed := false
+	for _, g := range c.Groups {
+		if !hasGroup(groups, g.Name) || !hasNetwork(g.Networks, network) {
+			continue
+		}
+		allowed = true
+		if role != "admin" {
+			role = g.Role
+		}
+	}
+	return role, allowed
+}
+
+func groupCN(dn string) string {
+	rdn := strings.SplitN(dn, ",", 2)[0]
+	if values := strings.SplitN(rdn, "=", 2); len(values) == 2 {
+		return values[1]
+	}
+	return rdn
+}
+
+func hasGroup(groups []string, name string) bool {
+	if name == "*" {
+		return true
+	}
+	for _, g := range groups {
+		if strings.EqualFold(g, name) || strings.EqualFold(groupCN(g), name) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasNetwork(networks []string, name string) bool {
+	for _, n := range networks {
+		if n == "*" || n == name {
+			return true
+		}
+	}
+	return false
+}
+
+type LDAPUser struct {
+	DN     string
+	Groups []string
 }
 
 type LDAPService struct {
-	Conn *ldap.Conn
-	Cfg  LDAPConfig
+	Cfg   LDAPConfig
+	lock  sync.Mutex
+	idle  chan *ldap.Conn
+	count int
 }
 
 func NewLDAPService(cfg LDAPConfig) (*LDAPService, error) {
-	conn, err := ldap.Dial("tcp", cfg.Server)
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 8 * 3600
+	}
+	if cfg.PoolSize == 0 {
+		cfg.PoolSize = 4
+	}
+	if cfg.GroupFilter == "" {
+		cfg.GroupFilter = "(member=%s)"
+	}
+	l := &LDAPService{
+		Cfg:  cfg,
+		idle: make(chan *ldap.Conn, cfg.PoolSize),
+	}
+	// open a connection to check configuration.
+	conn, err := l.get()
 	if err != nil {
 		return nil, err
 	}
-	if cfg.EnableTls {
-		err = conn.StartTLS(&tls.Config{InsecureSkipVerify: true})
+	l.put(conn, nil)
+	return l, nil
+}
+
+// Address returns address of server and whether it's ldaps.
+func (l *LDAPService) Address() (string, bool) {
+	if strings.HasPrefix(l.Cfg.Server, "ldaps://") {
+		return strings.TrimPrefix(l.Cfg.Server, "ldaps://"), true
+	}
+	return strings.TrimPrefix(l.Cfg.Server, "ldap://"), false
+}
+
+func (l *LDAPService) TlsConfig() (*tls.Config, error) {
+	addr, _ := l.Address()
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	config := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: l.Cfg.Insecure,
+	}
+	if l.Cfg.CaFile != "" {
+		caCert, err := ioutil.ReadFile(l.Cfg.CaFile)
 		if err != nil {
 			return nil, err
 		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, NewErr("invalid ca %s", l.Cfg.CaFile)
+		}
+		config.RootCAs = pool
 	}
-	if err = conn.Bind(cfg.BindDN, cfg.Password); err != nil {
+	return config, nil
+}
+
+func (l *LDAPService) dial() (*ldap.Conn, error) {
+	config, err := l.TlsConfig()
+	if err != nil {
 		return nil, err
 	}
-	if cfg.Timeout == 0 {
-		cfg.Timeout = 8 * 3600
+	var conn *ldap.Conn
+	if addr, secure := l.Address(); secure {
+		conn, err = ldap.DialTLS("tcp", addr, config)
+	} else {
+		conn, err = ldap.Dial("tcp", addr)
+		if err == nil && l.Cfg.EnableTls {
+			if err = conn.StartTLS(config); err != nil {
+				conn.Close()
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(10 * time.Second)
+	if err = conn.Bind(l.Cfg.BindDN, l.Cfg.Password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// get a healthy connection from pool, or open new one if pool is not full.
+func (l *LDAPService) get() (*ldap.Conn, error) {
+	for {
+		select {
+		case conn := <-l.idle:
+			if !conn.IsClosing() {
+				return conn, nil
+			}
+			l.drop(nil)
+			continue
+		default:
+		}
+		l.lock.Lock()
+		if l.count < l.Cfg.PoolSize {
+			l.count++
+			l.lock.Unlock()
+			conn, err := l.dial()
+			if err != nil {
+				l.drop(nil)
+				return nil, err
+			}
+			return conn, nil
+		}
+		l.lock.Unlock()
+		select {
+		case conn := <-l.idle:
+			if !conn.IsClosing() {
+				return conn, nil
+			}
+			l.drop(nil)
+		case <-time.After(10 * time.Second):
+			return nil, NewErr("no idle connection")
+		}
+	}
+}
+
+func (l *LDAPService) drop(conn *ldap.Conn) {
+	if conn != nil {
+		conn.Close()
+	}
+	l.lock.Lock()
+	l.count--
+	l.lock.Unlock()
+}
+
+// put connection back to pool, and drop it if network error.
+func (l *LDAPService) put(conn *ldap.Conn, err error) {
+	if conn.IsClosing() || ldap.IsErrorWithCode(err, ldap.ErrorNetwork) {
+		l.drop(conn)
+		return
+	}
+	l.idle <- conn
+}
+
+// Stats returns number of opened and idle connections.
+func (l *LDAPService) Stats() (int, int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.count, len(l.idle)
+}
+
+func (l *LDAPService) Close() {
+	for {
+		select {
+		case conn := <-l.idle:
+			l.drop(conn)
+		default:
+			return
+		}
+	}
+}
+
+// Login search user's DN and groups, and bind by it to check password.
+func (l *LDAPService) Login(userName, password string) (*LDAPUser, error) {
+	if password == "" {
+		return nil, NewErr("empty password")
+	}
+	user, err := l.login(userName, password)
+	if err != nil && ldap.IsErrorWithCode(err, ldap.ErrorNetwork) {
+		// try again by a new connection.
+		user, err = l.login(userName, password)
 	}
-	return &LDAPService{Conn: conn, Cfg: cfg}, nil
+	return user, err
 }
 
-func (l *LDAPService) Login(userName, password string) (bool, error) {
+func (l *LDAPService) login(userName, password string) (*LDAPUser, error) {
+	conn, err := l.get()
+	if err != nil {
+		return nil, err
+	}
+	user, err := l.search(conn, userName)
+	if err != nil {
+		l.put(conn, err)
+		return nil, err
+	}
+	err = conn.Bind(user.DN, password)
+	if e := conn.Bind(l.Cfg.BindDN, l.Cfg.Password); e != nil {
+		// maybe still bound as the user, so it's not back to pool.
+		l.drop(conn)
+		if err == nil {
+			err = e
+		}
+		return nil, err
+	}
+	l.put(conn, err)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (l *LDAPService) search(conn *ldap.Conn, userName string) (*LDAPUser, error) {
 	request := ldap.NewSearchRequest(
 		l.Cfg.BaseDN,
 		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
 		0, 0, false,
-		fmt.Sprintf(l.Cfg.Filter, userName),
-		[]string{l.Cfg.Attr},
+		fmt.Sprintf(l.Cfg.Filter, ldap.EscapeFilter(userName)),
+		[]string{l.Cfg.Attr, "memberOf"},
 		nil,
 	)
-	Debug("LDAPService.Login %v", request)
-	result, err := l.Conn.Search(request)
+	Debug("LDAPService.search %v", request)
+	result, err := conn.Search(request)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	if len(result.Entries) != 1 {
-		return false, fmt.Errorf("invalid users")
+		return nil, fmt.Errorf("invalid users")
 	}
-	userDN := result.Entries[0].DN
-	if err = l.Conn.Bind(userDN, password); err != nil {
-		return false, err
+	entry := result.Entries[0]
+	user := &LDAPUser{
+		DN:     entry.DN,
+		Groups: entry.GetAttributeValues("memberOf"),
+	}
+	if l.Cfg.GroupBase == "" {
+		return user, nil
+	}
+	request = ldap.NewSearchRequest(
+		l.Cfg.GroupBase,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		0, 0, false,
+		fmt.Sprintf(l.Cfg.GroupFilter, ldap.EscapeFilter(user.DN)),
+		[]string{"cn"},
+		nil,
+	)
+	result, err = conn.Search(request)
+	if err != nil {
+		return nil, err
 	}
-	if err = l.Conn.Bind(l.Cfg.BindDN, l.Cfg.Password); err != nil {
-		return false, nil
+	user.Groups = make([]string, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		user.Groups = append(user.Groups, entry.DN)
 	}
-	return true, nil
+	return user, nil
 }
@@ -0,0 +1,36 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLDAPConfig_Authorize(t *testing.T) {
+	c := &LDAPConfig{}
+	role, ok := c.Authorize(nil, "example")
+	assert.False(t, ok, "denied without groups.")
+	assert.Equal(t, "", role, "be the same.")
+
+	c.Groups = []LDAPGroup{
+		{Name: "vpn-users", Networks: []string{"example"}, Role: "guest"},
+		{Name: "cn=ops,ou=groups,dc=example,dc=com", Networks: []string{"*"}, Role: "admin"},
+	}
+	groups := []string{"cn=VPN-Users,ou=groups,dc=example,dc=com"}
+	role, ok = c.Authorize(groups, "example")
+	assert.True(t, ok, "allowed by cn.")
+	assert.Equal(t, "guest", role, "be the same.")
+	_, ok = c.Authorize(groups, "other")
+	assert.False(t, ok, "not allowed.")
+
+	groups = append(groups, "cn=ops,ou=groups,dc=example,dc=com")
+	role, ok = c.Authorize(groups, "example")
+	assert.True(t, ok, "allowed by dn.")
+	assert.Equal(t, "admin", role, "be the same.")
+
+	c.Groups = []LDAPGroup{{Name: "*", Networks: []string{"example"}}}
+	role, ok = c.Authorize(nil, "example")
+	assert.True(t, ok, "allowed by *.")
+	assert.Equal(t, "", role, "be the same.")
+	_, ok = c.Authorize(nil, "other")
+	assert.False(t, ok, "not allowed.")
+}
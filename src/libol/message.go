@@ -102,25 +102,47 @@ type FrameMessage struct {
 	total   int
 	frame   []byte
 	proto   *FrameProto
+	pool    *BufferPool
 }
 
+// NewFrameMessage returns frame with buffer of maxSize, and the buffer is
+// from pool if the size is MaxBuf or MaxMsg, which should be freed after
+// the frame is written.
 func NewFrameMessage(maxSize int) *FrameMessage {
 	if maxSize <= 0 {
 		maxSize = MaxBuf
 	}
-	maxSize += HlSize + EthDI
-	if HasLog(DEBUG) {
-		Debug("NewFrameMessage: size %d", maxSize)
-	}
 	m := FrameMessage{
 		params: make([]byte, 0, 2),
-		buffer: make([]byte, maxSize),
+	}
+	if pool, ok := framePools[maxSize]; ok {
+		m.pool = pool
+		m.buffer = pool.Get()
+	} else {
+		maxSize += HlSize + EthDI
+		if HasLog(DEBUG) {
+			Debug("NewFrameMessage: size %d", maxSize)
+		}
+		m.buffer = make([]byte, maxSize)
 	}
 	m.frame = m.buffer[HlSize:]
 	m.total = len(m.frame)
 	return &m
 }
 
+// Free puts buffer back to pool, and the frame must not be used anymore.
+func (m *FrameMessage) Free() {
+	if m.pool == nil {
+		return
+	}
+	m.pool.Put(m.buffer)
+	m.pool = nil
+	m.buffer = nil
+	m.frame = nil
+	m.params = nil
+	m.proto = nil
+}
+
 func NewFrameMessageFromBytes(buffer []byte) *FrameMessage {
 	m := FrameMessage{
 		params: make([]byte, 0, 2),
@@ -236,6 +258,7 @@ type Messager interface {
 	Send(conn net.Conn, frame *FrameMessage) (int, error)
 	Receive(conn net.Conn, max, min int) (*FrameMessage, error)
 	Flush()
+	Mtu() int
 }
 
 type StreamMessagerImpl struct {
@@ -249,6 +272,10 @@ func (s *StreamMessagerImpl) Flush() {
 	s.buffer = nil
 }
 
+func (s *StreamMessagerImpl) Mtu() int {
+	return 0
+}
+
 func (s *StreamMessagerImpl) write(conn net.Conn, tmp []byte) (int, error) {
 	if s.timeout != 0 {
 		err := conn.SetWriteDeadline(time.Now().Add(s.timeout))
@@ -335,12 +362,10 @@ func (s *StreamMessagerImpl) readX(conn net.Conn, buf []byte) error {
 		Log("StreamMessagerImpl.readX: %s %d", conn.RemoteAddr(), len(buf))
 	}
 	for left > 0 {
-		tmp := make([]byte, left)
-		n, err := s.read(conn, tmp)
+		n, err := s.read(conn, buf[offset:])
 		if err != nil {
 			return err
 		}
-		copy(buf[offset:], tmp)
 		offset += n
 		left -= n
 	}
@@ -412,12 +437,85 @@ type PacketMessagerImpl struct {
 	timeout time.Duration // ns for read and write deadline
 	block   kcp.BlockCrypt
 	bufSize int // default is (1518 + 20+20+14) * 8
+	pmtu    *Pmtu
+	reasm   *Reassembler
 }
 
 func (s *PacketMessagerImpl) Flush() {
 	//TODO
 }
 
+func (s *PacketMessagerImpl) Mtu() int {
+	if s.pmtu == nil {
+		return 0
+	}
+	return s.pmtu.Mtu()
+}
+
+// write sends datagram in fragments if it's larger than mtu of path, and
+// sends a probe before if it's due.
+func (s *PacketMessagerImpl) write(conn net.Conn, data []byte) error {
+	if s.pmtu == nil {
+		_, err := conn.Write(data)
+		return err
+	}
+	if df, ok := conn.(interface{ DontFragment(on bool) }); ok {
+		df.DontFragment(s.pmtu.DontFragment())
+	}
+	if probe := s.pmtu.Next(time.Now()); probe != nil {
+		if _, err := conn.Write(probe); err != nil {
+			Debug("PacketMessagerImpl.write: probe %s", err)
+		}
+	}
+	mtu := s.pmtu.Mtu()
+	if mtu == 0 || len(data) <= mtu {
+		_, err := conn.Write(data)
+		return err
+	}
+	for _, frag := range Fragment(s.pmtu.NextId(), data, mtu) {
+		if _, err := conn.Write(frag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read returns a datagram, and handles probes and fragments.
+func (s *PacketMessagerImpl) read(conn net.Conn, buffer []byte) (int, error) {
+	for {
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return 0, err
+		}
+		data := buffer[:n]
+		switch {
+		case isMagic(data, ProbeMagic) && n > 4:
+			if _, err := conn.Write(NewProbeAck(data)); err != nil {
+				Debug("PacketMessagerImpl.read: ack %s", err)
+			}
+			if s.pmtu != nil {
+				s.pmtu.Learn(n, time.Now())
+			}
+		case isMagic(data, ProbeAckMagic):
+			if s.pmtu != nil {
+				s.pmtu.Ack(data)
+			}
+		case isMagic(data, FragMagic):
+			if s.reasm == nil {
+				continue
+			}
+			if tmp := s.reasm.Add(data); tmp != nil {
+				if len(tmp) > len(buffer) {
+					return 0, NewErr("%s: large datagram %d", conn.RemoteAddr(), len(tmp))
+				}
+				return copy(buffer, tmp), nil
+			}
+		default:
+			return n, nil
+		}
+	}
+}
+
 func (s *PacketMessagerImpl) Send(conn net.Conn, frame *FrameMessage) (int, error) {
 	frame.buffer[0] = MAGIC[0]
 	frame.buffer[1] = MAGIC[1]
@@ -434,7 +532,7 @@ func (s *PacketMessagerImpl) Send(conn net.Conn, frame *FrameMessage) (int, erro
 			return 0, err
 		}
 	}
-	if _, err := conn.Write(frame.buffer[:HlSize+frame.size]); err != nil {
+	if err := s.write(conn, frame.buffer[:HlSize+frame.size]); err != nil {
 		return 0, err
 	}
 	return frame.size, nil
@@ -454,7 +552,7 @@ func (s *PacketMessagerImpl) Receive(conn net.Conn, max, min int) (*FrameMessage
 			return nil, err
 		}
 	}
-	n, err := conn.Read(frame.buffer)
+	n, err := s.read(conn, frame.buffer)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,135 @@
+package libol
+
+import (
+	"crypto/des"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"math/bits"
+	"strings"
+	"unicode/utf16"
+)
+
+// MD4 returns digest of data by RFC 1320, which is only used by MS-CHAP.
+func MD4(data []byte) []byte {
+	a, b, c, d := uint32(0x67452301), uint32(0xefcdab89), uint32(0x98badcfe), uint32(0x10325476)
+	size := uint64(len(data)) << 3
+	msg := append([]byte{}, data...)
+	msg = append(msg, 0x80)
+	for len(msg)%64 != 56 {
+		msg = append(msg, 0)
+	}
+	tail := make([]byte, 8)
+	binary.LittleEndian.PutUint64(tail, size)
+	msg = append(msg, tail...)
+
+	f := func(x, y, z uint32) uint32 { return (x & y) | (^x & z) }
+	g := func(x, y, z uint32) uint32 { return (x & y) | (x & z) | (y & z) }
+	h := func(x, y, z uint32) uint32 { return x ^ y ^ z }
+	r2 := []int{0, 4, 8, 12, 1, 5, 9, 13, 2, 6, 10, 14, 3, 7, 11, 15}
+	r3 := []int{0, 8, 4, 12, 2, 10, 6, 14, 1, 9, 5, 13, 3, 11, 7, 15}
+	s1 := []int{3, 7, 11, 19}
+	s2 := []int{3, 5, 9, 13}
+	s3 := []int{3, 9, 11, 15}
+
+	x := make([]uint32, 16)
+	for i := 0; i < len(msg); i += 64 {
+		for j := range x {
+			x[j] = binary.LittleEndian.Uint32(msg[i+j*4:])
+		}
+		aa, bb, cc, dd := a, b, c, d
+		for j := 0; j < 16; j++ {
+			v := bits.RotateLeft32(a+f(b, c, d)+x[j], s1[j%4])
+			a, b, c, d = d, v, b, c
+		}
+		for j := 0; j < 16; j++ {
+			v := bits.RotateLeft32(a+g(b, c, d)+x[r2[j]]+0x5a827999, s2[j%4])
+			a, b, c, d = d, v, b, c
+		}
+		for j := 0; j < 16; j++ {
+			v := bits.RotateLeft32(a+h(b, c, d)+x[r3[j]]+0x6ed9eba1, s3[j%4])
+			a, b, c, d = d, v, b, c
+		}
+		a, b, c, d = a+aa, b+bb, c+cc, d+dd
+	}
+	sum := make([]byte, 16)
+	binary.LittleEndian.PutUint32(sum[0:], a)
+	binary.LittleEndian.PutUint32(sum[4:], b)
+	binary.LittleEndian.PutUint32(sum[8:], c)
+	binary.LittleEndian.PutUint32(sum[12:], d)
+	return sum
+}
+
+// NtPasswordHash by RFC 2759 section 8.3.
+func NtPasswordHash(password string) []byte {
+	codes := utf16.Encode([]rune(password))
+	data := make([]byte, len(codes)*2)
+	for i, v := range codes {
+		binary.LittleEndian.PutUint16(data[i*2:], v)
+	}
+	return MD4(data)
+}
+
+// ChallengeHash by RFC 2759 section 8.2, and username is without domain.
+func ChallengeHash(peer, auth []byte, username string) []byte {
+	if i := strings.LastIndex(username, "\\"); i >= 0 {
+		username = username[i+1:]
+	}
+	h := sha1.New()
+	h.Write(peer)
+	h.Write(auth)
+	h.Write([]byte(username))
+	return h.Sum(nil)[:8]
+}
+
+func desKey(in []byte) []byte {
+	key := []byte{
+		in[0] >> 1,
+		(in[0]&0x01)<<6 | in[1]>>2,
+		(in[1]&0x03)<<5 | in[2]>>3,
+		(in[2]&0x07)<<4 | in[3]>>4,
+		(in[3]&0x0f)<<3 | in[4]>>5,
+		(in[4]&0x1f)<<2 | in[5]>>6,
+		(in[5]&0x3f)<<1 | in[6]>>7,
+		in[6] & 0x7f,
+	}
+	for i := range key {
+		key[i] <<= 1
+	}
+	return key
+}
+
+// AuthenticatorResponse by RFC 2759 section 8.7, and it's formatted
+// likes S=<40 hex>.
+func AuthenticatorResponse(auth, peer []byte, username, password string, ntResponse []byte) string {
+	magic1 := []byte("Magic server to client signing constant")
+	magic2 := []byte("Pad to make it do more than one iteration")
+	h := sha1.New()
+	h.Write(MD4(NtPasswordHash(password)))
+	h.Write(ntResponse)
+	h.Write(magic1)
+	digest := h.Sum(nil)
+	h = sha1.New()
+	h.Write(digest)
+	h.Write(ChallengeHash(peer, auth, username))
+	h.Write(magic2)
+	return "S=" + strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+}
+
+// ChallengeResponse by RFC 2759 section 8.5.
+func ChallengeResponse(challenge, hash []byte) []byte {
+	zHash := make([]byte, 21)
+	copy(zHash, hash)
+	resp := make([]byte, 24)
+	for i := 0; i < 3; i++ {
+		block, _ := des.NewCipher(desKey(zHash[i*7 : i*7+7]))
+		block.Encrypt(resp[i*8:], challenge)
+	}
+	return resp
+}
+
+// NtResponse by RFC 2759 section 8.1.
+func NtResponse(auth, peer []byte, username, password string) []byte {
+	challenge := ChallengeHash(peer, auth, username)
+	return ChallengeResponse(challenge, NtPasswordHash(password))
+}
@@ -0,0 +1,131 @@
+package libol
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	PassHashPrefix = "$argon2id$"
+	PassHashTime   = 2
+	PassHashMemory = 19 * 1024 // KiB
+	PassHashThread = 1
+	PassSaltSize   = 16
+	PassKeySize    = 32
+	PassOldPrefix  = "$pbkdf2-sha256$" // only checked for hashes saved before.
+)
+
+var b64 = base64.RawStdEncoding
+
+// IsHashed returns whether password is a hash likes
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>.
+func IsHashed(password string) bool {
+	return strings.HasPrefix(password, PassHashPrefix) || strings.HasPrefix(password, PassOldPrefix)
+}
+
+// HashPassword returns salted argon2id hash of password.
+func HashPassword(password string) string {
+	salt := make([]byte, PassSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		Warn("HashPassword %s", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, PassHashTime, PassHashMemory, PassHashThread, PassKeySize)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", PassHashPrefix, argon2.Version,
+		PassHashMemory, PassHashTime, PassHashThread, b64.EncodeToString(salt), b64.EncodeToString(key))
+}
+
+// CheckPassword compares password with the hashed or plain one in
+// constant time.
+func CheckPassword(hashed, password string) bool {
+	if strings.HasPrefix(hashed, PassOldPrefix) {
+		return checkPbkdf2(hashed, password)
+	}
+	if !IsHashed(hashed) {
+		return subtle.ConstantTimeCompare([]byte(hashed), []byte(password)) == 1
+	}
+	values := strings.Split(hashed[len(PassHashPrefix):], "$")
+	if len(values) != 4 {
+		return false
+	}
+	var version, rounds, threads int
+	var memory uint32
+	if _, err := fmt.Sscanf(values[0], "v=%d", &version); err != nil || version != argon2.Version {
+		return false
+	}
+	if _, err := fmt.Sscanf(values[1], "m=%d,t=%d,p=%d", &memory, &rounds, &threads); err != nil {
+		return false
+	}
+	if rounds <= 0 || threads <= 0 || threads > 255 {
+		return false
+	}
+	salt, err := b64.DecodeString(values[2])
+	if err != nil {
+		return false
+	}
+	key, err := b64.DecodeString(values[3])
+	if err != nil || len(key) == 0 {
+		return false
+	}
+	newKey := argon2.IDKey([]byte(password), salt, uint32(rounds), memory, uint8(threads), uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, newKey) == 1
+}
+
+// checkPbkdf2 compares password with hash likes
+// $pbkdf2-sha256$<iterations>$<salt>$<key>.
+func checkPbkdf2(hashed, password string) bool {
+	values := strings.Split(hashed[len(PassOldPrefix):], "$")
+	if len(values) != 3 {
+		return false
+	}
+	iter, err := strconv.Atoi(values[0])
+	if err != nil || iter <= 0 {
+		return false
+	}
+	salt, err := b64.DecodeString(values[1])
+	if err != nil {
+		return false
+	}
+	key, err := b64.DecodeString(values[2])
+	if err != nil {
+		return false
+	}
+	newKey := pbkdf2.Key([]byte(password), salt, iter, len(key), sha256.New)
+	return subtle.ConstantTimeCompare(key, newKey) == 1
+}
+
+type PassPolicy struct {
+	MinLength int // minimum length of password.
+	Classes   int // kinds of lower, upper, digit and symbol at least.
+	MaxAge    int // days the password is expired after set.
+}
+
+func (p *PassPolicy) Check(password string) error {
+	if len(password) < p.MinLength {
+		return NewErr("password is shorter than %d", p.MinLength)
+	}
+	var lower, upper, digit, symbol int
+	for _, c := range password {
+		switch {
+		case unicode.IsLower(c):
+			lower = 1
+		case unicode.IsUpper(c):
+			upper = 1
+		case unicode.IsDigit(c):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	if lower+upper+digit+symbol < p.Classes {
+		return NewErr("password needs %d kinds of lower, upper, digit and symbol", p.Classes)
+	}
+	return nil
+}
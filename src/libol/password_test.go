@@ -0,0 +1,35 @@
+package libol
+
+import (
+	"crypto/sha256"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/pbkdf2"
+	"strings"
+	"testing"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash := HashPassword("hi:123")
+	assert.True(t, IsHashed(hash), "be hashed.")
+	assert.False(t, strings.Contains(hash, ":"), "no colon.")
+	assert.NotEqual(t, hash, HashPassword("hi:123"), "be salted.")
+	assert.True(t, CheckPassword(hash, "hi:123"), "be the same.")
+	assert.False(t, CheckPassword(hash, "hi:456"), "not the same.")
+	assert.True(t, CheckPassword("plain", "plain"), "be the same.")
+	assert.False(t, CheckPassword("plain", "plai"), "not the same.")
+	assert.False(t, CheckPassword(PassHashPrefix+"x$y", "plain"), "invalid hash.")
+	assert.False(t, CheckPassword(PassOldPrefix+"x$y", "plain"), "invalid hash.")
+	// hash of pbkdf2 saved before is still checked.
+	old := PassOldPrefix + "1000$c2FsdA$" + b64.EncodeToString(pbkdf2.Key([]byte("hi:123"), []byte("salt"), 1000, 32, sha256.New))
+	assert.True(t, IsHashed(old), "be hashed.")
+	assert.True(t, CheckPassword(old, "hi:123"), "be the same.")
+	assert.False(t, CheckPassword(old, "hi:456"), "not the same.")
+}
+
+func TestPassPolicy_Check(t *testing.T) {
+	p := &PassPolicy{MinLength: 8, Classes: 3}
+	assert.NotNil(t, p.Check("aB1"), "too short.")
+	assert.NotNil(t, p.Check("abcdefgh1"), "too simple.")
+	assert.Nil(t, p.Check("abcdefG1"), "be valid.")
+	assert.Nil(t, p.Check("abcdefg#1"), "be valid.")
+}
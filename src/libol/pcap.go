@@ -0,0 +1,326 @@
+package libol
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	PcapSnapLen  = 65535
+	pcapShbType  = 0x0A0D0D0A
+	pcapIdbType  = 0x00000001
+	pcapEpbType  = 0x00000006
+	pcapMagic    = 0x1A2B3C4D
+	pcapLinkEth  = 1
+	pcapTsResol  = 9 // if_tsresol option
+	pcapTsMicros = 6
+)
+
+// PcapWriter writes ethernet frames as pcapng format.
+type PcapWriter struct {
+	out io.Writer
+}
+
+func pcapPad(size int) int {
+	return (4 - size%4) % 4
+}
+
+func (p *PcapWriter) block(typ uint32, body []byte) error {
+	size := 12 + len(body)
+	buf := make([]byte, size)
+	binary.LittleEndian.PutUint32(buf[0:4], typ)
+	binary.LittleEndian.PutUint32(buf[4:8], uint32(size))
+	copy(buf[8:], body)
+	binary.LittleEndian.PutUint32(buf[size-4:], uint32(size))
+	_, err := p.out.Write(buf)
+	return err
+}
+
+// NewPcapWriter writes section header and ethernet interface to w.
+func NewPcapWriter(w io.Writer, name string) (*PcapWriter, error) {
+	p := &PcapWriter{out: w}
+	shb := make([]byte, 16)
+	binary.LittleEndian.PutUint32(shb[0:4], pcapMagic)
+	binary.LittleEndian.PutUint16(shb[4:6], 1) // major
+	binary.LittleEndian.PutUint16(shb[6:8], 0) // minor
+	binary.LittleEndian.PutUint64(shb[8:16], 0xFFFFFFFFFFFFFFFF)
+	if err := p.block(pcapShbType, shb); err != nil {
+		return nil, err
+	}
+	idb := make([]byte, 8)
+	binary.LittleEndian.PutUint16(idb[0:2], pcapLinkEth)
+	binary.LittleEndian.PutUint32(idb[4:8], PcapSnapLen)
+	// if_name option
+	if name != "" {
+		opt := make([]byte, 4+len(name)+pcapPad(len(name)))
+		binary.LittleEndian.PutUint16(opt[0:2], 2)
+		binary.LittleEndian.PutUint16(opt[2:4], uint16(len(name)))
+		copy(opt[4:], name)
+		idb = append(idb, opt...)
+	}
+	tsresol := []byte{pcapTsResol, 0, 1, 0, pcapTsMicros, 0, 0, 0}
+	idb = append(idb, tsresol...)
+	idb = append(idb, 0, 0, 0, 0) // opt_endofopt
+	if err := p.block(pcapIdbType, idb); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Write writes a frame captured at time.
+func (p *PcapWriter) Write(at time.Time, frame []byte) error {
+	size := len(frame)
+	if size > PcapSnapLen {
+		frame = frame[:PcapSnapLen]
+	}
+	body := make([]byte, 20+len(frame)+pcapPad(len(frame)))
+	ts := uint64(at.UnixNano() / 1000)
+	binary.LittleEndian.PutUint32(body[0:4], 0) // interface id
+	binary.LittleEndian.PutUint32(body[4:8], uint32(ts>>32))
+	binary.LittleEndian.PutUint32(body[8:12], uint32(ts))
+	binary.LittleEndian.PutUint32(body[12:16], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(body[16:20], uint32(size))
+	copy(body[20:], frame)
+	return p.block(pcapEpbType, body)
+}
+
+// pcapFrame is headers decoded from ethernet frame to match.
+type pcapFrame struct {
+	src, dst     net.IP
+	proto        string // ip, ip6 or arp
+	l4           string // tcp, udp or icmp
+	sport, dport int
+	ethSrc       net.HardwareAddr
+	ethDst       net.HardwareAddr
+	vlan         bool
+}
+
+func decodeFrame(frame []byte) *pcapFrame {
+	f := &pcapFrame{}
+	if len(frame) < EtherLen {
+		return f
+	}
+	f.ethDst = frame[0:6]
+	f.ethSrc = frame[6:12]
+	typ := binary.BigEndian.Uint16(frame[12:14])
+	data := frame[EtherLen:]
+	if typ == EthVlan && len(data) >= VlanLen {
+		f.vlan = true
+		typ = binary.BigEndian.Uint16(data[2:4])
+		data = data[VlanLen:]
+	}
+	switch typ {
+	case EthArp:
+		f.proto = "arp"
+		if len(data) >= 28 {
+			f.src = data[14:18]
+			f.dst = data[24:28]
+		}
+	case EthIp4:
+		f.proto = "ip"
+		if len(data) < Ipv4Len {
+			return f
+		}
+		f.src = data[12:16]
+		f.dst = data[16:20]
+		size := int(data[0]&0x0f) * 4
+		if size < Ipv4Len || size > len(data) {
+			return f
+		}
+		frag := binary.BigEndian.Uint16(data[6:8]) & 0x1fff
+		f.decodeL4(data[9], data[size:], frag == 0)
+	case EthIp6:
+		f.proto = "ip6"
+		if len(data) < 40 {
+			return f
+		}
+		f.src = data[8:24]
+		f.dst = data[24:40]
+		f.decodeL4(data[6], data[40:], true)
+	}
+	return f
+}
+
+func (f *pcapFrame) decodeL4(proto byte, data []byte, first bool) {
+	switch proto {
+	case IpTcp:
+		f.l4 = "tcp"
+	case IpUdp:
+		f.l4 = "udp"
+	case IpIcmp, 58:
+		f.l4 = "icmp"
+		return
+	default:
+		return
+	}
+	if first && len(data) >= 4 {
+		f.sport = int(binary.BigEndian.Uint16(data[0:2]))
+		f.dport = int(binary.BigEndian.Uint16(data[2:4]))
+	}
+}
+
+type pcapMatch func(f *pcapFrame) bool
+
+// Filter is a simple BPF-like expression, likes 'udp port 53',
+// 'host 192.168.1.1 and not arp' or 'tcp dst port 80 or icmp'. The
+// 'and' binds tighter than 'or'.
+type Filter struct {
+	Expr  string
+	match pcapMatch
+}
+
+func NewFilter(expr string) (*Filter, error) {
+	f := &Filter{Expr: expr}
+	tokens := strings.Fields(strings.ToLower(expr))
+	if len(tokens) == 0 {
+		f.match = func(*pcapFrame) bool { return true }
+		return f, nil
+	}
+	p := &filterParser{tokens: tokens}
+	match, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(tokens) {
+		return nil, NewErr("unexpected %s", tokens[p.pos])
+	}
+	f.match = match
+	return f, nil
+}
+
+func (f *Filter) Match(frame []byte) bool {
+	return f.match(decodeFrame(frame))
+}
+
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *filterParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *filterParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *filterParser) or() (pcapMatch, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(f *pcapFrame) bool { return l(f) || right(f) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) and() (pcapMatch, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t == "" || t == "or" {
+			return left, nil
+		}
+		if t == "and" {
+			p.next()
+		}
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(f *pcapFrame) bool { return l(f) && right(f) }
+	}
+}
+
+func (p *filterParser) not() (pcapMatch, error) {
+	if p.peek() == "not" {
+		p.next()
+		m, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(f *pcapFrame) bool { return !m(f) }, nil
+	}
+	return p.primitive()
+}
+
+func (p *filterParser) primitive() (pcapMatch, error) {
+	dir := ""
+	t := p.next()
+	if t == "src" || t == "dst" {
+		dir = t
+		t = p.next()
+	}
+	switch t {
+	case "ip", "ip6", "arp":
+		return func(f *pcapFrame) bool { return f.proto == t }, nil
+	case "tcp", "udp", "icmp":
+		return func(f *pcapFrame) bool { return f.l4 == t }, nil
+	case "vlan":
+		return func(f *pcapFrame) bool { return f.vlan }, nil
+	case "host":
+		ip := net.ParseIP(p.next())
+		if ip == nil {
+			return nil, NewErr("invalid host")
+		}
+		return func(f *pcapFrame) bool {
+			return (dir != "dst" && ip.Equal(f.src)) || (dir != "src" && ip.Equal(f.dst))
+		}, nil
+	case "net":
+		_, ipNet, err := net.ParseCIDR(p.next())
+		if err != nil {
+			return nil, err
+		}
+		return func(f *pcapFrame) bool {
+			return (dir != "dst" && f.src != nil && ipNet.Contains(f.src)) ||
+				(dir != "src" && f.dst != nil && ipNet.Contains(f.dst))
+		}, nil
+	case "port":
+		port, err := strconv.Atoi(p.next())
+		if err != nil {
+			return nil, NewErr("invalid port")
+		}
+		return func(f *pcapFrame) bool {
+			if f.l4 != "tcp" && f.l4 != "udp" {
+				return false
+			}
+			return (dir != "dst" && f.sport == port) || (dir != "src" && f.dport == port)
+		}, nil
+	case "ether":
+		if p.next() != "host" {
+			return nil, NewErr("need ether host")
+		}
+		hw, err := net.ParseMAC(p.next())
+		if err != nil {
+			return nil, err
+		}
+		return func(f *pcapFrame) bool {
+			return (dir != "dst" && hw.String() == f.ethSrc.String()) ||
+				(dir != "src" && hw.String() == f.ethDst.String())
+		}, nil
+	case "":
+		return nil, NewErr("unexpected end")
+	}
+	return nil, NewErr("unknown %s", t)
+}
@@ -0,0 +1,366 @@
+package libol
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+const (
+	UdpMtu     = 1400 // size of datagram before probed.
+	UdpMinMtu  = 548
+	UdpMaxMtu  = 1446 // 1500 without ip, udp and roaming header.
+	FragHdr    = 8    // magic, id, index, count and size of datagram.
+	PmtuAgain  = 600  // seconds to probe again.
+	PmtuExpire = 5    // seconds to drop uncompleted fragments.
+	PmtuRound  = 60   // seconds between probes of different rounds.
+)
+
+var (
+	FragMagic     = []byte{0xff, 0xfd}
+	ProbeMagic    = []byte{0xff, 0xfc}
+	ProbeAckMagic = []byte{0xff, 0xfb}
+)
+
+func isMagic(data, magic []byte) bool {
+	return len(data) >= 2 && data[0] == magic[0] && data[1] == magic[1]
+}
+
+// Fragment splits datagram into fragments not larger than mtu.
+func Fragment(id uint16, data []byte, mtu int) [][]byte {
+	chunk := mtu - FragHdr
+	count := (len(data) + chunk - 1) / chunk
+	frags := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * chunk
+		if end > len(data) {
+			end = len(data)
+		}
+		frag := make([]byte, FragHdr+end-i*chunk)
+		copy(frag, FragMagic)
+		binary.BigEndian.PutUint16(frag[2:], id)
+		frag[4] = byte(i)
+		frag[5] = byte(count)
+		binary.BigEndian.PutUint16(frag[6:], uint16(len(data)))
+		copy(frag[FragHdr:], data[i*chunk:end])
+		frags = append(frags, frag)
+	}
+	return frags
+}
+
+type fragEntry struct {
+	frags  [][]byte
+	got    int
+	size   int
+	active int64
+}
+
+// Reassembler collects fragments to datagram.
+type Reassembler struct {
+	entries map[uint16]*fragEntry
+	max     int
+}
+
+func NewReassembler(max int) *Reassembler {
+	return &Reassembler{
+		entries: make(map[uint16]*fragEntry, max),
+		max:     max,
+	}
+}
+
+func (r *Reassembler) sweep(now int64) {
+	var oldest *fragEntry
+	var oldId uint16
+	for id, e := range r.entries {
+		if now-e.active > PmtuExpire {
+			delete(r.entries, id)
+			continue
+		}
+		if oldest == nil || e.active < oldest.active {
+			oldest, oldId = e, id
+		}
+	}
+	if len(r.entries) >= r.max && oldest != nil {
+		delete(r.entries, oldId)
+	}
+}
+
+// Add returns the datagram if all fragments received, otherwise nil.
+func (r *Reassembler) Add(frag []byte) []byte {
+	if len(frag) <= FragHdr || !isMagic(frag, FragMagic) {
+		return nil
+	}
+	id := binary.BigEndian.Uint16(frag[2:])
+	idx, count := int(frag[4]), int(frag[5])
+	size := int(binary.BigEndian.Uint16(frag[6:]))
+	if idx >= count {
+		return nil
+	}
+	now := time.Now().Unix()
+	e, ok := r.entries[id]
+	if !ok || len(e.frags) != count || e.size != size {
+		r.sweep(now)
+		e = &fragEntry{frags: make([][]byte, count), size: size}
+		r.entries[id] = e
+	}
+	e.active = now
+	if e.frags[idx] == nil {
+		e.frags[idx] = append([]byte{}, frag[FragHdr:]...)
+		e.got++
+	}
+	if e.got < count {
+		return nil
+	}
+	delete(r.entries, id)
+	data := make([]byte, 0, size)
+	for _, v := range e.frags {
+		data = append(data, v...)
+	}
+	if len(data) != size {
+		return nil
+	}
+	return data
+}
+
+// Pmtu probes maximum size of datagram to path by binary search between
+// lower and upper, and a probe is lost three times lowers upper. The mtu is
+// zero until peer acks, so don't fragment to peer not supports it.
+//
+// A passive one doesn't probe, and learns mtu by probes from peer.
+type Pmtu struct {
+	lock    sync.Mutex
+	mtu     int
+	acked   bool
+	lost    bool // no ack from peer, and datagrams aren't sent by DF.
+	passive bool
+	lower   int
+	upper   int
+	size    int    // size of probing, and zero if not.
+	id      uint16 // of probe.
+	frag    uint16 // id of fragments.
+	tries   int
+	sentAt  time.Time
+	nextAt  time.Time
+	Wait    time.Duration // to wait for ack.
+}
+
+func NewPmtu() *Pmtu {
+	return &Pmtu{
+		lower: UdpMinMtu,
+		upper: UdpMaxMtu,
+		Wait:  time.Second,
+	}
+}
+
+// NewPassivePmtu returns a pmtu learns from probes of peer, likes on a
+// socket shared by peers and it can't set DF for one.
+func NewPassivePmtu() *Pmtu {
+	p := NewPmtu()
+	p.passive = true
+	p.lower = 0
+	return p
+}
+
+func (p *Pmtu) Mtu() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.mtu
+}
+
+// NextId returns id for fragments.
+func (p *Pmtu) NextId() uint16 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.frag++
+	return p.frag
+}
+
+// DontFragment returns whether datagrams are sent by DF, and not if peer
+// doesn't ack probes.
+func (p *Pmtu) DontFragment() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return !p.passive && !p.lost
+}
+
+// Next returns probe to send if due, otherwise nil.
+func (p *Pmtu) Next(now time.Time) []byte {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.passive || now.Before(p.nextAt) {
+		return nil
+	}
+	if p.size > 0 {
+		if now.Sub(p.sentAt) < p.Wait {
+			return nil
+		}
+		p.tries++
+		if p.tries >= 3 {
+			p.upper = p.size - 1
+			p.size = 0
+		}
+	}
+	if p.upper-p.lower <= FragHdr {
+		if !p.acked {
+			Warn("Pmtu.Next: no ack from peer")
+			p.lost = true
+		} else if p.mtu != p.lower {
+			Info("Pmtu.Next: mtu from %d to %d", p.mtu, p.lower)
+			p.mtu = p.lower
+		}
+		p.lower, p.upper = UdpMinMtu, UdpMaxMtu
+		p.nextAt = now.Add(PmtuAgain * time.Second)
+		return nil
+	}
+	if p.size == 0 {
+		p.size = (p.lower + p.upper + 1) / 2
+		p.tries = 0
+		p.id++
+	}
+	p.sentAt = now
+	probe := make([]byte, p.size)
+	copy(probe, ProbeMagic)
+	binary.BigEndian.PutUint16(probe[2:], p.id)
+	return probe
+}
+
+// Ack updates lower by ack of probe.
+func (p *Pmtu) Ack(ack []byte) {
+	if len(ack) < 6 || !isMagic(ack, ProbeAckMagic) {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	id := binary.BigEndian.Uint16(ack[2:])
+	size := int(binary.BigEndian.Uint16(ack[4:]))
+	if p.size == 0 || id != p.id || size != p.size {
+		return
+	}
+	p.lower = size
+	p.size = 0
+	if !p.acked {
+		p.acked = true
+		p.mtu = UdpMtu
+	}
+}
+
+// Learn updates mtu by size of probe from peer if passive. The largest
+// probe of a round is the mtu probed by peer, and it's used after the
+// round but a larger one is used at once.
+func (p *Pmtu) Learn(size int, now time.Time) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !p.passive {
+		return
+	}
+	if now.Sub(p.sentAt) > PmtuRound*time.Second {
+		if p.lower > 0 && p.mtu != p.lower {
+			Info("Pmtu.Learn: mtu from %d to %d", p.mtu, p.lower)
+			p.mtu = p.lower
+		}
+		p.lower = 0
+	}
+	p.sentAt = now
+	if size > p.lower {
+		p.lower = size
+	}
+	if p.lower > p.mtu {
+		p.mtu = p.lower
+	}
+}
+
+// NewProbeAck returns ack of the probe.
+func NewProbeAck(probe []byte) []byte {
+	ack := make([]byte, 6)
+	copy(ack, ProbeAckMagic)
+	copy(ack[2:4], probe[2:4])
+	binary.BigEndian.PutUint16(ack[4:], uint16(len(probe)))
+	return ack
+}
+
+// ClampMss lowers mss option of tcp syn in ethernet frame, so segments of
+// the connection fit in a datagram of mtu. It returns true if changed.
+func ClampMss(frame []byte, mtu int) bool {
+	return ClampMssTo(frame, mtu, 0)
+}
+
+// ClampMssTo lowers mss option by mtu if given, and not more than max if
+// given.
+func ClampMssTo(frame []byte, mtu, max int) bool {
+	if (mtu <= 0 && max <= 0) || len(frame) < EtherLen {
+		return false
+	}
+	offset := EtherLen
+	proto := binary.BigEndian.Uint16(frame[12:14])
+	if proto == EthVlan {
+		if len(frame) < EtherLen+VlanLen {
+			return false
+		}
+		proto = binary.BigEndian.Uint16(frame[16:18])
+		offset += VlanLen
+	}
+	ip := frame[offset:]
+	switch proto {
+	case EthIp4:
+		if len(ip) < Ipv4Len || ip[9] != IpTcp {
+			return false
+		}
+		if binary.BigEndian.Uint16(ip[6:8])&0x1fff != 0 {
+			return false // not the first fragment.
+		}
+		offset += int(ip[0]&0x0f) * 4
+	case EthIp6:
+		if len(ip) < 40 || ip[6] != IpTcp {
+			return false
+		}
+		offset += 40
+	default:
+		return false
+	}
+	if len(frame) < offset+TcpLen {
+		return false
+	}
+	tcp := frame[offset:]
+	if tcp[13]&TcpSyn == 0 {
+		return false
+	}
+	size := int(tcp[12]>>4) * 4
+	if size < TcpLen || len(tcp) < size {
+		return false
+	}
+	mss := max
+	if mtu > 0 {
+		mss = mtu - HlSize - offset - TcpLen
+		if max > 0 && max < mss {
+			mss = max
+		}
+	}
+	for i := TcpLen; i+1 < size; {
+		kind, length := tcp[i], int(tcp[i+1])
+		if kind == 0 {
+			break
+		}
+		if kind == 1 {
+			i++
+			continue
+		}
+		if length < 2 || i+length > size {
+			break
+		}
+		if kind == 2 && length == 4 {
+			older := binary.BigEndian.Uint16(tcp[i+2:])
+			if mss <= 0 || int(older) <= mss {
+				return false
+			}
+			binary.BigEndian.PutUint16(tcp[i+2:], uint16(mss))
+			// updates checksum incrementally by RFC 1624.
+			sum := uint32(^binary.BigEndian.Uint16(tcp[16:18])) + uint32(^older) + uint32(mss)
+			sum = (sum & 0xffff) + (sum >> 16)
+			sum = (sum & 0xffff) + (sum >> 16)
+			binary.BigEndian.PutUint16(tcp[16:18], ^uint16(sum))
+			return true
+		}
+		i += length
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+package libol
+
+import (
+	"net"
+	"syscall"
+)
+
+// setDontFragment sets DF of datagrams even if larger than mtu cached by
+// kernel, otherwise kernel fragments them.
+func setDontFragment(conn *net.UDPConn, on bool) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	value := syscall.IP_PMTUDISC_WANT
+	if on {
+		value = syscall.IP_PMTUDISC_PROBE
+	}
+	var opErr error
+	err = raw.Control(func(fd uintptr) {
+		opErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, value)
+		// and for ipv6 socket, but ipv4 one hasn't it.
+		_ = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER, value)
+	})
+	if err != nil {
+		return err
+	}
+	return opErr
+}
@@ -0,0 +1,9 @@
+// +build !linux
+
+package libol
+
+import "net"
+
+func setDontFragment(conn *net.UDPConn, on bool) error {
+	return nil
+}
@@ -0,0 +1,125 @@
+package libol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFragment(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 300)
+	frags := Fragment(1, data, 1000)
+	assert.Equal(t, 4, len(frags), "be the same.")
+	for _, frag := range frags {
+		assert.True(t, len(frag) <= 1000, "not larger than mtu.")
+	}
+	r := NewReassembler(4)
+	// out of order and duplicated.
+	assert.Nil(t, r.Add(frags[3]), "not completed.")
+	assert.Nil(t, r.Add(frags[1]), "not completed.")
+	assert.Nil(t, r.Add(frags[1]), "not completed.")
+	assert.Nil(t, r.Add(frags[0]), "not completed.")
+	assert.Equal(t, data, r.Add(frags[2]), "be the same.")
+	assert.Equal(t, 0, len(r.entries), "be released.")
+	// lost fragments are dropped if too many.
+	for i := uint16(2); i < 10; i++ {
+		r.Add(Fragment(i, data, 1000)[0])
+	}
+	assert.True(t, len(r.entries) <= 4, "be limited.")
+}
+
+func TestPmtu_Probe(t *testing.T) {
+	path := 1200
+	p := NewPmtu()
+	p.Wait = 0
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		probe := p.Next(now)
+		if probe == nil {
+			break
+		}
+		if len(probe) <= path {
+			p.Ack(NewProbeAck(probe))
+		}
+	}
+	mtu := p.Mtu()
+	assert.True(t, mtu <= path && mtu > path-FragHdr*2, "be near path.")
+	assert.Nil(t, p.Next(now), "wait to probe again.")
+	assert.NotNil(t, p.Next(now.Add(PmtuAgain*time.Second)), "probe again.")
+}
+
+func TestPmtu_NoAck(t *testing.T) {
+	p := NewPmtu()
+	p.Wait = 0
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		if p.Next(now) == nil {
+			break
+		}
+	}
+	assert.Equal(t, 0, p.Mtu(), "not fragment.")
+	assert.False(t, p.DontFragment(), "not DF.")
+}
+
+func TestPmtu_Learn(t *testing.T) {
+	p := NewPassivePmtu()
+	now := time.Now()
+	assert.Nil(t, p.Next(now), "not probe.")
+	for _, size := range []int{997, 1221, 1109, 1165} {
+		p.Learn(size, now)
+	}
+	assert.Equal(t, 1221, p.Mtu(), "be the largest.")
+	// path is lower in next round.
+	now = now.Add(PmtuAgain * time.Second)
+	for _, size := range []int{997, 1053} {
+		p.Learn(size, now)
+	}
+	assert.Equal(t, 1221, p.Mtu(), "be the same until round ended.")
+	p.Learn(997, now.Add(PmtuAgain*time.Second))
+	assert.Equal(t, 1053, p.Mtu(), "be the same.")
+}
+
+// tcpSum returns checksum of tcp in ipv4 frame, and it's zero if valid.
+func tcpSum(frame []byte) uint16 {
+	ip := frame[EtherLen:]
+	tcp := ip[Ipv4Len:]
+	sum := uint32(IpTcp) + uint32(len(tcp))
+	data := append(append([]byte{}, ip[12:20]...), tcp...)
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i:]))
+	}
+	for sum > 0xffff {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+	return ^uint16(sum)
+}
+
+func TestClampMss(t *testing.T) {
+	frame := make([]byte, EtherLen+Ipv4Len+TcpLen+8)
+	binary.BigEndian.PutUint16(frame[12:], EthIp4)
+	ip := frame[EtherLen:]
+	ip[0], ip[9] = 0x45, IpTcp
+	copy(ip[12:], []byte{192, 168, 0, 1, 192, 168, 0, 2})
+	tcp := ip[Ipv4Len:]
+	tcp[12], tcp[13] = 0x70, TcpSyn
+	// nop, nop, mss of 1460 and end.
+	copy(tcp[TcpLen:], []byte{1, 1, 2, 4, 0x05, 0xb4, 0, 0})
+	binary.BigEndian.PutUint16(tcp[16:], tcpSum(frame))
+	assert.Equal(t, uint16(0), tcpSum(frame), "be valid.")
+
+	assert.False(t, ClampMss(frame, 0), "not probed.")
+	assert.False(t, ClampMss(frame, 1600), "be smaller.")
+	assert.True(t, ClampMss(frame, 1200), "be clamped.")
+	mss := 1200 - HlSize - EtherLen - Ipv4Len - TcpLen
+	assert.Equal(t, uint16(mss), binary.BigEndian.Uint16(tcp[TcpLen+4:]), "be the same.")
+	assert.Equal(t, uint16(0), tcpSum(frame), "be valid.")
+	assert.True(t, ClampMssTo(frame, 0, 1000), "be clamped.")
+	assert.Equal(t, uint16(1000), binary.BigEndian.Uint16(tcp[TcpLen+4:]), "be the same.")
+	assert.True(t, ClampMssTo(frame, 1100, 960), "be clamped.")
+	assert.Equal(t, uint16(960), binary.BigEndian.Uint16(tcp[TcpLen+4:]), "be the same.")
+	assert.Equal(t, uint16(0), tcpSum(frame), "be valid.")
+	tcp[13] = TcpAck
+	assert.False(t, ClampMss(frame, 1000), "not syn.")
+}
@@ -0,0 +1,41 @@
+package libol
+
+import "sync"
+
+// BufferPool reuses buffers of same size on data path, and the buffer must
+// not be referenced after it's put back.
+type BufferPool struct {
+	size int
+	pool sync.Pool
+}
+
+func NewBufferPool(size int) *BufferPool {
+	p := &BufferPool{size: size}
+	p.pool.New = func() interface{} {
+		return make([]byte, p.size)
+	}
+	return p
+}
+
+func (p *BufferPool) Size() int {
+	return p.size
+}
+
+func (p *BufferPool) Get() []byte {
+	return p.pool.Get().([]byte)
+}
+
+// Put puts buffer back, and drops the one smaller than size of pool.
+func (p *BufferPool) Put(b []byte) {
+	if cap(b) < p.size {
+		return
+	}
+	p.pool.Put(b[:p.size])
+}
+
+// framePools is pools of FrameMessage by maximum size of frame, and it's
+// read only after initialized.
+var framePools = map[int]*BufferPool{
+	MaxBuf: NewBufferPool(MaxBuf + HlSize + EthDI),
+	MaxMsg: NewBufferPool(MaxMsg + HlSize + EthDI),
+}
@@ -0,0 +1,122 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBufferPool(t *testing.T) {
+	p := NewBufferPool(64)
+	b := p.Get()
+	assert.Equal(t, 64, len(b), "be the same.")
+	p.Put(b[:10])
+	assert.Equal(t, 64, len(p.Get()), "be the same.")
+	p.Put(make([]byte, 10)) // dropped as too small.
+	assert.Equal(t, 64, len(p.Get()), "be the same.")
+}
+
+func TestFrameMessage_Free(t *testing.T) {
+	m := NewFrameMessage(0)
+	assert.NotNil(t, m.pool, "from pool.")
+	assert.Equal(t, MaxBuf+EthDI, len(m.Frame()), "be the same.")
+	m.Append([]byte{1, 2, 3})
+	assert.Equal(t, 3, m.Size(), "be the same.")
+	m.Free()
+	assert.Nil(t, m.Frame(), "be freed.")
+	m.Free() // free twice is okay.
+
+	c := NewFrameMessage(100)
+	assert.Nil(t, c.pool, "not from pool.")
+	c.Free()
+	assert.NotNil(t, c.Frame(), "not freed.")
+}
+
+func TestUdpReader(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer server.Close()
+	client, err := net.DialUDP("udp", nil, server.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Skip(err)
+	}
+	defer client.Close()
+	for _, v := range []string{"hi", "openlan", "udp"} {
+		_, _ = client.Write([]byte(v))
+	}
+	pool := NewBufferPool(1500)
+	reader := NewUdpReader(server, pool, 4)
+	recv := make([]string, 0, 3)
+	_ = server.SetReadDeadline(time.Now().Add(time.Second))
+	for len(recv) < 3 {
+		err := reader.Read(func(data []byte, addr *net.UDPAddr) {
+			assert.Equal(t, client.LocalAddr().String(), addr.String(), "be the same.")
+			recv = append(recv, string(data))
+			pool.Put(data)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, []string{"hi", "openlan", "udp"}, recv, "be the same.")
+}
+
+func TestUdpWriter(t *testing.T) {
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer server.Close()
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer client.Close()
+	writer := NewUdpWriter(server, NewBufferPool(16), 4)
+	addr := client.LocalAddr().(*net.UDPAddr)
+	for _, v := range []string{"hi", "openlan", "udp", "larger than the pool"} {
+		_, err := writer.WriteToUDP([]byte(v), addr)
+		assert.Nil(t, err, "be queued.")
+	}
+	go writer.Loop()
+	recv := make([]string, 0, 4)
+	buf := make([]byte, 1500)
+	_ = client.SetReadDeadline(time.Now().Add(time.Second))
+	for len(recv) < 4 {
+		n, err := client.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recv = append(recv, string(buf[:n]))
+	}
+	// the larger one is sent at once.
+	assert.Equal(t, []string{"larger than the pool", "hi", "openlan", "udp"}, recv, "be the same.")
+	writer.Close()
+	_, err = writer.WriteToUDP([]byte("hi"), addr)
+	assert.NotNil(t, err, "be closed.")
+}
+
+var benchFrame *FrameMessage
+
+func BenchmarkFrameMessage_Alloc(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m := NewFrameMessageFromBytes(make([]byte, MaxBuf+HlSize+EthDI))
+		m.SetSize(0)
+		m.Append(EthZero)
+		benchFrame = m
+	}
+}
+
+func BenchmarkFrameMessage_Pool(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m := NewFrameMessage(0)
+		m.Append(EthZero)
+		benchFrame = m
+		m.Free()
+	}
+}
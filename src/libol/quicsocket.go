@@ -0,0 +1,592 @@
+package libol
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"github.com/quic-go/quic-go"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	QuicAlpn     = "openlan"
+	QuicOverhead = 48 // of header and tag in a packet, and not counted by quic.
+)
+
+var quicLarge = make([]byte, 0xffff)
+
+type QuicConfig struct {
+	Tls     *tls.Config   // certificates for server, and root cas for client.
+	Local   string        // source address to bind.
+	Timeout time.Duration // ns
+	RdQus   int           // per frames
+	WrQus   int           // per frames
+}
+
+var defaultQuicConfig = QuicConfig{
+	Timeout: 120 * time.Second,
+}
+
+// config returns quic config with datagrams and 0-RTT enabled.
+func (c *QuicConfig) config() *quic.Config {
+	return &quic.Config{
+		MaxIdleTimeout:  c.Timeout,
+		EnableDatagrams: true,
+		Allow0RTT:       true,
+	}
+}
+
+// tlsConfig returns a copy of tls with version 1.3 and alpn of openlan.
+func (c *QuicConfig) tlsConfig() *tls.Config {
+	var cfg *tls.Config
+	if c.Tls != nil {
+		cfg = c.Tls.Clone()
+	} else {
+		cfg = &tls.Config{}
+	}
+	cfg.MinVersion = tls.VersionTLS13
+	cfg.NextProtos = []string{QuicAlpn}
+	return cfg
+}
+
+// quicConn is a connection which sends control messages in a stream, and
+// ethernet frames in datagrams. A frame larger than datagram is sent in
+// fragments.
+type quicConn struct {
+	lock       sync.RWMutex
+	wlock      sync.Mutex // serializes writing of stream.
+	conn       *quic.Conn
+	laddr      net.Addr // saved, and changed if migrated.
+	raddr      net.Addr
+	stream     *quic.Stream
+	ready      chan struct{} // closed if stream opened or accepted.
+	queue      chan []byte
+	rdeadline  time.Time
+	wdeadline  time.Time
+	fragId     uint32
+	dialed     bool         // by client, and migrated if route changed.
+	local      *net.UDPAddr // source address to bind if not nil.
+	source     net.IP       // of route to switch, and migrated if changed.
+	migrating  int32
+	transports []*quic.Transport // of client, and closed with connection.
+	sockets    []*net.UDPConn
+}
+
+func newQuicConn(conn *quic.Conn, stream *quic.Stream, size int) *quicConn {
+	if size <= 0 {
+		size = 1024
+	}
+	c := &quicConn{
+		conn:  conn,
+		laddr: conn.LocalAddr(),
+		raddr: conn.RemoteAddr(),
+		ready: make(chan struct{}),
+		queue: make(chan []byte, size),
+	}
+	if stream != nil {
+		c.stream = stream
+		close(c.ready)
+	}
+	Go(c.readDatagram)
+	Go(c.readStream)
+	return c
+}
+
+func (c *quicConn) push(data []byte) bool {
+	select {
+	case c.queue <- data:
+		return true
+	case <-c.conn.Context().Done():
+		return false
+	}
+}
+
+func (c *quicConn) readDatagram() {
+	ctx := c.conn.Context()
+	// not read replayable 0-RTT data before handshake completed.
+	select {
+	case <-c.conn.HandshakeComplete():
+	case <-ctx.Done():
+		return
+	}
+	for {
+		data, err := c.conn.ReceiveDatagram(ctx)
+		if err != nil {
+			Debug("quicConn.readDatagram: %s", err)
+			return
+		}
+		if !c.push(data) {
+			return
+		}
+	}
+}
+
+func (c *quicConn) readStream() {
+	ctx := c.conn.Context()
+	select {
+	case <-c.conn.HandshakeComplete():
+	case <-ctx.Done():
+		return
+	}
+	c.lock.RLock()
+	stream := c.stream
+	c.lock.RUnlock()
+	if stream == nil {
+		var err error
+		if stream, err = c.conn.AcceptStream(ctx); err != nil {
+			Debug("quicConn.readStream: %s", err)
+			return
+		}
+		c.lock.Lock()
+		c.stream = stream
+		if !c.wdeadline.IsZero() {
+			_ = stream.SetWriteDeadline(c.wdeadline)
+		}
+		c.lock.Unlock()
+		close(c.ready)
+	}
+	for {
+		head := make([]byte, HlSize)
+		if _, err := readFull(stream, head); err != nil {
+			Debug("quicConn.readStream: %s", err)
+			_ = c.conn.CloseWithError(0, err.Error())
+			return
+		}
+		size := int(head[HlMI])<<8 | int(head[HlMI+1])
+		data := make([]byte, HlSize+size)
+		copy(data, head)
+		if _, err := readFull(stream, data[HlSize:]); err != nil {
+			Debug("quicConn.readStream: %s", err)
+			_ = c.conn.CloseWithError(0, err.Error())
+			return
+		}
+		if !c.push(data) {
+			return
+		}
+	}
+}
+
+func readFull(stream *quic.Stream, buf []byte) (int, error) {
+	offset := 0
+	for offset < len(buf) {
+		n, err := stream.Read(buf[offset:])
+		if err != nil {
+			return offset, err
+		}
+		offset += n
+	}
+	return offset, nil
+}
+
+// Read returns a message from stream or datagram.
+func (c *quicConn) Read(b []byte) (int, error) {
+	var timeout <-chan time.Time
+	c.lock.RLock()
+	deadline := c.rdeadline
+	c.lock.RUnlock()
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case data := <-c.queue:
+		return copy(b, data), nil
+	case <-c.conn.Context().Done():
+		return 0, context.Cause(c.conn.Context())
+	case <-timeout:
+		return 0, os.ErrDeadlineExceeded
+	}
+}
+
+// space returns size of datagram can be sent in a packet, and it's grown
+// by path mtu discovery of quic.
+func (c *quicConn) space() int {
+	var large *quic.DatagramTooLargeError
+	if errors.As(c.conn.SendDatagram(quicLarge), &large) {
+		return int(large.MaxDatagramPayloadSize) - QuicOverhead
+	}
+	return 0
+}
+
+func (c *quicConn) Write(b []byte) (int, error) {
+	if len(b) > HlSize && isControl(b[HlSize:]) {
+		return c.writeStream(b)
+	}
+	space := c.space()
+	if space <= FragHdr {
+		return 0, NewErr("%s: no space of datagram", c.raddr)
+	}
+	if len(b) <= space {
+		if err := c.conn.SendDatagram(b); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	id := uint16(atomic.AddUint32(&c.fragId, 1))
+	for _, frag := range Fragment(id, b, space) {
+		if err := c.conn.SendDatagram(frag); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (c *quicConn) writeStream(b []byte) (int, error) {
+	select {
+	case <-c.ready:
+	case <-c.conn.Context().Done():
+		return 0, context.Cause(c.conn.Context())
+	}
+	if c.dialed {
+		c.roam()
+	}
+	c.wlock.Lock()
+	defer c.wlock.Unlock()
+	return c.stream.Write(b)
+}
+
+// roam migrates connection to a new path if route to switch is changed.
+func (c *quicConn) roam() {
+	remote, ok := c.raddr.(*net.UDPAddr)
+	if !ok {
+		return
+	}
+	route, err := net.DialUDP("udp", c.local, remote)
+	if err != nil {
+		return
+	}
+	source := route.LocalAddr().(*net.UDPAddr).IP
+	_ = route.Close()
+	c.lock.RLock()
+	same := c.source.Equal(source)
+	c.lock.RUnlock()
+	if same || !atomic.CompareAndSwapInt32(&c.migrating, 0, 1) {
+		return
+	}
+	Go(func() {
+		defer atomic.StoreInt32(&c.migrating, 0)
+		if err := c.migrate(); err != nil {
+			Warn("quicConn.roam: %s", err)
+			return
+		}
+		c.lock.Lock()
+		c.source = source
+		c.lock.Unlock()
+	})
+}
+
+// migrate probes a path from a new socket, and switches to it if validated.
+func (c *quicConn) migrate() error {
+	conn, err := net.ListenUDP("udp", c.local)
+	if err != nil {
+		return err
+	}
+	tr := &quic.Transport{Conn: conn}
+	path, err := c.conn.AddPath(tr)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	// the transport may own ids of connection, and closed with it.
+	c.lock.Lock()
+	c.transports = append(c.transports, tr)
+	c.sockets = append(c.sockets, conn)
+	c.lock.Unlock()
+	ctx, cancel := context.WithTimeout(c.conn.Context(), 5*time.Second)
+	defer cancel()
+	if err := path.Probe(ctx); err != nil {
+		_ = path.Close()
+		return err
+	}
+	if err := path.Switch(); err != nil {
+		_ = path.Close()
+		return err
+	}
+	Info("quicConn.migrate: %s to %s", conn.LocalAddr(), c.raddr)
+	c.lock.Lock()
+	c.laddr = conn.LocalAddr()
+	c.lock.Unlock()
+	return nil
+}
+
+func (c *quicConn) Close() error {
+	err := c.conn.CloseWithError(0, "")
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, tr := range c.transports {
+		_ = tr.Close()
+	}
+	for _, conn := range c.sockets {
+		_ = conn.Close()
+	}
+	c.transports = nil
+	c.sockets = nil
+	return err
+}
+
+func (c *quicConn) LocalAddr() net.Addr {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.laddr
+}
+
+func (c *quicConn) RemoteAddr() net.Addr {
+	return c.raddr
+}
+
+func (c *quicConn) SetDeadline(t time.Time) error {
+	_ = c.SetReadDeadline(t)
+	return c.SetWriteDeadline(t)
+}
+
+func (c *quicConn) SetReadDeadline(t time.Time) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.rdeadline = t
+	return nil
+}
+
+func (c *quicConn) SetWriteDeadline(t time.Time) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.wdeadline = t
+	if c.stream != nil {
+		return c.stream.SetWriteDeadline(t)
+	}
+	return nil
+}
+
+// Server Implement
+
+type QuicServer struct {
+	*SocketServerImpl
+	quicCfg   *QuicConfig
+	listener  *quic.EarlyListener
+	transport *quic.Transport
+	conn      *net.UDPConn
+}
+
+func NewQuicServer(listen string, cfg *QuicConfig) *QuicServer {
+	if cfg == nil {
+		cfg = &defaultQuicConfig
+	}
+	k := &QuicServer{
+		quicCfg:          cfg,
+		SocketServerImpl: NewSocketServer(listen),
+	}
+	if cfg.WrQus > 0 {
+		k.WrQus = cfg.WrQus
+	}
+	k.close = k.Close
+	return k
+}
+
+func (k *QuicServer) Listen() (err error) {
+	if k.quicCfg.Tls == nil || len(k.quicCfg.Tls.Certificates) == 0 {
+		return NewErr("quic without certificate")
+	}
+	addr, err := net.ResolveUDPAddr("udp", k.address)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return err
+	}
+	tr := &quic.Transport{Conn: conn}
+	listener, err := tr.ListenEarly(k.quicCfg.tlsConfig(), k.quicCfg.config())
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.listener = listener
+	k.transport = tr
+	k.conn = conn
+	Info("QuicServer.Listen: quic://%s", k.address)
+	return nil
+}
+
+func (k *QuicServer) Close() {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	if k.listener != nil {
+		_ = k.listener.Close()
+		_ = k.transport.Close()
+		_ = k.conn.Close()
+		Info("QuicServer.Close: %s", k.address)
+		k.listener = nil
+	}
+}
+
+func (k *QuicServer) Accept() {
+	Debug("QuicServer.Accept")
+	promise := Promise{
+		First:  2 * time.Second,
+		MinInt: 5 * time.Second,
+		MaxInt: 30 * time.Second,
+	}
+	promise.Done(func() error {
+		if err := k.Listen(); err != nil {
+			Warn("QuicServer.Accept: %s", err)
+			return err
+		}
+		return nil
+	})
+	defer k.Close()
+	k.lock.RLock()
+	listener := k.listener
+	k.lock.RUnlock()
+	for {
+		var conn net.Conn
+		qc, err := listener.Accept(context.Background())
+		if errors.Is(err, quic.ErrServerClosed) {
+			return
+		}
+		if err == nil {
+			conn = newQuicConn(qc, nil, k.quicCfg.RdQus)
+		}
+		if k.preAccept(conn, err) != nil {
+			continue
+		}
+		k.onClients <- NewQuicClientFromConn(conn, k.quicCfg)
+	}
+}
+
+// Client Implement
+
+type QuicClient struct {
+	*SocketClientImpl
+	quicCfg *QuicConfig
+	tlsCfg  *tls.Config // with session cache for 0-RTT of reconnecting.
+}
+
+func NewQuicClient(addr string, cfg *QuicConfig) *QuicClient {
+	if cfg == nil {
+		cfg = &defaultQuicConfig
+	}
+	tlsCfg := cfg.tlsConfig()
+	if tlsCfg.ServerName == "" {
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			tlsCfg.ServerName = host
+		}
+	}
+	if tlsCfg.ClientSessionCache == nil {
+		tlsCfg.ClientSessionCache = tls.NewLRUClientSessionCache(4)
+	}
+	c := &QuicClient{
+		quicCfg: cfg,
+		tlsCfg:  tlsCfg,
+		SocketClientImpl: NewSocketClient(addr, &PacketMessagerImpl{
+			timeout: cfg.Timeout,
+			bufSize: cfg.RdQus * MaxFrame,
+			reasm:   NewReassembler(16),
+		}),
+	}
+	return c
+}
+
+func NewQuicClientFromConn(conn net.Conn, cfg *QuicConfig) *QuicClient {
+	if cfg == nil {
+		cfg = &defaultQuicConfig
+	}
+	addr := conn.RemoteAddr().String()
+	c := &QuicClient{
+		quicCfg: cfg,
+		SocketClientImpl: NewSocketClient(addr, &PacketMessagerImpl{
+			timeout: cfg.Timeout,
+			bufSize: cfg.RdQus * MaxFrame,
+			reasm:   NewReassembler(16),
+		}),
+	}
+	c.updateConn(conn)
+	return c
+}
+
+func (c *QuicClient) Connect() error {
+	if !c.Retry() {
+		return nil
+	}
+	c.out.Info("QuicClient.Connect: quic://%s", c.address)
+	addr, err := net.ResolveUDPAddr("udp", c.address)
+	if err != nil {
+		return err
+	}
+	local := LocalUDPAddr(c.quicCfg.Local)
+	udpConn, err := net.ListenUDP("udp", local)
+	if err != nil {
+		return err
+	}
+	tr := &quic.Transport{Conn: udpConn}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := tr.DialEarly(ctx, addr, c.tlsCfg, c.quicCfg.config())
+	if err != nil {
+		_ = tr.Close()
+		_ = udpConn.Close()
+		return err
+	}
+	// open stream in 0-RTT, and login is sent without waiting handshake.
+	stream, err := conn.OpenStream()
+	if err != nil {
+		_ = conn.CloseWithError(0, "")
+		_ = tr.Close()
+		_ = udpConn.Close()
+		return err
+	}
+	qc := newQuicConn(conn, stream, c.quicCfg.RdQus)
+	qc.dialed = true
+	qc.local = local
+	qc.transports = []*quic.Transport{tr}
+	qc.sockets = []*net.UDPConn{udpConn}
+	if route, err := net.DialUDP("udp", local, addr); err == nil {
+		qc.source = route.LocalAddr().(*net.UDPAddr).IP
+		_ = route.Close()
+	}
+	c.SetConnection(qc)
+	if c.listener.OnConnected != nil {
+		_ = c.listener.OnConnected(c)
+	}
+	return nil
+}
+
+func (c *QuicClient) Close() {
+	c.out.Debug("QuicClient.Close: %v", c.IsOk())
+	c.lock.Lock()
+	if c.connection != nil {
+		if c.status != ClTerminal {
+			c.status = ClClosed
+		}
+		c.out.Info("QuicClient.Close")
+		c.updateConn(nil)
+		c.private = nil
+		c.lock.Unlock()
+		if c.listener.OnClose != nil {
+			_ = c.listener.OnClose(c)
+		}
+	} else {
+		c.lock.Unlock()
+	}
+}
+
+func (c *QuicClient) Terminal() {
+	c.SetStatus(ClTerminal)
+	c.Close()
+}
+
+func (c *QuicClient) SetStatus(v SocketStatus) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.status != v {
+		if c.listener.OnStatus != nil {
+			c.listener.OnStatus(c, c.status, v)
+		}
+		c.status = v
+	}
+}
@@ -0,0 +1,128 @@
+package libol
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+func quicCert(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "openlan"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func quicAccept(t *testing.T, server *QuicServer) SocketClient {
+	select {
+	case client := <-server.onClients:
+		return client
+	case <-time.After(3 * time.Second):
+		t.Fatal("not accepted")
+	}
+	return nil
+}
+
+func quicFrame(size int) *FrameMessage {
+	frame := NewFrameMessage(MaxBuf)
+	data := make([]byte, size)
+	data[0] = 0x01
+	for i := range data[EthDI:] {
+		data[EthDI+i] = byte(i)
+	}
+	frame.Append(data)
+	return frame
+}
+
+func quicAddr(t *testing.T) string {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().String()
+}
+
+func TestQuic(t *testing.T) {
+	addr := quicAddr(t)
+	server := NewQuicServer(addr, &QuicConfig{
+		Tls:     &tls.Config{Certificates: []tls.Certificate{quicCert(t)}},
+		Timeout: 10 * time.Second,
+	})
+	Go(server.Accept)
+	defer server.Close()
+
+	client := NewQuicClient(addr, &QuicConfig{
+		Tls:     &tls.Config{InsecureSkipVerify: true},
+		Timeout: 10 * time.Second,
+	})
+	assert.Nil(t, client.Connect(), "be connected.")
+	defer client.Close()
+
+	// control message is sent in stream.
+	login := NewControlFrame(LoginReq, []byte(`{"name":"hi"}`))
+	assert.Nil(t, client.WriteMsg(login), "be written.")
+	peer := quicAccept(t, server)
+	frame, err := peer.ReadMsg()
+	assert.Nil(t, err, "be read.")
+	assert.True(t, frame.Decode(), "be control.")
+	assert.Equal(t, LoginReq, frame.Action(), "be the same.")
+
+	// frame is larger than datagram, and sent in fragments.
+	big := quicFrame(1514)
+	data := append([]byte{}, big.Frame()[:big.Size()]...)
+	assert.Nil(t, peer.WriteMsg(big), "be written.")
+	frame, err = client.ReadMsg()
+	assert.Nil(t, err, "be read.")
+	assert.Equal(t, data, frame.Frame(), "be the same.")
+
+	// migrate to a new socket, and the peer is kept.
+	conn := client.connection.(*quicConn)
+	local := conn.LocalAddr().String()
+	assert.Nil(t, conn.migrate(), "be migrated.")
+	assert.NotEqual(t, local, conn.LocalAddr().String(), "be changed.")
+	small := quicFrame(64)
+	data = append([]byte{}, small.Frame()[:small.Size()]...)
+	assert.Nil(t, client.WriteMsg(small), "be written.")
+	frame, err = peer.ReadMsg()
+	assert.Nil(t, err, "be read.")
+	assert.Equal(t, data, frame.Frame(), "be the same.")
+
+	// reconnect in 0-RTT by ticket of session.
+	client.Close()
+	assert.Nil(t, client.Connect(), "be connected.")
+	assert.Nil(t, client.WriteMsg(NewControlFrame(LoginReq, []byte(`{"name":"hi"}`))), "be written.")
+	peer = quicAccept(t, server)
+	frame, err = peer.ReadMsg()
+	assert.Nil(t, err, "be read.")
+	assert.True(t, frame.Decode(), "be control.")
+	assert.Equal(t, LoginReq, frame.Action(), "be the same.")
+	conn = client.connection.(*quicConn)
+	<-conn.conn.HandshakeComplete()
+	assert.True(t, conn.conn.ConnectionState().Used0RTT, "be 0-RTT.")
+}
+
+func TestQuic_NoCert(t *testing.T) {
+	server := NewQuicServer(quicAddr(t), &QuicConfig{})
+	assert.NotNil(t, server.Listen(), "be rejected.")
+}
@@ -0,0 +1,538 @@
+package libol
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	RadiusAccessRequest   = 1
+	RadiusAccessAccept    = 2
+	RadiusAccessReject    = 3
+	RadiusAcctRequest     = 4
+	RadiusAcctResponse    = 5
+	RadiusAccessChallenge = 11
+)
+
+const (
+	RadiusUserName        = 1
+	RadiusUserPassword    = 2
+	RadiusNasIpAddress    = 4
+	RadiusFramedIpAddress = 8
+	RadiusFilterId        = 11
+	RadiusReplyMessage    = 18
+	RadiusState           = 24
+	RadiusClass           = 25
+	RadiusVendorSpecific  = 26
+	RadiusSessionTimeout  = 27
+	RadiusCallingStation  = 31
+	RadiusNasIdentifier   = 32
+	RadiusAcctStatusType  = 40
+	RadiusAcctInOctets    = 42
+	RadiusAcctOutOctets   = 43
+	RadiusAcctSessionId   = 44
+	RadiusAcctSessionTime = 46
+	RadiusAcctTermCause   = 49
+	RadiusAcctInGiga      = 52
+	RadiusAcctOutGiga     = 53
+	RadiusMessageAuth     = 80
+)
+
+const (
+	RadiusAcctStart   = 1
+	RadiusAcctStop    = 2
+	RadiusAcctInterim = 3
+)
+
+const (
+	RadiusVendorMicrosoft = 311
+	RadiusMsChapChallenge = 11
+	RadiusMsChap2Response = 25
+	RadiusMsChap2Success  = 26
+)
+
+type RadiusAttr struct {
+	Type  byte
+	Value []byte
+}
+
+type RadiusPacket struct {
+	Code          byte
+	Id            byte
+	Authenticator [16]byte
+	Attrs         []RadiusAttr
+}
+
+func (p *RadiusPacket) Add(typ byte, value []byte) {
+	for len(value) > 253 { // split long value likes State or Class.
+		p.Attrs = append(p.Attrs, RadiusAttr{Type: typ, Value: value[:253]})
+		value = value[253:]
+	}
+	p.Attrs = append(p.Attrs, RadiusAttr{Type: typ, Value: value})
+}
+
+func (p *RadiusPacket) AddString(typ byte, value string) {
+	p.Add(typ, []byte(value))
+}
+
+func (p *RadiusPacket) AddUint32(typ byte, value uint32) {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, value)
+	p.Add(typ, data)
+}
+
+func (p *RadiusPacket) AddVendor(vendor uint32, typ byte, value []byte) {
+	data := make([]byte, 6, 6+len(value))
+	binary.BigEndian.PutUint32(data, vendor)
+	data[4] = typ
+	data[5] = byte(len(value) + 2)
+	p.Add(RadiusVendorSpecific, append(data, value...))
+}
+
+// Get returns value of the first attribute.
+func (p *RadiusPacket) Get(typ byte) []byte {
+	for _, attr := range p.Attrs {
+		if attr.Type == typ {
+			return attr.Value
+		}
+	}
+	return nil
+}
+
+func (p *RadiusPacket) GetString(typ byte) string {
+	return string(p.Get(typ))
+}
+
+func (p *RadiusPacket) GetUint32(typ byte) uint32 {
+	if value := p.Get(typ); len(value) == 4 {
+		return binary.BigEndian.Uint32(value)
+	}
+	return 0
+}
+
+// GetVendor returns value of the first vendor specific attribute.
+func (p *RadiusPacket) GetVendor(vendor uint32, typ byte) []byte {
+	for _, attr := range p.Attrs {
+		value := attr.Value
+		if attr.Type != RadiusVendorSpecific || len(value) < 6 {
+			continue
+		}
+		if binary.BigEndian.Uint32(value) != vendor || value[4] != typ {
+			continue
+		}
+		if size := int(value[5]); size >= 2 && size+4 <= len(value) {
+			return value[6 : size+4]
+		}
+	}
+	return nil
+}
+
+func (p *RadiusPacket) Encode() []byte {
+	size := 20
+	for _, attr := range p.Attrs {
+		size += 2 + len(attr.Value)
+	}
+	data := make([]byte, 20, size)
+	data[0] = p.Code
+	data[1] = p.Id
+	binary.BigEndian.PutUint16(data[2:], uint16(size))
+	copy(data[4:20], p.Authenticator[:])
+	for _, attr := range p.Attrs {
+		data = append(data, attr.Type, byte(len(attr.Value)+2))
+		data = append(data, attr.Value...)
+	}
+	return data
+}
+
+func DecodeRadius(data []byte) (*RadiusPacket, error) {
+	if len(data) < 20 {
+		return nil, NewErr("too short %d", len(data))
+	}
+	size := int(binary.BigEndian.Uint16(data[2:]))
+	if size < 20 || size > len(data) {
+		return nil, NewErr("invalid length %d", size)
+	}
+	p := &RadiusPacket{Code: data[0], Id: data[1]}
+	copy(p.Authenticator[:], data[4:20])
+	for i := 20; i < size; {
+		if i+2 > size || data[i+1] < 2 || i+int(data[i+1]) > size {
+			return nil, NewErr("invalid attribute at %d", i)
+		}
+		attr := RadiusAttr{
+			Type:  data[i],
+			Value: append([]byte{}, data[i+2:i+int(data[i+1])]...),
+		}
+		p.Attrs = append(p.Attrs, attr)
+		i += int(data[i+1])
+	}
+	return p, nil
+}
+
+func radiusHash(data ...[]byte) []byte {
+	h := md5.New()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// RadiusHidePassword encrypts User-Password by RFC 2865 section 5.2.
+func RadiusHidePassword(password, secret string, auth []byte) []byte {
+	size := (len(password) + 15) / 16 * 16
+	if size == 0 {
+		size = 16
+	}
+	data := make([]byte, size)
+	copy(data, password)
+	last := auth
+	for i := 0; i < size; i += 16 {
+		b := radiusHash([]byte(secret), last)
+		for j := 0; j < 16; j++ {
+			data[i+j] ^= b[j]
+		}
+		last = data[i : i+16]
+	}
+	return data
+}
+
+// RadiusShowPassword decrypts User-Password hidden by RadiusHidePassword.
+func RadiusShowPassword(data []byte, secret string, auth []byte) string {
+	if len(data)%16 != 0 {
+		return ""
+	}
+	plain := make([]byte, len(data))
+	last := auth
+	for i := 0; i < len(data); i += 16 {
+		b := radiusHash([]byte(secret), last)
+		for j := 0; j < 16; j++ {
+			plain[i+j] = data[i+j] ^ b[j]
+		}
+		last = data[i : i+16]
+	}
+	return string(bytes.TrimRight(plain, "\x00"))
+}
+
+// SignRadius fills Message-Authenticator if present, and computes
+// authenticator of accounting request or response by request's.
+func SignRadius(p *RadiusPacket, secret string, request []byte) []byte {
+	if request != nil {
+		copy(p.Authenticator[:], request)
+	} else if p.Code == RadiusAcctRequest {
+		p.Authenticator = [16]byte{}
+	}
+	for i, attr := range p.Attrs {
+		if attr.Type == RadiusMessageAuth {
+			p.Attrs[i].Value = make([]byte, 16)
+			mac := hmac.New(md5.New, []byte(secret))
+			mac.Write(p.Encode())
+			p.Attrs[i].Value = mac.Sum(nil)
+		}
+	}
+	data := p.Encode()
+	if request != nil || p.Code == RadiusAcctRequest {
+		sum := radiusHash(data, []byte(secret))
+		copy(p.Authenticator[:], sum)
+		copy(data[4:20], sum)
+	}
+	return data
+}
+
+// VerifyRadius checks authenticator and Message-Authenticator of response.
+func VerifyRadius(data []byte, secret string, request []byte) bool {
+	if len(data) < 20 {
+		return false
+	}
+	size := int(binary.BigEndian.Uint16(data[2:]))
+	if size > len(data) {
+		return false
+	}
+	data = data[:size]
+	copied := append([]byte{}, data...)
+	copy(copied[4:20], request)
+	if !hmac.Equal(radiusHash(copied, []byte(secret)), data[4:20]) {
+		return false
+	}
+	p, err := DecodeRadius(copied)
+	if err != nil {
+		return false
+	}
+	for i, attr := range p.Attrs {
+		if attr.Type != RadiusMessageAuth {
+			continue
+		}
+		p.Attrs[i].Value = make([]byte, 16)
+		mac := hmac.New(md5.New, []byte(secret))
+		mac.Write(p.Encode())
+		return hmac.Equal(mac.Sum(nil), attr.Value)
+	}
+	return true
+}
+
+type RadiusConfig struct {
+	Servers     []string // authentication servers, tried by order.
+	AcctServers []string // accounting servers, and no accounting if empty.
+	Secret      string
+	Auth        string // pap or mschapv2.
+	NasId       string
+	Timeout     int // seconds to wait a response.
+	Retries     int // times to send request to a server.
+	Interim     int // seconds between interim updates.
+}
+
+type RadiusResult struct {
+	Role    string // from Filter-Id.
+	Class   []byte
+	Timeout uint32 // from Session-Timeout.
+	Message string
+}
+
+// RadiusChallenge is returned by Login if server requires more
+// information likes one time password, and the next login responds it
+// with the State.
+type RadiusChallenge struct {
+	Message string
+	State   []byte
+}
+
+func (c *RadiusChallenge) Error() string {
+	return "challenge: " + c.Message
+}
+
+type RadiusAcct struct {
+	Status  uint32
+	Session string
+	User    string
+	Station string // address of client.
+	Input   uint64
+	Output  uint64
+	Time    int64 // seconds since started.
+	Cause   uint32
+}
+
+type RadiusService struct {
+	Cfg    RadiusConfig
+	lock   sync.Mutex
+	id     byte
+	active map[bool]int // index of active server for accounting or not.
+}
+
+func NewRadiusService(cfg RadiusConfig) *RadiusService {
+	if cfg.Auth == "" {
+		cfg.Auth = "pap"
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 3
+	}
+	if cfg.Retries == 0 {
+		cfg.Retries = 2
+	}
+	if cfg.Interim == 0 {
+		cfg.Interim = 300
+	}
+	return &RadiusService{
+		Cfg:    cfg,
+		active: make(map[bool]int, 2),
+	}
+}
+
+func (r *RadiusService) nextId() byte {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.id++
+	return r.id
+}
+
+func (r *RadiusService) exchange(server string, p *RadiusPacket, data []byte) (*RadiusPacket, error) {
+	conn, err := net.Dial("udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	buf := make([]byte, 4096)
+	timeout := time.Duration(r.Cfg.Timeout) * time.Second
+	for i := 0; i < r.Cfg.Retries; i++ {
+		if _, err = conn.Write(data); err != nil {
+			return nil, err
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(timeout))
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				break
+			}
+			if n < 20 || buf[1] != p.Id {
+				continue
+			}
+			if !VerifyRadius(buf[:n], r.Cfg.Secret, p.Authenticator[:]) {
+				Warn("RadiusService.exchange invalid response from %s", server)
+				continue
+			}
+			return DecodeRadius(buf[:n])
+		}
+	}
+	return nil, NewErr("%s timeout", server)
+}
+
+// Exchange sends request to servers by order, and the server responded
+// is tried firstly in next time.
+func (r *RadiusService) Exchange(p *RadiusPacket) (*RadiusPacket, error) {
+	isAcct := p.Code == RadiusAcctRequest
+	servers := r.Cfg.Servers
+	if isAcct {
+		servers = r.Cfg.AcctServers
+	}
+	if len(servers) == 0 {
+		return nil, NewErr("no servers")
+	}
+	data := SignRadius(p, r.Cfg.Secret, nil)
+	r.lock.Lock()
+	start := r.active[isAcct]
+	r.lock.Unlock()
+	var err error
+	for i := 0; i < len(servers); i++ {
+		index := (start + i) % len(servers)
+		var resp *RadiusPacket
+		if resp, err = r.exchange(servers[index], p, data); err == nil {
+			r.lock.Lock()
+			r.active[isAcct] = index
+			r.lock.Unlock()
+			return resp, nil
+		}
+		Warn("RadiusService.Exchange %s", err)
+	}
+	return nil, err
+}
+
+func (r *RadiusService) newRequest(code byte) *RadiusPacket {
+	p := &RadiusPacket{Code: code, Id: r.nextId()}
+	if code == RadiusAccessRequest {
+		if _, err := rand.Read(p.Authenticator[:]); err != nil {
+			Warn("RadiusService.newRequest %s", err)
+		}
+		// against forged response, see Blast-RADIUS.
+		p.Add(RadiusMessageAuth, make([]byte, 16))
+	}
+	if r.Cfg.NasId != "" {
+		p.AddString(RadiusNasIdentifier, r.Cfg.NasId)
+	}
+	return p
+}
+
+// addPassword adds password by pap or mschapv2, and returns authenticator
+// response expected in MS-CHAP2-Success if mschapv2.
+func (r *RadiusService) addPassword(p *RadiusPacket, userName, password string) (string, error) {
+	if r.Cfg.Auth != "mschapv2" {
+		value := RadiusHidePassword(password, r.Cfg.Secret, p.Authenticator[:])
+		p.Add(RadiusUserPassword, value)
+		return "", nil
+	}
+	auth := make([]byte, 16)
+	peer := make([]byte, 16)
+	if _, err := rand.Read(auth); err != nil {
+		return "", err
+	}
+	if _, err := rand.Read(peer); err != nil {
+		return "", err
+	}
+	ntResponse := NtResponse(auth, peer, userName, password)
+	// ident, flags, peer challenge, reserved and nt response.
+	value := make([]byte, 2, 50)
+	value[0] = p.Id
+	value = append(value, peer...)
+	value = append(value, make([]byte, 8)...)
+	value = append(value, ntResponse...)
+	p.AddVendor(RadiusVendorMicrosoft, RadiusMsChapChallenge, auth)
+	p.AddVendor(RadiusVendorMicrosoft, RadiusMsChap2Response, value)
+	return AuthenticatorResponse(auth, peer, userName, password, ntResponse), nil
+}
+
+// checkSuccess returns whether MS-CHAP2-Success of response is expected,
+// and it's likes ident and S=<40 hex> with optional message.
+func (r *RadiusService) checkSuccess(resp *RadiusPacket, expect string) bool {
+	value := resp.GetVendor(RadiusVendorMicrosoft, RadiusMsChap2Success)
+	if len(value) < 1+len(expect) {
+		return false
+	}
+	return hmac.Equal(value[1:1+len(expect)], []byte(expect))
+}
+
+// Login authenticates user. If server responds a challenge, it returns
+// RadiusChallenge, and the next login responds it by password with the
+// state of challenge.
+func (r *RadiusService) Login(userName, password string, state []byte) (*RadiusResult, error) {
+	if password == "" {
+		return nil, NewErr("empty password")
+	}
+	p := r.newRequest(RadiusAccessRequest)
+	p.AddString(RadiusUserName, userName)
+	expect := ""
+	if state != nil {
+		p.Add(RadiusState, state)
+		p.Add(RadiusUserPassword, RadiusHidePassword(password, r.Cfg.Secret, p.Authenticator[:]))
+	} else if value, err := r.addPassword(p, userName, password); err != nil {
+		return nil, err
+	} else {
+		expect = value
+	}
+	resp, err := r.Exchange(p)
+	if err != nil {
+		return nil, err
+	}
+	message := resp.GetString(RadiusReplyMessage)
+	switch resp.Code {
+	case RadiusAccessAccept:
+		// server proves it knows the password.
+		if expect != "" && !r.checkSuccess(resp, expect) {
+			return nil, NewErr("invalid MS-CHAP2-Success")
+		}
+		return &RadiusResult{
+			Role:    resp.GetString(RadiusFilterId),
+			Class:   resp.Get(RadiusClass),
+			Timeout: resp.GetUint32(RadiusSessionTimeout),
+			Message: message,
+		}, nil
+	case RadiusAccessChallenge:
+		return nil, &RadiusChallenge{Message: message, State: resp.Get(RadiusState)}
+	case RadiusAccessReject:
+		return nil, NewErr("rejected %s", message)
+	}
+	return nil, NewErr("unknown code %d", resp.Code)
+}
+
+// Accounting sends accounting request, and it's ignored if no servers.
+func (r *RadiusService) Accounting(acct *RadiusAcct) error {
+	if len(r.Cfg.AcctServers) == 0 {
+		return nil
+	}
+	p := r.newRequest(RadiusAcctRequest)
+	p.AddUint32(RadiusAcctStatusType, acct.Status)
+	p.AddString(RadiusAcctSessionId, acct.Session)
+	p.AddString(RadiusUserName, acct.User)
+	if acct.Station != "" {
+		p.AddString(RadiusCallingStation, acct.Station)
+	}
+	if acct.Status != RadiusAcctStart {
+		p.AddUint32(RadiusAcctInOctets, uint32(acct.Input))
+		p.AddUint32(RadiusAcctInGiga, uint32(acct.Input>>32))
+		p.AddUint32(RadiusAcctOutOctets, uint32(acct.Output))
+		p.AddUint32(RadiusAcctOutGiga, uint32(acct.Output>>32))
+		p.AddUint32(RadiusAcctSessionTime, uint32(acct.Time))
+	}
+	if acct.Status == RadiusAcctStop && acct.Cause != 0 {
+		p.AddUint32(RadiusAcctTermCause, acct.Cause)
+	}
+	resp, err := r.Exchange(p)
+	if err != nil {
+		return err
+	}
+	if resp.Code != RadiusAcctResponse {
+		return NewErr("unknown code %d", resp.Code)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package libol
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+func TestMsChapV2(t *testing.T) {
+	// test vectors from RFC 2759 section 9.2.
+	auth, _ := hex.DecodeString("5B5D7C7D7B3F2F3E3C2C602132262628")
+	peer, _ := hex.DecodeString("21402324255E262A28295F2B3A337C7E")
+	assert.Equal(t, "d02e4386bce91226", hex.EncodeToString(ChallengeHash(peer, auth, "User")), "be the same.")
+	assert.Equal(t, "44ebba8d5312b8d611474411f56989ae", hex.EncodeToString(NtPasswordHash("clientPass")), "be the same.")
+	resp := NtResponse(auth, peer, "User", "clientPass")
+	assert.Equal(t, "82309ecd8d708b5ea08faa3981cd83544233114a3d85d6df", hex.EncodeToString(resp), "be the same.")
+	assert.Equal(t, "31d6cfe0d16ae931b73c59d7e0c089c0", hex.EncodeToString(MD4(nil)), "be the same.")
+	value := AuthenticatorResponse(auth, peer, "User", "clientPass", resp)
+	assert.Equal(t, "S=407A5589115FD0D6209F510FE9C04566932CDA56", value, "be the same.")
+}
+
+func TestRadiusPassword(t *testing.T) {
+	auth := bytes.Repeat([]byte{0x5a}, 16)
+	for _, pass := range []string{"", "hi", "0123456789abcdef", "0123456789abcdef-0123"} {
+		data := RadiusHidePassword(pass, "secret", auth)
+		assert.Equal(t, 0, len(data)%16, "be padded.")
+		assert.Equal(t, pass, RadiusShowPassword(data, "secret", auth), "be the same.")
+	}
+}
+
+// radiusServer is a stand-in server accepts hi:123, and challenges hi:otp
+// with 654321.
+func radiusServer(t *testing.T, secret string) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			req, err := DecodeRadius(buf[:n])
+			if err != nil {
+				continue
+			}
+			resp := &RadiusPacket{Id: req.Id, Code: RadiusAccessReject}
+			switch req.Code {
+			case RadiusAccessRequest:
+				name := req.GetString(RadiusUserName)
+				pass := RadiusShowPassword(req.Get(RadiusUserPassword), secret, req.Authenticator[:])
+				success := []byte(nil)
+				if value := req.GetVendor(RadiusVendorMicrosoft, RadiusMsChap2Response); len(value) == 50 {
+					auth := req.GetVendor(RadiusVendorMicrosoft, RadiusMsChapChallenge)
+					if bytes.Equal(value[26:], NtResponse(auth, value[2:18], name, "123")) {
+						pass = "123"
+						success = append([]byte{value[0]}, AuthenticatorResponse(auth, value[2:18], name, pass, value[26:])...)
+						if name == "bad" {
+							success[3] ^= 0x01
+						}
+					}
+				}
+				state := req.GetString(RadiusState)
+				switch {
+				case (name == "hi" || name == "bad") && pass == "123":
+					resp.Code = RadiusAccessAccept
+					resp.AddString(RadiusFilterId, "admin")
+					if success != nil {
+						resp.AddVendor(RadiusVendorMicrosoft, RadiusMsChap2Success, success)
+					}
+				case name == "hi" && pass == "otp":
+					resp.Code = RadiusAccessChallenge
+					resp.AddString(RadiusState, "s1")
+					resp.AddString(RadiusReplyMessage, "code")
+				case name == "hi" && state == "s1" && pass == "654321":
+					resp.Code = RadiusAccessAccept
+				}
+				resp.Add(RadiusMessageAuth, make([]byte, 16))
+			case RadiusAcctRequest:
+				resp.Code = RadiusAcctResponse
+			}
+			data := SignRadius(resp, secret, req.Authenticator[:])
+			_, _ = conn.WriteToUDP(data, addr)
+		}
+	}()
+	return conn
+}
+
+func TestRadiusService(t *testing.T) {
+	server := radiusServer(t, "secret")
+	defer server.Close()
+	dead, _ := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	defer dead.Close()
+
+	addr := server.LocalAddr().String()
+	svc := NewRadiusService(RadiusConfig{
+		Servers:     []string{dead.LocalAddr().String(), addr},
+		AcctServers: []string{addr},
+		Secret:      "secret",
+		Timeout:     1,
+		Retries:     1,
+	})
+	result, err := svc.Login("hi", "123", nil)
+	assert.Nil(t, err, "failover to second.")
+	assert.Equal(t, "admin", result.Role, "be the same.")
+	assert.Equal(t, 1, svc.active[false], "be the same.")
+	_, err = svc.Login("hi", "456", nil)
+	assert.NotNil(t, err, "be rejected.")
+
+	_, err = svc.Login("hi", "otp", nil)
+	challenge, ok := err.(*RadiusChallenge)
+	assert.True(t, ok, "be challenged.")
+	assert.Equal(t, "s1", string(challenge.State), "be the same.")
+	_, err = svc.Login("hi", "654321", nil)
+	assert.NotNil(t, err, "without state.")
+	_, err = svc.Login("hi", "654321", challenge.State)
+	assert.Nil(t, err, "response challenge.")
+
+	svc.Cfg.Auth = "mschapv2"
+	_, err = svc.Login("hi", "123", nil)
+	assert.Nil(t, err, "by mschapv2.")
+	_, err = svc.Login("bad", "123", nil)
+	assert.NotNil(t, err, "invalid authenticator response.")
+
+	err = svc.Accounting(&RadiusAcct{Status: RadiusAcctStart, Session: "s", User: "hi"})
+	assert.Nil(t, err, "be accounted.")
+
+	svc.Cfg.Secret = "wrong"
+	_, err = svc.Login("hi", "123", nil)
+	assert.NotNil(t, err, "invalid response.")
+}
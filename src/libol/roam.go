@@ -0,0 +1,547 @@
+package libol
+
+import (
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	RoamHdrSize = 26  // magic, session id, counter and tag.
+	RoamIdle    = 600 // seconds to forget an idle session.
+)
+
+var RoamMagic = []byte{0x4f, 0x52}
+
+// RoamHello is offer of point or answer of switch at login, and key of the
+// session is derived from both by ecdh. So the header is added only after
+// negotiated, and the key isn't known by other points.
+type RoamHello struct {
+	Sid   uint64 `json:"sid,omitempty"`
+	Key   []byte `json:"key"` // public key of p256.
+	Nonce []byte `json:"nonce"`
+}
+
+// RoamClient negotiates roaming at login, and point offers while switch
+// answers.
+type RoamClient interface {
+	RoamOffer() *RoamHello
+	RoamAccept(answer *RoamHello) error
+	RoamAnswer(offer *RoamHello) (*RoamHello, error)
+}
+
+// newRoamHello returns hello with a new private key.
+func newRoamHello(sid uint64) (*RoamHello, []byte, error) {
+	private, x, y, err := elliptic.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	h := &RoamHello{
+		Sid:   sid,
+		Key:   elliptic.Marshal(elliptic.P256(), x, y),
+		Nonce: nonce,
+	}
+	return h, private, nil
+}
+
+// NewRoamKey derives key of session from private key and public one of
+// peer, and nonces of offer and answer.
+func NewRoamKey(private []byte, offer, answer *RoamHello, public []byte) ([]byte, error) {
+	curve := elliptic.P256()
+	x, y := elliptic.Unmarshal(curve, public)
+	if x == nil {
+		return nil, NewErr("invalid public key")
+	}
+	shared, _ := curve.ScalarMult(x, y, private)
+	salt := append(append([]byte{}, offer.Nonce...), answer.Nonce...)
+	info := make([]byte, 8)
+	binary.BigEndian.PutUint64(info, offer.Sid)
+	key := make([]byte, 32)
+	reader := hkdf.New(sha256.New, shared.Bytes(), salt, append([]byte("openlan roaming"), info...))
+	if _, err := io.ReadFull(reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func roamTag(key, header, payload []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(header)
+	mac.Write(payload)
+	return mac.Sum(nil)[:8]
+}
+
+// RoamSeal returns packet of payload with roaming header.
+func RoamSeal(key []byte, sid, counter uint64, payload []byte) []byte {
+	data := make([]byte, RoamHdrSize+len(payload))
+	copy(data, RoamMagic)
+	binary.BigEndian.PutUint64(data[2:], sid)
+	binary.BigEndian.PutUint64(data[10:], counter)
+	copy(data[RoamHdrSize:], payload)
+	copy(data[18:RoamHdrSize], roamTag(key, data[:18], payload))
+	return data
+}
+
+type RoamPacket struct {
+	Sid     uint64
+	Counter uint64
+	Payload []byte
+}
+
+// roamSid returns session id of packet, and false if no roaming header.
+func roamSid(data []byte) (uint64, bool) {
+	if len(data) < RoamHdrSize || data[0] != RoamMagic[0] || data[1] != RoamMagic[1] {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(data[2:]), true
+}
+
+// RoamOpen parses packet with roaming header, and returns nil if not or
+// the tag isn't authenticated by key. So legacy packet likes the header is
+// taken as raw.
+func RoamOpen(key, data []byte) *RoamPacket {
+	sid, ok := roamSid(data)
+	if !ok || key == nil {
+		return nil
+	}
+	p := &RoamPacket{
+		Sid:     sid,
+		Counter: binary.BigEndian.Uint64(data[10:]),
+		Payload: data[RoamHdrSize:],
+	}
+	if !hmac.Equal(data[18:RoamHdrSize], roamTag(key, data[:18], p.Payload)) {
+		return nil
+	}
+	return p
+}
+
+// RoamConn is client side of udp connection which carries a session id, so
+// server can migrate the session to new address after NAT rebinding, and
+// it rebinds a new socket if local address is changed. The header is added
+// only after accepted answer of switch at login.
+type RoamConn struct {
+	lock    sync.RWMutex
+	conn    *net.UDPConn
+	remote  *net.UDPAddr
+	local   *net.UDPAddr // source address to bind if not nil.
+	offer   *RoamHello
+	private []byte
+	key     []byte // of session if negotiated.
+	sid     uint64
+	counter uint64
+	closed  bool
+	df      bool // sets DF to probe mtu of path.
+	rlock   sync.Mutex
+	pool    *BufferPool
+	reader  *UdpReader // of socket, and renewed after rebind.
+	pending [][]byte   // datagrams read in batch.
+}
+
+func NewRoamConn(conn *net.UDPConn) *RoamConn {
+	c := &RoamConn{
+		conn:   conn,
+		remote: conn.RemoteAddr().(*net.UDPAddr),
+	}
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		Warn("NewRoamConn %s", err)
+	}
+	c.sid = binary.BigEndian.Uint64(buf)
+	return c
+}
+
+// LocalUDPAddr returns udp address to bind source, and nil if empty.
+func LocalUDPAddr(local string) *net.UDPAddr {
+	if local == "" {
+		return nil
+	}
+	return &net.UDPAddr{IP: net.ParseIP(local)}
+}
+
+// Offer returns hello to login, and nil if failed.
+func (c *RoamConn) Offer() *RoamHello {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.offer == nil {
+		offer, private, err := newRoamHello(c.sid)
+		if err != nil {
+			Warn("RoamConn.Offer: %s", err)
+			return nil
+		}
+		c.offer, c.private = offer, private
+	}
+	return c.offer
+}
+
+// Accept derives key of session by answer, and adds header since then.
+func (c *RoamConn) Accept(answer *RoamHello) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.offer == nil {
+		return NewErr("not offered")
+	}
+	key, err := NewRoamKey(c.private, c.offer, answer, answer.Key)
+	if err != nil {
+		return err
+	}
+	c.key = key
+	Info("RoamConn.Accept: %x to %s", c.sid, c.remote)
+	return nil
+}
+
+func (c *RoamConn) socket() (*net.UDPConn, []byte) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.conn, c.key
+}
+
+// rebind opens a new socket to replace the older if not closed.
+func (c *RoamConn) rebind(older *net.UDPConn) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return NewErr("closed")
+	}
+	if c.conn != older {
+		return nil
+	}
+	conn, err := net.DialUDP("udp", c.local, c.remote)
+	if err != nil {
+		return err
+	}
+	Info("RoamConn.rebind: %s to %s", older.LocalAddr(), conn.LocalAddr())
+	if c.df {
+		if err := setDontFragment(conn, true); err != nil {
+			Warn("RoamConn.rebind: %s", err)
+		}
+	}
+	c.conn = conn
+	_ = older.Close()
+	return nil
+}
+
+// DontFragment sets or clears DF of datagrams, and it's kept after rebind.
+func (c *RoamConn) DontFragment(on bool) {
+	c.lock.RLock()
+	same := c.df == on
+	c.lock.RUnlock()
+	if same {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.df = on
+	if err := setDontFragment(c.conn, on); err != nil {
+		Warn("RoamConn.DontFragment: %s", err)
+	}
+}
+
+func (c *RoamConn) Write(b []byte) (int, error) {
+	conn, key := c.socket()
+	data := b
+	if key != nil {
+		counter := atomic.AddUint64(&c.counter, 1)
+		data = RoamSeal(key, c.sid, counter, b)
+	}
+	if _, err := conn.Write(data); err != nil {
+		// can't migrate if not negotiated.
+		if key == nil || c.rebind(conn) != nil {
+			return 0, err
+		}
+		if conn, _ = c.socket(); conn != nil {
+			if _, err := conn.Write(data); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(b), nil
+}
+
+func (c *RoamConn) Read(b []byte) (int, error) {
+	c.rlock.Lock()
+	defer c.rlock.Unlock()
+	for {
+		conn, key := c.socket()
+		data, err := c.next(conn, len(b)+RoamHdrSize)
+		if err != nil {
+			if now, _ := c.socket(); now != conn { // rebind by writer.
+				continue
+			}
+			return 0, err
+		}
+		n := 0
+		// switch sends raw until sealed packet is received.
+		if p := RoamOpen(key, data); p != nil && p.Sid == c.sid {
+			n = copy(b, p.Payload)
+		} else {
+			n = copy(b, data)
+		}
+		c.pool.Put(data)
+		return n, nil
+	}
+}
+
+// next returns a datagram of conn read in batch, and its buffer is put
+// back to pool after used.
+func (c *RoamConn) next(conn *net.UDPConn, size int) ([]byte, error) {
+	if c.pool == nil || c.pool.Size() < size {
+		c.pool = NewBufferPool(size)
+		c.reader = nil
+	}
+	if c.reader == nil || c.reader.udp != conn {
+		c.reader = NewUdpReader(conn, c.pool, UdpBatch)
+	}
+	for len(c.pending) == 0 {
+		err := c.reader.Read(func(data []byte, addr *net.UDPAddr) {
+			c.pending = append(c.pending, data)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	data := c.pending[0]
+	c.pending = append(c.pending[:0], c.pending[1:]...)
+	return data, nil
+}
+
+// ReadFrom and WriteTo is used as packet connection by kcp.
+func (c *RoamConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, err := c.Read(b)
+	return n, c.remote, err
+}
+
+func (c *RoamConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.Write(b)
+}
+
+func (c *RoamConn) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.closed = true
+	return c.conn.Close()
+}
+
+func (c *RoamConn) LocalAddr() net.Addr {
+	conn, _ := c.socket()
+	return conn.LocalAddr()
+}
+
+func (c *RoamConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *RoamConn) SetDeadline(t time.Time) error {
+	conn, _ := c.socket()
+	return conn.SetDeadline(t)
+}
+
+func (c *RoamConn) SetReadDeadline(t time.Time) error {
+	conn, _ := c.socket()
+	return conn.SetReadDeadline(t)
+}
+
+func (c *RoamConn) SetWriteDeadline(t time.Time) error {
+	conn, _ := c.socket()
+	return conn.SetWriteDeadline(t)
+}
+
+type roamSession struct {
+	sid       uint64
+	key       []byte
+	first     *net.UDPAddr // as address of session even if migrated.
+	remote    *net.UDPAddr
+	counter   uint64
+	sent      uint64
+	active    int64
+	confirmed bool // received sealed packet, and seals to it since then.
+}
+
+// RoamListener is server side of udp connection, and returns the first
+// address of session as source of packets even if it's migrated. Sessions
+// are negotiated by Answer at login, and others are raw.
+type RoamListener struct {
+	*net.UDPConn
+	lock     sync.RWMutex
+	sessions map[uint64]*roamSession
+	addrs    map[string]*roamSession
+	sweepAt  int64
+	writer   *UdpWriter // writes in batch if not nil.
+}
+
+func NewRoamListener(conn *net.UDPConn) *RoamListener {
+	return &RoamListener{
+		UDPConn:  conn,
+		sessions: make(map[uint64]*roamSession, 1024),
+		addrs:    make(map[string]*roamSession, 1024),
+	}
+}
+
+func (l *RoamListener) del(s *roamSession) {
+	delete(l.sessions, s.sid)
+	if o, ok := l.addrs[s.first.String()]; ok && o == s {
+		delete(l.addrs, s.first.String())
+	}
+}
+
+func (l *RoamListener) sweep(now int64) {
+	if now-l.sweepAt < 60 {
+		return
+	}
+	l.sweepAt = now
+	for _, s := range l.sessions {
+		if now-s.active > RoamIdle {
+			l.del(s)
+		}
+	}
+}
+
+// Answer negotiates session of address by offer of point, and returns
+// hello to reply.
+func (l *RoamListener) Answer(addr *net.UDPAddr, offer *RoamHello) (*RoamHello, error) {
+	if offer == nil || offer.Sid == 0 {
+		return nil, NewErr("invalid offer")
+	}
+	answer, private, err := newRoamHello(offer.Sid)
+	if err != nil {
+		return nil, err
+	}
+	key, err := NewRoamKey(private, offer, answer, offer.Key)
+	if err != nil {
+		return nil, err
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if s, ok := l.sessions[offer.Sid]; ok && s.first.String() != addr.String() {
+		return nil, NewErr("session %x in use", offer.Sid)
+	}
+	if o, ok := l.addrs[addr.String()]; ok {
+		l.del(o)
+	}
+	s := &roamSession{
+		sid:    offer.Sid,
+		key:    key,
+		first:  addr,
+		remote: addr,
+		active: time.Now().Unix(),
+	}
+	l.sessions[s.sid] = s
+	l.addrs[addr.String()] = s
+	Info("RoamListener.Answer: %x on %s", s.sid, addr)
+	return answer, nil
+}
+
+// open returns payload of datagram and address of session, and nil if the
+// datagram should be dropped.
+func (l *RoamListener) open(data []byte, addr *net.UDPAddr) ([]byte, *net.UDPAddr) {
+	sid, ok := roamSid(data)
+	if !ok { // client not supports roaming.
+		return data, addr
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := time.Now().Unix()
+	l.sweep(now)
+	s, ok := l.sessions[sid]
+	if !ok {
+		return data, addr
+	}
+	p := RoamOpen(s.key, data)
+	if p == nil { // not sealed by session, and likes the header.
+		return data, addr
+	}
+	if s.remote.String() != addr.String() {
+		// only newer packet can migrate session.
+		if p.Counter <= s.counter {
+			Debug("RoamListener.open: dropped from %s", addr)
+			return nil, nil
+		}
+		Info("RoamListener.open: %s migrated from %s to %s", s.first, s.remote, addr)
+		s.remote = addr
+	}
+	if p.Counter > s.counter {
+		s.counter = p.Counter
+	}
+	s.active = now
+	s.confirmed = true
+	return p.Payload, s.first
+}
+
+func (l *RoamListener) ReadFromUDP(b []byte) (int, *net.UDPAddr, error) {
+	data := make([]byte, len(b)+RoamHdrSize)
+	for {
+		n, addr, err := l.UDPConn.ReadFromUDP(data)
+		if err != nil {
+			return 0, nil, err
+		}
+		if payload, first := l.open(data[:n], addr); first != nil {
+			return copy(b, payload), first, nil
+		}
+	}
+}
+
+func (l *RoamListener) ReadFrom(b []byte) (int, net.Addr, error) {
+	return l.ReadFromUDP(b)
+}
+
+func (l *RoamListener) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	l.lock.Lock()
+	s, ok := l.addrs[addr.String()]
+	if !ok || !s.confirmed {
+		l.lock.Unlock()
+		return l.writeTo(b, addr)
+	}
+	s.sent++
+	data := RoamSeal(s.key, s.sid, s.sent, b)
+	remote := s.remote
+	l.lock.Unlock()
+	if _, err := l.writeTo(data, remote); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (l *RoamListener) writeTo(b []byte, addr *net.UDPAddr) (int, error) {
+	if l.writer != nil {
+		return l.writer.WriteToUDP(b, addr)
+	}
+	return l.UDPConn.WriteToUDP(b, addr)
+}
+
+func (l *RoamListener) WriteTo(b []byte, addr net.Addr) (int, error) {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return 0, NewErr("invalid address %s", addr)
+	}
+	return l.WriteToUDP(b, udpAddr)
+}
+
+// Remote returns current address of session.
+func (l *RoamListener) Remote(addr *net.UDPAddr) *net.UDPAddr {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	if s, ok := l.addrs[addr.String()]; ok {
+		return s.remote
+	}
+	return addr
+}
+
+// Forget deletes session of address.
+func (l *RoamListener) Forget(addr *net.UDPAddr) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if s, ok := l.addrs[addr.String()]; ok {
+		l.del(s)
+	}
+}
@@ -0,0 +1,101 @@
+package libol
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func roamPair(t *testing.T) (*RoamListener, *RoamConn) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewRoamListener(conn)
+	dial, err := net.DialUDP("udp", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, NewRoamConn(dial)
+}
+
+func roamRead(l *RoamListener) (string, *net.UDPAddr) {
+	buf := make([]byte, 1024)
+	_ = l.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	n, addr, err := l.ReadFromUDP(buf)
+	if err != nil {
+		return "", nil
+	}
+	return string(buf[:n]), addr
+}
+
+func TestRoam_Migrate(t *testing.T) {
+	server, client := roamPair(t)
+	defer server.Close()
+	defer client.Close()
+
+	answer, err := server.Answer(client.LocalAddr().(*net.UDPAddr), client.Offer())
+	assert.Nil(t, err, "be answered.")
+	assert.Nil(t, client.Accept(answer), "be accepted.")
+	_, _ = client.Write([]byte("hi"))
+	data, first := roamRead(server)
+	assert.Equal(t, "hi", data, "be the same.")
+
+	// rebind to a new socket likes address changed.
+	client.DontFragment(true)
+	conn, _ := client.socket()
+	assert.Nil(t, client.rebind(conn), "be rebind.")
+	assert.True(t, client.df, "be kept.")
+	_, _ = client.Write([]byte("hello"))
+	data, addr := roamRead(server)
+	assert.Equal(t, "hello", data, "be the same.")
+	assert.Equal(t, first.String(), addr.String(), "be the same session.")
+	assert.Equal(t, client.LocalAddr().String(), server.Remote(first).String(), "be migrated.")
+
+	_, _ = server.WriteToUDP([]byte("back"), first)
+	buf := make([]byte, 1024)
+	_ = client.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	n, err := client.Read(buf)
+	assert.Nil(t, err, "read from new socket.")
+	assert.Equal(t, "back", string(buf[:n]), "be the same.")
+
+	// forged packet by key of another session is raw of its address.
+	forged, _ := net.DialUDP("udp", nil, server.LocalAddr().(*net.UDPAddr))
+	defer forged.Close()
+	other := NewRoamConn(forged)
+	key, _ := NewRoamKey(other.private, client.Offer(), answer, answer.Key)
+	_, _ = forged.Write(RoamSeal(key, client.sid, client.counter+1, []byte("bad")))
+	_, addr = roamRead(server)
+	assert.Equal(t, forged.LocalAddr().String(), addr.String(), "not the session.")
+	// replayed packet from another address is dropped.
+	_, _ = forged.Write(RoamSeal(client.key, client.sid, 1, []byte("bad")))
+	data, _ = roamRead(server)
+	assert.Equal(t, "", data, "be dropped.")
+	assert.Equal(t, client.LocalAddr().String(), server.Remote(first).String(), "not migrated.")
+	// session id is owned by the first address.
+	_, err = server.Answer(forged.LocalAddr().(*net.UDPAddr), client.Offer())
+	assert.NotNil(t, err, "be in use.")
+}
+
+func TestRoam_Raw(t *testing.T) {
+	server, client := roamPair(t)
+	defer server.Close()
+	defer client.Close()
+
+	// not negotiated with older switch, and sends raw.
+	_, _ = client.Write([]byte("hi"))
+	data, first := roamRead(server)
+	assert.Equal(t, "hi", data, "be the same.")
+	// legacy packet likes header is raw.
+	legacy := append([]byte{0x4f, 0x52}, make([]byte, RoamHdrSize)...)
+	_, _ = client.Write(legacy)
+	data, _ = roamRead(server)
+	assert.Equal(t, string(legacy), data, "be the same.")
+	conn, _ := client.socket()
+	assert.Nil(t, client.rebind(conn), "be rebind.")
+	_, _ = client.Write([]byte("hello"))
+	data, addr := roamRead(server)
+	assert.Equal(t, "hello", data, "be the same.")
+	assert.NotEqual(t, first.String(), addr.String(), "not migrated without roaming.")
+}
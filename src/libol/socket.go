@@ -75,6 +75,7 @@ type SocketClient interface {
 	SetListener(listener ClientListener)
 	SetTimeout(v int64)
 	Out() *SubLogger
+	Mtu() int
 }
 
 type StreamSocket struct {
@@ -105,6 +106,14 @@ func (t *StreamSocket) IsOk() bool {
 	return t.connection != nil
 }
 
+// Mtu returns size of datagram probed to path, and zero for stream.
+func (t *StreamSocket) Mtu() int {
+	if t.message == nil {
+		return 0
+	}
+	return t.message.Mtu()
+}
+
 func (t *StreamSocket) WriteMsg(frame *FrameMessage) error {
 	if !t.IsOk() {
 		t.statistics.Add(CsDropped, 1)
@@ -408,7 +417,9 @@ func (t *SocketServerImpl) Read(client SocketClient, ReadAt ReadClient) {
 		for {
 			select {
 			case frame := <-queue:
-				if err := ReadAt(client, frame); err != nil {
+				err := ReadAt(client, frame)
+				frame.Free()
+				if err != nil {
 					Error("SocketServerImpl.Read: readAt %s", err)
 					return
 				}
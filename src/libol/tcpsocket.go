@@ -9,6 +9,8 @@ import (
 
 type TcpConfig struct {
 	Tls     *tls.Config
+	Proxy   string // url of outbound proxy.
+	Local   string // source address to bind.
 	Block   kcp.BlockCrypt
 	Timeout time.Duration // ns
 	RdQus   int           // per frames
@@ -123,16 +125,23 @@ func (t *TcpClient) Connect() error {
 	}
 	var err error
 	var conn net.Conn
+	if t.tcpCfg.Proxy != "" {
+		t.out.Info("TcpClient.Connect: via %s", RedactProxy(t.tcpCfg.Proxy))
+	}
 	if t.tcpCfg.Tls != nil {
 		t.out.Info("TcpClient.Connect: tls://%s", t.address)
-		conn, err = tls.Dial("tcp", t.address, t.tcpCfg.Tls)
 	} else {
 		t.out.Info("TcpClient.Connect: tcp://%s", t.address)
-		conn, err = net.Dial("tcp", t.address)
 	}
+	conn, err = DialFrom(t.tcpCfg.Local, t.tcpCfg.Proxy, t.address, 0)
 	if err != nil {
 		return err
 	}
+	if t.tcpCfg.Tls != nil {
+		if conn, err = TlsHandshake(conn, t.address, t.tcpCfg.Tls); err != nil {
+			return err
+		}
+	}
 	t.SetConnection(conn)
 	if t.listener.OnConnected != nil {
 		_ = t.listener.OnConnected(t)
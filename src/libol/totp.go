@@ -0,0 +1,112 @@
+package libol
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	TotpPeriod = 30
+	TotpDigits = 6
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenTotpKey returns a random base32 secret of 160 bits.
+func GenTotpKey() string {
+	key := make([]byte, 20)
+	if _, err := rand.Read(key); err != nil {
+		Warn("GenTotpKey %s", err)
+	}
+	return b32.EncodeToString(key)
+}
+
+// Totp returns code of the secret at time step by RFC 6238.
+func Totp(secret string, step int64) string {
+	key, err := b32.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return ""
+	}
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+// CheckTotp returns the time step matched by code, and allows one step of
+// clock skew. It returns zero if not matched.
+func CheckTotp(secret, code string, now int64) int64 {
+	if len(code) != TotpDigits {
+		return 0
+	}
+	step := now / TotpPeriod
+	for _, s := range []int64{step, step - 1, step + 1} {
+		if subtle.ConstantTimeCompare([]byte(Totp(secret, s)), []byte(code)) == 1 {
+			return s
+		}
+	}
+	return 0
+}
+
+// TotpUri returns provisioning uri for authenticator apps.
+func TotpUri(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("digits", fmt.Sprintf("%d", TotpDigits))
+	query.Set("period", fmt.Sprintf("%d", TotpPeriod))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// GenRecovery returns random recovery codes likes xxxxx-xxxxx.
+func GenRecovery(size int) []string {
+	codes := make([]string, 0, size)
+	for i := 0; i < size; i++ {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			Warn("GenRecovery %s", err)
+		}
+		value := strings.ToLower(b32.EncodeToString(buf))
+		codes = append(codes, value[:5]+"-"+value[5:10])
+	}
+	return codes
+}
+
+// HashRecovery returns hash of recovery code to save.
+func HashRecovery(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// SplitChallenge parses password of OpenVPN static challenge likes
+// SCRV1:<base64 password>:<base64 response>.
+func SplitChallenge(password string) (string, string) {
+	values := strings.Split(password, ":")
+	if len(values) != 3 || values[0] != "SCRV1" {
+		return password, ""
+	}
+	pass, err := base64.StdEncoding.DecodeString(values[1])
+	if err != nil {
+		return password, ""
+	}
+	code, err := base64.StdEncoding.DecodeString(values[2])
+	if err != nil {
+		return password, ""
+	}
+	return string(pass), string(code)
+}
@@ -0,0 +1,34 @@
+package libol
+
+import (
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestTotp(t *testing.T) {
+	// test vectors from RFC 6238 appendix B with 6 digits.
+	secret := b32.EncodeToString([]byte("12345678901234567890"))
+	assert.Equal(t, "287082", Totp(secret, 59/TotpPeriod), "be the same.")
+	assert.Equal(t, "081804", Totp(secret, 1111111109/TotpPeriod), "be the same.")
+	assert.Equal(t, int64(1111111109/TotpPeriod), CheckTotp(secret, "081804", 1111111109+TotpPeriod), "allow skew.")
+	assert.Equal(t, int64(0), CheckTotp(secret, "081804", 1111111109+3*TotpPeriod), "be expired.")
+
+	uri := TotpUri("OpenLAN", "hi@example", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/OpenLAN:hi@example?"), "be the same.")
+	assert.Equal(t, 32, len(GenTotpKey()), "be the same.")
+	codes := GenRecovery(2)
+	assert.Equal(t, 11, len(codes[0]), "be the same.")
+	assert.NotEqual(t, codes[0], codes[1], "be random.")
+}
+
+func TestSplitChallenge(t *testing.T) {
+	enc := base64.StdEncoding.EncodeToString
+	pass, code := SplitChallenge("SCRV1:" + enc([]byte("a:b")) + ":" + enc([]byte("123456")))
+	assert.Equal(t, "a:b", pass, "be the same.")
+	assert.Equal(t, "123456", code, "be the same.")
+	pass, code = SplitChallenge("plain")
+	assert.Equal(t, "plain", pass, "be the same.")
+	assert.Equal(t, "", code, "be the same.")
+}
@@ -8,6 +8,7 @@ import (
 
 type UdpConfig struct {
 	Block   kcp.BlockCrypt
+	Local   string        // source address to bind.
 	Timeout time.Duration // ns
 	Clients int
 	RdQus   int // per frames
@@ -98,6 +99,8 @@ func NewUdpClient(addr string, cfg *UdpConfig) *UdpClient {
 			timeout: cfg.Timeout,
 			block:   cfg.Block,
 			bufSize: cfg.RdQus * MaxFrame,
+			pmtu:    NewPmtu(),
+			reasm:   NewReassembler(16),
 		}),
 	}
 	return c
@@ -113,6 +116,8 @@ func NewUdpClientFromConn(conn net.Conn, cfg *UdpConfig) *UdpClient {
 			timeout: cfg.Timeout,
 			block:   cfg.Block,
 			bufSize: cfg.RdQus * MaxFrame,
+			pmtu:    NewPassivePmtu(),
+			reasm:   NewReassembler(16),
 		}),
 	}
 	c.updateConn(conn)
@@ -124,11 +129,18 @@ func (c *UdpClient) Connect() error {
 		return nil
 	}
 	c.out.Info("UdpClient.Connect: udp://%s", c.address)
-	conn, err := net.Dial("udp", c.address)
+	addr, err := net.ResolveUDPAddr("udp", c.address)
 	if err != nil {
 		return err
 	}
-	c.SetConnection(conn)
+	local := LocalUDPAddr(c.udpCfg.Local)
+	conn, err := net.DialUDP("udp", local, addr)
+	if err != nil {
+		return err
+	}
+	roam := NewRoamConn(conn)
+	roam.local = local
+	c.SetConnection(roam)
 	if c.listener.OnConnected != nil {
 		_ = c.listener.OnConnected(c)
 	}
@@ -169,3 +181,30 @@ func (c *UdpClient) SetStatus(v SocketStatus) {
 		c.status = v
 	}
 }
+
+func (c *UdpClient) RoamOffer() *RoamHello {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if conn, ok := c.connection.(*RoamConn); ok {
+		return conn.Offer()
+	}
+	return nil
+}
+
+func (c *UdpClient) RoamAccept(answer *RoamHello) error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if conn, ok := c.connection.(*RoamConn); ok {
+		return conn.Accept(answer)
+	}
+	return NewErr("operation notSupport")
+}
+
+func (c *UdpClient) RoamAnswer(offer *RoamHello) (*RoamHello, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if conn, ok := c.connection.(*XDPConn); ok {
+		return conn.connection.Answer(conn.remoteAddr, offer)
+	}
+	return nil, NewErr("operation notSupport")
+}
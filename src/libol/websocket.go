@@ -36,6 +36,8 @@ type WebCert struct {
 
 type WebConfig struct {
 	Cert    *WebCert
+	Proxy   string // url of outbound proxy.
+	Local   string // source address to bind.
 	Block   kcp.BlockCrypt
 	Timeout time.Duration // ns
 	RdQus   int           // per frames
@@ -193,11 +195,24 @@ func (t *WebClient) Connect() error {
 			return err
 		}
 	}
-	conn, err := websocket.DialConfig(config)
+	if t.webCfg.Proxy != "" {
+		t.out.Info("WebClient.Connect: via %s", RedactProxy(t.webCfg.Proxy))
+	}
+	conn, err := DialFrom(t.webCfg.Local, t.webCfg.Proxy, t.address, 0)
+	if err != nil {
+		return err
+	}
+	if config.TlsConfig != nil {
+		if conn, err = TlsHandshake(conn, t.address, config.TlsConfig); err != nil {
+			return err
+		}
+	}
+	ws, err := websocket.NewClient(config, conn)
 	if err != nil {
+		conn.Close()
 		return err
 	}
-	t.SetConnection(conn)
+	t.SetConnection(ws)
 	if t.listener.OnConnected != nil {
 		_ = t.listener.OnConnected(t)
 	}
@@ -9,12 +9,16 @@ import (
 type XDP struct {
 	lock       sync.RWMutex
 	bufSize    int
-	connection *net.UDPConn
+	connection *RoamListener
 	address    *net.UDPAddr
 	sessions   *SafeStrMap
 	accept     chan *XDPConn
+	pool       *BufferPool
+	writer     *UdpWriter
 }
 
+// XDPListen listens udp, and sessions negotiated roaming at login can
+// migrate address.
 func XDPListen(addr string, clients, bufSize int) (net.Listener, error) {
 	udpAddr, err := net.ResolveUDPAddr("udp", addr)
 	if err != nil {
@@ -29,16 +33,21 @@ func XDPListen(addr string, clients, bufSize int) (net.Listener, error) {
 		sessions: NewSafeStrMap(clients),
 		accept:   make(chan *XDPConn, 2),
 		bufSize:  bufSize,
+		pool:     NewBufferPool(bufSize + RoamHdrSize),
 	}
 	conn, err := net.ListenUDP("udp", udpAddr)
 	if err != nil {
 		return nil, err
 	}
-	x.connection = conn
+	x.connection = NewRoamListener(conn)
+	x.writer = NewUdpWriter(conn, x.pool, UdpBatch)
+	x.connection.writer = x.writer
+	Go(x.writer.Loop)
 	Go(x.Loop)
 	return x, nil
 }
 
+// Recv dispatches data to XDPConn, and the data is owned by it if no error.
 func (x *XDP) Recv(udpAddr *net.UDPAddr, data []byte) error {
 	// dispatch to XDPConn and new accept
 	addr := udpAddr.String()
@@ -52,10 +61,12 @@ func (x *XDP) Recv(udpAddr *net.UDPAddr, data []byte) error {
 		remoteAddr: udpAddr,
 		localAddr:  x.address,
 		readQueue:  make(chan []byte, 1024),
+		pool:       x.pool,
 		closed:     false,
 		onClose: func(conn *XDPConn) {
 			Info("XDP.Recv: onClose %s", conn)
 			x.sessions.Del(addr)
+			x.connection.Forget(udpAddr)
 		},
 	}
 	if err := x.sessions.Set(addr, conn); err != nil {
@@ -66,18 +77,28 @@ func (x *XDP) Recv(udpAddr *net.UDPAddr, data []byte) error {
 	return nil
 }
 
+func (x *XDP) recv(data []byte, udpAddr *net.UDPAddr) {
+	payload, first := x.connection.open(data, udpAddr)
+	if first == nil {
+		x.pool.Put(data)
+		return
+	}
+	// moves payload to head that the buffer can be put back.
+	n := copy(data, payload)
+	if err := x.Recv(first, data[:n]); err != nil {
+		x.pool.Put(data)
+		Warn("XDP.recv: %s", err)
+	}
+}
+
 // Loop forever
 func (x *XDP) Loop() {
+	reader := NewUdpReader(x.connection.UDPConn, x.pool, UdpBatch)
 	for {
-		data := make([]byte, x.bufSize)
-		n, udpAddr, err := x.connection.ReadFromUDP(data)
-		if err != nil {
+		if err := reader.Read(x.recv); err != nil {
 			Error("XDP.Loop %s", err)
 			break
 		}
-		if err := x.Recv(udpAddr, data[:n]); err != nil {
-			Warn("XDP.Loop: %s", err)
-		}
 	}
 }
 
@@ -93,6 +114,7 @@ func (x *XDP) Close() error {
 	defer x.lock.Unlock()
 
 	_ = x.connection.Close()
+	x.writer.Close()
 	return nil
 }
 
@@ -103,10 +125,11 @@ func (x *XDP) Addr() net.Addr {
 
 type XDPConn struct {
 	lock       sync.RWMutex
-	connection *net.UDPConn
+	connection *RoamListener
 	remoteAddr *net.UDPAddr
 	localAddr  *net.UDPAddr
 	readQueue  chan []byte
+	pool       *BufferPool
 	closed     bool
 	readDead   time.Time
 	writeDead  time.Time
@@ -117,6 +140,7 @@ func (c *XDPConn) toQueue(b []byte) {
 	c.lock.RLock()
 	if c.closed {
 		c.lock.RUnlock()
+		c.free(b)
 		return
 	} else {
 		c.lock.RUnlock()
@@ -124,6 +148,12 @@ func (c *XDPConn) toQueue(b []byte) {
 	c.readQueue <- b
 }
 
+func (c *XDPConn) free(b []byte) {
+	if c.pool != nil {
+		c.pool.Put(b)
+	}
+}
+
 func (c *XDPConn) Read(b []byte) (n int, err error) {
 	c.lock.RLock()
 	if c.closed {
@@ -151,7 +181,9 @@ func (c *XDPConn) Read(b []byte) (n int, err error) {
 		if timeout != nil {
 			timeout.Stop()
 		}
-		return copy(b, d), nil
+		n = copy(b, d)
+		c.free(d)
+		return n, nil
 	}
 }
 
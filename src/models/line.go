@@ -1,12 +1,18 @@
 package models
 
 import (
+	"github.com/danieldin95/openlan-go/src/libol"
 	"net"
 	"strconv"
+	"sync/atomic"
 	"time"
 )
 
 type Line struct {
+	// updated by Hit atomically, and first for alignment.
+	HitTime    int64
+	Packets    uint64
+	Bytes      uint64
 	EthType    uint16
 	IpSource   net.IP
 	IpDest     net.IP
@@ -14,18 +20,56 @@ type Line struct {
 	PortDest   uint16
 	PortSource uint16
 	NewTime    int64
-	HitTime    int64
+	ExportAt   int64  // start of counters to export.
+	exported   uint64 // packets exported by active timeout.
+	expBytes   uint64
+	User       string // of point sent or received.
+	Network    string
 }
 
 func NewLine(t uint16) *Line {
 	l := &Line{
-		EthType: t,
-		NewTime: time.Now().Unix(),
-		HitTime: time.Now().Unix(),
+		EthType:  t,
+		NewTime:  time.Now().Unix(),
+		HitTime:  time.Now().Unix(),
+		ExportAt: time.Now().Unix(),
 	}
 	return l
 }
 
+// Hit adds counters of line by frames, and it's safe with Copy.
+func (l *Line) Hit(packets, bytes uint64, now int64) {
+	atomic.AddUint64(&l.Packets, packets)
+	atomic.AddUint64(&l.Bytes, bytes)
+	atomic.StoreInt64(&l.HitTime, now)
+}
+
+// Copy returns line to expose, and counters are loaded atomically.
+func (l *Line) Copy() *Line {
+	return &Line{
+		HitTime:    atomic.LoadInt64(&l.HitTime),
+		Packets:    atomic.LoadUint64(&l.Packets),
+		Bytes:      atomic.LoadUint64(&l.Bytes),
+		EthType:    l.EthType,
+		IpSource:   l.IpSource,
+		IpDest:     l.IpDest,
+		IpProtocol: l.IpProtocol,
+		PortDest:   l.PortDest,
+		PortSource: l.PortSource,
+		NewTime:    l.NewTime,
+		User:       l.User,
+		Network:    l.Network,
+	}
+}
+
+// Exported marks counters exported by active timeout, and the next record
+// has counters since now.
+func (l *Line) Exported(now int64) {
+	l.ExportAt = now
+	l.exported = atomic.LoadUint64(&l.Packets)
+	l.expBytes = atomic.LoadUint64(&l.Bytes)
+}
+
 func (l *Line) String() string {
 	str := strconv.FormatUint(uint64(l.EthType), 10)
 	str += ":" + l.IpSource.String()
@@ -36,10 +80,29 @@ func (l *Line) String() string {
 	return str
 }
 
+// Record returns record of flow since lastly exported, and the time is in
+// milliseconds.
+func (l *Line) Record(reason uint8) *libol.FlowRecord {
+	return &libol.FlowRecord{
+		Source:     l.IpSource,
+		Dest:       l.IpDest,
+		Protocol:   l.IpProtocol,
+		PortSource: l.PortSource,
+		PortDest:   l.PortDest,
+		Packets:    atomic.LoadUint64(&l.Packets) - l.exported,
+		Bytes:      atomic.LoadUint64(&l.Bytes) - l.expBytes,
+		Start:      l.ExportAt * 1000,
+		End:        atomic.LoadInt64(&l.HitTime) * 1000,
+		Reason:     reason,
+		User:       l.User,
+		Network:    l.Network,
+	}
+}
+
 func (l *Line) UpTime() int64 {
 	return time.Now().Unix() - l.NewTime
 }
 
 func (l *Line) LastTime() int64 {
-	return time.Now().Unix() - l.HitTime
+	return time.Now().Unix() - atomic.LoadInt64(&l.HitTime)
 }
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLine_Exported(t *testing.T) {
+	l := NewLine(0x0800)
+	l.NewTime, l.ExportAt = 100, 100
+	l.Hit(2, 200, 150)
+	r := l.Record(0)
+	assert.Equal(t, uint64(2), r.Packets, "be the same.")
+	assert.Equal(t, int64(100000), r.Start, "be the same.")
+	l.Exported(160)
+	l.Hit(1, 50, 170)
+	r = l.Record(0)
+	assert.Equal(t, uint64(1), r.Packets, "since exported.")
+	assert.Equal(t, uint64(50), r.Bytes, "since exported.")
+	assert.Equal(t, int64(160000), r.Start, "be the same.")
+	c := l.Copy()
+	assert.Equal(t, uint64(3), c.Packets, "be total.")
+	assert.Equal(t, int64(100), c.NewTime, "be kept.")
+}
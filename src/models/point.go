@@ -15,9 +15,15 @@ type Point struct {
 	Uptime   int64              `json:"uptime"`
 	Status   string             `json:"status"`
 	IfName   string             `json:"device"`
+	Mtu      int                `json:"mtu,omitempty"`
 	Client   libol.SocketClient `json:"-"`
 	Device   network.Taper      `json:"-"`
 	System   string             `json:"system"`
+	Counted  int64              `json:"-"` // bytes already accounted to user.
+	Bond     *libol.Bond        `json:"-"` // connections of bonding if not nil.
+	TcpMss   int                `json:"-"` // maximum mss of tcp syn, and 0 is none.
+	ClampMss bool               `json:"-"` // clamp mss by mtu probed.
+	Health   *libol.Health      `json:"-"`
 }
 
 func NewPoint(c libol.SocketClient, d network.Taper, proto string) (w *Point) {
@@ -27,6 +33,7 @@ func NewPoint(c libol.SocketClient, d network.Taper, proto string) (w *Point) {
 		Client:   c,
 		Device:   d,
 		Protocol: proto,
+		Health:   libol.NewHealth(),
 	}
 }
 
@@ -35,6 +42,7 @@ func (p *Point) Update() *Point {
 	if client != nil {
 		p.Uptime = client.UpTime()
 		p.Status = client.Status().String()
+		p.Mtu = client.Mtu()
 	}
 	device := p.Device
 	if device != nil {
@@ -43,6 +51,14 @@ func (p *Point) Update() *Point {
 	return p
 }
 
+// Statistics returns of all connections if bonding.
+func (p *Point) Statistics() map[string]int64 {
+	if p.Bond != nil {
+		return p.Bond.Statistics()
+	}
+	return p.Client.Statistics()
+}
+
 func (p *Point) SetUser(user *User) {
 	p.User = user.Name
 	p.UUID = user.UUID
@@ -54,3 +70,14 @@ func (p *Point) SetUser(user *User) {
 	p.System = user.System
 	p.Alias = user.Alias
 }
+
+// Clamp lowers mss of tcp syn in frame by mtu of client and setting of
+// bridge, and nothing is changed if neither enabled.
+func (p *Point) Clamp(frame []byte, mtu int) {
+	if !p.ClampMss {
+		mtu = 0
+	}
+	if mtu > 0 || p.TcpMss > 0 {
+		libol.ClampMssTo(frame, mtu, p.TcpMss)
+	}
+}
@@ -2,13 +2,28 @@ package models
 
 import (
 	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/danieldin95/openlan-go/src/network"
 	"github.com/danieldin95/openlan-go/src/schema"
 )
 
+func NewHealthSchema(h *libol.Health) *schema.Health {
+	if h == nil {
+		return nil
+	}
+	sts := h.Stats()
+	return &schema.Health{
+		Rtt:      sts.Rtt,
+		Jitter:   sts.Jitter,
+		Loss:     sts.Loss,
+		Score:    sts.Score,
+		Degraded: sts.Degraded,
+	}
+}
+
 func NewPointSchema(p *Point) schema.Point {
 	client, dev := p.Client, p.Device
-	sts := client.Statistics()
-	return schema.Point{
+	sts := p.Statistics()
+	obj := schema.Point{
 		Uptime:    p.Uptime,
 		UUID:      p.UUID,
 		Alias:     p.Alias,
@@ -23,7 +38,16 @@ func NewPointSchema(p *Point) schema.Point {
 		Network:   p.Network,
 		AliveTime: client.AliveTime(),
 		System:    p.System,
+		Mtu:       client.Mtu(),
+		Health:    NewHealthSchema(p.Health),
+	}
+	if p.Bond != nil {
+		obj.Bond = p.Bond.Id
+		for _, m := range p.Bond.Members() {
+			obj.Links = append(obj.Links, m.Client.String())
+		}
 	}
+	return obj
 }
 
 func NewLinkSchema(p *Point) schema.Link {
@@ -42,6 +66,7 @@ func NewLinkSchema(p *Point) schema.Link {
 		ErrPkt:    sts[libol.CsSendError],
 		Network:   p.Network,
 		AliveTime: client.AliveTime(),
+		Health:    NewHealthSchema(p.Health),
 	}
 }
 
@@ -66,26 +91,53 @@ func NewOnLineSchema(l *Line) schema.OnLine {
 		IpProto:    libol.IpProto2Str(l.IpProtocol),
 		PortSource: l.PortSource,
 		PortDest:   l.PortDest,
+		Packets:    l.Packets,
+		Bytes:      l.Bytes,
+		User:       l.User,
+		Network:    l.Network,
 	}
 }
 
 func NewUserSchema(u *User) schema.User {
 	return schema.User{
-		Name:     u.Name,
-		Password: u.Password,
-		Alias:    u.Alias,
-		Network:  u.Network,
-		Role:     u.Role,
+		Name:       u.Name,
+		Password:   u.Password,
+		Alias:      u.Alias,
+		Network:    u.Network,
+		Role:       u.Role,
+		PassExpire: u.PassExpire,
+		NotBefore:  u.NotBefore,
+		NotAfter:   u.NotAfter,
+		Disabled:   u.Disabled,
+		MaxSession: u.MaxSession,
+		Quota:      u.Quota,
+		Used:       u.Used,
+		Otp:        u.OtpKey != "",
+		Vlan:       u.Vlan.String(),
+		Promisc:    u.Promisc,
+		Security:   u.Security.String(),
+		UpdateAt:   u.UpdateAt,
 	}
 }
 
 func SchemaToUserModel(user *schema.User) *User {
 	obj := &User{
-		Alias:    user.Alias,
-		Password: user.Password,
-		Name:     user.Name,
-		Network:  user.Network,
-		Role:     user.Role,
+		Alias:      user.Alias,
+		Password:   user.Password,
+		Name:       user.Name,
+		Network:    user.Network,
+		Role:       user.Role,
+		PassExpire: user.PassExpire,
+		NotBefore:  user.NotBefore,
+		NotAfter:   user.NotAfter,
+		Disabled:   user.Disabled,
+		MaxSession: user.MaxSession,
+		Quota:      user.Quota,
+		Used:       user.Used,
+		Code:       user.Code,
+		Vlan:       network.ParseVlan(user.Vlan),
+		Promisc:    user.Promisc,
+		Security:   network.ParseSecurity(user.Security),
 	}
 	obj.Update()
 	return obj
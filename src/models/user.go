@@ -3,21 +3,50 @@ package models
 import (
 	"fmt"
 	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/danieldin95/openlan-go/src/network"
 	"runtime"
 	"strings"
 	"time"
 )
 
 type User struct {
-	Alias    string             `json:"alias"`
-	Name     string             `json:"name"`
-	Network  string             `json:"network"`
-	Password string             `json:"password"`
-	UUID     string             `json:"uuid"`
-	System   string             `json:"system"`
-	Role     string             `json:"type"` // admin , guest or ldap
-	Last     libol.SocketClient `json:"last"` // lastly accessed by this.
-	UpdateAt int64
+	Alias      string             `json:"alias"`
+	Name       string             `json:"name"`
+	Network    string             `json:"network"`
+	Password   string             `json:"password"`
+	UUID       string             `json:"uuid"`
+	System     string             `json:"system"`
+	Role       string             `json:"type"`    // admin or guest
+	Backend    string             `json:"backend"` // ldap, radius or empty for local
+	Last       libol.SocketClient `json:"last"`    // lastly accessed by this.
+	UpdateAt   int64
+	PassExpire int64                  `json:"passExpire"` // password expired time.
+	NotBefore  int64                  `json:"notBefore"`
+	NotAfter   int64                  `json:"notAfter"`
+	Disabled   bool                   `json:"disabled"`
+	MaxSession int                    `json:"maxSession"`       // zero is unlimited.
+	Quota      int64                  `json:"quota"`            // bytes, zero is unlimited.
+	Used       int64                  `json:"used"`             // bytes transferred.
+	Code       string                 `json:"code,omitempty"`   // one time password of login.
+	State      []byte                 `json:"state,omitempty"`  // of radius challenge responded by code.
+	Challenge  *libol.RadiusChallenge `json:"-"`                // by radius server if checking failed.
+	OtpKey     string                 `json:"-"`                // secret of totp.
+	OtpStep    int64                  `json:"-"`                // time step lastly used.
+	Recovery   []string               `json:"-"`                // hashes of recovery codes.
+	Bond       string                 `json:"bond,omitempty"`   // id of bonding joined by this connection.
+	Weight     int                    `json:"weight,omitempty"` // weight of this connection in bonding.
+	Roam       *libol.RoamHello       `json:"roam,omitempty"`   // offer of roaming by udp or kcp.
+	Vlan       *network.PortVlan      `json:"-"`                // of tap on bridge.
+	Promisc    bool                   `json:"-"`                // reaches all points if isolation.
+	Security   *network.PortSecurity  `json:"-"`                // of source addresses on tap.
+}
+
+// Login is body of login response after okay or challenge, and older
+// switch has none.
+type Login struct {
+	Roam    *libol.RoamHello `json:"roam,omitempty"`    // answer of roaming.
+	Message string           `json:"message,omitempty"` // of challenge.
+	State   []byte           `json:"state,omitempty"`   // of challenge.
 }
 
 func NewUser(name, network, password string) *User {
@@ -57,3 +86,20 @@ func (u *User) Update() {
 func (u *User) Id() string {
 	return u.Name + "@" + u.Network
 }
+
+// Allowed returns error if user is disabled, not in validity or out of quota.
+func (u *User) Allowed(now int64) error {
+	if u.Disabled {
+		return libol.NewErr("user disabled")
+	}
+	if u.NotBefore > 0 && now < u.NotBefore {
+		return libol.NewErr("user not valid yet")
+	}
+	if u.NotAfter > 0 && now > u.NotAfter {
+		return libol.NewErr("user expired")
+	}
+	if u.Quota > 0 && u.Used >= u.Quota {
+		return libol.NewErr("user out of quota")
+	}
+	return nil
+}
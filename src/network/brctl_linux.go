@@ -2,9 +2,13 @@ package network
 
 import (
 	"fmt"
+	"github.com/danieldin95/openlan-go/src/libol"
 	"github.com/vishvananda/netlink"
+	"net"
 	"os"
+	"os/exec"
 	"strconv"
+	"syscall"
 )
 
 type BrCtl struct {
@@ -104,6 +108,75 @@ func (b *BrCtl) CallIptables(value int) error {
 	return nil
 }
 
+func (b *BrCtl) VlanFiltering(on bool) error {
+	file := b.SysPath("vlan_filtering")
+	fp, err := os.OpenFile(file, os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	value := "0"
+	if on {
+		value = "1"
+	}
+	if _, err := fp.Write([]byte(value)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetVlan enables vlan filtering of bridge, and replaces default vlan 1
+// of port by the settings. The bridge itself is a port by its name, and the
+// trunk carries the allowed vlans, or 1-4094 if none likes virtual bridge.
+func (b *BrCtl) SetVlan(port string, vlan *PortVlan) error {
+	if vlan == nil {
+		return nil
+	}
+	if err := b.VlanFiltering(true); err != nil {
+		return err
+	}
+	link, err := netlink.LinkByName(port)
+	if err != nil {
+		return err
+	}
+	self := port == b.Name
+	_ = netlink.BridgeVlanDel(link, 1, true, true, self, !self)
+	if vlan.Mode == VlanTrunk {
+		if err := b.trunk(link, vlan.Allowed, self); err != nil {
+			return err
+		}
+	}
+	// pvid is added last, so it's untagged even if in the range of trunk.
+	if vlan.Pvid > 0 {
+		if err := netlink.BridgeVlanAdd(link, uint16(vlan.Pvid), true, true, self, !self); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trunk adds tagged vlans of port, and 1-4094 if none by once.
+func (b *BrCtl) trunk(link netlink.Link, allowed []int, self bool) error {
+	if len(allowed) == 0 {
+		args := []string{"vlan", "add", "dev", link.Attrs().Name, "vid", "1-" + strconv.Itoa(VlanMax)}
+		if self {
+			args = append(args, "self")
+		} else {
+			args = append(args, "master")
+		}
+		if out, err := exec.Command("bridge", args...).CombinedOutput(); err != nil {
+			return libol.NewErr("bridge %s: %s", err, out)
+		}
+		return nil
+	}
+	for _, vid := range allowed {
+		if err := netlink.BridgeVlanAdd(link, uint16(vid), false, false, self, !self); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type BrPort struct {
 	Name string
 	Path string
@@ -134,3 +207,158 @@ func (p *BrPort) Cost(cost int) error {
 	}
 	return nil
 }
+
+// Isolated sets port can't forward to other isolated ports.
+func (p *BrPort) Isolated(on bool) error {
+	file := p.SysPath("isolated")
+	fp, err := os.OpenFile(file, os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	value := "0"
+	if on {
+		value = "1"
+	}
+	if _, err := fp.Write([]byte(value)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Guard sets port is blocked if received bpdu.
+func (p *BrPort) Guard(on bool) error {
+	file := p.SysPath("bpdu_guard")
+	fp, err := os.OpenFile(file, os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	value := "0"
+	if on {
+		value = "1"
+	}
+	if _, err := fp.Write([]byte(value)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Learning sets whether learns addresses from the port.
+func (p *BrPort) Learning(on bool) error {
+	file := p.SysPath("learning")
+	fp, err := os.OpenFile(file, os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	value := "0"
+	if on {
+		value = "1"
+	}
+	if _, err := fp.Write([]byte(value)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func ebtables(args ...string) ([]byte, error) {
+	return exec.Command("ebtables", args...).CombinedOutput()
+}
+
+// Secure binds addresses to the port by static fdb, and drops frames
+// sourced by others with ebtables. The empty addresses clear it.
+func (p *BrPort) Secure(macs []string) error {
+	if len(macs) == 0 {
+		p.unfilter()
+		return p.Learning(true)
+	}
+	if err := p.bind(macs); err != nil {
+		return err
+	}
+	if err := p.filter(macs); err != nil {
+		return err
+	}
+	return p.Learning(false)
+}
+
+// Restrict binds addresses to the port by static fdb, and others are
+// still learned. If full, frames sourced by addresses not bound or not
+// allowed are dropped, and the maximum of addresses is enforced.
+func (p *BrPort) Restrict(macs, allowed []string, full bool) error {
+	if err := p.bind(macs); err != nil {
+		return err
+	}
+	if full {
+		if err := p.filter(append(append([]string{}, macs...), allowed...)); err != nil {
+			return err
+		}
+	} else {
+		p.unfilter()
+	}
+	return p.Learning(true)
+}
+
+func (p *BrPort) bind(macs []string) error {
+	if len(macs) == 0 {
+		return nil
+	}
+	link, err := netlink.LinkByName(p.Name)
+	if err != nil {
+		return err
+	}
+	for _, addr := range macs {
+		hw, err := net.ParseMAC(addr)
+		if err != nil {
+			return err
+		}
+		neigh := &netlink.Neigh{
+			LinkIndex:    link.Attrs().Index,
+			Family:       syscall.AF_BRIDGE,
+			State:        netlink.NUD_NOARP,
+			Flags:        netlink.NTF_MASTER,
+			HardwareAddr: hw,
+		}
+		if err := netlink.NeighSet(neigh); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *BrPort) hooks() []string {
+	return []string{"INPUT", "FORWARD"}
+}
+
+func (p *BrPort) unfilter() {
+	chain := "OLS-" + p.Name
+	for _, hook := range p.hooks() {
+		_, _ = ebtables("-D", hook, "-i", p.Name, "-j", chain)
+	}
+	_, _ = ebtables("-F", chain)
+	_, _ = ebtables("-X", chain)
+}
+
+// filter drops frames sourced by addresses not in macs.
+func (p *BrPort) filter(macs []string) error {
+	chain := "OLS-" + p.Name
+	for _, hook := range p.hooks() {
+		_, _ = ebtables("-D", hook, "-i", p.Name, "-j", chain)
+	}
+	_, _ = ebtables("-F", chain)
+	_, _ = ebtables("-N", chain)
+	for _, addr := range macs {
+		if out, err := ebtables("-A", chain, "-s", addr, "-j", "RETURN"); err != nil {
+			return libol.NewErr("%s %s", err, out)
+		}
+	}
+	if out, err := ebtables("-A", chain, "-j", "DROP"); err != nil {
+		return libol.NewErr("%s %s", err, out)
+	}
+	for _, hook := range p.hooks() {
+		if out, err := ebtables("-A", hook, "-i", p.Name, "-j", chain); err != nil {
+			return libol.NewErr("%s %s", err, out)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,499 @@
+package network
+
+import (
+	"encoding/binary"
+	"github.com/danieldin95/openlan-go/src/libol"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	McastTimeout = 260 // seconds of group membership interval.
+	McastQuery   = 125 // seconds of general query interval.
+	igmpProto    = 0x02
+	icmp6Proto   = 0x3a
+)
+
+// stormBucket limits broadcast, multicast and unknown unicast frames
+// received from a port by tokens per second.
+type stormBucket struct {
+	tokens int
+	last   int64
+	drops  int64
+}
+
+// mcastGroup is ports subscribed a multicast group in a vlan.
+type mcastGroup struct {
+	ports map[string]int64 // expired time by port name.
+}
+
+// flooder suppresses floods of VirtualBridge.
+type flooder struct {
+	lock     sync.Mutex
+	storm    int // frames per second, and zero is unlimited.
+	buckets  map[string]*stormBucket
+	snooping bool
+	groups   map[string]*mcastGroup
+	routers  map[string]int64 // ports of multicast router by queries.
+	queryAt  int64            // general query sent by the bridge itself.
+	lookup   func(ip net.IP) net.HardwareAddr
+}
+
+func (b *VirtualBridge) SetStorm(pps int) error {
+	b.flood.lock.Lock()
+	defer b.flood.lock.Unlock()
+	b.flood.storm = pps
+	b.flood.buckets = make(map[string]*stormBucket, 32)
+	b.out.Info("VirtualBridge.SetStorm: %d", pps)
+	return nil
+}
+
+func (b *VirtualBridge) SetSnooping(on bool) error {
+	b.flood.lock.Lock()
+	defer b.flood.lock.Unlock()
+	b.flood.snooping = on
+	b.flood.groups = make(map[string]*mcastGroup, 32)
+	b.flood.routers = make(map[string]int64, 4)
+	b.out.Info("VirtualBridge.SetSnooping: %v", on)
+	return nil
+}
+
+func (b *VirtualBridge) SetArpProxy(lookup func(ip net.IP) net.HardwareAddr) error {
+	b.flood.lock.Lock()
+	defer b.flood.lock.Unlock()
+	b.flood.lookup = lookup
+	b.out.Info("VirtualBridge.SetArpProxy: %v", lookup != nil)
+	return nil
+}
+
+// allowFlood returns whether the port has tokens to flood a frame.
+func (b *VirtualBridge) allowFlood(port Taper) bool {
+	f := &b.flood
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.storm <= 0 {
+		return true
+	}
+	now := time.Now().UnixNano() / 1e6
+	name := port.Name()
+	bucket, ok := f.buckets[name]
+	if !ok {
+		bucket = &stormBucket{tokens: f.storm, last: now}
+		f.buckets[name] = bucket
+	}
+	if elapsed := now - bucket.last; elapsed > 0 {
+		bucket.tokens += int(elapsed * int64(f.storm) / 1000)
+		if bucket.tokens > f.storm {
+			bucket.tokens = f.storm
+		}
+		bucket.last = now
+	}
+	if bucket.tokens <= 0 {
+		bucket.drops++
+		b.sts.Storm++
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// StormStats returns frames dropped by storm control of ports.
+func (b *VirtualBridge) StormStats() map[string]int64 {
+	f := &b.flood
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.storm <= 0 {
+		return nil
+	}
+	stats := make(map[string]int64, len(f.buckets))
+	for name, bucket := range f.buckets {
+		stats[name] = bucket.drops
+	}
+	return stats
+}
+
+// proxyArp answers arp request by neighbor of the switch, and returns true
+// if answered.
+func (b *VirtualBridge) proxyArp(m *Framer) bool {
+	b.flood.lock.Lock()
+	lookup := b.flood.lookup
+	b.flood.lock.Unlock()
+	data := m.Data
+	if lookup == nil || len(data) < libol.EtherLen+28 {
+		return false
+	}
+	if binary.BigEndian.Uint16(data[12:14]) != libol.EthArp {
+		return false
+	}
+	arp := data[libol.EtherLen:]
+	if binary.BigEndian.Uint16(arp[6:8]) != libol.ArpRequest {
+		return false
+	}
+	sender, target := net.IP(arp[14:18]), net.IP(arp[24:28])
+	// gratuitous arp announces itself, and probe checks address conflict.
+	if sender.Equal(target) || sender.Equal(net.IPv4zero) {
+		return false
+	}
+	hw := lookup(target)
+	if hw == nil {
+		return false
+	}
+	// the target is on the same port, and it'll answer itself.
+	if fdb := b.GetMac(b.FdbKey(m.Vlan, hw)); fdb == nil || fdb.Device == m.Source {
+		return false
+	}
+	reply := make([]byte, libol.EtherLen+28)
+	copy(reply[0:6], data[6:12])
+	copy(reply[6:12], hw)
+	binary.BigEndian.PutUint16(reply[12:14], libol.EthArp)
+	resp := reply[libol.EtherLen:]
+	copy(resp[0:6], arp[0:6]) // hardware and protocol
+	binary.BigEndian.PutUint16(resp[6:8], libol.ArpReply)
+	copy(resp[8:14], hw)
+	copy(resp[14:18], target)
+	copy(resp[18:24], arp[8:14])
+	copy(resp[24:28], arp[14:18])
+	frame := b.GetVlan(m.Source).Egress(m.Vlan, reply)
+	if frame == nil {
+		return false
+	}
+	b.sts.Send++
+	b.sts.Proxy++
+	if _, err := m.Source.Send(frame); err != nil {
+		b.out.Warn("VirtualBridge.proxyArp: %s %s", m.Source, err)
+	}
+	return true
+}
+
+func mcastKey(vlan int, group net.IP) string {
+	return strconv.Itoa(vlan) + "/" + group.String()
+}
+
+func (f *flooder) join(vlan int, group net.IP, port string, now int64) {
+	key := mcastKey(vlan, group)
+	g, ok := f.groups[key]
+	if !ok {
+		g = &mcastGroup{ports: make(map[string]int64, 4)}
+		f.groups[key] = g
+	}
+	g.ports[port] = now + McastTimeout
+}
+
+func (f *flooder) leave(vlan int, group net.IP, port string) {
+	key := mcastKey(vlan, group)
+	if g, ok := f.groups[key]; ok {
+		delete(g.ports, port)
+		if len(g.ports) == 0 {
+			delete(f.groups, key)
+		}
+	}
+}
+
+// igmp learns reports and queries of IGMPv2/v3.
+func (f *flooder) igmp(vlan int, data []byte, port string, now int64) {
+	if len(data) < 8 {
+		return
+	}
+	switch data[0] {
+	case 0x11: // query
+		f.routers[port] = now + McastTimeout
+	case 0x16: // v2 report
+		f.join(vlan, net.IP(data[4:8]), port, now)
+	case 0x17: // v2 leave
+		f.leave(vlan, net.IP(data[4:8]), port)
+	case 0x22: // v3 report
+		count := int(binary.BigEndian.Uint16(data[6:8]))
+		data = data[8:]
+		for i := 0; i < count && len(data) >= 8; i++ {
+			typ, aux := data[0], int(data[1])*4
+			sources := int(binary.BigEndian.Uint16(data[2:4]))
+			group := net.IP(data[4:8])
+			// include none is leave.
+			if (typ == 1 || typ == 3) && sources == 0 {
+				f.leave(vlan, group, port)
+			} else if typ != 6 {
+				f.join(vlan, group, port, now)
+			}
+			size := 8 + sources*4 + aux
+			if size > len(data) {
+				break
+			}
+			data = data[size:]
+		}
+	}
+}
+
+// mld learns reports and queries of MLDv1/v2.
+func (f *flooder) mld(vlan int, data []byte, port string, now int64) {
+	if len(data) < 24 {
+		return
+	}
+	switch data[0] {
+	case 130: // query
+		f.routers[port] = now + McastTimeout
+	case 131: // v1 report
+		f.join(vlan, net.IP(data[8:24]), port, now)
+	case 132: // v1 done
+		f.leave(vlan, net.IP(data[8:24]), port)
+	case 143: // v2 report
+		count := int(binary.BigEndian.Uint16(data[6:8]))
+		data = data[8:]
+		for i := 0; i < count && len(data) >= 20; i++ {
+			typ, aux := data[0], int(data[1])*4
+			sources := int(binary.BigEndian.Uint16(data[2:4]))
+			group := net.IP(data[4:20])
+			if (typ == 1 || typ == 3) && sources == 0 {
+				f.leave(vlan, group, port)
+			} else if typ != 6 {
+				f.join(vlan, group, port, now)
+			}
+			size := 20 + sources*16 + aux
+			if size > len(data) {
+				break
+			}
+			data = data[size:]
+		}
+	}
+}
+
+// mcastOf returns group of multicast frame and the payload of igmp or mld,
+// and the group is nil if not routable multicast.
+func mcastOf(data []byte) (net.IP, []byte, bool) {
+	if len(data) < libol.EtherLen {
+		return nil, nil, false
+	}
+	ip := data[libol.EtherLen:]
+	switch binary.BigEndian.Uint16(data[12:14]) {
+	case libol.EthIp4:
+		if len(ip) < libol.Ipv4Len {
+			return nil, nil, false
+		}
+		size := int(ip[0]&0x0f) * 4
+		if size < libol.Ipv4Len || size > len(ip) {
+			return nil, nil, false
+		}
+		if ip[9] == igmpProto {
+			return nil, ip[size:], true
+		}
+		group := net.IP(ip[16:20])
+		if !group.IsMulticast() || group.IsLinkLocalMulticast() {
+			return nil, nil, false
+		}
+		return group, nil, false
+	case libol.EthIp6:
+		if len(ip) < 40 {
+			return nil, nil, false
+		}
+		next, payload := ip[6], ip[40:]
+		// skip hop-by-hop options with router alert.
+		if next == 0 && len(payload) >= 8 {
+			size := (int(payload[1]) + 1) * 8
+			if size > len(payload) {
+				return nil, nil, false
+			}
+			next, payload = payload[0], payload[size:]
+		}
+		if next == icmp6Proto && len(payload) > 0 {
+			switch payload[0] {
+			case 130, 131, 132, 143:
+				return nil, payload, true
+			}
+		}
+		group := net.IP(ip[24:40])
+		if !group.IsMulticast() || group.IsLinkLocalMulticast() || group.IsInterfaceLocalMulticast() {
+			return nil, nil, false
+		}
+		return group, nil, false
+	}
+	return nil, nil, false
+}
+
+// snoop learns membership from the frame if snooping.
+func (b *VirtualBridge) snoop(m *Framer) {
+	f := &b.flood
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if !f.snooping || m.Data[0]&0x01 == 0 {
+		return
+	}
+	_, payload, ctrl := mcastOf(m.Data)
+	if !ctrl {
+		return
+	}
+	now := time.Now().Unix()
+	if binary.BigEndian.Uint16(m.Data[12:14]) == libol.EthIp4 {
+		f.igmp(m.Vlan, payload, m.Source.Name(), now)
+	} else {
+		f.mld(m.Vlan, payload, m.Source.Name(), now)
+	}
+}
+
+// mcastPorts returns names of ports subscribed group of the frame and
+// multicast routers, and nil if should flood.
+func (b *VirtualBridge) mcastPorts(m *Framer) map[string]bool {
+	f := &b.flood
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if !f.snooping {
+		return nil
+	}
+	group, _, _ := mcastOf(m.Data)
+	if group == nil {
+		return nil
+	}
+	now := time.Now().Unix()
+	ports := make(map[string]bool, 8)
+	if g, ok := f.groups[mcastKey(m.Vlan, group)]; ok {
+		for name, expire := range g.ports {
+			if expire > now {
+				ports[name] = true
+			}
+		}
+	}
+	for name, expire := range f.routers {
+		if expire > now {
+			ports[name] = true
+		}
+	}
+	// the bridge itself likes a router.
+	if b.kernel != nil {
+		ports[b.kernel.Name()] = true
+	}
+	return ports
+}
+
+// expireMcast deletes memberships and routers timeout.
+func (b *VirtualBridge) expireMcast() {
+	f := &b.flood
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	now := time.Now().Unix()
+	for key, g := range f.groups {
+		for name, expire := range g.ports {
+			if expire <= now {
+				delete(g.ports, name)
+			}
+		}
+		if len(g.ports) == 0 {
+			delete(f.groups, key)
+		}
+	}
+	for name, expire := range f.routers {
+		if expire <= now {
+			delete(f.routers, name)
+		}
+	}
+}
+
+// querier sends general queries to vlans with groups if no router
+// queries, and memberships are refreshed by reports answered.
+func (b *VirtualBridge) querier(now int64) {
+	f := &b.flood
+	f.lock.Lock()
+	if !f.snooping || now-f.queryAt < McastQuery {
+		f.lock.Unlock()
+		return
+	}
+	for _, expire := range f.routers {
+		if expire > now {
+			f.lock.Unlock()
+			return
+		}
+	}
+	f.queryAt = now
+	v4 := make(map[int]bool, 4)
+	v6 := make(map[int]bool, 4)
+	for key := range f.groups {
+		values := strings.SplitN(key, "/", 2)
+		vlan, _ := strconv.Atoi(values[0])
+		if net.ParseIP(values[1]).To4() != nil {
+			v4[vlan] = true
+		} else {
+			v6[vlan] = true
+		}
+	}
+	f.lock.Unlock()
+	var src net.IP
+	if ip, _, err := net.ParseCIDR(b.address); err == nil {
+		src = ip.To4()
+	}
+	for vlan := range v4 {
+		frame := &Framer{Data: igmpQuery(b.stp.address, src), Vlan: vlan, Source: b.kernel}
+		_ = b.FloodTo(frame, nil)
+	}
+	for vlan := range v6 {
+		frame := &Framer{Data: mldQuery(b.stp.address), Vlan: vlan, Source: b.kernel}
+		_ = b.FloodTo(frame, nil)
+	}
+}
+
+// igmpQuery returns frame of igmpv2 general query, and the source is
+// 0.0.0.0 if no address.
+func igmpQuery(hw net.HardwareAddr, src net.IP) []byte {
+	frame := make([]byte, libol.EtherLen+24+8)
+	copy(frame[0:6], []byte{0x01, 0x00, 0x5e, 0x00, 0x00, 0x01})
+	copy(frame[6:12], hw)
+	binary.BigEndian.PutUint16(frame[12:14], libol.EthIp4)
+	ip := frame[libol.EtherLen:]
+	ip[0], ip[1] = 0x46, 0xc0
+	binary.BigEndian.PutUint16(ip[2:4], 24+8)
+	ip[8], ip[9] = 1, igmpProto
+	copy(ip[12:16], src)
+	copy(ip[16:20], []byte{224, 0, 0, 1})
+	copy(ip[20:24], []byte{0x94, 0x04, 0x00, 0x00}) // router alert.
+	binary.BigEndian.PutUint16(ip[10:12], checksum(ip[:24]))
+	igmp := ip[24:]
+	igmp[0], igmp[1] = 0x11, 100 // max response in 1/10 seconds.
+	binary.BigEndian.PutUint16(igmp[2:4], checksum(igmp))
+	return frame
+}
+
+// mldQuery returns frame of mldv1 general query from link local address
+// of the bridge.
+func mldQuery(hw net.HardwareAddr) []byte {
+	frame := make([]byte, libol.EtherLen+40+8+24)
+	copy(frame[0:6], []byte{0x33, 0x33, 0x00, 0x00, 0x00, 0x01})
+	copy(frame[6:12], hw)
+	binary.BigEndian.PutUint16(frame[12:14], libol.EthIp6)
+	ip := frame[libol.EtherLen:]
+	ip[0] = 0x60
+	binary.BigEndian.PutUint16(ip[4:6], 8+24)
+	ip[6], ip[7] = 0, 1 // hop-by-hop options, and hop limit.
+	src, dst := ip[8:24], ip[24:40]
+	src[0], src[1] = 0xfe, 0x80
+	if len(hw) == 6 {
+		copy(src[8:11], hw[0:3])
+		src[8] ^= 0x02
+		src[11], src[12] = 0xff, 0xfe
+		copy(src[13:16], hw[3:6])
+	}
+	dst[0], dst[1], dst[15] = 0xff, 0x02, 0x01
+	copy(ip[40:48], []byte{icmp6Proto, 0, 0x05, 0x02, 0x00, 0x00, 0x01, 0x00}) // router alert.
+	mld := ip[48:]
+	mld[0] = 130
+	binary.BigEndian.PutUint16(mld[4:6], 10000) // max response in milliseconds.
+	pseudo := make([]byte, 40, 40+len(mld))
+	copy(pseudo[0:32], ip[8:40])
+	binary.BigEndian.PutUint32(pseudo[32:36], uint32(len(mld)))
+	pseudo[39] = icmp6Proto
+	binary.BigEndian.PutUint16(mld[2:4], checksum(append(pseudo, mld...)))
+	return frame
+}
+
+// checksum returns internet checksum of data.
+func checksum(data []byte) uint16 {
+	sum := uint32(0)
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i:]))
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
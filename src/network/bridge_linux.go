@@ -3,6 +3,10 @@ package network
 import (
 	"github.com/danieldin95/openlan-go/src/libol"
 	"github.com/vishvananda/netlink"
+	"net"
+	"sync"
+	"syscall"
+	"time"
 )
 
 type LinuxBridge struct {
@@ -13,14 +17,18 @@ type LinuxBridge struct {
 	device  netlink.Link
 	ctl     *BrCtl
 	out     *libol.SubLogger
+	lock    sync.Mutex
+	secures map[string]*linuxSecure
+	polling bool
 }
 
 func NewLinuxBridge(name string, mtu int) *LinuxBridge {
 	b := &LinuxBridge{
-		name:  name,
-		ifMtu: mtu,
-		ctl:   NewBrCtl(name),
-		out:   libol.NewSubLogger(name),
+		name:    name,
+		ifMtu:   mtu,
+		ctl:     NewBrCtl(name),
+		out:     libol.NewSubLogger(name),
+		secures: make(map[string]*linuxSecure, 32),
 	}
 	Bridges.Add(b)
 	return b
@@ -88,6 +96,12 @@ func (b *LinuxBridge) AddSlave(name string) error {
 }
 
 func (b *LinuxBridge) DelSlave(name string) error {
+	b.lock.Lock()
+	_, ok := b.secures[name]
+	b.lock.Unlock()
+	if ok {
+		_ = b.SetSecurity(name, nil, nil)
+	}
 	if err := b.ctl.DelPort(name); err != nil {
 		b.out.Error("LinuxBridge.DelSlave: %s", name)
 		return err
@@ -142,6 +156,247 @@ func (b *LinuxBridge) Stats() DeviceStats {
 	return b.sts
 }
 
+func (b *LinuxBridge) SetVlan(port string, vlan *PortVlan) error {
+	if err := b.ctl.SetVlan(port, vlan); err != nil {
+		b.out.Error("LinuxBridge.SetVlan: %s %s", port, err)
+		return err
+	}
+	b.out.Info("LinuxBridge.SetVlan: %s %s", port, vlan)
+	return nil
+}
+
+func (b *LinuxBridge) SetIsolated(port string, on bool) error {
+	if err := NewBrPort(port).Isolated(on); err != nil {
+		b.out.Error("LinuxBridge.SetIsolated: %s %s", port, err)
+		return err
+	}
+	b.out.Info("LinuxBridge.SetIsolated: %s %v", port, on)
+	return nil
+}
+
+func (b *LinuxBridge) SetStorm(pps int) error {
+	return libol.NewErr("operation notSupport")
+}
+
+func (b *LinuxBridge) SetSnooping(on bool) error {
+	br, ok := b.device.(*netlink.Bridge)
+	if !ok {
+		return libol.NewErr("%s notFound", b.name)
+	}
+	return netlink.BridgeSetMcastSnoop(br, on)
+}
+
+func (b *LinuxBridge) SetArpProxy(lookup func(ip net.IP) net.HardwareAddr) error {
+	return libol.NewErr("operation notSupport")
+}
+
+func (b *LinuxBridge) SetCost(port string, cost int) error {
+	return NewBrPort(port).Cost(cost)
+}
+
+// SetEdge guards port by bpdu, and kernel has no edge of legacy stp.
+func (b *LinuxBridge) SetEdge(port string, edge bool) error {
+	if err := NewBrPort(port).Guard(edge); err != nil {
+		b.out.Error("LinuxBridge.SetEdge: %s %s", port, err)
+		return err
+	}
+	b.out.Info("LinuxBridge.SetEdge: %s %v", port, edge)
+	return nil
+}
+
+// StpStats returns nil, and spanning tree is shown by kernel.
+func (b *LinuxBridge) StpStats() *StpStats {
+	return nil
+}
+
+func (b *LinuxBridge) StormStats() map[string]int64 {
+	return nil
+}
+
 func (b *LinuxBridge) CallIptables(value int) error {
 	return b.ctl.CallIptables(value)
 }
+
+// linuxSecure is security of a port, and the maximum and sticky are
+// enforced by polling addresses learned in fdb.
+type linuxSecure struct {
+	*PortSecurity
+	handler SecureHandler
+	limited bool
+	full    bool
+	allowed []string // addresses learned when full.
+	alerted map[string]bool
+}
+
+// SetSecurity binds addresses to port by static fdb and ebtables, and the
+// maximum of addresses learned is enforced by fdb and ebtables if given.
+func (b *LinuxBridge) SetSecurity(port string, sec *PortSecurity, handler SecureHandler) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var err error
+	brPort := NewBrPort(port)
+	limited := sec != nil && (sec.Sticky || sec.Limit() != len(sec.Macs))
+	if limited {
+		err = brPort.Restrict(sec.Macs, nil, false)
+	} else if sec != nil {
+		err = brPort.Secure(sec.Macs)
+	} else {
+		err = brPort.Secure(nil)
+	}
+	if err != nil {
+		b.out.Error("LinuxBridge.SetSecurity: %s %s", port, err)
+		return err
+	}
+	if sec == nil || (!limited && len(sec.Macs) == 0) {
+		delete(b.secures, port)
+	} else {
+		b.secures[port] = &linuxSecure{
+			PortSecurity: sec.Copy(),
+			handler:      handler,
+			limited:      limited,
+			alerted:      make(map[string]bool, 4),
+		}
+	}
+	if limited && !b.polling {
+		b.polling = true
+		libol.Go(b.poll)
+	}
+	b.out.Info("LinuxBridge.SetSecurity: %s %s", port, sec)
+	return nil
+}
+
+func (s *linuxSecure) isAllowed(addr string) bool {
+	for _, a := range s.allowed {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+type secureEvent struct {
+	handler  SecureHandler
+	port     string
+	addr     string
+	violated bool
+}
+
+// poll enforces security of ports per second until none is limited.
+func (b *LinuxBridge) poll() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		events := make([]secureEvent, 0, 4)
+		limited := 0
+		b.lock.Lock()
+		for port, s := range b.secures {
+			if !s.limited {
+				continue
+			}
+			limited++
+			events = append(events, b.enforce(port, s)...)
+		}
+		if limited == 0 {
+			b.polling = false
+		}
+		b.lock.Unlock()
+		// handler maybe frees the port.
+		for _, e := range events {
+			if e.handler != nil {
+				e.handler(e.port, e.addr, e.violated)
+			}
+		}
+		if limited == 0 {
+			return
+		}
+	}
+}
+
+// enforce learns sticky addresses of port, and deletes addresses out of
+// the maximum from fdb.
+func (b *LinuxBridge) enforce(port string, s *linuxSecure) []secureEvent {
+	link, err := netlink.LinkByName(port)
+	if err != nil {
+		return nil
+	}
+	neighs, err := netlink.NeighList(link.Attrs().Index, syscall.AF_BRIDGE)
+	if err != nil {
+		b.out.Warn("LinuxBridge.enforce: %s %s", port, err)
+		return nil
+	}
+	learned := make([]string, 0, 8)
+	entries := make(map[string][]netlink.Neigh, 8)
+	for _, n := range neighs {
+		if n.State&(netlink.NUD_PERMANENT|netlink.NUD_NOARP) != 0 || n.Flags&netlink.NTF_SELF != 0 {
+			continue
+		}
+		hw := n.HardwareAddr
+		if len(hw) != 6 || hw[0]&0x01 == 0x01 {
+			continue
+		}
+		addr := hw.String()
+		if s.Has(addr) {
+			continue
+		}
+		if _, ok := entries[addr]; !ok {
+			learned = append(learned, addr)
+		}
+		entries[addr] = append(entries[addr], n)
+	}
+	events := make([]secureEvent, 0, 4)
+	violated := make([]string, 0, 4)
+	limit := s.Limit()
+	full := s.full
+	changed := false
+	if s.Sticky {
+		for _, addr := range learned {
+			if limit > 0 && len(s.Macs) >= limit {
+				violated = append(violated, addr)
+				continue
+			}
+			s.Macs = append(s.Macs, addr)
+			changed = true
+			b.out.Info("LinuxBridge.enforce: sticky %s on %s", addr, port)
+			events = append(events, secureEvent{handler: s.handler, port: port, addr: addr})
+		}
+		full = limit > 0 && len(s.Macs) >= limit
+	} else {
+		allowed := make([]string, 0, len(learned))
+		for _, addr := range learned {
+			if (s.full && !s.isAllowed(addr)) || len(s.Macs)+len(allowed) >= limit {
+				violated = append(violated, addr)
+			} else {
+				allowed = append(allowed, addr)
+			}
+		}
+		// addresses allowed maybe aged out.
+		full = len(s.Macs)+len(allowed) >= limit
+		if full {
+			s.allowed = allowed
+		} else {
+			s.allowed = nil
+		}
+	}
+	if changed || full != s.full {
+		s.full = full
+		if err := NewBrPort(port).Restrict(s.Macs, s.allowed, s.full); err != nil {
+			b.out.Warn("LinuxBridge.enforce: %s %s", port, err)
+		}
+	}
+	for _, addr := range violated {
+		for _, n := range entries[addr] {
+			_ = netlink.NeighDel(&n)
+		}
+		b.sts.Drop++
+		if !s.alerted[addr] {
+			s.alerted[addr] = true
+			if s.Action != SecureDrop {
+				b.out.Warn("LinuxBridge.enforce: %s on %s", addr, port)
+			}
+		}
+		if s.Action != SecureDrop {
+			events = append(events, secureEvent{handler: s.handler, port: port, addr: addr, violated: true})
+		}
+	}
+	return events
+}
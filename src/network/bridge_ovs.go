@@ -0,0 +1,460 @@
+package network
+
+import (
+	"bufio"
+	"github.com/danieldin95/openlan-go/src/libol"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OvsBridge is a bridge of Open vSwitch, and configured by OVSDB.
+type OvsBridge struct {
+	ifMtu   int
+	name    string
+	address string
+	lock    sync.RWMutex
+	ports   map[string]bool
+	db      *OvsDb
+	ctl     *OvsDb
+	out     *libol.SubLogger
+}
+
+func NewOvsBridge(name string, mtu int) *OvsBridge {
+	b := &OvsBridge{
+		name:  name,
+		ifMtu: mtu,
+		ports: make(map[string]bool, 1024),
+		db:    NewOvsDb(OvsDbAddr),
+		ctl:   NewOvsDb(OvsCtlAddr),
+		out:   libol.NewSubLogger(name),
+	}
+	Bridges.Add(b)
+	return b
+}
+
+// SetRemote sets address of ovsdb server and unixctl of ovs-vswitchd, and
+// empty is default.
+func (b *OvsBridge) SetRemote(db, ctl string) {
+	if db != "" {
+		b.db.Close()
+		b.db = NewOvsDb(db)
+	}
+	if ctl != "" {
+		b.ctl.Close()
+		b.ctl = NewOvsDb(ctl)
+	}
+}
+
+// exists returns uuid of the row named in table, and empty if not found.
+func (b *OvsBridge) exists(table, name string) (string, error) {
+	results, err := b.db.Transact(OvsOp{
+		"op":      "select",
+		"table":   table,
+		"where":   OvsWhere("name", name),
+		"columns": []string{"_uuid"},
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 || results[0] == nil || len(results[0].Rows) == 0 {
+		return "", nil
+	}
+	if uuid, ok := results[0].Rows[0]["_uuid"].([]interface{}); ok && len(uuid) == 2 {
+		if value, ok := uuid[1].(string); ok {
+			return value, nil
+		}
+	}
+	return "", nil
+}
+
+func (b *OvsBridge) update(table, name string, row map[string]interface{}) error {
+	_, err := b.db.Transact(OvsOp{
+		"op":    "update",
+		"table": table,
+		"where": OvsWhere("name", name),
+		"row":   row,
+	})
+	return err
+}
+
+// setOther sets key of other_config column.
+func (b *OvsBridge) setOther(table, name, key, value string) error {
+	_, err := b.db.Transact(OvsOp{
+		"op":        "mutate",
+		"table":     table,
+		"where":     OvsWhere("name", name),
+		"mutations": []interface{}{[]interface{}{"other_config", "delete", OvsSet(key)}},
+	}, OvsOp{
+		"op":        "mutate",
+		"table":     table,
+		"where":     OvsWhere("name", name),
+		"mutations": []interface{}{[]interface{}{"other_config", "insert", OvsMap(map[string]string{key: value})}},
+	})
+	return err
+}
+
+func (b *OvsBridge) Open(addr string) {
+	b.out.Debug("OvsBridge.Open")
+	uuid, err := b.exists("Bridge", b.name)
+	if err != nil {
+		b.out.Error("OvsBridge.Open: %s", err)
+		return
+	}
+	if uuid == "" {
+		_, err := b.db.Transact(OvsOp{
+			"op":        "insert",
+			"table":     "Interface",
+			"row":       map[string]interface{}{"name": b.name, "type": "internal"},
+			"uuid-name": "iface",
+		}, OvsOp{
+			"op":        "insert",
+			"table":     "Port",
+			"row":       map[string]interface{}{"name": b.name, "interfaces": OvsNamed("iface")},
+			"uuid-name": "port",
+		}, OvsOp{
+			"op":        "insert",
+			"table":     "Bridge",
+			"row":       map[string]interface{}{"name": b.name, "ports": OvsNamed("port")},
+			"uuid-name": "bridge",
+		}, OvsOp{
+			"op":        "mutate",
+			"table":     "Open_vSwitch",
+			"where":     []interface{}{},
+			"mutations": []interface{}{[]interface{}{"bridges", "insert", OvsSet(OvsNamed("bridge"))}},
+		})
+		if err != nil {
+			b.out.Error("OvsBridge.Open: %s", err)
+			return
+		}
+	}
+	if err := b.update("Interface", b.name, map[string]interface{}{"mtu_request": b.ifMtu}); err != nil {
+		b.out.Warn("OvsBridge.Open: mtu %s", err)
+	}
+	// internal interface is created by ovs-vswitchd in background.
+	for i := 0; i < 10; i++ {
+		if _, err := net.InterfaceByName(b.name); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if out, err := libol.IpLinkUp(b.name); err != nil {
+		b.out.Error("OvsBridge.Open: IpLink %s:%s", err, out)
+	}
+	if addr != "" {
+		if out, err := libol.IpAddrAdd(b.name, addr); err != nil {
+			b.out.Error("OvsBridge.Open: IpAddr %s:%s", err, out)
+		}
+		b.address = addr
+	}
+	b.out.Info("OvsBridge.Open success")
+}
+
+func (b *OvsBridge) Close() error {
+	if b.address != "" {
+		if out, err := libol.IpAddrDel(b.name, b.address); err != nil {
+			b.out.Error("OvsBridge.Close: IpAddr %s:%s", err, out)
+		}
+	}
+	b.db.Close()
+	b.ctl.Close()
+	return nil
+}
+
+func (b *OvsBridge) AddSlave(name string) error {
+	uuid, err := b.exists("Port", name)
+	if err != nil {
+		b.out.Error("OvsBridge.AddSlave: %s %s", name, err)
+		return err
+	}
+	if uuid == "" {
+		_, err = b.db.Transact(OvsOp{
+			"op":        "insert",
+			"table":     "Interface",
+			"row":       map[string]interface{}{"name": name},
+			"uuid-name": "iface",
+		}, OvsOp{
+			"op":        "insert",
+			"table":     "Port",
+			"row":       map[string]interface{}{"name": name, "interfaces": OvsNamed("iface")},
+			"uuid-name": "port",
+		}, OvsOp{
+			"op":        "mutate",
+			"table":     "Bridge",
+			"where":     OvsWhere("name", b.name),
+			"mutations": []interface{}{[]interface{}{"ports", "insert", OvsSet(OvsNamed("port"))}},
+		})
+		if err != nil {
+			b.out.Error("OvsBridge.AddSlave: %s %s", name, err)
+			return err
+		}
+	}
+	b.lock.Lock()
+	b.ports[name] = true
+	b.lock.Unlock()
+	b.out.Info("OvsBridge.AddSlave: %s", name)
+	return nil
+}
+
+func (b *OvsBridge) DelSlave(name string) error {
+	uuid, err := b.exists("Port", name)
+	if err == nil && uuid != "" {
+		// the port and interface are collected as unreferenced.
+		_, err = b.db.Transact(OvsOp{
+			"op":        "mutate",
+			"table":     "Bridge",
+			"where":     OvsWhere("name", b.name),
+			"mutations": []interface{}{[]interface{}{"ports", "delete", OvsSet([]interface{}{"uuid", uuid})}},
+		})
+	}
+	if err != nil {
+		b.out.Error("OvsBridge.DelSlave: %s %s", name, err)
+		return err
+	}
+	b.lock.Lock()
+	delete(b.ports, name)
+	b.lock.Unlock()
+	b.out.Info("OvsBridge.DelSlave: %s", name)
+	return nil
+}
+
+func (b *OvsBridge) ListSlave() <-chan Taper {
+	data := make(chan Taper, 32)
+	go func() {
+		b.lock.RLock()
+		defer b.lock.RUnlock()
+		for name := range b.ports {
+			if tap := Taps.Get(name); tap != nil {
+				data <- tap
+			}
+		}
+		data <- nil
+	}()
+	return data
+}
+
+func (b *OvsBridge) Type() string {
+	return ProviderOvs
+}
+
+func (b *OvsBridge) String() string {
+	return b.name
+}
+
+func (b *OvsBridge) Name() string {
+	return b.name
+}
+
+func (b *OvsBridge) Kernel() string {
+	return b.name
+}
+
+func (b *OvsBridge) Mtu() int {
+	return b.ifMtu
+}
+
+func (b *OvsBridge) Stp(enable bool) error {
+	return b.update("Bridge", b.name, map[string]interface{}{"rstp_enable": enable})
+}
+
+func (b *OvsBridge) Delay(value int) error {
+	return b.setOther("Bridge", b.name, "rstp-forward-delay", strconv.Itoa(value))
+}
+
+func (b *OvsBridge) SetCost(port string, cost int) error {
+	return b.setOther("Port", port, "rstp-path-cost", strconv.Itoa(cost))
+}
+
+// SetEdge sets port as admin edge of rstp, and bpdu guard notSupport by ovs.
+func (b *OvsBridge) SetEdge(port string, edge bool) error {
+	value := strconv.FormatBool(edge)
+	if err := b.setOther("Port", port, "rstp-port-admin-edge", value); err != nil {
+		return err
+	}
+	return b.setOther("Port", port, "rstp-port-auto-edge", strconv.FormatBool(!edge))
+}
+
+// ofports returns names of interfaces by openflow port.
+func (b *OvsBridge) ofports() (map[string]string, error) {
+	results, err := b.db.Transact(OvsOp{
+		"op":      "select",
+		"table":   "Interface",
+		"where":   []interface{}{},
+		"columns": []string{"name", "ofport"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ports := make(map[string]string, 32)
+	if len(results) == 0 || results[0] == nil {
+		return ports, nil
+	}
+	for _, row := range results[0].Rows {
+		name, _ := row["name"].(string)
+		if ofport, ok := row["ofport"].(float64); ok {
+			ports[strconv.Itoa(int(ofport))] = name
+		}
+	}
+	ports["LOCAL"] = b.name
+	return ports, nil
+}
+
+func (b *OvsBridge) ListMac() <-chan *MacFdb {
+	data := make(chan *MacFdb, 32)
+	go func() {
+		defer func() { data <- nil }()
+		ports, err := b.ofports()
+		if err != nil {
+			b.out.Warn("OvsBridge.ListMac: %s", err)
+			return
+		}
+		var table string
+		if err := b.ctl.Call("fdb/show", []interface{}{b.name}, &table); err != nil {
+			b.out.Warn("OvsBridge.ListMac: %s", err)
+			return
+		}
+		for _, fdb := range ParseOvsFdb(table, ports) {
+			data <- fdb
+		}
+	}()
+	return data
+}
+
+// ParseOvsFdb parses output of fdb/show, and ignores addresses on ports not
+// tap devices.
+func ParseOvsFdb(table string, ports map[string]string) []*MacFdb {
+	now := time.Now().Unix()
+	fdbs := make([]*MacFdb, 0, 32)
+	scanner := bufio.NewScanner(strings.NewReader(table))
+	for scanner.Scan() {
+		// port  VLAN  MAC                Age
+		columns := strings.Fields(scanner.Text())
+		if len(columns) < 4 {
+			continue
+		}
+		vlan, err := strconv.Atoi(columns[1])
+		if err != nil {
+			continue
+		}
+		addr, err := net.ParseMAC(columns[2])
+		if err != nil {
+			continue
+		}
+		age, _ := strconv.Atoi(columns[3])
+		tap := Taps.Get(ports[columns[0]])
+		if tap == nil {
+			continue
+		}
+		fdbs = append(fdbs, &MacFdb{
+			Address: addr,
+			Device:  tap,
+			Uptime:  now - int64(age),
+			NewTime: now - int64(age),
+			Vlan:    vlan,
+		})
+	}
+	return fdbs
+}
+
+// Stats sums statistics of interfaces on the bridge.
+func (b *OvsBridge) Stats() DeviceStats {
+	sts := DeviceStats{}
+	results, err := b.db.Transact(OvsOp{
+		"op":      "select",
+		"table":   "Interface",
+		"where":   []interface{}{},
+		"columns": []string{"name", "statistics"},
+	})
+	if err != nil || len(results) == 0 || results[0] == nil {
+		return sts
+	}
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	for _, row := range results[0].Rows {
+		name, _ := row["name"].(string)
+		if !b.ports[name] {
+			continue
+		}
+		value := func(key string) int64 {
+			if v, ok := OvsMapOf(row["statistics"])[key].(float64); ok {
+				return int64(v)
+			}
+			return 0
+		}
+		// received by bridge from the port, and sent to it.
+		sts.Recv += value("rx_packets")
+		sts.Send += value("tx_packets")
+		sts.Drop += value("rx_dropped") + value("tx_dropped")
+	}
+	return sts
+}
+
+func (b *OvsBridge) CallIptables(value int) error {
+	return libol.NewErr("operation notSupport")
+}
+
+func (b *OvsBridge) SetVlan(port string, vlan *PortVlan) error {
+	row := map[string]interface{}{
+		"tag":       OvsSet(),
+		"trunks":    OvsSet(),
+		"vlan_mode": OvsSet(),
+	}
+	if vlan != nil {
+		if vlan.Pvid > 0 {
+			row["tag"] = vlan.Pvid
+		}
+		switch vlan.Mode {
+		case VlanAccess:
+			row["vlan_mode"] = "access"
+		case VlanTrunk:
+			trunks := make([]interface{}, 0, len(vlan.Allowed))
+			for _, vid := range vlan.Allowed {
+				trunks = append(trunks, vid)
+			}
+			row["trunks"] = OvsSet(trunks...)
+			if vlan.Pvid > 0 {
+				row["vlan_mode"] = "native-untagged"
+			} else {
+				row["vlan_mode"] = "trunk"
+			}
+		}
+	}
+	if err := b.update("Port", port, row); err != nil {
+		b.out.Error("OvsBridge.SetVlan: %s %s", port, err)
+		return err
+	}
+	b.out.Info("OvsBridge.SetVlan: %s %s", port, vlan)
+	return nil
+}
+
+// SetIsolated sets port protected, that can't forward to other protected.
+func (b *OvsBridge) SetIsolated(port string, on bool) error {
+	return b.update("Port", port, map[string]interface{}{"protected": on})
+}
+
+func (b *OvsBridge) SetStorm(pps int) error {
+	return libol.NewErr("operation notSupport")
+}
+
+func (b *OvsBridge) SetSnooping(on bool) error {
+	return b.update("Bridge", b.name, map[string]interface{}{"mcast_snooping_enable": on})
+}
+
+func (b *OvsBridge) SetArpProxy(lookup func(ip net.IP) net.HardwareAddr) error {
+	return libol.NewErr("operation notSupport")
+}
+
+// StpStats returns nil, and spanning tree is shown by ovs-appctl.
+func (b *OvsBridge) StpStats() *StpStats {
+	return nil
+}
+
+func (b *OvsBridge) StormStats() map[string]int64 {
+	return nil
+}
+
+func (b *OvsBridge) SetSecurity(port string, sec *PortSecurity, handler SecureHandler) error {
+	return libol.NewErr("operation notSupport")
+}
@@ -0,0 +1,157 @@
+package network
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"sync"
+	"testing"
+)
+
+// ovsStandIn answers transact and fdb/show likes ovsdb-server and
+// ovs-vswitchd, and records operations.
+type ovsStandIn struct {
+	lock     sync.Mutex
+	listener net.Listener
+	ports    map[string]bool
+	ops      []map[string]interface{}
+}
+
+func newOvsStandIn(t *testing.T) *ovsStandIn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen %s", err)
+	}
+	s := &ovsStandIn{listener: ln, ports: map[string]bool{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *ovsStandIn) Addr() string {
+	return "tcp:" + s.listener.Addr().String()
+}
+
+func (s *ovsStandIn) result(op map[string]interface{}) interface{} {
+	table, _ := op["table"].(string)
+	switch op["op"] {
+	case "select":
+		rows := make([]interface{}, 0, 4)
+		if table == "Interface" {
+			rows = append(rows, map[string]interface{}{
+				"name":   "ovs-tap0",
+				"ofport": 1,
+				"statistics": []interface{}{"map", []interface{}{
+					[]interface{}{"rx_packets", 3},
+					[]interface{}{"tx_packets", 4},
+					[]interface{}{"rx_dropped", 1},
+				}},
+			})
+		} else if where, ok := op["where"].([]interface{}); ok && len(where) > 0 {
+			name := where[0].([]interface{})[2].(string)
+			if s.ports[name] {
+				rows = append(rows, map[string]interface{}{"_uuid": []interface{}{"uuid", "uuid-" + name}})
+			}
+		}
+		return map[string]interface{}{"rows": rows}
+	case "insert":
+		if row, ok := op["row"].(map[string]interface{}); ok && (table == "Port" || table == "Bridge") {
+			s.ports[row["name"].(string)] = true
+		}
+		return map[string]interface{}{"uuid": []interface{}{"uuid", "new"}}
+	}
+	return map[string]interface{}{"count": 1}
+}
+
+func (s *ovsStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+	// echo firstly as ovsdb-server does for keepalive.
+	_ = enc.Encode(map[string]interface{}{"method": "echo", "params": []interface{}{}, "id": "echo"})
+	for {
+		req := map[string]interface{}{}
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		if req["id"] == "echo" {
+			continue
+		}
+		params, _ := req["params"].([]interface{})
+		var result interface{}
+		s.lock.Lock()
+		switch req["method"] {
+		case "transact":
+			results := make([]interface{}, 0, len(params))
+			for _, p := range params[1:] {
+				op := p.(map[string]interface{})
+				s.ops = append(s.ops, op)
+				results = append(results, s.result(op))
+			}
+			result = results
+		case "fdb/show":
+			result = " port  VLAN  MAC                Age\n" +
+				"    1    10  00:16:3e:00:00:01    3\n" +
+				"LOCAL     0  00:16:3e:00:00:02    1\n"
+		}
+		s.lock.Unlock()
+		_ = enc.Encode(map[string]interface{}{"id": req["id"], "result": result, "error": nil})
+	}
+}
+
+func (s *ovsStandIn) last() map[string]interface{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.ops[len(s.ops)-1]
+}
+
+func TestOvsBridge(t *testing.T) {
+	server := newOvsStandIn(t)
+	defer server.listener.Close()
+
+	br := NewOvsBridge("br-ovs", 1500)
+	br.SetRemote(server.Addr(), server.Addr())
+	br.Open("")
+	assert.True(t, server.ports["br-ovs"], "be created.")
+
+	tap, _ := NewVirtualTap("ovs", TapConfig{Name: "ovs-tap0"})
+	assert.Nil(t, br.AddSlave(tap.Name()), "be nil.")
+	assert.True(t, server.ports[tap.Name()], "be added.")
+	last := server.last()
+	assert.Equal(t, "mutate", last["op"], "be the same.")
+	assert.Equal(t, "Bridge", last["table"], "be the same.")
+
+	_ = br.SetVlan(tap.Name(), &PortVlan{Mode: VlanTrunk, Pvid: 1, Allowed: []int{10, 20}})
+	row := server.last()["row"].(map[string]interface{})
+	assert.Equal(t, float64(1), row["tag"], "be the same.")
+	assert.Equal(t, "native-untagged", row["vlan_mode"], "be the same.")
+	assert.Equal(t, []interface{}{"set", []interface{}{float64(10), float64(20)}}, row["trunks"], "be the same.")
+
+	sts := br.Stats()
+	assert.Equal(t, int64(3), sts.Recv, "be the same.")
+	assert.Equal(t, int64(4), sts.Send, "be the same.")
+	assert.Equal(t, int64(1), sts.Drop, "be the same.")
+
+	macs := make([]*MacFdb, 0, 2)
+	for fdb := range br.ListMac() {
+		if fdb == nil {
+			break
+		}
+		macs = append(macs, fdb)
+	}
+	assert.Equal(t, 1, len(macs), "only tap device.")
+	assert.Equal(t, "00:16:3e:00:00:01", net.HardwareAddr(macs[0].Address).String(), "be the same.")
+	assert.Equal(t, 10, macs[0].Vlan, "be the same.")
+	assert.Equal(t, tap.Name(), macs[0].Device.Name(), "be the same.")
+
+	assert.Nil(t, br.DelSlave(tap.Name()), "be nil.")
+	assert.Equal(t, "delete", server.last()["mutations"].([]interface{})[0].([]interface{})[1], "be the same.")
+	_ = br.Close()
+}
@@ -0,0 +1,169 @@
+package network
+
+import (
+	"sync"
+	"time"
+)
+
+// portSecure is security of a port in VirtualBridge, and it's locked by
+// itself as checked for every frame of the port.
+type portSecure struct {
+	*PortSecurity
+	lock      sync.Mutex
+	handler   SecureHandler
+	learned   map[string]int64 // time of addresses learned by port.
+	violateAt int64
+}
+
+// hold returns whether the handler of violation is held, and it's
+// called once per second at most.
+func (s *portSecure) hold(now int64) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.violateAt == now {
+		return true
+	}
+	s.violateAt = now
+	return false
+}
+
+// expire removes addresses not seen in timeout.
+func (s *portSecure) expire(now, timeout int64) {
+	for addr, at := range s.learned {
+		if now-at > timeout {
+			delete(s.learned, addr)
+		}
+	}
+}
+
+// SetSecurity limits source addresses of port, and addresses in Macs are
+// bound to the port that others can't source them.
+func (b *VirtualBridge) SetSecurity(port string, sec *PortSecurity, handler SecureHandler) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.unsecure(port)
+	if sec != nil {
+		s := &portSecure{
+			PortSecurity: sec.Copy(),
+			handler:      handler,
+			learned:      make(map[string]int64, 4),
+		}
+		b.secures[port] = s
+		for _, addr := range s.Macs {
+			b.owners[addr] = port
+		}
+	}
+	b.out.Info("VirtualBridge.SetSecurity: %s %s", port, sec)
+	return nil
+}
+
+func (b *VirtualBridge) unsecure(port string) {
+	for key, p := range b.alerts {
+		if p == port {
+			delete(b.alerts, key)
+		}
+	}
+	if s, ok := b.secures[port]; ok {
+		s.lock.Lock()
+		for _, addr := range s.Macs {
+			if b.owners[addr] == port {
+				delete(b.owners, addr)
+			}
+		}
+		s.lock.Unlock()
+		delete(b.secures, port)
+	}
+}
+
+// secure returns whether the source address of frame is allowed on the
+// port, and learns it if sticky.
+func (b *VirtualBridge) secure(m *Framer) bool {
+	src := m.Data[6:12]
+	if src[0]&0x01 == 0x01 {
+		return true
+	}
+	addr := b.Eth2Str(src)
+	port := m.Source.Name()
+	b.lock.RLock()
+	s := b.secures[port]
+	owner, bound := b.owners[addr]
+	b.lock.RUnlock()
+	if bound {
+		if owner == port {
+			return true
+		}
+		// the address is bound to another port.
+		b.violate(s, port, addr, owner)
+		return false
+	}
+	if s == nil {
+		return true
+	}
+	now := time.Now().Unix()
+	s.lock.Lock()
+	if _, ok := s.learned[addr]; ok {
+		s.learned[addr] = now
+		s.lock.Unlock()
+		return true
+	}
+	limit := s.Limit()
+	if s.Sticky {
+		if limit > 0 && len(s.Macs) >= limit {
+			s.lock.Unlock()
+			b.violate(s, port, addr, "")
+			return false
+		}
+		s.Macs = append(s.Macs, addr)
+		s.lock.Unlock()
+		b.lock.Lock()
+		b.owners[addr] = port
+		b.lock.Unlock()
+		b.out.Info("VirtualBridge.secure: sticky %s on %s", addr, port)
+		if s.handler != nil {
+			s.handler(port, addr, false)
+		}
+		return true
+	}
+	if limit > 0 && len(s.Macs)+len(s.learned) >= limit {
+		s.expire(now, int64(b.timeout))
+		if len(s.Macs)+len(s.learned) >= limit {
+			s.lock.Unlock()
+			b.violate(s, port, addr, "")
+			return false
+		}
+	}
+	s.learned[addr] = now
+	s.lock.Unlock()
+	return true
+}
+
+// violate drops frame and takes action of security on port.
+func (b *VirtualBridge) violate(s *portSecure, port, addr, owner string) {
+	b.sts.Drop++
+	action := SecureAlert // spoofed address is always alerted.
+	if s != nil && owner == "" {
+		action = s.Action
+	}
+	key := port + "/" + addr
+	b.lock.RLock()
+	_, alerted := b.alerts[key]
+	b.lock.RUnlock()
+	if !alerted {
+		b.lock.Lock()
+		b.alerts[key] = port
+		b.lock.Unlock()
+		if owner != "" {
+			b.out.Warn("VirtualBridge.violate: %s of %s on %s", addr, owner, port)
+		} else if action != SecureDrop {
+			b.out.Warn("VirtualBridge.violate: %s on %s", addr, port)
+		} else {
+			b.out.Debug("VirtualBridge.violate: %s on %s", addr, port)
+		}
+	}
+	if s == nil || s.handler == nil || action == SecureDrop {
+		return
+	}
+	if !s.hold(time.Now().Unix()) {
+		s.handler(port, addr, true)
+	}
+}
@@ -0,0 +1,751 @@
+package network
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"github.com/danieldin95/openlan-go/src/libol"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StpHello    = 2  // seconds
+	StpMaxAge   = 20 // seconds
+	StpDelay    = 15 // seconds
+	StpCost     = 20000
+	StpPriority = 0x8000
+	stpMigrate  = 3 // seconds to wait bpdu before taking port as edge, or migrating protocol.
+)
+
+const (
+	StpRoot       = "root"
+	StpDesignated = "designated"
+	StpAlternate  = "alternate"
+	StpBackup     = "backup"
+	StpDiscarding = "discarding"
+	StpLearning   = "learning"
+	StpForwarding = "forwarding"
+)
+
+const (
+	stpConfig = 0x00
+	stpRst    = 0x02
+	stpTcn    = 0x80
+)
+
+const (
+	stpFlagTc         = 0x01
+	stpFlagProposal   = 0x02
+	stpFlagLearning   = 0x10
+	stpFlagForwarding = 0x20
+	stpFlagAgreement  = 0x40
+	stpFlagTca        = 0x80
+	stpRoleMask       = 0x0c
+	stpRoleAlternate  = 0x04
+	stpRoleRoot       = 0x08
+	stpRoleDesignated = 0x0c
+)
+
+var stpAddr = []byte{0x01, 0x80, 0xc2, 0x00, 0x00, 0x00}
+
+// stpVector is priority vector of spanning tree, and lower is better.
+type stpVector struct {
+	Root   [8]byte
+	Cost   uint32
+	Bridge [8]byte
+	Port   uint16
+}
+
+func (v stpVector) compare(o stpVector) int {
+	if c := bytes.Compare(v.Root[:], o.Root[:]); c != 0 {
+		return c
+	}
+	if v.Cost != o.Cost {
+		if v.Cost < o.Cost {
+			return -1
+		}
+		return 1
+	}
+	if c := bytes.Compare(v.Bridge[:], o.Bridge[:]); c != 0 {
+		return c
+	}
+	if v.Port != o.Port {
+		if v.Port < o.Port {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// stpBpdu is config, rapid or topology change notification bpdu.
+type stpBpdu struct {
+	stpVector
+	Type  byte
+	Flags byte
+	Age   int // seconds
+	Hello int // seconds
+}
+
+func (d *stpBpdu) Encode(src []byte) []byte {
+	frame := make([]byte, 60)
+	copy(frame[0:6], stpAddr)
+	copy(frame[6:12], src)
+	copy(frame[14:17], []byte{0x42, 0x42, 0x03}) // llc
+	b := frame[17:]
+	b[3] = d.Type
+	switch d.Type {
+	case stpTcn:
+		binary.BigEndian.PutUint16(frame[12:14], 3+4)
+		return frame
+	case stpConfig:
+		binary.BigEndian.PutUint16(frame[12:14], 3+35)
+	default:
+		binary.BigEndian.PutUint16(frame[12:14], 3+36)
+		b[2] = 0x02 // version of rstp
+	}
+	b[4] = d.Flags
+	copy(b[5:13], d.Root[:])
+	binary.BigEndian.PutUint32(b[13:17], d.Cost)
+	copy(b[17:25], d.Bridge[:])
+	binary.BigEndian.PutUint16(b[25:27], d.Port)
+	binary.BigEndian.PutUint16(b[27:29], uint16(d.Age*256))
+	binary.BigEndian.PutUint16(b[29:31], StpMaxAge*256)
+	binary.BigEndian.PutUint16(b[31:33], uint16(d.Hello*256))
+	binary.BigEndian.PutUint16(b[33:35], StpDelay*256)
+	return frame
+}
+
+// decodeBpdu returns bpdu of frame, and nil if not a bpdu.
+func decodeBpdu(data []byte) *stpBpdu {
+	if len(data) < 17+4 || !bytes.Equal(data[0:6], stpAddr) {
+		return nil
+	}
+	if data[14] != 0x42 || data[15] != 0x42 {
+		return nil
+	}
+	b := data[17:]
+	if b[0] != 0 || b[1] != 0 {
+		return nil
+	}
+	d := &stpBpdu{Type: b[3]}
+	switch d.Type {
+	case stpTcn:
+		d.Flags = stpFlagTc
+		return d
+	case stpConfig, stpRst:
+		if len(b) < 35 {
+			return nil
+		}
+	default:
+		return nil
+	}
+	d.Flags = b[4]
+	if d.Type == stpConfig { // always from designated port.
+		d.Flags = d.Flags&(stpFlagTc|stpFlagTca) | stpRoleDesignated
+	}
+	copy(d.Root[:], b[5:13])
+	d.Cost = binary.BigEndian.Uint32(b[13:17])
+	copy(d.Bridge[:], b[17:25])
+	d.Port = binary.BigEndian.Uint16(b[25:27])
+	d.Age = int(binary.BigEndian.Uint16(b[27:29]) / 256)
+	d.Hello = int(binary.BigEndian.Uint16(b[31:33]) / 256)
+	if d.Hello <= 0 {
+		d.Hello = StpHello
+	}
+	return d
+}
+
+type stpPort struct {
+	port      Taper
+	id        uint16
+	cost      uint32
+	role      string
+	state     string
+	admin     bool // administrative edge likes the kernel port.
+	guard     bool // bpdu guard, and blocked if received bpdu.
+	blocked   bool
+	edge      bool
+	seen      bool  // received bpdu.
+	legacy    bool  // peer is legacy stp.
+	migrateAt int64 // ms
+	info      *stpBpdu
+	expire    int64 // ms
+	forwardAt int64 // ms
+	tcWhile   int64 // ms
+	proposing bool
+}
+
+type stpSend struct {
+	port  Taper
+	frame []byte
+}
+
+// spanning is rapid spanning tree of VirtualBridge, and times are in
+// milliseconds.
+type spanning struct {
+	lock     sync.Mutex
+	enable   bool
+	priority uint16
+	address  net.HardwareAddr
+	delay    int // seconds of forward delay.
+	index    uint16
+	ports    map[string]*stpPort
+	root     stpVector
+	rootPort *stpPort
+	rootAge  int
+	hello    int64
+	flush    map[string]bool // ports to flush fdb for topology change.
+}
+
+func newSpanning(name string) spanning {
+	sum := sha1.Sum([]byte(name))
+	addr := make(net.HardwareAddr, 6)
+	copy(addr, sum[:6])
+	addr[0] = (addr[0] & 0xfe) | 0x02 // local unicast.
+	return spanning{
+		priority: StpPriority,
+		address:  addr,
+		delay:    StpDelay,
+		ports:    make(map[string]*stpPort, 32),
+		flush:    make(map[string]bool, 32),
+	}
+}
+
+func (s *spanning) id() [8]byte {
+	var id [8]byte
+	binary.BigEndian.PutUint16(id[0:2], s.priority)
+	copy(id[2:8], s.address)
+	return id
+}
+
+func stpIdStr(id [8]byte) string {
+	return fmt.Sprintf("%x.%s", id[0:2], net.HardwareAddr(id[2:8]))
+}
+
+// reset restarts port as designated and discarding until forward delay.
+func (s *spanning) reset(p *stpPort, now int64) {
+	p.role = StpDesignated
+	p.info = nil
+	p.seen = false
+	p.edge = p.admin
+	p.proposing = false
+	p.legacy = false
+	p.migrateAt = now
+	if p.blocked {
+		p.state = StpDiscarding
+	} else if p.edge {
+		p.state = StpForwarding
+	} else {
+		p.state = StpDiscarding
+		p.forwardAt = now + stpMigrate*1000
+	}
+}
+
+func (s *spanning) add(port Taper, edge bool, now int64) {
+	s.index++
+	p := &stpPort{
+		port:  port,
+		id:    0x8000 | (s.index & 0x0fff),
+		cost:  StpCost,
+		admin: edge,
+	}
+	s.reset(p, now)
+	s.ports[port.Name()] = p
+	s.selectRoles(now)
+}
+
+// edge sets port as administrative edge with bpdu guard, and unblocks it.
+func (s *spanning) edge(p *stpPort, edge bool, now int64) {
+	p.admin, p.guard, p.blocked = edge, edge, false
+	s.reset(p, now)
+	s.selectRoles(now)
+}
+
+// block discards frames of port guarded, and returns true if newly.
+func (s *spanning) block(p *stpPort) bool {
+	if p.blocked {
+		return false
+	}
+	p.blocked = true
+	p.state = StpDiscarding
+	p.proposing = false
+	return true
+}
+
+func (s *spanning) del(name string, now int64) {
+	if _, ok := s.ports[name]; ok {
+		delete(s.ports, name)
+		s.selectRoles(now)
+	}
+}
+
+// forwarding returns whether the port can forward, and learning whether
+// learn addresses from it.
+func (s *spanning) forwarding(name string) (bool, bool) {
+	p, ok := s.ports[name]
+	if !ok {
+		return true, true
+	}
+	if p.blocked {
+		return false, false
+	}
+	if !s.enable {
+		return true, true
+	}
+	return p.state == StpForwarding, p.state != StpDiscarding
+}
+
+func (s *spanning) forward(p *stpPort, now int64) {
+	if p.state == StpForwarding || p.blocked {
+		return
+	}
+	p.state = StpForwarding
+	p.proposing = false
+	if !p.edge {
+		s.change(p, now)
+	}
+}
+
+// change starts topology change by port, and flushes addresses learned on
+// others.
+func (s *spanning) change(from *stpPort, now int64) {
+	for name, p := range s.ports {
+		if p.edge {
+			continue
+		}
+		if p.role == StpRoot || p.role == StpDesignated {
+			p.tcWhile = now + 2*StpHello*1000
+		}
+		if p != from {
+			s.flush[name] = true
+		}
+	}
+}
+
+func (s *spanning) setRole(p *stpPort, role string, now int64) bool {
+	if p.role == role {
+		return false
+	}
+	p.role = role
+	switch role {
+	case StpRoot:
+		s.forward(p, now)
+	case StpDesignated:
+		if p.edge {
+			s.forward(p, now)
+		} else if p.state != StpForwarding {
+			p.state = StpDiscarding
+			if p.seen {
+				p.forwardAt = now + int64(s.delay)*1000
+			}
+			p.proposing = true
+		}
+	default:
+		p.state = StpDiscarding
+		p.proposing = false
+	}
+	return true
+}
+
+// selectRoles selects root port by the best priority vector received, and
+// returns true if any role changed.
+func (s *spanning) selectRoles(now int64) bool {
+	me := s.id()
+	best := stpVector{Root: me, Bridge: me}
+	var root *stpPort
+	for _, p := range s.ports {
+		if p.info == nil || p.info.Bridge == me {
+			continue
+		}
+		v := p.info.stpVector
+		v.Cost += p.cost
+		if c := v.compare(best); c < 0 || (c == 0 && root != nil && p.id < root.id) {
+			best, root = v, p
+		}
+	}
+	s.root, s.rootPort, s.rootAge = best, root, 0
+	if root != nil {
+		s.rootAge = root.info.Age + 1
+	}
+	changed := false
+	for _, p := range s.ports {
+		role := StpDesignated
+		if p == root {
+			role = StpRoot
+		} else if p.info != nil {
+			designated := stpVector{Root: best.Root, Cost: best.Cost, Bridge: me, Port: p.id}
+			if p.info.compare(designated) < 0 {
+				role = StpAlternate
+				if p.info.Bridge == me {
+					role = StpBackup
+				}
+			}
+		}
+		if s.setRole(p, role, now) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (s *spanning) bpdu(p *stpPort, flags byte, now int64) stpSend {
+	switch p.role {
+	case StpRoot:
+		flags |= stpRoleRoot
+	case StpDesignated:
+		flags |= stpRoleDesignated
+	default:
+		flags |= stpRoleAlternate
+	}
+	if p.state != StpDiscarding {
+		flags |= stpFlagLearning
+	}
+	if p.state == StpForwarding {
+		flags |= stpFlagForwarding
+	}
+	if p.tcWhile > now {
+		flags |= stpFlagTc
+	}
+	if p.role == StpDesignated && p.proposing && p.state != StpForwarding {
+		flags |= stpFlagProposal
+	}
+	d := &stpBpdu{
+		stpVector: stpVector{Root: s.root.Root, Cost: s.root.Cost, Bridge: s.id(), Port: p.id},
+		Type:      stpRst,
+		Flags:     flags,
+		Age:       s.rootAge,
+		Hello:     StpHello,
+	}
+	if p.legacy {
+		// legacy root port notifies change by tcn, and others by config.
+		d.Type = stpConfig
+		if p.role == StpRoot {
+			d.Type = stpTcn
+		}
+		d.Flags &= stpFlagTc | stpFlagTca
+	}
+	return stpSend{port: p.port, frame: d.Encode(s.address)}
+}
+
+// transmit returns bpdu on designated ports, and the root port if topology
+// changing.
+func (s *spanning) transmit(now int64) []stpSend {
+	sends := make([]stpSend, 0, len(s.ports))
+	for _, p := range s.ports {
+		if p.admin {
+			continue
+		}
+		if p.role == StpDesignated || (p.role == StpRoot && p.tcWhile > now) {
+			sends = append(sends, s.bpdu(p, 0, now))
+		}
+	}
+	return sends
+}
+
+// recv handles bpdu received on port, and returns bpdu to send.
+func (s *spanning) recv(p *stpPort, d *stpBpdu, now int64) []stpSend {
+	var sends []stpSend
+	changed := false
+	if !p.seen || p.edge != p.admin {
+		p.seen, p.edge = true, p.admin
+		changed = true
+	}
+	// migrates to legacy stp by config or tcn, and back to rapid one by rst
+	// after migrate delay to avoid flapping.
+	if legacy := d.Type != stpRst; legacy != p.legacy && now >= p.migrateAt {
+		p.legacy = legacy
+		p.migrateAt = now + stpMigrate*1000
+		changed = true
+	}
+	role := d.Flags & stpRoleMask
+	if d.Type != stpTcn && role == stpRoleDesignated && d.Age < StpMaxAge {
+		p.info = d
+		p.expire = now + 3*int64(d.Hello)*1000
+		if s.selectRoles(now) {
+			changed = true
+		}
+		if d.Flags&stpFlagProposal != 0 && p.role == StpRoot {
+			// sync to avoid loops, and then agree the proposal.
+			for _, o := range s.ports {
+				if o != p && o.role == StpDesignated && !o.edge {
+					o.state = StpDiscarding
+					o.forwardAt = now + int64(s.delay)*1000
+					o.proposing = true
+				}
+			}
+			s.forward(p, now)
+			sends = append(sends, s.bpdu(p, stpFlagAgreement, now))
+			changed = true
+		}
+		if d.Type == stpConfig && d.Flags&stpFlagTca != 0 && p.role == StpRoot {
+			p.tcWhile = 0 // tcn acknowledged.
+		}
+	} else if role == stpRoleRoot && d.Flags&stpFlagAgreement != 0 {
+		if p.role == StpDesignated && d.Root == s.root.Root && p.state != StpForwarding {
+			s.forward(p, now)
+			changed = true
+		}
+	} else if d.Type == stpTcn && p.role == StpDesignated {
+		sends = append(sends, s.bpdu(p, stpFlagTca, now))
+	}
+	if d.Flags&stpFlagTc != 0 {
+		for name, o := range s.ports {
+			if o == p || o.edge {
+				continue
+			}
+			if o.role == StpRoot || o.role == StpDesignated {
+				o.tcWhile = now + 2*StpHello*1000
+			}
+			s.flush[name] = true
+		}
+	}
+	if changed {
+		sends = append(sends, s.transmit(now)...)
+	}
+	return sends
+}
+
+// tick ages information, moves states by forward delay and sends hello.
+func (s *spanning) tick(now int64) []stpSend {
+	changed := false
+	for _, p := range s.ports {
+		if p.info != nil && now >= p.expire {
+			p.info = nil
+			changed = true
+		}
+	}
+	if changed {
+		s.selectRoles(now)
+	}
+	for _, p := range s.ports {
+		if p.role != StpDesignated || p.state == StpForwarding || now < p.forwardAt {
+			continue
+		}
+		changed = true
+		if !p.seen {
+			p.edge = true
+			s.forward(p, now)
+		} else if p.state == StpDiscarding {
+			p.state = StpLearning
+			p.forwardAt = now + int64(s.delay)*1000
+		} else {
+			s.forward(p, now)
+		}
+	}
+	if changed || now-s.hello >= StpHello*1000 {
+		s.hello = now
+		return s.transmit(now)
+	}
+	return nil
+}
+
+func (s *spanning) takeFlush() map[string]bool {
+	if len(s.flush) == 0 {
+		return nil
+	}
+	flush := s.flush
+	s.flush = make(map[string]bool, 32)
+	return flush
+}
+
+type StpPortStats struct {
+	Name   string `json:"name"`
+	Id     string `json:"id"`
+	Cost   int    `json:"cost"`
+	Role   string `json:"role"`
+	State  string `json:"state"`
+	Edge   bool   `json:"edge"`
+	Guard  bool   `json:"guard,omitempty"`
+	Legacy bool   `json:"legacy,omitempty"`
+}
+
+type StpStats struct {
+	Bridge   string         `json:"bridge"`
+	Root     string         `json:"root"`
+	Cost     int            `json:"cost"`
+	RootPort string         `json:"rootPort,omitempty"`
+	Ports    []StpPortStats `json:"ports"`
+}
+
+func (s *spanning) stats() *StpStats {
+	sts := &StpStats{
+		Bridge: stpIdStr(s.id()),
+		Root:   stpIdStr(s.root.Root),
+		Cost:   int(s.root.Cost),
+		Ports:  make([]StpPortStats, 0, len(s.ports)),
+	}
+	if s.rootPort != nil {
+		sts.RootPort = s.rootPort.port.Name()
+	}
+	for name, p := range s.ports {
+		sts.Ports = append(sts.Ports, StpPortStats{
+			Name:   name,
+			Id:     fmt.Sprintf("%x", p.id),
+			Cost:   int(p.cost),
+			Role:   p.role,
+			State:  p.state,
+			Edge:   p.edge,
+			Guard:  p.guard,
+			Legacy: p.legacy,
+		})
+	}
+	sort.Slice(sts.Ports, func(i, j int) bool {
+		return sts.Ports[i].Name < sts.Ports[j].Name
+	})
+	return sts
+}
+
+func stpNow() int64 {
+	return time.Now().UnixNano() / 1e6
+}
+
+func (b *VirtualBridge) Stp(enable bool) error {
+	now := stpNow()
+	b.stp.lock.Lock()
+	defer b.stp.lock.Unlock()
+	b.stp.enable = enable
+	if enable {
+		for _, p := range b.stp.ports {
+			b.stp.reset(p, now)
+		}
+		b.stp.selectRoles(now)
+	}
+	b.out.Info("VirtualBridge.Stp: %v", enable)
+	return nil
+}
+
+// Delay sets forward delay in seconds.
+func (b *VirtualBridge) Delay(value int) error {
+	if value <= 0 {
+		return libol.NewErr("invalid delay %d", value)
+	}
+	b.stp.lock.Lock()
+	defer b.stp.lock.Unlock()
+	b.stp.delay = value
+	return nil
+}
+
+func (b *VirtualBridge) SetCost(port string, cost int) error {
+	b.stp.lock.Lock()
+	defer b.stp.lock.Unlock()
+	p, ok := b.stp.ports[port]
+	if !ok {
+		return libol.NewErr("%s notFound", port)
+	}
+	p.cost = uint32(cost)
+	b.stp.selectRoles(stpNow())
+	b.out.Info("VirtualBridge.SetCost: %s %d", port, cost)
+	return nil
+}
+
+// SetEdge sets port as administrative edge with bpdu guard, and points
+// access by it that only links take part in spanning tree.
+func (b *VirtualBridge) SetEdge(port string, edge bool) error {
+	b.stp.lock.Lock()
+	defer b.stp.lock.Unlock()
+	p, ok := b.stp.ports[port]
+	if !ok {
+		return libol.NewErr("%s notFound", port)
+	}
+	b.stp.edge(p, edge, stpNow())
+	b.out.Info("VirtualBridge.SetEdge: %s %v", port, edge)
+	return nil
+}
+
+func (b *VirtualBridge) StpStats() *StpStats {
+	b.stp.lock.Lock()
+	defer b.stp.lock.Unlock()
+	if !b.stp.enable {
+		return nil
+	}
+	return b.stp.stats()
+}
+
+func (b *VirtualBridge) stpAdd(port Taper) {
+	b.stp.lock.Lock()
+	defer b.stp.lock.Unlock()
+	b.stp.add(port, port.Name() == b.Kernel(), stpNow())
+}
+
+func (b *VirtualBridge) stpDel(name string) {
+	b.stp.lock.Lock()
+	defer b.stp.lock.Unlock()
+	b.stp.del(name, stpNow())
+}
+
+// stpState returns whether frames from or to port can be forwarded, and
+// whether addresses can be learned.
+func (b *VirtualBridge) stpState(port Taper) (bool, bool) {
+	b.stp.lock.Lock()
+	defer b.stp.lock.Unlock()
+	return b.stp.forwarding(port.Name())
+}
+
+// stpInput handles bpdu if spanning tree enabled or port guarded, and
+// returns true if consumed.
+func (b *VirtualBridge) stpInput(m *Framer) bool {
+	if len(m.Data) < 6 || !bytes.Equal(m.Data[0:6], stpAddr) {
+		return false
+	}
+	name := m.Source.Name()
+	b.stp.lock.Lock()
+	// guarded port is blocked by any bpdu even if spanning tree disabled.
+	if p, ok := b.stp.ports[name]; ok && p.guard {
+		blocked := b.stp.block(p)
+		b.stp.lock.Unlock()
+		if blocked {
+			b.out.Warn("VirtualBridge.stpInput: %s blocked by bpdu guard", name)
+		}
+		return true
+	}
+	if !b.stp.enable {
+		b.stp.lock.Unlock()
+		return false
+	}
+	var sends []stpSend
+	if d := decodeBpdu(m.Data); d != nil {
+		if p, ok := b.stp.ports[name]; ok {
+			sends = b.stp.recv(p, d, stpNow())
+		}
+	}
+	flush := b.stp.takeFlush()
+	b.stp.lock.Unlock()
+	b.stpOutput(sends, flush)
+	return true
+}
+
+func (b *VirtualBridge) stpTick(now int64) {
+	b.stp.lock.Lock()
+	if !b.stp.enable {
+		b.stp.lock.Unlock()
+		return
+	}
+	sends := b.stp.tick(now)
+	flush := b.stp.takeFlush()
+	b.stp.lock.Unlock()
+	b.stpOutput(sends, flush)
+}
+
+func (b *VirtualBridge) stpOutput(sends []stpSend, flush map[string]bool) {
+	for _, s := range sends {
+		if _, err := s.port.Send(s.frame); err != nil {
+			b.out.Debug("VirtualBridge.stpOutput: %s %s", s.port, err)
+		}
+	}
+	if flush == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for key, fdb := range b.macs {
+		if flush[fdb.Device.Name()] {
+			delete(b.macs, key)
+		}
+	}
+	b.out.Event("VirtualBridge.stpOutput: flush %d ports", len(flush))
+}
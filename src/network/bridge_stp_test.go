@@ -0,0 +1,160 @@
+package network
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+// stpWire moves frames between ports of two bridges.
+type stpWire struct {
+	br   map[*VirtualTap]*VirtualBridge
+	peer map[*VirtualTap]*VirtualTap
+}
+
+func (w *stpWire) connect(a *VirtualTap, aBr *VirtualBridge, b *VirtualTap, bBr *VirtualBridge) {
+	w.br[a], w.br[b] = aBr, bBr
+	w.peer[a], w.peer[b] = b, a
+}
+
+func (w *stpWire) pump() {
+	for moved := true; moved; {
+		moved = false
+		for port, peer := range w.peer {
+			for port.kernC > 0 {
+				port.kernC--
+				frame := <-port.kernQ
+				_ = w.br[peer].Input(&Framer{Data: frame, Source: peer})
+				moved = true
+			}
+		}
+	}
+}
+
+func newStpBridge(name, addr string, size int) (*VirtualBridge, []*VirtualTap) {
+	br := NewVirtualBridge(name, 1500)
+	br.stp.address, _ = net.ParseMAC(addr)
+	_ = br.Stp(true)
+	_ = br.Delay(2)
+	ports := make([]*VirtualTap, size)
+	for i := range ports {
+		ports[i], _ = NewVirtualTap(name, TapConfig{KernBuf: 64, VirBuf: 64})
+		ports[i].Up()
+		_ = br.AddSlave(ports[i].Name())
+	}
+	return br, ports
+}
+
+func TestVirtualBridge_Stp(t *testing.T) {
+	a, aPorts := newStpBridge("br-stp-a", "00:16:3e:00:00:01", 2)
+	b, bPorts := newStpBridge("br-stp-b", "00:16:3e:00:00:02", 2)
+	wire := &stpWire{
+		br:   make(map[*VirtualTap]*VirtualBridge, 4),
+		peer: make(map[*VirtualTap]*VirtualTap, 4),
+	}
+	wire.connect(aPorts[0], a, bPorts[0], b)
+	wire.connect(aPorts[1], a, bPorts[1], b)
+
+	now := stpNow()
+	for i := int64(0); i < 10; i++ {
+		a.stpTick(now + i*1000)
+		b.stpTick(now + i*1000)
+		wire.pump()
+	}
+	sa, sb := a.StpStats(), b.StpStats()
+	assert.Equal(t, sa.Bridge, sa.Root, "be root.")
+	assert.Equal(t, sa.Root, sb.Root, "be the same.")
+	assert.Equal(t, bPorts[0].Name(), sb.RootPort, "be the same.")
+	for _, p := range sa.Ports {
+		assert.Equal(t, StpDesignated, p.Role, "be the same.")
+		assert.Equal(t, StpForwarding, p.State, "be the same.")
+	}
+	roles := map[string]string{}
+	states := map[string]string{}
+	for _, p := range sb.Ports {
+		roles[p.Name], states[p.Name] = p.Role, p.State
+	}
+	assert.Equal(t, StpRoot, roles[bPorts[0].Name()], "be the same.")
+	assert.Equal(t, StpForwarding, states[bPorts[0].Name()], "be the same.")
+	assert.Equal(t, StpAlternate, roles[bPorts[1].Name()], "be the same.")
+	assert.Equal(t, StpDiscarding, states[bPorts[1].Name()], "be the same.")
+
+	// prefer the second link by cost.
+	_ = b.SetCost(bPorts[0].Name(), StpCost*2)
+	for i := int64(10); i < 14; i++ {
+		a.stpTick(now + i*1000)
+		b.stpTick(now + i*1000)
+		wire.pump()
+	}
+	sb = b.StpStats()
+	assert.Equal(t, bPorts[1].Name(), sb.RootPort, "be the same.")
+
+	// lose the second link, and the first one takes over.
+	delete(wire.peer, aPorts[1])
+	delete(wire.peer, bPorts[1])
+	for i := int64(14); i < 24; i++ {
+		a.stpTick(now + i*1000)
+		b.stpTick(now + i*1000)
+		wire.pump()
+		for bPorts[1].kernC > 0 {
+			bPorts[1].kernC--
+			<-bPorts[1].kernQ
+		}
+		for aPorts[1].kernC > 0 {
+			aPorts[1].kernC--
+			<-aPorts[1].kernQ
+		}
+	}
+	sb = b.StpStats()
+	assert.Equal(t, bPorts[0].Name(), sb.RootPort, "be the same.")
+}
+
+func TestStpBpdu(t *testing.T) {
+	d := &stpBpdu{Type: stpRst, Flags: stpRoleDesignated | stpFlagProposal, Age: 1, Hello: StpHello}
+	d.Root[0], d.Bridge[0], d.Cost, d.Port = 0x80, 0x80, 20000, 0x8001
+	frame := d.Encode([]byte{0x00, 0x16, 0x3e, 0x00, 0x00, 0x01})
+	n := decodeBpdu(frame)
+	assert.NotNil(t, n, "be decoded.")
+	assert.Equal(t, d.stpVector, n.stpVector, "be the same.")
+	assert.Equal(t, d.Flags, n.Flags, "be the same.")
+	assert.Equal(t, 1, n.Age, "be the same.")
+	assert.Nil(t, decodeBpdu(frame[:20]), "be nil.")
+}
+
+func TestVirtualBridge_StpLegacy(t *testing.T) {
+	a, aPorts := newStpBridge("br-stp-c", "00:16:3e:00:00:03", 1)
+	// config bpdu of legacy stp from a better root.
+	d := &stpBpdu{Type: stpConfig, Flags: stpFlagTc, Hello: StpHello}
+	d.Root[0], d.Bridge[0], d.Port = 0x10, 0x10, 0x8001
+	now := stpNow()
+	_ = a.Input(&Framer{Data: d.Encode([]byte{0x00, 0x16, 0x3e, 0x00, 0x00, 0x04}), Source: aPorts[0]})
+	sa := a.StpStats()
+	assert.True(t, sa.Ports[0].Legacy, "be legacy.")
+	assert.Equal(t, StpRoot, sa.Ports[0].Role, "be the same.")
+	// notifies change by tcn until acknowledged.
+	a.stpTick(now + 1000)
+	var frame []byte
+	for aPorts[0].kernC > 0 {
+		aPorts[0].kernC--
+		frame = <-aPorts[0].kernQ
+	}
+	n := decodeBpdu(frame)
+	assert.NotNil(t, n, "be decoded.")
+	assert.Equal(t, byte(stpTcn), n.Type, "be tcn.")
+}
+
+func TestVirtualBridge_StpGuard(t *testing.T) {
+	a, aPorts := newStpBridge("br-stp-d", "00:16:3e:00:00:05", 1)
+	_ = a.Stp(false)
+	name := aPorts[0].Name()
+	_ = a.SetEdge(name, true)
+	forward, _ := a.stpState(aPorts[0])
+	assert.True(t, forward, "be forwarding.")
+	d := &stpBpdu{Type: stpRst, Flags: stpRoleDesignated, Hello: StpHello}
+	_ = a.Input(&Framer{Data: d.Encode([]byte{0x00, 0x16, 0x3e, 0x00, 0x00, 0x06}), Source: aPorts[0]})
+	forward, _ = a.stpState(aPorts[0])
+	assert.False(t, forward, "be blocked.")
+	_ = a.SetEdge(name, true)
+	forward, _ = a.stpState(aPorts[0])
+	assert.True(t, forward, "be unblocked.")
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"github.com/danieldin95/openlan-go/src/libol"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -13,14 +14,22 @@ type VirtualBridge struct {
 	name    string
 	lock    sync.RWMutex
 	ports   map[string]Taper
-	macs    map[string]*MacFdb
+	macs    map[string]*MacFdb // by vlan and address.
+	vlans   map[string]*PortVlan
+	isolate map[string]bool // isolated ports can't talk to each other.
+	secures map[string]*portSecure
+	owners  map[string]string // port by address bound.
+	alerts  map[string]string // port by violation alerted.
 	done    chan bool
 	ticker  *time.Ticker
+	hello   *time.Ticker
 	timeout int
 	address string
 	kernel  Taper
 	out     *libol.SubLogger
 	sts     DeviceStats
+	flood   flooder
+	stp     spanning
 }
 
 func NewVirtualBridge(name string, mtu int) *VirtualBridge {
@@ -29,10 +38,17 @@ func NewVirtualBridge(name string, mtu int) *VirtualBridge {
 		ifMtu:   mtu,
 		ports:   make(map[string]Taper, 1024),
 		macs:    make(map[string]*MacFdb, 1024),
+		vlans:   make(map[string]*PortVlan, 1024),
+		isolate: make(map[string]bool, 1024),
+		secures: make(map[string]*portSecure, 1024),
+		owners:  make(map[string]string, 1024),
+		alerts:  make(map[string]string, 1024),
 		done:    make(chan bool),
 		ticker:  time.NewTicker(5 * time.Second),
+		hello:   time.NewTicker(time.Second),
 		timeout: 5 * 60,
 		out:     libol.NewSubLogger(name),
+		stp:     newSpanning(name),
 	}
 	Bridges.Add(b)
 	return b
@@ -50,6 +66,9 @@ func (b *VirtualBridge) Open(addr string) {
 			b.out.Error("VirtualBridge.Open IpAddr %s:%s", err, out)
 		}
 		b.kernel = tap
+		if dev, err := net.InterfaceByName(tap.Name()); err == nil && len(dev.HardwareAddr) == 6 {
+			b.stp.address = dev.HardwareAddr
+		}
 		b.out.Info("VirtualBridge.Open %s", tap.Name())
 		_ = b.AddSlave(tap.name)
 	}
@@ -79,6 +98,7 @@ func (b *VirtualBridge) Close() error {
 		_ = b.kernel.Close()
 	}
 	b.ticker.Stop()
+	b.hello.Stop()
 	b.done <- true
 	return nil
 }
@@ -93,10 +113,12 @@ func (b *VirtualBridge) AddSlave(name string) error {
 	b.lock.Lock()
 	b.ports[name] = tap
 	b.lock.Unlock()
+	b.stpAdd(tap)
 	b.out.Info("VirtualBridge.AddSlave: %s", name)
 	libol.Go(func() {
+		// frame is copied by ports if sent, so reuses buffer to receive.
+		data := make([]byte, b.ifMtu)
 		for {
-			data := make([]byte, b.ifMtu)
 			n, err := tap.Recv(data)
 			if err != nil || n == 0 {
 				break
@@ -117,6 +139,13 @@ func (b *VirtualBridge) DelSlave(name string) error {
 	if _, ok := b.ports[name]; ok {
 		delete(b.ports, name)
 	}
+	delete(b.vlans, name)
+	delete(b.isolate, name)
+	b.unsecure(name)
+	b.flood.lock.Lock()
+	delete(b.flood.buckets, name)
+	b.flood.lock.Unlock()
+	b.stpDel(name)
 	b.out.Info("VirtualBridge.DelSlave: %s", name)
 	return nil
 }
@@ -147,7 +176,13 @@ func (b *VirtualBridge) Name() string {
 }
 
 func (b *VirtualBridge) Forward(m *Framer) error {
-	if err := b.UniCast(m); err != nil {
+	if m.Data[0]&0x01 == 0x01 { // broadcast or multicast
+		if b.proxyArp(m) || !b.allowFlood(m.Source) {
+			return nil
+		}
+		return b.FloodTo(m, b.mcastPorts(m))
+	}
+	if err := b.UniCast(m); err != nil && b.allowFlood(m.Source) {
 		_ = b.Flood(m)
 	}
 	return nil
@@ -174,6 +209,8 @@ func (b *VirtualBridge) Expire() error {
 		}
 	}
 	b.lock.Unlock()
+	b.expireMcast()
+	b.querier(time.Now().Unix())
 	return nil
 }
 
@@ -186,14 +223,75 @@ func (b *VirtualBridge) Start() {
 			case t := <-b.ticker.C:
 				b.out.Log("VirtualBridge.Start: Tick at %s", t)
 				_ = b.Expire()
+			case <-b.hello.C:
+				b.stpTick(stpNow())
 			}
 		}
 	})
 }
 
+func (b *VirtualBridge) SetVlan(port string, vlan *PortVlan) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if vlan == nil {
+		delete(b.vlans, port)
+	} else {
+		b.vlans[port] = vlan
+	}
+	b.out.Info("VirtualBridge.SetVlan: %s %s", port, vlan)
+	return nil
+}
+
+func (b *VirtualBridge) SetIsolated(port string, on bool) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if on {
+		b.isolate[port] = true
+	} else {
+		delete(b.isolate, port)
+	}
+	b.out.Info("VirtualBridge.SetIsolated: %s %v", port, on)
+	return nil
+}
+
+// Isolated returns whether frame from one port can't go to another.
+func (b *VirtualBridge) Isolated(from, to Taper) bool {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.isolate[from.Name()] && b.isolate[to.Name()]
+}
+
+func (b *VirtualBridge) GetVlan(port Taper) *PortVlan {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	return b.vlans[port.Name()]
+}
+
 func (b *VirtualBridge) Input(m *Framer) error {
 	b.sts.Recv++
+	if b.stpInput(m) {
+		return nil
+	}
+	forward, learn := b.stpState(m.Source)
+	if !learn {
+		b.sts.Drop++
+		return nil
+	}
+	vid, data := b.GetVlan(m.Source).Ingress(m.Data)
+	if vid < 0 {
+		b.sts.Drop++
+		return nil
+	}
+	m.Vlan, m.Data = vid, data
+	if !b.secure(m) {
+		return nil
+	}
 	b.Learn(m)
+	if !forward {
+		b.sts.Drop++
+		return nil
+	}
+	b.snoop(m)
 	return b.Forward(m)
 }
 
@@ -209,8 +307,11 @@ func (b *VirtualBridge) Learn(m *Framer) {
 	if mac[0]&0x01 == 0x01 {
 		return
 	}
-	key := b.Eth2Str(mac)
+	key := b.FdbKey(m.Vlan, mac)
 	if l := b.GetMac(key); l != nil {
+		if l.Device != m.Source {
+			b.out.Warn("VirtualBridge.Learn: %s moved from %s to %s", key, l.Device, m.Source)
+		}
 		b.UpdateMac(key, m.Source)
 		return
 	}
@@ -219,12 +320,21 @@ func (b *VirtualBridge) Learn(m *Framer) {
 		Uptime:  time.Now().Unix(),
 		NewTime: time.Now().Unix(),
 		Address: make([]byte, 6),
+		Vlan:    m.Vlan,
 	}
 	copy(learn.Address, mac)
 	b.out.Event("VirtualBridge.Learn: %s on %s", key, m.Source)
 	b.AddMac(key, learn)
 }
 
+// FdbKey returns key of address in vlan, and the address only if vlan 0.
+func (b *VirtualBridge) FdbKey(vlan int, addr []byte) string {
+	if vlan == 0 {
+		return b.Eth2Str(addr)
+	}
+	return strconv.Itoa(vlan) + "/" + b.Eth2Str(addr)
+}
+
 func (b *VirtualBridge) GetMac(mac string) *MacFdb {
 	b.lock.RLock()
 	defer b.lock.RUnlock()
@@ -263,25 +373,46 @@ func (b *VirtualBridge) ListMac() <-chan *MacFdb {
 }
 
 func (b *VirtualBridge) Flood(m *Framer) error {
+	return b.FloodTo(m, nil)
+}
+
+// FloodTo sends frame to ports in names, and all if names is nil.
+func (b *VirtualBridge) FloodTo(m *Framer, names map[string]bool) error {
 	data := m.Data
 	from := m.Source
 	if b.out.Has(libol.FLOW) {
 		b.out.Flow("VirtualBridge.Flood: % x", data[:20])
 	}
 	outs := make([]Taper, 0, 32)
+	vlans := make([]*PortVlan, 0, 32)
 	b.lock.RLock()
-	for _, port := range b.ports {
+	isolated := from != nil && b.isolate[from.Name()]
+	for name, port := range b.ports {
+		if isolated && b.isolate[name] {
+			continue
+		}
+		if names != nil && !names[name] {
+			continue
+		}
 		if from != port {
 			outs = append(outs, port)
+			vlans = append(vlans, b.vlans[name])
 		}
 	}
 	b.lock.RUnlock()
-	for _, port := range outs {
+	for i, port := range outs {
+		frame := vlans[i].Egress(m.Vlan, data)
+		if forward, _ := b.stpState(port); !forward {
+			frame = nil
+		}
+		if frame == nil {
+			continue
+		}
 		if b.out.Has(libol.FLOW) {
-			b.out.Flow("VirtualBridge.Flood: %s % x", port, data[:20])
+			b.out.Flow("VirtualBridge.Flood: %s % x", port, frame[:20])
 		}
 		b.sts.Send++
-		if _, err := port.Send(data); err != nil {
+		if _, err := port.Send(frame); err != nil {
 			b.out.Error("VirtualBridge.Flood: %s %s", port, err)
 		}
 	}
@@ -291,15 +422,22 @@ func (b *VirtualBridge) Flood(m *Framer) error {
 func (b *VirtualBridge) UniCast(m *Framer) error {
 	data := m.Data
 	from := m.Source
-	dest := b.Eth2Str(data[:6])
+	dest := b.FdbKey(m.Vlan, data[:6])
 	learn := b.GetMac(dest)
 	if learn == nil {
 		return errors.New(dest + " notFound")
 	}
 	out := learn.Device
-	if out != from && out.Has(UsUp) { // out should running
+	frame := b.GetVlan(out).Egress(m.Vlan, data)
+	if b.Isolated(from, out) {
+		frame = nil
+	}
+	if forward, _ := b.stpState(out); !forward {
+		frame = nil
+	}
+	if out != from && out.Has(UsUp) && frame != nil { // out should running
 		b.sts.Send++
-		if _, err := out.Send(data); err != nil {
+		if _, err := out.Send(frame); err != nil {
 			b.out.Warn("VirtualBridge.UniCast: %s %s", out, err)
 		}
 	} else {
@@ -315,14 +453,6 @@ func (b *VirtualBridge) Mtu() int {
 	return b.ifMtu
 }
 
-func (b *VirtualBridge) Stp(enable bool) error {
-	return libol.NewErr("operation notSupport")
-}
-
-func (b *VirtualBridge) Delay(value int) error {
-	return libol.NewErr("operation notSupport")
-}
-
 func (b *VirtualBridge) Stats() DeviceStats {
 	return b.sts
 }
@@ -0,0 +1,199 @@
+package network
+
+import (
+	"encoding/binary"
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func newBridgePorts(name string, size int) (*VirtualBridge, []*VirtualTap) {
+	br := NewVirtualBridge(name, 1500)
+	ports := make([]*VirtualTap, size)
+	for i := range ports {
+		ports[i], _ = NewVirtualTap(name, TapConfig{KernBuf: 16, VirBuf: 16})
+		ports[i].Up()
+		br.ports[ports[i].Name()] = ports[i]
+	}
+	return br, ports
+}
+
+func TestVirtualBridge_Isolated(t *testing.T) {
+	br, ports := newBridgePorts("br-isolated", 3)
+	a, b, link := ports[0], ports[1], ports[2]
+	_ = br.SetIsolated(a.Name(), true)
+	_ = br.SetIsolated(b.Name(), true)
+
+	frame := make([]byte, 64)
+	copy(frame[:6], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	copy(frame[6:12], []byte{0x00, 0x16, 0x3e, 0x00, 0x00, 0x0b})
+	_ = br.Input(&Framer{Data: frame, Source: b})
+	assert.Equal(t, 0, a.kernC, "be isolated.")
+	assert.Equal(t, 1, link.kernC, "be flooded.")
+
+	// unicast to learned address.
+	to := make([]byte, 64)
+	copy(to[:6], frame[6:12])
+	_ = br.Input(&Framer{Data: to, Source: a})
+	assert.Equal(t, 0, b.kernC, "be isolated.")
+	_ = br.Input(&Framer{Data: to, Source: link})
+	assert.Equal(t, 1, b.kernC, "from promiscuous.")
+}
+
+func TestVirtualBridge_Storm(t *testing.T) {
+	br, ports := newBridgePorts("br-storm", 2)
+	_ = br.SetStorm(5)
+	frame := make([]byte, 64)
+	copy(frame[:6], libol.EthAll)
+	for i := 0; i < 10; i++ {
+		_ = br.Input(&Framer{Data: frame, Source: ports[0]})
+	}
+	assert.Equal(t, 5, ports[1].kernC, "be limited.")
+	assert.Equal(t, int64(5), br.Stats().Storm, "be the same.")
+	assert.Equal(t, int64(5), br.StormStats()[ports[0].Name()], "be the same.")
+	assert.Equal(t, int64(0), br.StormStats()[ports[1].Name()], "be the same.")
+}
+
+func igmpFrame(typ byte, group net.IP) []byte {
+	frame := make([]byte, libol.EtherLen+libol.Ipv4Len+8)
+	copy(frame[:6], []byte{0x01, 0x00, 0x5e, group[1] & 0x7f, group[2], group[3]})
+	binary.BigEndian.PutUint16(frame[12:14], libol.EthIp4)
+	ip := frame[libol.EtherLen:]
+	ip[0] = 0x45
+	ip[9] = igmpProto
+	copy(ip[16:20], group.To4())
+	igmp := ip[libol.Ipv4Len:]
+	igmp[0] = typ
+	copy(igmp[4:8], group.To4())
+	return frame
+}
+
+func TestVirtualBridge_Snooping(t *testing.T) {
+	br, ports := newBridgePorts("br-snooping", 3)
+	_ = br.SetSnooping(true)
+	group := net.ParseIP("239.1.1.1").To4()
+	_ = br.Input(&Framer{Data: igmpFrame(0x16, group), Source: ports[1]})
+	data := igmpFrame(0x00, group)
+	data[libol.EtherLen+9] = libol.IpUdp
+	before := ports[2].kernC
+	_ = br.Input(&Framer{Data: data, Source: ports[0]})
+	assert.Equal(t, 1, ports[1].kernC, "be subscribed.")
+	assert.Equal(t, before, ports[2].kernC, "not subscribed.")
+	_ = br.Input(&Framer{Data: igmpFrame(0x17, group), Source: ports[1]})
+	_ = br.Input(&Framer{Data: data, Source: ports[0]})
+	assert.Equal(t, 1, ports[1].kernC, "be left.")
+}
+
+func TestVirtualBridge_Querier(t *testing.T) {
+	br, ports := newBridgePorts("br-querier", 2)
+	_ = br.SetSnooping(true)
+	br.querier(McastQuery)
+	assert.Equal(t, 0, ports[0].kernC, "no groups.")
+	group := net.ParseIP("239.1.1.1").To4()
+	_ = br.Input(&Framer{Data: igmpFrame(0x16, group), Source: ports[1]})
+	for ports[0].kernC > 0 {
+		ports[0].kernC--
+		<-ports[0].kernQ
+	}
+	br.querier(McastQuery * 2)
+	assert.Equal(t, 1, ports[0].kernC, "be queried.")
+	query := <-ports[0].kernQ
+	ports[0].kernC--
+	assert.Equal(t, []byte{0x01, 0x00, 0x5e, 0x00, 0x00, 0x01}, query[:6], "be the same.")
+	ip := query[libol.EtherLen:]
+	assert.Equal(t, uint16(0), checksum(ip[:24]), "be valid.")
+	assert.Equal(t, byte(0x11), ip[24], "be query.")
+	assert.Equal(t, uint16(0), checksum(ip[24:]), "be valid.")
+	br.querier(McastQuery*2 + 1)
+	assert.Equal(t, 0, ports[0].kernC, "not in interval.")
+
+	// other querier is elected.
+	_ = br.Input(&Framer{Data: igmpFrame(0x11, net.IPv4zero.To4()), Source: ports[0]})
+	br.querier(time.Now().Unix())
+	assert.Equal(t, 0, ports[0].kernC, "not queried.")
+}
+
+func TestVirtualBridge_ArpProxy(t *testing.T) {
+	br, ports := newBridgePorts("br-arp", 3)
+	hw, _ := net.ParseMAC("00:16:3e:00:00:0b")
+	ip := net.ParseIP("192.168.1.11").To4()
+	_ = br.SetArpProxy(func(target net.IP) net.HardwareAddr {
+		if target.Equal(ip) {
+			return hw
+		}
+		return nil
+	})
+	// learn address on port 1.
+	frame := make([]byte, 64)
+	copy(frame[6:12], hw)
+	_ = br.Input(&Framer{Data: frame, Source: ports[1]})
+	<-ports[0].kernQ
+	ports[0].kernC--
+
+	req := make([]byte, libol.EtherLen+28)
+	copy(req[:6], libol.EthAll)
+	copy(req[6:12], []byte{0x00, 0x16, 0x3e, 0x00, 0x00, 0x0a})
+	binary.BigEndian.PutUint16(req[12:14], libol.EthArp)
+	binary.BigEndian.PutUint16(req[libol.EtherLen+6:], libol.ArpRequest)
+	copy(req[libol.EtherLen+24:], ip)
+	// gratuitous arp and probe aren't answered.
+	copy(req[libol.EtherLen+14:], ip)
+	_ = br.Input(&Framer{Data: req, Source: ports[0]})
+	assert.Equal(t, 0, ports[0].kernC, "not answered.")
+	copy(req[libol.EtherLen+14:], net.IPv4zero.To4())
+	_ = br.Input(&Framer{Data: req, Source: ports[0]})
+	assert.Equal(t, 0, ports[0].kernC, "not answered.")
+
+	copy(req[libol.EtherLen+14:], []byte{192, 168, 1, 10})
+	before := ports[2].kernC
+	_ = br.Input(&Framer{Data: req, Source: ports[0]})
+	assert.Equal(t, before, ports[2].kernC, "be suppressed.")
+	assert.Equal(t, 1, ports[0].kernC, "be answered.")
+	reply := <-ports[0].kernQ
+	assert.Equal(t, []byte(hw), reply[libol.EtherLen+8:libol.EtherLen+14], "be the same.")
+}
+
+func TestVirtualBridge_Security(t *testing.T) {
+	br, ports := newBridgePorts("br-secure", 3)
+	a, b, c := ports[0], ports[1], ports[2]
+	sticky := make([]string, 0, 2)
+	violated := 0
+	_ = br.SetSecurity(a.Name(), &PortSecurity{Max: 1, Action: SecureDisconnect, Sticky: true},
+		func(port, addr string, v bool) {
+			if v {
+				violated++
+			} else {
+				sticky = append(sticky, addr)
+			}
+		})
+	_ = br.SetSecurity(b.Name(), &PortSecurity{Max: 1, Action: SecureDrop}, nil)
+
+	frame := func(src byte) []byte {
+		data := make([]byte, 64)
+		copy(data[:6], libol.EthAll)
+		copy(data[6:12], []byte{0x00, 0x16, 0x3e, 0x00, 0x00, src})
+		return data
+	}
+	_ = br.Input(&Framer{Data: frame(0x01), Source: a})
+	assert.Equal(t, []string{"00:16:3e:00:00:01"}, sticky, "be sticky.")
+	_ = br.Input(&Framer{Data: frame(0x02), Source: a})
+	assert.Equal(t, 1, violated, "be violated.")
+	assert.Nil(t, br.GetMac("00:16:3e:00:00:02"), "be dropped.")
+	s := &portSecure{}
+	assert.False(t, s.hold(100), "not held.")
+	assert.True(t, s.hold(100), "be held.")
+	assert.False(t, s.hold(101), "not held.")
+
+	_ = br.Input(&Framer{Data: frame(0x03), Source: b})
+	_ = br.Input(&Framer{Data: frame(0x04), Source: b})
+	assert.NotNil(t, br.GetMac("00:16:3e:00:00:03"), "be learned.")
+	assert.Nil(t, br.GetMac("00:16:3e:00:00:04"), "be dropped.")
+
+	// the sticky address can't move to others.
+	before := a.kernC
+	_ = br.Input(&Framer{Data: frame(0x01), Source: c})
+	assert.Equal(t, before, a.kernC, "be dropped.")
+	assert.Equal(t, a.Name(), br.GetMac("00:16:3e:00:00:01").Device.Name(), "be the same.")
+}
@@ -1,6 +1,7 @@
 package network
 
 import (
+	"net"
 	"sync"
 )
 
@@ -8,6 +9,7 @@ const (
 	ProviderVir = "virtual"
 	ProviderKer = "kernel"
 	ProviderLin = "linux"
+	ProviderOvs = "openvswitch"
 )
 
 type MacFdb struct {
@@ -15,6 +17,7 @@ type MacFdb struct {
 	Device  Taper
 	Uptime  int64
 	NewTime int64
+	Vlan    int
 }
 
 type Bridger interface {
@@ -33,6 +36,16 @@ type Bridger interface {
 	String() string
 	Stats() DeviceStats
 	CallIptables(value int) error
+	SetVlan(port string, vlan *PortVlan) error
+	SetIsolated(port string, on bool) error
+	SetStorm(pps int) error
+	SetSnooping(on bool) error
+	SetArpProxy(lookup func(ip net.IP) net.HardwareAddr) error
+	SetCost(port string, cost int) error
+	SetEdge(port string, edge bool) error
+	StpStats() *StpStats          // nil if not spanning by itself.
+	StormStats() map[string]int64 // dropped by storm control of ports.
+	SetSecurity(port string, sec *PortSecurity, handler SecureHandler) error
 }
 
 type bridger struct {
@@ -1,8 +1,11 @@
 package network
 
 func NewBridger(provider, name string, ifMtu int) Bridger {
-	if provider == ProviderVir {
+	switch provider {
+	case ProviderVir:
 		return NewVirtualBridge(name, ifMtu)
+	case ProviderOvs:
+		return NewOvsBridge(name, ifMtu)
 	}
 	return NewLinuxBridge(name, ifMtu)
 }
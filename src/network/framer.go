@@ -4,4 +4,5 @@ type Framer struct {
 	Data   []byte
 	Source Taper
 	Output Taper
+	Vlan   int // vlan of untagged data.
 }
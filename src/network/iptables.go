@@ -116,6 +116,9 @@ func (ru IpRule) Eq(obj IpRule) bool {
 	if ru.DstPort != obj.DstPort {
 		return false
 	}
+	if ru.Output != obj.Output {
+		return false
+	}
 	return true
 }
 
@@ -0,0 +1,206 @@
+package network
+
+import (
+	"encoding/json"
+	"github.com/danieldin95/openlan-go/src/libol"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OvsDbAddr   = "unix:/var/run/openvswitch/db.sock"
+	OvsCtlAddr  = "unix:/var/run/openvswitch/ovs-vswitchd.*.ctl"
+	OvsDatabase = "Open_vSwitch"
+)
+
+// OvsOp is an operation of transact, likes insert, select or update.
+type OvsOp map[string]interface{}
+
+type OvsResult struct {
+	Uuid    []interface{}            `json:"uuid,omitempty"`
+	Rows    []map[string]interface{} `json:"rows,omitempty"`
+	Count   int                      `json:"count,omitempty"`
+	Error   string                   `json:"error,omitempty"`
+	Details string                   `json:"details,omitempty"`
+}
+
+type ovsRequest struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+	Id     interface{}   `json:"id"`
+}
+
+type ovsResponse struct {
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result"`
+	Error  interface{}     `json:"error"`
+	Id     interface{}     `json:"id"`
+}
+
+// OvsDb is a client of JSON-RPC 1.0 used by OVSDB management protocol and
+// unixctl of ovs-vswitchd. The address likes unix:/path or tcp:host:port.
+type OvsDb struct {
+	lock sync.Mutex
+	addr string
+	conn net.Conn
+	dec  *json.Decoder
+	id   int
+}
+
+func NewOvsDb(addr string) *OvsDb {
+	return &OvsDb{addr: addr}
+}
+
+func (o *OvsDb) dial() error {
+	network, address := "unix", o.addr
+	if i := strings.Index(o.addr, ":"); i > 0 {
+		switch o.addr[:i] {
+		case "unix", "tcp":
+			network, address = o.addr[:i], o.addr[i+1:]
+		}
+	}
+	if network == "unix" && strings.Contains(address, "*") {
+		if matches, _ := filepath.Glob(address); len(matches) > 0 {
+			address = matches[0]
+		}
+	}
+	conn, err := net.DialTimeout(network, address, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	o.conn = conn
+	o.dec = json.NewDecoder(conn)
+	return nil
+}
+
+func (o *OvsDb) close() {
+	if o.conn != nil {
+		_ = o.conn.Close()
+		o.conn = nil
+	}
+}
+
+func (o *OvsDb) Close() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.close()
+}
+
+func (o *OvsDb) send(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_ = o.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err = o.conn.Write(data)
+	return err
+}
+
+// Call invokes method with params and decodes result of response, and
+// answers echo from server while waiting.
+func (o *OvsDb) Call(method string, params []interface{}, result interface{}) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.conn == nil {
+		if err := o.dial(); err != nil {
+			return err
+		}
+	}
+	o.id++
+	if err := o.send(&ovsRequest{Method: method, Params: params, Id: o.id}); err != nil {
+		o.close()
+		return err
+	}
+	for {
+		resp := &ovsResponse{}
+		_ = o.conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+		if err := o.dec.Decode(resp); err != nil {
+			o.close()
+			return err
+		}
+		if resp.Method == "echo" {
+			reply := map[string]interface{}{"id": resp.Id, "result": resp.Params, "error": nil}
+			if err := o.send(reply); err != nil {
+				o.close()
+				return err
+			}
+			continue
+		}
+		if resp.Method != "" { // notifications.
+			continue
+		}
+		if id, ok := resp.Id.(float64); !ok || int(id) != o.id {
+			continue
+		}
+		if resp.Error != nil {
+			return libol.NewErr("%s: %v", method, resp.Error)
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	}
+}
+
+// Transact executes operations on Open_vSwitch database atomically.
+func (o *OvsDb) Transact(ops ...OvsOp) ([]*OvsResult, error) {
+	params := []interface{}{OvsDatabase}
+	for _, op := range ops {
+		params = append(params, op)
+	}
+	results := make([]*OvsResult, 0, len(ops))
+	if err := o.Call("transact", params, &results); err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		if r != nil && r.Error != "" {
+			return results, libol.NewErr("%s: %s", r.Error, r.Details)
+		}
+	}
+	return results, nil
+}
+
+func OvsSet(values ...interface{}) []interface{} {
+	if values == nil {
+		values = []interface{}{}
+	}
+	return []interface{}{"set", values}
+}
+
+func OvsMap(values map[string]string) []interface{} {
+	pairs := make([]interface{}, 0, len(values))
+	for k, v := range values {
+		pairs = append(pairs, []interface{}{k, v})
+	}
+	return []interface{}{"map", pairs}
+}
+
+func OvsNamed(name string) []interface{} {
+	return []interface{}{"named-uuid", name}
+}
+
+func OvsWhere(column string, value interface{}) [][]interface{} {
+	return [][]interface{}{{column, "==", value}}
+}
+
+// OvsMapOf returns pairs of map column.
+func OvsMapOf(value interface{}) map[string]interface{} {
+	pairs := make(map[string]interface{}, 16)
+	data, ok := value.([]interface{})
+	if !ok || len(data) != 2 || data[0] != "map" {
+		return pairs
+	}
+	items, _ := data[1].([]interface{})
+	for _, item := range items {
+		if kv, ok := item.([]interface{}); ok && len(kv) == 2 {
+			if k, ok := kv[0].(string); ok {
+				pairs[k] = kv[1]
+			}
+		}
+	}
+	return pairs
+}
@@ -0,0 +1,106 @@
+package network
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	SecureDrop       = "drop"
+	SecureAlert      = "alert"
+	SecureDisconnect = "disconnect"
+)
+
+// PortSecurity limits source addresses of a port. The addresses in Macs are
+// always allowed, and others are learned until the maximum. The sticky one
+// binds addresses learned to Macs.
+type PortSecurity struct {
+	Max    int      `json:"max,omitempty"`
+	Action string   `json:"action,omitempty"` // drop, alert or disconnect if violated.
+	Sticky bool     `json:"sticky,omitempty"`
+	Macs   []string `json:"macs,omitempty"`
+}
+
+// SecureHandler is called with address learned by sticky port, or address
+// violated the security of port.
+type SecureHandler func(port, addr string, violated bool)
+
+// ParseSecurity parses security formatted by String, likes 2/drop or
+// 1/disconnect/sticky/00-16-3e-00-00-01.
+func ParseSecurity(value string) *PortSecurity {
+	if value == "" {
+		return nil
+	}
+	columns := strings.SplitN(value, "/", 4)
+	s := &PortSecurity{Action: SecureDrop}
+	s.Max, _ = strconv.Atoi(columns[0])
+	if len(columns) > 1 && columns[1] != "" {
+		s.Action = columns[1]
+	}
+	if len(columns) > 2 {
+		s.Sticky = columns[2] == "sticky"
+	}
+	if len(columns) > 3 && columns[3] != "" {
+		for _, addr := range strings.Split(columns[3], ",") {
+			if hw, err := net.ParseMAC(addr); err == nil {
+				s.Macs = append(s.Macs, hw.String())
+			}
+		}
+	}
+	switch s.Action {
+	case SecureDrop, SecureAlert, SecureDisconnect:
+		return s
+	}
+	return nil
+}
+
+func (s *PortSecurity) String() string {
+	if s == nil {
+		return ""
+	}
+	value := strconv.Itoa(s.Max) + "/" + s.Action
+	if !s.Sticky && len(s.Macs) == 0 {
+		return value
+	}
+	if s.Sticky {
+		value += "/sticky"
+	} else {
+		value += "/"
+	}
+	if len(s.Macs) > 0 {
+		// colon is separator of user line.
+		macs := make([]string, 0, len(s.Macs))
+		for _, addr := range s.Macs {
+			macs = append(macs, strings.Replace(addr, ":", "-", -1))
+		}
+		value += "/" + strings.Join(macs, ",")
+	}
+	return value
+}
+
+func (s *PortSecurity) Has(addr string) bool {
+	for _, a := range s.Macs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// Limit returns the maximum of addresses, and zero is unlimited.
+func (s *PortSecurity) Limit() int {
+	if s.Max <= 0 && !s.Sticky {
+		return len(s.Macs)
+	}
+	return s.Max
+}
+
+func (s *PortSecurity) Copy() *PortSecurity {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	c.Macs = append([]string{}, s.Macs...)
+	return &c
+}
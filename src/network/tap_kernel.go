@@ -9,6 +9,7 @@ import (
 type KernelTap struct {
 	lock   sync.Mutex
 	device *water.Interface
+	queues []*water.Interface // the first one is device.
 	master Bridger
 	tenant string
 	name   string
@@ -27,10 +28,19 @@ func NewKernelTap(tenant string, c TapConfig) (*KernelTap, error) {
 	tap := &KernelTap{
 		tenant: tenant,
 		device: device,
+		queues: []*water.Interface{device},
 		name:   device.Name(),
 		config: c,
 		ifMtu:  1514,
 	}
+	for i := 1; i < c.Queues; i++ {
+		queue, err := WaterQueue(tap.name, c)
+		if err != nil {
+			libol.Warn("NewKernelTap: %s queue %d %s", tap.name, i, err)
+			break
+		}
+		tap.queues = append(tap.queues, queue)
+	}
 	Taps.Add(tap)
 	return tap, nil
 }
@@ -74,6 +84,25 @@ func (t *KernelTap) Read(p []byte) (int, error) {
 	}
 }
 
+func (t *KernelTap) Queues() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return len(t.queues)
+}
+
+// ReadQueue reads data from queue q, and frames of a flow are always in
+// same queue selected by kernel.
+func (t *KernelTap) ReadQueue(q int, p []byte) (int, error) {
+	t.lock.Lock()
+	if t.device == nil || q >= len(t.queues) {
+		t.lock.Unlock()
+		return 0, libol.NewErr("Closed")
+	}
+	queue := t.queues[q]
+	t.lock.Unlock()
+	return queue.Read(p)
+}
+
 func (t *KernelTap) Write(p []byte) (int, error) {
 	t.lock.Lock()
 	if t.device == nil {
@@ -104,8 +133,12 @@ func (t *KernelTap) Close() error {
 		t.master = nil
 	}
 	err := t.device.Close()
+	for _, queue := range t.queues[1:] {
+		_ = queue.Close()
+	}
 	Taps.Del(t.name)
 	t.device = nil
+	t.queues = nil
 	return err
 }
 
@@ -0,0 +1,18 @@
+package network
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestKernelTap_Queues(t *testing.T) {
+	tap, err := NewKernelTap("", TapConfig{Type: TAP, Queues: 4})
+	if err != nil {
+		t.Skipf("Tap.Open %s", err)
+	}
+	assert.Equal(t, 4, tap.Queues(), "be the same.")
+	_ = tap.Close()
+	assert.Equal(t, 0, tap.Queues(), "be closed.")
+	_, err = tap.ReadQueue(1, make([]byte, 64))
+	assert.NotNil(t, err, "be closed.")
+}
@@ -5,6 +5,23 @@ import (
 	"sync"
 )
 
+// frames is pool of buffers queued in VirtualTap, and the frame larger than
+// MaxFrame is allocated.
+var frames = libol.NewBufferPool(libol.MaxFrame)
+
+// cloneFrame returns a copy of p, which is queued that the caller can reuse
+// its buffer.
+func cloneFrame(p []byte) []byte {
+	var data []byte
+	if len(p) <= frames.Size() {
+		data = frames.Get()[:len(p)]
+	} else {
+		data = make([]byte, len(p))
+	}
+	copy(data, p)
+	return data
+}
+
 type VirtualTap struct {
 	lock   sync.RWMutex
 	kernC  int
@@ -84,7 +101,7 @@ func (t *VirtualTap) Write(p []byte) (int, error) {
 		return 0, nil
 	}
 	t.virtC++
-	t.virtQ <- p
+	t.virtQ <- cloneFrame(p)
 	return len(p), nil
 }
 
@@ -94,12 +111,26 @@ func (t *VirtualTap) Read(p []byte) (int, error) {
 		t.lock.Unlock()
 		return 0, libol.NewErr("notUp")
 	}
+	queue := t.kernQ
 	t.lock.Unlock()
-	data := <-t.kernQ
+	data, ok := <-queue
+	if !ok {
+		return 0, libol.NewErr("notUp")
+	}
 	t.lock.Lock()
 	t.kernC--
 	t.lock.Unlock()
-	return copy(p, data), nil
+	n := copy(p, data)
+	frames.Put(data)
+	return n, nil
+}
+
+func (t *VirtualTap) Queues() int {
+	return 1
+}
+
+func (t *VirtualTap) ReadQueue(q int, p []byte) (int, error) {
+	return t.Read(p)
 }
 
 func (t *VirtualTap) Recv(p []byte) (int, error) {
@@ -108,12 +139,18 @@ func (t *VirtualTap) Recv(p []byte) (int, error) {
 		t.lock.Unlock()
 		return 0, libol.NewErr("notUp")
 	}
+	queue := t.virtQ
 	t.lock.Unlock()
-	data := <-t.virtQ
+	data, ok := <-queue
+	if !ok {
+		return 0, libol.NewErr("notUp")
+	}
 	t.lock.Lock()
 	t.virtC--
 	t.lock.Unlock()
-	return copy(p, data), nil
+	n := copy(p, data)
+	frames.Put(data)
+	return n, nil
 }
 
 func (t *VirtualTap) Send(p []byte) (int, error) {
@@ -131,7 +168,7 @@ func (t *VirtualTap) Send(p []byte) (int, error) {
 		return 0, nil
 	}
 	t.kernC++
-	t.kernQ <- p
+	t.kernQ <- cloneFrame(p)
 	return len(p), nil
 }
 
@@ -13,9 +13,11 @@ const (
 )
 
 type DeviceStats struct {
-	Send int64 `json:"send"`
-	Recv int64 `json:"recv"`
-	Drop int64 `json:"drop"`
+	Send  int64 `json:"send"`
+	Recv  int64 `json:"recv"`
+	Drop  int64 `json:"drop"`
+	Storm int64 `json:"storm,omitempty"` // dropped by storm control.
+	Proxy int64 `json:"proxy,omitempty"` // arp answered by proxy.
 }
 
 type Taper interface {
@@ -26,6 +28,8 @@ type Taper interface {
 	Write([]byte) (int, error) // write data from user space to kernel
 	Send([]byte) (int, error)  // send data from virtual bridge to kernel
 	Recv([]byte) (int, error)  // recv data from kernel to virtual bridge
+	Queues() int               // number of queues to read
+	ReadQueue(q int, p []byte) (int, error)
 	Close() error
 	Master() Bridger
 	SetMaster(dev Bridger) error
@@ -112,4 +116,5 @@ type TapConfig struct {
 	Name     string
 	VirBuf   int
 	KernBuf  int
+	Queues   int // opened with multiple queues if more than one.
 }
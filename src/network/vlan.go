@@ -0,0 +1,170 @@
+package network
+
+import (
+	"encoding/binary"
+	"github.com/danieldin95/openlan-go/src/config"
+	"github.com/danieldin95/openlan-go/src/libol"
+	"strconv"
+	"strings"
+)
+
+const (
+	VlanAccess = "access"
+	VlanTrunk  = "trunk"
+	VlanMax    = 4094
+)
+
+// PortVlan is vlan settings of a bridge port. The access port carries
+// untagged frames of pvid only, and the trunk port carries tagged frames of
+// allowed vlans and untagged frames of pvid as native.
+type PortVlan struct {
+	Mode    string `json:"mode"`
+	Pvid    int    `json:"pvid"`
+	Allowed []int  `json:"allowed,omitempty"` // empty is 1-4094 for trunk.
+}
+
+// NewPortVlan returns vlan of port by config, and nil if not configured.
+func NewPortVlan(c *config.Vlan) *PortVlan {
+	if c == nil {
+		return nil
+	}
+	return &PortVlan{
+		Mode:    c.Mode,
+		Pvid:    c.Pvid,
+		Allowed: append([]int(nil), c.Allowed...),
+	}
+}
+
+// ParseVlan parses vlan formatted by String, likes access/10 or
+// trunk/1/10,20.
+func ParseVlan(value string) *PortVlan {
+	if value == "" {
+		return nil
+	}
+	columns := strings.SplitN(value, "/", 3)
+	v := &PortVlan{Mode: columns[0]}
+	if len(columns) > 1 {
+		v.Pvid, _ = strconv.Atoi(columns[1])
+	}
+	if len(columns) > 2 && columns[2] != "" {
+		for _, s := range strings.Split(columns[2], ",") {
+			if vid, err := strconv.Atoi(s); err == nil {
+				v.Allowed = append(v.Allowed, vid)
+			}
+		}
+	}
+	if v.Mode != VlanAccess && v.Mode != VlanTrunk {
+		return nil
+	}
+	return v
+}
+
+func (v *PortVlan) String() string {
+	if v == nil {
+		return ""
+	}
+	value := v.Mode + "/" + strconv.Itoa(v.Pvid)
+	if len(v.Allowed) > 0 {
+		allowed := make([]string, 0, len(v.Allowed))
+		for _, vid := range v.Allowed {
+			allowed = append(allowed, strconv.Itoa(vid))
+		}
+		value += "/" + strings.Join(allowed, ",")
+	}
+	return value
+}
+
+// Allow returns whether vlan is carried tagged by trunk, and vlan 0 isn't
+// even if all.
+func (v *PortVlan) Allow(vid int) bool {
+	if vid <= 0 || vid > VlanMax {
+		return false
+	}
+	if len(v.Allowed) == 0 {
+		return true
+	}
+	for _, a := range v.Allowed {
+		if a == vid {
+			return true
+		}
+	}
+	return false
+}
+
+// Ingress returns vlan of frame received on the port and the frame
+// without tag, and -1 if dropped. The port without settings carries all
+// vlans and untagged frames of vlan 0.
+func (v *PortVlan) Ingress(data []byte) (int, []byte) {
+	vid, tagged := VlanOf(data)
+	if v == nil {
+		return vid, VlanPop(data)
+	}
+	switch v.Mode {
+	case VlanAccess:
+		if tagged && vid != v.Pvid {
+			return -1, nil
+		}
+		return v.Pvid, VlanPop(data)
+	default:
+		if !tagged {
+			return v.Pvid, data
+		}
+		if vid == 0 { // priority tagged.
+			return v.Pvid, VlanPop(data)
+		}
+		if vid != v.Pvid && !v.Allow(vid) {
+			return -1, nil
+		}
+		return vid, VlanPop(data)
+	}
+}
+
+// Egress returns frame of vlan to send on the port, and nil if the vlan is
+// not carried by this port.
+func (v *PortVlan) Egress(vid int, data []byte) []byte {
+	if v == nil {
+		return VlanPush(data, vid)
+	}
+	if vid == v.Pvid {
+		return data
+	}
+	if v.Mode == VlanTrunk && v.Allow(vid) {
+		return VlanPush(data, vid)
+	}
+	return nil
+}
+
+// VlanOf returns vid of frame, and zero if untagged.
+func VlanOf(data []byte) (int, bool) {
+	if len(data) < libol.EtherLen+libol.VlanLen {
+		return 0, false
+	}
+	if binary.BigEndian.Uint16(data[12:14]) != libol.EthVlan {
+		return 0, false
+	}
+	return int(binary.BigEndian.Uint16(data[14:16]) & 0x0fff), true
+}
+
+// VlanPop returns frame without 802.1Q tag.
+func VlanPop(data []byte) []byte {
+	if _, tagged := VlanOf(data); !tagged {
+		return data
+	}
+	frame := make([]byte, len(data)-libol.VlanLen)
+	copy(frame, data[:12])
+	copy(frame[12:], data[12+libol.VlanLen:])
+	return frame
+}
+
+// VlanPush returns frame with 802.1Q tag of vid, and itself if vid is zero.
+func VlanPush(data []byte, vid int) []byte {
+	if vid <= 0 || len(data) < libol.EtherLen {
+		return data
+	}
+	frame := make([]byte, len(data)+libol.VlanLen)
+	copy(frame, data[:12])
+	binary.BigEndian.PutUint16(frame[12:14], libol.EthVlan)
+	binary.BigEndian.PutUint16(frame[14:16], uint16(vid&0x0fff))
+	copy(frame[12+libol.VlanLen:], data[12:])
+	return frame
+}
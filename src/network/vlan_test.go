@@ -0,0 +1,58 @@
+package network
+
+import (
+	"github.com/danieldin95/openlan-go/src/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPortVlan(t *testing.T) {
+	frame := make([]byte, 64)
+	frame[12], frame[13] = 0x08, 0x00
+	tagged := VlanPush(frame, 10)
+	vid, ok := VlanOf(tagged)
+	assert.True(t, ok, "be tagged.")
+	assert.Equal(t, 10, vid, "be the same.")
+	assert.Equal(t, frame, VlanPop(tagged), "be the same.")
+
+	access := ParseVlan("access/10")
+	vid, data := access.Ingress(frame)
+	assert.Equal(t, 10, vid, "be pvid.")
+	assert.Equal(t, frame, data, "be the same.")
+	vid, _ = access.Ingress(VlanPush(frame, 20))
+	assert.Equal(t, -1, vid, "be dropped.")
+	assert.Equal(t, frame, access.Egress(10, frame), "be untagged.")
+	assert.Nil(t, access.Egress(20, frame), "not carried.")
+
+	trunk := ParseVlan("trunk/1/10,20")
+	assert.Equal(t, "trunk/1/10,20", trunk.String(), "be the same.")
+	vid, data = trunk.Ingress(tagged)
+	assert.Equal(t, 10, vid, "be the same.")
+	assert.Equal(t, frame, data, "be untagged.")
+	vid, _ = trunk.Ingress(frame)
+	assert.Equal(t, 1, vid, "be native.")
+	vid, _ = trunk.Ingress(VlanPush(frame, 30))
+	assert.Equal(t, -1, vid, "not allowed.")
+	assert.Equal(t, tagged, trunk.Egress(10, frame), "be tagged.")
+	assert.Equal(t, frame, trunk.Egress(1, frame), "be native.")
+	assert.Nil(t, trunk.Egress(30, frame), "not allowed.")
+
+	// all vlans except vlan 0 of ports without settings.
+	all := ParseVlan("trunk/10")
+	assert.Equal(t, VlanPush(frame, 30), all.Egress(30, frame), "be tagged.")
+	assert.Equal(t, frame, all.Egress(10, frame), "be native.")
+	assert.Nil(t, all.Egress(0, frame), "not merged to native.")
+	priority := VlanPush(frame, 10)
+	priority[14], priority[15] = 0xe0, 0x00
+	vid, data = all.Ingress(priority)
+	assert.Equal(t, 10, vid, "be native.")
+	assert.Equal(t, frame, data, "be untagged.")
+	assert.Equal(t, []int{1}, NewPortVlan(&config.Vlan{Mode: VlanTrunk, Allowed: []int{1}}).Allowed, "be the same.")
+	assert.Nil(t, NewPortVlan(nil), "be nil.")
+
+	var none *PortVlan
+	vid, _ = none.Ingress(tagged)
+	assert.Equal(t, 10, vid, "be the same.")
+	assert.Equal(t, tagged, none.Egress(10, frame), "be tagged.")
+	assert.Nil(t, ParseVlan("hybrid/1"), "be nil.")
+}
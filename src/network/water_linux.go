@@ -0,0 +1,26 @@
+package network
+
+import (
+	"github.com/songgao/water"
+)
+
+// WaterNew opens tap with IFF_MULTI_QUEUE if queues is more than one, and
+// the other queues are opened by the name of this one.
+func WaterNew(c TapConfig) (*water.Interface, error) {
+	deviceType := water.DeviceType(water.TAP)
+	if c.Type == TUN {
+		deviceType = water.TUN
+	}
+	cfg := water.Config{DeviceType: deviceType}
+	cfg.PlatformSpecificParams = water.PlatformSpecificParams{
+		Name:       c.Name,
+		MultiQueue: c.Queues > 1,
+	}
+	return water.New(cfg)
+}
+
+// WaterQueue opens another queue of tap opened with multiple queues.
+func WaterQueue(name string, c TapConfig) (*water.Interface, error) {
+	c.Name = name
+	return WaterNew(c)
+}
@@ -1,8 +1,9 @@
-// +build !windows
+// +build !windows,!linux
 
 package network
 
 import (
+	"github.com/danieldin95/openlan-go/src/libol"
 	"github.com/songgao/water"
 )
 
@@ -19,3 +20,7 @@ func WaterNew(c TapConfig) (*water.Interface, error) {
 	}
 	return water.New(cfg)
 }
+
+func WaterQueue(name string, c TapConfig) (*water.Interface, error) {
+	return nil, libol.NewErr("operation notSupport")
+}
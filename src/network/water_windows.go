@@ -1,6 +1,7 @@
 package network
 
 import (
+	"github.com/danieldin95/openlan-go/src/libol"
 	"github.com/songgao/water"
 )
 
@@ -32,3 +33,7 @@ func WaterNew(c TapConfig) (dev *water.Interface, err error) {
 	}
 	return nil, err
 }
+
+func WaterQueue(name string, c TapConfig) (*water.Interface, error) {
+	return nil, libol.NewErr("operation notSupport")
+}
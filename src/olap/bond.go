@@ -0,0 +1,85 @@
+package olap
+
+import (
+	"github.com/danieldin95/openlan-go/src/config"
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/danieldin95/openlan-go/src/schema"
+)
+
+// NewLinkWorker returns socket worker connects by an uplink of bonding.
+func NewLinkWorker(c *config.Point, bond *libol.Bond, link config.BondLink) *SocketWorker {
+	ep := link.Endpoint
+	if ep.Source == "" && link.Interface != "" {
+		ep.Source = libol.InterfaceAddr(link.Interface)
+	}
+	t := NewSocketWorker(c)
+	t.paths = NewPaths([]config.Endpoint{ep})
+	t.out = libol.NewSubLogger(c.Id() + "@" + ep.String())
+	t.bond = bond
+	t.weight = link.Weight
+	t.user.Bond = bond.Id
+	t.user.Weight = link.Weight
+	return t
+}
+
+func (w *Worker) initBond(b *config.Bond) {
+	w.bond = libol.NewBond(libol.GenRandom(8), b.Mode)
+	w.out.Info("Worker.initBond: %s by %d links", w.bond.Id, len(b.Links))
+	w.links = make([]*SocketWorker, 0, len(b.Links))
+	for _, link := range b.Links {
+		w.links = append(w.links, NewLinkWorker(w.cfg, w.bond, link))
+	}
+	w.conWorker = w.links[0]
+}
+
+// authed returns number of links authenticated except s.
+func (w *Worker) authed(s *SocketWorker) int {
+	count := 0
+	for _, l := range w.links {
+		if l != s && l.client != nil && l.client.Have(libol.ClAuth) {
+			count++
+		}
+	}
+	return count
+}
+
+// WriteTap writes frame received from links to tap.
+func (w *Worker) WriteTap(frame *libol.FrameMessage) error {
+	libol.Captures.Input(w.cfg.Network, w.UUID(), frame.Frame())
+	return w.tapWorker.Write(frame)
+}
+
+// Write sends frame from tap by a link selected from bonding.
+func (w *Worker) Write(frame *libol.FrameMessage) error {
+	libol.Captures.Input(w.cfg.Network, w.UUID(), frame.Frame())
+	if w.bond == nil {
+		return w.conWorker.Write(frame)
+	}
+	client := w.bond.Select(frame.Frame())
+	for _, l := range w.links {
+		if client != nil && l.client == client {
+			return l.Write(frame)
+		}
+	}
+	// no link alive, and dropped by unAuth.
+	return w.conWorker.Write(frame)
+}
+
+// Health returns of the link connected firstly.
+func (w *Worker) Health() *libol.Health {
+	return w.conWorker.Health()
+}
+
+func (w *Worker) Paths() []schema.PointPath {
+	if w.bond == nil {
+		return w.conWorker.Paths()
+	}
+	paths := make([]schema.PointPath, 0, len(w.links))
+	for _, l := range w.links {
+		for _, p := range l.Paths() {
+			p.Active = l.client != nil && l.client.Have(libol.ClAuth)
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/danieldin95/openlan-go/src/libol"
 	"github.com/gorilla/mux"
 	"net/http"
+	"strconv"
 )
 
 type Http struct {
@@ -93,6 +94,44 @@ func (h *Http) LoadRouter() {
 			ResponseJson(w, h.pointer.Config())
 		}
 	})
+	router.HandleFunc("/current/path", func(w http.ResponseWriter, r *http.Request) {
+		format := GetQueryOne(r, "format")
+		if format == "yaml" {
+			ResponseYaml(w, h.pointer.Paths())
+		} else {
+			ResponseJson(w, h.pointer.Paths())
+		}
+	})
+	router.HandleFunc("/current/capture", h.Capture)
+}
+
+// Capture streams frames of this point captured as pcapng.
+func (h *Http) Capture(w http.ResponseWriter, r *http.Request) {
+	c, err := libol.NewCapture("", "", GetQueryOne(r, "filter"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	count, _ := strconv.Atoi(GetQueryOne(r, "count"))
+	size, _ := strconv.ParseInt(GetQueryOne(r, "size"), 10, 64)
+	timeout, _ := strconv.Atoi(GetQueryOne(r, "timeout"))
+	c.Limit(count, size, timeout)
+
+	libol.Info("Http.Capture: %s by '%s'", c.Id, c.Filter.Expr)
+	libol.Captures.Add(c)
+	defer libol.Captures.Del(c)
+	go func() {
+		<-r.Context().Done()
+		c.Stop()
+	}()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	var flush func()
+	if f, ok := w.(http.Flusher); ok {
+		flush = f.Flush
+	}
+	if err := c.Dump(w, flush); err != nil {
+		libol.Warn("Http.Capture: %s %s", c.Id, err)
+	}
 }
 
 func (h *Http) Start() {
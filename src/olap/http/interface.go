@@ -1,8 +1,12 @@
 package http
 
-import "github.com/danieldin95/openlan-go/src/config"
+import (
+	"github.com/danieldin95/openlan-go/src/config"
+	"github.com/danieldin95/openlan-go/src/schema"
+)
 
 type Pointer interface {
 	UUID() string
 	Config() *config.Point
+	Paths() []schema.PointPath
 }
@@ -0,0 +1,189 @@
+package olap
+
+import (
+	"github.com/danieldin95/openlan-go/src/config"
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/danieldin95/openlan-go/src/schema"
+	"time"
+)
+
+// Path is an endpoint to virtual switch with its probed state.
+type Path struct {
+	Endpoint config.Endpoint
+	Alive    bool  // reachable at last probe, or logged in for datagram.
+	ProbeAt  int64 // last probe time.
+	FailAt   int64 // last time failed keepalive on it.
+}
+
+func NewPaths(endpoints []config.Endpoint) []*Path {
+	paths := make([]*Path, 0, len(endpoints))
+	for _, ep := range endpoints {
+		paths = append(paths, &Path{Endpoint: ep, Alive: true})
+	}
+	return paths
+}
+
+// IsDatagram returns true for protocols have no handshake before login.
+func IsDatagram(protocol string) bool {
+	switch protocol {
+	case "udp", "kcp", "quic":
+		return true
+	}
+	return false
+}
+
+// ProbeEndpoint check whether endpoint is reachable. Datagram protocols
+// can't be probed, and are alive only after logged in.
+func ProbeEndpoint(proxy string, ep config.Endpoint, timeout time.Duration) error {
+	if IsDatagram(ep.Protocol) {
+		return libol.NewErr("%s not probed", ep.Protocol)
+	}
+	conn, err := libol.Dial(proxy, ep.Connection, timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (t *SocketWorker) newClient() libol.SocketClient {
+	path := t.paths[t.active()]
+	return GetSocketClient(t.pinCfg, path.Endpoint)
+}
+
+func (t *SocketWorker) active() int {
+	return int(t.record.Get(rtPath))
+}
+
+func (t *SocketWorker) Active() config.Endpoint {
+	return t.paths[t.active()].Endpoint
+}
+
+func (t *SocketWorker) Paths() []schema.PointPath {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	index := t.active()
+	paths := make([]schema.PointPath, 0, len(t.paths))
+	for i, p := range t.paths {
+		paths = append(paths, schema.PointPath{
+			Connection: p.Endpoint.Connection,
+			Protocol:   p.Endpoint.Protocol,
+			Active:     i == index,
+			Alive:      p.Alive,
+			ProbeAt:    p.ProbeAt,
+			FailAt:     p.FailAt,
+		})
+	}
+	return paths
+}
+
+// selectPath returns next path after current, which is alive and not failed
+// in hold time. Otherwise the next one in order.
+func (t *SocketWorker) selectPath(cur int) int {
+	hold := int64(t.pinCfg.FailOver.Hold)
+	now := time.Now().Unix()
+	size := len(t.paths)
+	for i := 1; i < size; i++ {
+		index := (cur + i) % size
+		p := t.paths[index]
+		if p.Alive && now-p.FailAt >= hold {
+			return index
+		}
+	}
+	return (cur + 1) % size
+}
+
+func (t *SocketWorker) switchTo(index int, reason string) {
+	from := t.paths[t.active()].Endpoint
+	to := t.paths[index].Endpoint
+	t.out.Warn("SocketWorker.switchTo: %s -> %s by %s", from, to, reason)
+	if t.client != nil {
+		if t.client.Have(libol.ClAuth) {
+			_ = t.sendLeave(t.client)
+		}
+		t.client.Terminal()
+	}
+	t.record.Set(rtPath, int64(index))
+	t.record.Set(rtFails, 0)
+	t.record.Set(rtSleeps, 0)
+	t.record.Add(rtSwitches, 1)
+	t.setClient(t.newClient())
+	_ = t.connect()
+}
+
+// failover switch to next path when reconnecting on active path is failed
+// more than retries.
+func (t *SocketWorker) failover() bool {
+	if len(t.paths) < 2 {
+		return false
+	}
+	t.record.Add(rtFails, 1)
+	if t.record.Get(rtFails) < int64(t.pinCfg.FailOver.Retries) {
+		return false
+	}
+	cur := t.active()
+	t.lock.Lock()
+	t.paths[cur].FailAt = time.Now().Unix()
+	t.paths[cur].Alive = false
+	t.lock.Unlock()
+	t.switchTo(t.selectPath(cur), "failover")
+	return true
+}
+
+func (t *SocketWorker) probe() {
+	if len(t.paths) < 2 {
+		return
+	}
+	now := time.Now().Unix()
+	if now-t.record.Get(rtProbe) < int64(t.pinCfg.FailOver.Probe) {
+		return
+	}
+	t.record.Set(rtProbe, now)
+	endpoints := make([]config.Endpoint, 0, len(t.paths))
+	proxies := make([]string, 0, len(t.paths))
+	for _, p := range t.paths {
+		endpoints = append(endpoints, p.Endpoint)
+		proxies = append(proxies, GetProxy(t.pinCfg, p.Endpoint))
+	}
+	libol.Go(func() {
+		alive := make([]bool, len(endpoints))
+		for i, ep := range endpoints {
+			if IsDatagram(ep.Protocol) {
+				continue
+			}
+			if err := ProbeEndpoint(proxies[i], ep, 5*time.Second); err != nil {
+				t.out.Debug("SocketWorker.probe: %s %s", ep, err)
+				continue
+			}
+			alive[i] = true
+		}
+		ev := NewEvent(EvSocProbe, "from probe")
+		ev.Data = alive
+		t.eventQueue <- ev
+	})
+}
+
+// onProbe updates state of stream paths, and a failed datagram path is kept
+// not alive until logged in on it again.
+func (t *SocketWorker) onProbe(alive []bool) {
+	now := time.Now().Unix()
+	t.lock.Lock()
+	for i, p := range t.paths {
+		if i < len(alive) && !IsDatagram(p.Endpoint.Protocol) {
+			p.Alive = alive[i]
+			p.ProbeAt = now
+		}
+	}
+	t.lock.Unlock()
+	if !t.pinCfg.FailOver.FailBack {
+		return
+	}
+	cur := t.active()
+	hold := int64(t.pinCfg.FailOver.Hold)
+	for i := 0; i < cur; i++ {
+		p := t.paths[i]
+		if p.Alive && now-p.FailAt >= hold {
+			t.switchTo(i, "failback")
+			break
+		}
+	}
+}
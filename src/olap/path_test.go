@@ -0,0 +1,60 @@
+package olap
+
+import (
+	"github.com/danieldin95/openlan-go/src/config"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSocketWorker_SelectPath(t *testing.T) {
+	c := &config.Point{
+		Network: "default",
+		Endpoints: []config.Endpoint{
+			{Connection: "1.1.1.1", Protocol: "udp"},
+			{Connection: "1.1.1.2", Protocol: "tcp"},
+			{Connection: "1.1.1.3:443", Protocol: "wss"},
+		},
+		Queue: &config.Queue{},
+	}
+	c.Correct(nil)
+	assert.Equal(t, "1.1.1.1:10002", c.Connection, "be the same.")
+	assert.Equal(t, 2, c.FailOver.Retries, "be the same.")
+	w := NewSocketWorker(c)
+	assert.Equal(t, 3, len(w.paths), "be the same.")
+	assert.Equal(t, 1, w.selectPath(0), "be the same.")
+	w.paths[1].FailAt = time.Now().Unix()
+	assert.Equal(t, 2, w.selectPath(0), "be the same.")
+	w.paths[2].Alive = false
+	assert.Equal(t, 1, w.selectPath(0), "be the same.")
+	assert.Equal(t, 0, w.selectPath(2), "be the same.")
+}
+
+func TestProbeEndpoint(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err, "listen.")
+	defer ln.Close()
+	ep := config.Endpoint{Connection: ln.Addr().String(), Protocol: "tls"}
+	assert.Nil(t, ProbeEndpoint("", ep, time.Second), "be alive.")
+	ep.Protocol = "udp"
+	assert.NotNil(t, ProbeEndpoint("", ep, time.Second), "not probed.")
+}
+
+func TestSocketWorker_OnProbe(t *testing.T) {
+	c := &config.Point{
+		Network: "default",
+		Endpoints: []config.Endpoint{
+			{Connection: "1.1.1.1", Protocol: "udp"},
+			{Connection: "1.1.1.3:443", Protocol: "wss"},
+		},
+		Queue: &config.Queue{},
+	}
+	c.Correct(nil)
+	w := NewSocketWorker(c)
+	w.paths[0].Alive = false
+	w.paths[0].FailAt = time.Now().Unix() - 3600
+	w.onProbe([]bool{true, true})
+	assert.False(t, w.paths[0].Alive, "be not alive.")
+	assert.True(t, w.paths[1].Alive, "be alive.")
+}
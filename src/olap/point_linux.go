@@ -99,13 +99,22 @@ func (p *Point) OnTap(w *TapWorker) error {
 	p.out.Info("Point.OnTap")
 	tap := w.device
 	name := tap.Name()
-	if tap.Type() == network.ProviderVir { // virtual device
-		br := network.Bridges.Get(p.brName)
+	// virtual device, or kernel device on bridge of openvswitch.
+	br := network.Bridges.Get(p.brName)
+	if tap.Type() == network.ProviderVir || (br != nil && br.Type() == network.ProviderOvs) {
 		if br == nil {
 			p.out.Error("Point.OnTap: Get notFound", p.brName)
 			return libol.NewErr("%s notFound", p.brName)
 		}
 		_ = br.AddSlave(name)
+		if vlan := p.vlan(); vlan != nil {
+			_ = br.SetVlan(name, vlan)
+		}
+		if p.config.Interface.Cost > 0 {
+			if err := br.SetCost(name, p.config.Interface.Cost); err != nil {
+				p.out.Error("Point.OnTap: Cost %s: %s", name, err)
+			}
+		}
 		link, err := netlink.LinkByName(br.Kernel())
 		if err != nil {
 			p.out.Error("Point.OnTap: Get %s: %s", p.brName, err)
@@ -129,6 +138,11 @@ func (p *Point) OnTap(w *TapWorker) error {
 			p.out.Error("Point.OnTap: Get %s: %s", p.brName, err)
 		}
 	}
+	if vlan := p.vlan(); vlan != nil && p.brName != "" {
+		if err := network.NewBrCtl(p.brName).SetVlan(name, vlan); err != nil {
+			p.out.Error("Point.OnTap: SetVlan %s: %s", name, err)
+		}
+	}
 	if p.config.Interface.Cost > 0 {
 		port := network.NewBrPort(name)
 		if err := port.Cost(p.config.Interface.Cost); err != nil {
@@ -139,6 +153,10 @@ func (p *Point) OnTap(w *TapWorker) error {
 	return nil
 }
 
+func (p *Point) vlan() *network.PortVlan {
+	return network.NewPortVlan(p.config.Vlan)
+}
+
 func (p *Point) AddRoutes(routes []*models.Route) error {
 	if routes == nil || p.link == nil {
 		return nil
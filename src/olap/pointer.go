@@ -6,6 +6,7 @@ import (
 	"github.com/danieldin95/openlan-go/src/models"
 	"github.com/danieldin95/openlan-go/src/network"
 	"github.com/danieldin95/openlan-go/src/olap/http"
+	"github.com/danieldin95/openlan-go/src/schema"
 	"runtime"
 )
 
@@ -25,6 +26,9 @@ type Pointer interface {
 	Alias() string
 	Config() *config.Point
 	Network() *models.Network
+	Paths() []schema.PointPath
+	Health() *libol.Health
+	SetCode(code string)
 }
 
 type MixPoint struct {
@@ -45,6 +49,13 @@ func NewMixPoint(config *config.Point) MixPoint {
 
 func (p *MixPoint) Initialize() {
 	libol.Info("MixPoint.Initialize")
+	if p.config.Otp == "prompt" {
+		if code, err := ReadCode("Code: "); err == nil {
+			p.config.Otp = code
+		} else {
+			p.out.Warn("MixPoint.Initialize: %s", err)
+		}
+	}
 	p.worker.SetUUID(p.UUID())
 	p.worker.Initialize()
 	if p.config.Http != nil {
@@ -85,7 +96,10 @@ func (p *MixPoint) Status() libol.SocketStatus {
 }
 
 func (p *MixPoint) Addr() string {
-	return p.config.Connection
+	if p.worker.conWorker == nil {
+		return p.config.Connection
+	}
+	return p.worker.conWorker.Active().Connection
 }
 
 func (p *MixPoint) IfName() string {
@@ -145,5 +159,23 @@ func (p *MixPoint) Network() *models.Network {
 }
 
 func (p *MixPoint) Protocol() string {
-	return p.config.Protocol
+	if p.worker.conWorker == nil {
+		return p.config.Protocol
+	}
+	return p.worker.conWorker.Active().Protocol
+}
+
+func (p *MixPoint) Paths() []schema.PointPath {
+	if p.worker.conWorker == nil {
+		return nil
+	}
+	return p.worker.Paths()
+}
+
+func (p *MixPoint) Health() *libol.Health {
+	return p.worker.Health()
+}
+
+func (p *MixPoint) SetCode(code string) {
+	p.worker.SetCode(code)
 }
@@ -1,6 +1,7 @@
 package olap
 
 import (
+	"bytes"
 	"encoding/json"
 	"github.com/danieldin95/openlan-go/src/config"
 	"github.com/danieldin95/openlan-go/src/libol"
@@ -29,12 +30,18 @@ const (
 	rtIpAddr    = "addrAt"   // record last receive ipAddr message after success.
 	rtConnects  = "conns"    // record times of reconnecting
 	rtLatency   = "latency"  // latency by ping.
+	rtPath      = "path"     // index of active path.
+	rtFails     = "fails"    // record times of reconnecting failed on active path.
+	rtSwitches  = "switches" // record times of switching path.
+	rtProbe     = "probeAt"  // record last time to probe paths.
+	rtDegraded  = "degradAt" // record time when link is degraded, and zero if not.
 )
 
 type SocketWorker struct {
 	// private
 	listener   SocketWorkerListener
 	client     libol.SocketClient
+	paths      []*Path
 	lock       sync.Mutex
 	user       *models.User
 	network    *models.Network
@@ -49,11 +56,14 @@ type SocketWorker struct {
 	record     *libol.SafeStrInt64
 	out        *libol.SubLogger
 	wlFrame    *libol.FrameMessage // Last frame from write.
+	bond       *libol.Bond         // joined bonding if not nil.
+	weight     int                 // weight in bonding.
+	health     *libol.Health
 }
 
-func NewSocketWorker(client libol.SocketClient, c *config.Point) *SocketWorker {
+func NewSocketWorker(c *config.Point) *SocketWorker {
 	t := &SocketWorker{
-		client:     client,
+		paths:      NewPaths(c.GetEndpoints()),
 		network:    models.NewNetwork(c.Network, c.Interface.Address),
 		routes:     make(map[string]*models.Route, 64),
 		record:     libol.NewSafeStrInt64(),
@@ -64,6 +74,7 @@ func NewSocketWorker(client libol.SocketClient, c *config.Point) *SocketWorker {
 		writeQueue: make(chan *libol.FrameMessage, c.Queue.SockWr),
 		jobber:     make([]jobTimer, 0, 32),
 		out:        libol.NewSubLogger(c.Id()),
+		health:     libol.NewHealth(),
 	}
 	t.user = &models.User{
 		Alias:    c.Alias,
@@ -71,6 +82,7 @@ func NewSocketWorker(client libol.SocketClient, c *config.Point) *SocketWorker {
 		Password: c.Password,
 		Network:  c.Network,
 		System:   runtime.GOOS,
+		Code:     c.Otp,
 	}
 	t.keepalive = KeepAlive{
 		Interval: 15,
@@ -96,6 +108,16 @@ func (t *SocketWorker) Initialize() {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 	t.out.Info("SocketWorker.Initialize")
+	t.setClient(t.newClient())
+	t.record.Set(rtLast, time.Now().Unix())
+	t.record.Set(rtReConnect, time.Now().Unix())
+}
+
+func (t *SocketWorker) setClient(client libol.SocketClient) {
+	t.client = client
+	if t.bond != nil {
+		t.bond.Add(client, t.weight)
+	}
 	t.client.SetMaxSize(t.pinCfg.Interface.IfMtu)
 	t.client.SetListener(libol.ClientListener{
 		OnConnected: func(client libol.SocketClient) error {
@@ -109,8 +131,6 @@ func (t *SocketWorker) Initialize() {
 			return nil
 		},
 	})
-	t.record.Set(rtLast, time.Now().Unix())
-	t.record.Set(rtReConnect, time.Now().Unix())
 }
 
 func (t *SocketWorker) Start() {
@@ -209,6 +229,9 @@ func (t *SocketWorker) reconnect() {
 			}
 			t.out.Info("SocketWorker.reconnect: l: %d a: %d", rtLast, rtLive)
 			t.out.Info("SocketWorker.reconnect: c: %d r: %d", rtConn, rtReCon)
+			if t.failover() {
+				return nil
+			}
 			return t.connect()
 		},
 	}
@@ -219,6 +242,11 @@ func (t *SocketWorker) sendLogin(client libol.SocketClient) error {
 	if client == nil {
 		return libol.NewErr("client is nil")
 	}
+	// offers roaming of this connection, and ignored by older switch.
+	t.user.Roam = nil
+	if roam, ok := client.(libol.RoamClient); ok {
+		t.user.Roam = roam.RoamOffer()
+	}
 	body, err := json.Marshal(t.user)
 	if err != nil {
 		return err
@@ -285,12 +313,24 @@ func (t *SocketWorker) onLogin(resp []byte) error {
 		t.out.Cmd("SocketWorker.onLogin: %s", resp)
 		return nil
 	}
+	if strings.HasPrefix(string(resp), "challenge") {
+		t.onChallenge(resp[9:])
+		return nil
+	}
+	t.lock.Lock()
+	t.user.State = nil
+	t.lock.Unlock()
 	if strings.HasPrefix(string(resp), "okay") {
+		t.onRoam(resp[4:])
 		t.client.SetStatus(libol.ClAuth)
+		t.lock.Lock()
+		t.paths[t.active()].Alive = true
+		t.lock.Unlock()
 		if t.listener.OnSuccess != nil {
 			_ = t.listener.OnSuccess(t)
 		}
 		t.record.Set(rtSleeps, 0)
+		t.record.Set(rtFails, 0)
 		t.record.Set(rtIpAddr, 0)
 		t.record.Set(rtSuccess, time.Now().Unix())
 		t.eventQueue <- NewEvent(EvSocSuccess, "from login")
@@ -302,6 +342,40 @@ func (t *SocketWorker) onLogin(resp []byte) error {
 	return nil
 }
 
+// onChallenge keeps state of challenge, and login is sent again with it
+// after code updated.
+func (t *SocketWorker) onChallenge(data []byte) {
+	t.client.SetStatus(libol.ClUnAuth)
+	resp := &models.Login{}
+	if err := json.Unmarshal(bytes.TrimSpace(data), resp); err != nil {
+		t.out.Warn("SocketWorker.onChallenge: invalid %s", data)
+		return
+	}
+	t.lock.Lock()
+	t.user.State = resp.State
+	t.user.Code = ""
+	t.lock.Unlock()
+	t.out.Warn("SocketWorker.onChallenge: code required %s", resp.Message)
+}
+
+// onRoam accepts answer of roaming in login response, and the connection
+// is kept without roaming if not answered.
+func (t *SocketWorker) onRoam(data []byte) {
+	data = bytes.TrimSpace(data)
+	roam, ok := t.client.(libol.RoamClient)
+	if len(data) == 0 || !ok {
+		return
+	}
+	resp := &models.Login{}
+	if err := json.Unmarshal(data, resp); err != nil || resp.Roam == nil {
+		t.out.Warn("SocketWorker.onRoam: invalid %s", data)
+		return
+	}
+	if err := roam.RoamAccept(resp.Roam); err != nil {
+		t.out.Warn("SocketWorker.onRoam: %s", err)
+	}
+}
+
 func (t *SocketWorker) onIpAddr(resp []byte) error {
 	if !t.pinCfg.RequestAddr {
 		t.out.Info("SocketWorker.onIpAddr: notAllowed")
@@ -339,9 +413,43 @@ func (t *SocketWorker) onPong(resp []byte) error {
 	}
 	latency := time.Now().UnixNano() - m.DateTime // ns
 	t.record.Set(rtLatency, latency/1e6)          // ms
+	if t.bond != nil {
+		t.bond.SetLatency(t.client, latency/1e6)
+	}
+	if m.Seq > 0 {
+		t.health.Recv(m.Seq, time.Now().UnixNano()/1e6)
+	}
+	t.checkHealth()
 	return nil
 }
 
+func (t *SocketWorker) checkHealth() {
+	var th *libol.HealthThreshold
+	if t.pinCfg.Health != nil {
+		th = t.pinCfg.Health.Threshold()
+	}
+	if t.health.Check(th) {
+		ev := NewEvent(EvSocHealth, "from ping")
+		ev.Data = t.health.Stats()
+		t.eventQueue <- ev
+	}
+}
+
+func (t *SocketWorker) onHealth(stats libol.HealthStats) {
+	if stats.Degraded {
+		t.record.Set(rtDegraded, time.Now().Unix())
+		t.out.Warn("SocketWorker.onHealth: degraded rtt %dms jitter %dms loss %d%%",
+			stats.Rtt, stats.Jitter, stats.Loss)
+	} else {
+		t.record.Set(rtDegraded, 0)
+		t.out.Info("SocketWorker.onHealth: recovered score %d", stats.Score)
+	}
+}
+
+func (t *SocketWorker) Health() *libol.Health {
+	return t.health
+}
+
 // handle instruct from virtual switch
 func (t *SocketWorker) onInstruct(frame *libol.FrameMessage) error {
 	if !frame.IsControl() {
@@ -376,18 +484,28 @@ type PingMsg struct {
 	Alias      string `json:"alias"`
 	Connection string `json:"connection"`
 	Address    string `json:"address"`
+	Seq        uint64 `json:"seq,omitempty"`
+	Rtt        int64  `json:"rtt,omitempty"`    // ms, measured recently.
+	Jitter     int64  `json:"jitter,omitempty"` // ms
+	Loss       int    `json:"loss,omitempty"`   // percent
 }
 
 func (t *SocketWorker) sendPing(client libol.SocketClient) error {
 	if client == nil {
 		return libol.NewErr("client is nil")
 	}
+	now := time.Now().UnixNano()
+	stats := t.health.Stats()
 	data := &PingMsg{
-		DateTime:   time.Now().UnixNano(),
+		DateTime:   now,
 		UUID:       t.user.UUID,
 		Alias:      t.user.Alias,
 		Address:    t.client.LocalAddr(),
 		Connection: t.client.RemoteAddr(),
+		Seq:        t.health.Send(now / 1e6),
+		Rtt:        stats.Rtt,
+		Jitter:     stats.Jitter,
+		Loss:       stats.Loss,
 	}
 	body, err := json.Marshal(data)
 	if err != nil {
@@ -455,6 +573,7 @@ func (t *SocketWorker) doTicker() error {
 	t.checkAlive()  // period to check whether alive.
 	t.keepAlive()   // send ping and wait pong to keep alive.
 	t.checkJobber() // period to check job whether timeout.
+	t.probe()       // period to probe paths for failover.
 	return nil
 }
 
@@ -476,6 +595,14 @@ func (t *SocketWorker) dispatch(ev *WorkerEvent) {
 		t.reconnect()
 	case EvSocSignIn, EvSocLogin:
 		_ = t.toLogin(t.client)
+	case EvSocProbe:
+		if alive, ok := ev.Data.([]bool); ok {
+			t.onProbe(alive)
+		}
+	case EvSocHealth:
+		if stats, ok := ev.Data.(libol.HealthStats); ok {
+			t.onHealth(stats)
+		}
 	}
 }
 
@@ -488,6 +615,7 @@ func (t *SocketWorker) Loop() {
 			t.lock.Unlock()
 		case d := <-t.writeQueue:
 			_ = t.DoWrite(d)
+			d.Free()
 		case <-t.done:
 			return
 		case c := <-t.ticker.C:
@@ -515,6 +643,7 @@ func (t *SocketWorker) Read(client libol.SocketClient) {
 			t.out.Debug("SocketWorker.Read: %x", data)
 		}
 		if data.Size() <= 0 {
+			data.Free()
 			continue
 		}
 		data.Decode()
@@ -522,6 +651,7 @@ func (t *SocketWorker) Read(client libol.SocketClient) {
 			t.lock.Lock()
 			_ = t.onInstruct(data)
 			t.lock.Unlock()
+			data.Free()
 			continue
 		}
 		t.record.Set(rtLast, time.Now().Unix())
@@ -529,7 +659,7 @@ func (t *SocketWorker) Read(client libol.SocketClient) {
 			_ = t.listener.ReadAt(data)
 		}
 	}
-	if !t.isStopped() {
+	if !client.Have(libol.ClTerminal) && !t.isStopped() {
 		t.eventQueue <- NewEvent(EvSocRecon, "from read")
 	}
 }
@@ -578,6 +708,14 @@ func (t *SocketWorker) SetAuth(auth string) {
 	}
 }
 
+// SetCode updates one time password and login again.
+func (t *SocketWorker) SetCode(code string) {
+	t.lock.Lock()
+	t.user.Code = code
+	t.lock.Unlock()
+	t.eventQueue <- NewEvent(EvSocLogin, "from code")
+}
+
 func (t *SocketWorker) SetUUID(v string) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
@@ -200,6 +200,7 @@ func (a *TapWorker) Read(device network.Taper) {
 			data = data[libol.EtherLen:]
 		}
 		if n, err := device.Read(data); err != nil {
+			frame.Free()
 			a.out.Error("TapWorker.Read: %s", err)
 			break
 		} else {
@@ -207,6 +208,7 @@ func (a *TapWorker) Read(device network.Taper) {
 				a.out.Debug("TapWorker.Read: %x", data[:n])
 			}
 			if size := a.onFrame(frame, data[:n]); size == 0 {
+				frame.Free()
 				continue
 			}
 			if a.listener.ReadAt != nil {
@@ -239,6 +241,7 @@ func (a *TapWorker) Loop() {
 			return
 		case d := <-a.writeQueue:
 			_ = a.DoWrite(d)
+			d.Free()
 		case ev := <-a.eventQueue:
 			a.lock.Lock()
 			a.dispatch(ev)
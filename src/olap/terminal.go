@@ -1,10 +1,12 @@
 package olap
 
 import (
+	"bufio"
 	"fmt"
 	"github.com/chzyer/readline"
 	"github.com/danieldin95/openlan-go/src/libol"
 	"io"
+	"os"
 	"strings"
 )
 
@@ -25,6 +27,7 @@ func NewTerminal(pointer Pointer) *Terminal {
 		readline.PcItem("show",
 			readline.PcItem("config"),
 			readline.PcItem("network"),
+			readline.PcItem("path"),
 			readline.PcItem("record"),
 			readline.PcItem("statistics"),
 		),
@@ -32,6 +35,7 @@ func NewTerminal(pointer Pointer) *Terminal {
 			readline.PcItem("user"),
 			readline.PcItem("connection"),
 		),
+		readline.PcItem("otp"),
 	)
 
 	config := &readline.Config{
@@ -67,7 +71,13 @@ func (t *Terminal) CmdShow(args string) {
 		}
 	case "statistics":
 		if c := t.Pointer.Client(); c != nil {
-			v := c.Statistics()
+			v := struct {
+				Statistics map[string]int64
+				Health     libol.HealthStats
+			}{
+				Statistics: c.Statistics(),
+				Health:     t.Pointer.Health().Stats(),
+			}
 			if out, err := libol.Marshal(v, true); err == nil {
 				fmt.Printf("%s\n", out)
 			}
@@ -82,17 +92,24 @@ func (t *Terminal) CmdShow(args string) {
 		if str, err := libol.Marshal(cfg, true); err == nil {
 			fmt.Printf("%s\n", str)
 		}
+	case "path":
+		v := t.Pointer.Paths()
+		if str, err := libol.Marshal(v, true); err == nil {
+			fmt.Printf("%s\n", str)
+		}
 	default:
 		v := struct {
 			UUID   string
 			UpTime int64
 			Device string
 			Status string
+			Path   string
 		}{
 			UUID:   t.Pointer.UUID(),
 			UpTime: t.Pointer.UpTime(),
 			Device: t.Pointer.IfName(),
 			Status: t.Pointer.Status().String(),
+			Path:   t.Pointer.Protocol() + "://" + t.Pointer.Addr(),
 		}
 		if str, err := libol.Marshal(v, true); err == nil {
 			fmt.Printf("%s\n", str)
@@ -100,6 +117,18 @@ func (t *Terminal) CmdShow(args string) {
 	}
 }
 
+// CmdOtp login again with one time password, and prompts it if empty.
+func (t *Terminal) CmdOtp(args string) {
+	if args == "" {
+		code, err := t.Console.ReadPassword("Code: ")
+		if err != nil {
+			return
+		}
+		args = string(code)
+	}
+	t.Pointer.SetCode(args)
+}
+
 func (t *Terminal) Trim(v string) string {
 	return strings.TrimSpace(v)
 }
@@ -145,8 +174,35 @@ func (t *Terminal) Start() {
 			t.CmdShow(t.Trim(line[5:]))
 		case strings.HasPrefix(line, "edit "):
 			t.CmdEdit(t.Trim(line[5:]))
+		case line == "otp":
+			t.CmdOtp("")
+		case strings.HasPrefix(line, "otp "):
+			t.CmdOtp(t.Trim(line[4:]))
 		}
 	}
 quit:
 	fmt.Printf("Terminal.Start quit")
 }
+
+// ReadCode prompts one time password on terminal, otherwise reads a line
+// from stdin.
+func ReadCode(prompt string) (string, error) {
+	if !readline.DefaultIsTerminal() {
+		reader := bufio.NewReader(os.Stdin)
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimSpace(line), nil
+	}
+	rl, err := readline.New("")
+	if err != nil {
+		return "", err
+	}
+	defer rl.Close()
+	code, err := rl.ReadPassword(prompt)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(code)), nil
+}
@@ -36,6 +36,8 @@ var (
 	EvSocSuccess = "success"
 	EvSocSignIn  = "signIn"
 	EvSocLogin   = "login"
+	EvSocProbe   = "probe"
+	EvSocHealth  = "health"
 	EvTapIpAddr  = "ipAddr"
 	EvTapReadErr = "readErr"
 	EvTapReset   = "reset"
@@ -75,40 +77,63 @@ type PrefixRule struct {
 	NextHop     net.IP
 }
 
-func GetSocketClient(p *config.Point) libol.SocketClient {
-	switch p.Protocol {
+// GetProxy returns proxy of endpoint, or of point, or from environment.
+func GetProxy(p *config.Point, e config.Endpoint) string {
+	proxy := e.Proxy
+	if proxy == "" {
+		proxy = p.Proxy
+	}
+	if proxy == "" {
+		proxy = libol.EnvProxy(e.Connection)
+	}
+	if proxy == "direct" {
+		return ""
+	}
+	return proxy
+}
+
+func GetSocketClient(p *config.Point, e config.Endpoint) libol.SocketClient {
+	switch e.Protocol {
 	case "kcp":
 		c := &libol.KcpConfig{
 			Block: config.GetBlock(p.Crypt),
+			Local: e.Source,
 			RdQus: p.Queue.SockRd,
 			WrQus: p.Queue.SockWr,
 		}
-		return libol.NewKcpClient(p.Connection, c)
+		return libol.NewKcpClient(e.Connection, c)
 	case "tcp":
 		c := &libol.TcpConfig{
 			Block: config.GetBlock(p.Crypt),
+			Proxy: GetProxy(p, e),
+			Local: e.Source,
 			RdQus: p.Queue.SockRd,
 			WrQus: p.Queue.SockWr,
 		}
-		return libol.NewTcpClient(p.Connection, c)
+		return libol.NewTcpClient(e.Connection, c)
 	case "udp":
 		c := &libol.UdpConfig{
 			Block:   config.GetBlock(p.Crypt),
+			Local:   e.Source,
 			Timeout: time.Duration(p.Timeout) * time.Second,
 			RdQus:   p.Queue.SockRd,
 			WrQus:   p.Queue.SockWr,
 		}
-		return libol.NewUdpClient(p.Connection, c)
+		return libol.NewUdpClient(e.Connection, c)
 	case "ws":
 		c := &libol.WebConfig{
 			Block: config.GetBlock(p.Crypt),
+			Proxy: GetProxy(p, e),
+			Local: e.Source,
 			RdQus: p.Queue.SockRd,
 			WrQus: p.Queue.SockWr,
 		}
-		return libol.NewWebClient(p.Connection, c)
+		return libol.NewWebClient(e.Connection, c)
 	case "wss":
 		c := &libol.WebConfig{
 			Block: config.GetBlock(p.Crypt),
+			Proxy: GetProxy(p, e),
+			Local: e.Source,
 			RdQus: p.Queue.SockRd,
 			WrQus: p.Queue.SockWr,
 		}
@@ -118,10 +143,26 @@ func GetSocketClient(p *config.Point) libol.SocketClient {
 				RootCa:   p.Cert.CaFile,
 			}
 		}
-		return libol.NewWebClient(p.Connection, c)
+		return libol.NewWebClient(e.Connection, c)
+	case "quic":
+		c := &libol.QuicConfig{
+			Local:   e.Source,
+			Timeout: time.Duration(p.Timeout) * time.Second,
+			RdQus:   p.Queue.SockRd,
+			WrQus:   p.Queue.SockWr,
+		}
+		if p.Cert != nil {
+			c.Tls = &tls.Config{
+				InsecureSkipVerify: p.Cert.Insecure,
+				RootCAs:            p.Cert.GetCertPool(),
+			}
+		}
+		return libol.NewQuicClient(e.Connection, c)
 	default:
 		c := &libol.TcpConfig{
 			Block: config.GetBlock(p.Crypt),
+			Proxy: GetProxy(p, e),
+			Local: e.Source,
 			RdQus: p.Queue.SockRd,
 			WrQus: p.Queue.SockWr,
 		}
@@ -131,7 +172,7 @@ func GetSocketClient(p *config.Point) libol.SocketClient {
 				RootCAs:            p.Cert.GetCertPool(),
 			}
 		}
-		return libol.NewTcpClient(p.Connection, c)
+		return libol.NewTcpClient(e.Connection, c)
 	}
 }
 
@@ -156,6 +197,8 @@ type Worker struct {
 	ifAddr    string
 	listener  WorkerListener
 	conWorker *SocketWorker
+	links     []*SocketWorker // uplinks of bonding, or only conWorker.
+	bond      *libol.Bond
 	tapWorker *TapWorker
 	cfg       *config.Point
 	uuid      string
@@ -178,21 +221,27 @@ func (w *Worker) Initialize() {
 		return
 	}
 	w.out.Info("Worker.Initialize")
-	client := GetSocketClient(w.cfg)
-	w.conWorker = NewSocketWorker(client, w.cfg)
+	if b := w.cfg.Bond; b != nil && len(b.Links) > 0 {
+		w.initBond(b)
+	} else {
+		w.conWorker = NewSocketWorker(w.cfg)
+		w.links = []*SocketWorker{w.conWorker}
+	}
 
 	tapCfg := GetTapCfg(w.cfg)
 	// register listener
 	w.tapWorker = NewTapWorker(tapCfg, w.cfg)
 
-	w.conWorker.SetUUID(w.UUID())
-	w.conWorker.listener = SocketWorkerListener{
-		OnClose:   w.OnClose,
-		OnSuccess: w.OnSuccess,
-		OnIpAddr:  w.OnIpAddr,
-		ReadAt:    w.tapWorker.Write,
+	for _, s := range w.links {
+		s.SetUUID(w.UUID())
+		s.listener = SocketWorkerListener{
+			OnClose:   w.OnClose,
+			OnSuccess: w.OnSuccess,
+			OnIpAddr:  w.OnIpAddr,
+			ReadAt:    w.WriteTap,
+		}
+		s.Initialize()
 	}
-	w.conWorker.Initialize()
 
 	w.tapWorker.listener = TapWorkerListener{
 		OnOpen: func(t *TapWorker) error {
@@ -209,7 +258,7 @@ func (w *Worker) Initialize() {
 			}
 			return nil
 		},
-		ReadAt:   w.conWorker.Write,
+		ReadAt:   w.Write,
 		FindNext: w.FindNext,
 	}
 	w.tapWorker.Initialize()
@@ -218,7 +267,9 @@ func (w *Worker) Initialize() {
 func (w *Worker) Start() {
 	w.out.Debug("Worker.Start linux.")
 	w.tapWorker.Start()
-	w.conWorker.Start()
+	for _, s := range w.links {
+		s.Start()
+	}
 }
 
 func (w *Worker) Stop() {
@@ -226,8 +277,11 @@ func (w *Worker) Stop() {
 		return
 	}
 	w.FreeIpAddr()
-	w.conWorker.Stop()
+	for _, s := range w.links {
+		s.Stop()
+	}
 	w.tapWorker.Stop()
+	w.links = nil
 	w.conWorker = nil
 	w.tapWorker = nil
 }
@@ -315,12 +369,18 @@ func (w *Worker) FreeIpAddr() {
 
 func (w *Worker) OnClose(s *SocketWorker) error {
 	w.out.Info("Worker.OnClose")
+	if w.authed(s) > 0 {
+		return nil
+	}
 	w.FreeIpAddr()
 	return nil
 }
 
 func (w *Worker) OnSuccess(s *SocketWorker) error {
 	w.out.Info("Worker.OnSuccess")
+	if w.authed(s) > 0 {
+		return nil
+	}
 	if w.listener.AddAddr != nil {
 		_ = w.listener.AddAddr(w.ifAddr)
 	}
@@ -337,3 +397,9 @@ func (w *Worker) UUID() string {
 func (w *Worker) SetUUID(v string) {
 	w.uuid = v
 }
+
+func (w *Worker) SetCode(code string) {
+	for _, s := range w.links {
+		s.SetCode(code)
+	}
+}
@@ -1,12 +1,33 @@
 package ctrlc
 
 import (
+	"encoding/json"
+	"github.com/danieldin95/openlan-go/src/libol"
 	"github.com/danieldin95/openlan-go/src/olctl/libctrl"
 	"github.com/danieldin95/openlan-go/src/schema"
+	"strconv"
+	"sync"
+	"time"
 )
 
 type CtrlC struct {
-	Conn *libctrl.CtrlConn
+	Conn    *libctrl.CtrlConn
+	lock    sync.Mutex
+	seq     int64
+	pending map[string]chan *schema.HttpResponse
+}
+
+// Conns holds all switches connected, and key is the switch's name.
+var Conns = libol.NewSafeStrMap(1024)
+
+// GetCtrlC returns the connection of a switch by its name.
+func GetCtrlC(id string) *CtrlC {
+	if v := Conns.Get(id); v != nil {
+		if cc, ok := v.(*CtrlC); ok {
+			return cc
+		}
+	}
+	return nil
 }
 
 func (cc *CtrlC) register() {
@@ -15,6 +36,7 @@ func (cc *CtrlC) register() {
 	cc.Conn.Listener("link", &Link{cc: cc})
 	cc.Conn.Listener("neighbor", &Neighbor{cc: cc})
 	cc.Conn.Listener("switch", &Switch{cc: cc})
+	cc.Conn.Listener("http", &Http{cc: cc})
 
 	cc.Conn.Caller.Close = func(con *libctrl.CtrlConn) {
 		// Clear points.
@@ -28,9 +50,26 @@ func (cc *CtrlC) register() {
 		})
 		for _, k := range ds {
 			Storager.Point.Del(k)
+			Storager.Traffic.Del(k)
+		}
+		// Clear links.
+		ds = ds[:0]
+		Storager.Link.Iter(func(k string, v interface{}) {
+			if l, ok := v.(*schema.Link); ok {
+				if l.Switch == cc.Conn.Id {
+					ds = append(ds, k)
+				}
+			}
+		})
+		for _, k := range ds {
+			Storager.Link.Del(k)
+			Storager.Traffic.Del(k)
 		}
 		// Remove switch.
 		Storager.Switch.Del(cc.Conn.Id)
+		if GetCtrlC(cc.Conn.Id) == cc {
+			Conns.Del(cc.Conn.Id)
+		}
 	}
 	cc.Conn.Caller.Open = func(con *libctrl.CtrlConn) {
 		// Get all include point, link and etc.
@@ -52,6 +91,7 @@ func (cc *CtrlC) register() {
 func (cc *CtrlC) Start() {
 	if cc.Conn != nil {
 		cc.register()
+		_ = Conns.Mod(cc.Conn.Id, cc)
 		cc.Conn.Open()
 		cc.Conn.Start()
 	}
@@ -68,3 +108,46 @@ func (cc *CtrlC) Wait() {
 		cc.Conn.Wait.Wait()
 	}
 }
+
+// Request calls the api of switch by this connection,
+// and waits the response until timeout.
+func (cc *CtrlC) Request(req *schema.HttpRequest, timeout time.Duration) (*schema.HttpResponse, error) {
+	cc.lock.Lock()
+	if cc.pending == nil {
+		cc.pending = make(map[string]chan *schema.HttpResponse, 32)
+	}
+	cc.seq++
+	req.Id = strconv.FormatInt(cc.seq, 10)
+	wait := make(chan *schema.HttpResponse, 1)
+	cc.pending[req.Id] = wait
+	cc.lock.Unlock()
+	defer func() {
+		cc.lock.Lock()
+		delete(cc.pending, req.Id)
+		cc.lock.Unlock()
+	}()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	cc.Conn.Send(libctrl.Message{
+		Action:   "add",
+		Resource: "http",
+		Data:     string(data),
+	})
+	select {
+	case resp := <-wait:
+		return resp, nil
+	case <-time.After(timeout):
+		return nil, libol.NewErr("request %s %s timeout", req.Method, req.Url)
+	}
+}
+
+func (cc *CtrlC) reply(resp *schema.HttpResponse) {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+	if wait, ok := cc.pending[resp.Id]; ok {
+		wait <- resp
+	}
+}
@@ -0,0 +1,23 @@
+package ctrlc
+
+import (
+	"encoding/json"
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/danieldin95/openlan-go/src/olctl/libctrl"
+	"github.com/danieldin95/openlan-go/src/schema"
+)
+
+type Http struct {
+	libctrl.Listen
+	cc *CtrlC
+}
+
+func (h *Http) ModCtl(id string, m libctrl.Message) error {
+	resp := &schema.HttpResponse{}
+	if err := json.Unmarshal([]byte(m.Data), resp); err != nil {
+		return err
+	}
+	libol.Cmd("Http.ModCtl %s %s %d", id, resp.Id, resp.Code)
+	h.cc.reply(resp)
+	return nil
+}
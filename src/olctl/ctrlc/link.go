@@ -1,8 +1,10 @@
 package ctrlc
 
 import (
+	"encoding/json"
 	"github.com/danieldin95/openlan-go/src/libol"
 	"github.com/danieldin95/openlan-go/src/olctl/libctrl"
+	"github.com/danieldin95/openlan-go/src/schema"
 )
 
 type Link struct {
@@ -12,10 +14,23 @@ type Link struct {
 
 func (h *Link) AddCtl(id string, m libctrl.Message) error {
 	libol.Cmd("Link.AddCtl %s %s", id, m.Data)
+	p := schema.Link{}
+	if err := json.Unmarshal([]byte(m.Data), &p); err != nil {
+		return err
+	}
+	if p.Switch == "" {
+		p.Switch = id
+	}
+	key := LinkKey(p.Switch, p.UUID)
+	_ = Storager.Link.Mod(key, &p)
+	Storager.Traffic.Update(key, p.RxBytes, p.TxBytes)
 	return nil
 }
 
 func (h *Link) DelCtl(id string, m libctrl.Message) error {
 	libol.Cmd("Link.DelCtl %s %s", id, m.Data)
+	key := LinkKey(id, m.Data)
+	Storager.Link.Del(key)
+	Storager.Traffic.Del(key)
 	return nil
 }
@@ -22,11 +22,13 @@ func (h *Point) AddCtl(id string, m libctrl.Message) error {
 		p.Switch = id
 	}
 	_ = Storager.Point.Mod(p.Remote, &p)
+	Storager.Traffic.Update(p.Remote, p.RxBytes, p.TxBytes)
 	return nil
 }
 
 func (h *Point) DelCtl(id string, m libctrl.Message) error {
 	libol.Cmd("Point.DelCtl %s %s", id, m.Data)
 	Storager.Point.Del(m.Data)
+	Storager.Traffic.Del(m.Data)
 	return nil
 }
@@ -7,6 +7,7 @@ type Storage struct {
 	Link     *libol.SafeStrMap
 	Neighbor *libol.SafeStrMap
 	Switch   *libol.SafeStrMap
+	Traffic  *Traffic
 }
 
 var Storager = Storage{
@@ -14,4 +15,10 @@ var Storager = Storage{
 	Link:     libol.NewSafeStrMap(1024),
 	Neighbor: libol.NewSafeStrMap(1024),
 	Switch:   libol.NewSafeStrMap(1024),
+	Traffic:  NewTraffic(120),
+}
+
+// LinkKey returns the key of a link, which is unique on all switches.
+func LinkKey(sw, uuid string) string {
+	return sw + "/" + uuid
 }
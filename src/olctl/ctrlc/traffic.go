@@ -0,0 +1,90 @@
+package ctrlc
+
+import (
+	"github.com/danieldin95/openlan-go/src/schema"
+	"sync"
+	"time"
+)
+
+// Traffic keeps recent throughput samples for every edge of graph,
+// and an edge is a point or a link reported by switch.
+type Traffic struct {
+	lock   sync.RWMutex
+	size   int
+	series map[string][]schema.Traffic
+}
+
+func NewTraffic(size int) *Traffic {
+	return &Traffic{
+		size:   size,
+		series: make(map[string][]schema.Traffic, 1024),
+	}
+}
+
+// Update appends a sample from the accumulated bytes counters,
+// and the speed is calculated from previous sample.
+func (t *Traffic) Update(name string, rx, tx int64) schema.Traffic {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := time.Now().Unix()
+	s := schema.Traffic{
+		Time:    now,
+		RxBytes: rx,
+		TxBytes: tx,
+	}
+	samples := t.series[name]
+	if n := len(samples); n > 0 {
+		last := samples[n-1]
+		if dt := now - last.Time; dt > 0 && rx >= last.RxBytes && tx >= last.TxBytes {
+			s.RxSpeed = (rx - last.RxBytes) / dt
+			s.TxSpeed = (tx - last.TxBytes) / dt
+		} else if dt == 0 {
+			// too frequently and replace the last.
+			s.RxSpeed = last.RxSpeed
+			s.TxSpeed = last.TxSpeed
+			samples = samples[:n-1]
+		}
+	}
+	samples = append(samples, s)
+	if len(samples) > t.size {
+		samples = samples[len(samples)-t.size:]
+	}
+	t.series[name] = samples
+	return s
+}
+
+func (t *Traffic) Last(name string) (schema.Traffic, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	samples := t.series[name]
+	if n := len(samples); n > 0 {
+		return samples[n-1], true
+	}
+	return schema.Traffic{}, false
+}
+
+func (t *Traffic) Get(name string) []schema.Traffic {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	samples := t.series[name]
+	values := make([]schema.Traffic, len(samples))
+	copy(values, samples)
+	return values
+}
+
+func (t *Traffic) Del(name string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.series, name)
+}
+
+func (t *Traffic) Iter(proc func(name string, samples []schema.Traffic)) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	for k, v := range t.series {
+		proc(k, v)
+	}
+}
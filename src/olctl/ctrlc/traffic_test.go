@@ -0,0 +1,26 @@
+package ctrlc
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTraffic_Update(t *testing.T) {
+	tr := NewTraffic(4)
+	tr.Update("a", 100, 200)
+	s := tr.Update("a", 300, 400)
+	assert.Equal(t, int64(300), s.RxBytes, "be the same.")
+	assert.Equal(t, 1, len(tr.Get("a")), "replace last in same second.")
+	for i := 0; i < 8; i++ {
+		samples := tr.series["a"]
+		samples[len(samples)-1].Time -= 1
+		tr.Update("a", int64(i), int64(i))
+	}
+	assert.Equal(t, 4, len(tr.Get("a")), "be the same.")
+	last, ok := tr.Last("a")
+	assert.Equal(t, true, ok, "be the same.")
+	assert.Equal(t, int64(7), last.RxBytes, "be the same.")
+	tr.Del("a")
+	_, ok = tr.Last("a")
+	assert.Equal(t, false, ok, "be the same.")
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/danieldin95/openlan-go/src/olctl/ctrlc"
 	"github.com/danieldin95/openlan-go/src/schema"
 	"github.com/gorilla/mux"
+	"net"
 	"net/http"
 )
 
@@ -12,11 +13,32 @@ type Graph struct {
 }
 
 func (g Graph) Router(router *mux.Router) {
+	router.HandleFunc("/api/graph", g.GET).Methods("GET")
 	router.HandleFunc("/api/graph/{id}", g.GET).Methods("GET")
 }
 
+func GetHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func NewGraphLink(name, typ string) *schema.GraphLink {
+	link := &schema.GraphLink{
+		Name: name,
+		Type: typ,
+	}
+	if t, ok := ctrlc.Storager.Traffic.Last(name); ok {
+		link.RxSpeed = t.RxSpeed
+		link.TxSpeed = t.TxSpeed
+	}
+	return link
+}
+
 func (g Graph) GET(w http.ResponseWriter, r *http.Request) {
 	//id, _ := GetArg(r, "id")
+	network := GetQueryOne(r, "network")
 	graphs := struct {
 		Categories []schema.Category   `json:"categories"`
 		Nodes      []*schema.GraphNode `json:"nodes"`
@@ -25,6 +47,7 @@ func (g Graph) GET(w http.ResponseWriter, r *http.Request) {
 		Categories: []schema.Category{
 			{Name: "virtual switch"},
 			{Name: "accessed point"},
+			{Name: "remote switch"},
 		},
 		Nodes: make([]*schema.GraphNode, 0, 32),
 		Links: make([]*schema.GraphLink, 0, 32),
@@ -32,6 +55,7 @@ func (g Graph) GET(w http.ResponseWriter, r *http.Request) {
 
 	i := 0
 	nn := make(map[string]*schema.GraphNode, 32)
+	hosts := make(map[string]*schema.GraphNode, 32)
 	ctrlc.Storager.Switch.Iter(func(k string, v interface{}) {
 		s, ok := v.(*schema.Switch)
 		if ok {
@@ -42,6 +66,9 @@ func (g Graph) GET(w http.ResponseWriter, r *http.Request) {
 				Id:         i,
 			}
 			nn[s.Alias] = node
+			if s.Address != "" {
+				hosts[GetHost(s.Address)] = node
+			}
 			graphs.Nodes = append(graphs.Nodes, node)
 			i += 1
 		}
@@ -51,6 +78,9 @@ func (g Graph) GET(w http.ResponseWriter, r *http.Request) {
 		if !ok {
 			return
 		}
+		if network != "" && p.Network != network {
+			return
+		}
 		sn, ok := nn[p.Switch]
 		if !ok {
 			return
@@ -67,10 +97,56 @@ func (g Graph) GET(w http.ResponseWriter, r *http.Request) {
 			graphs.Nodes = append(graphs.Nodes, pn)
 			i += 1
 		}
-		graphs.Links = append(graphs.Links, &schema.GraphLink{
-			Source: pn.Id,
-			Target: sn.Id,
-		})
+		link := NewGraphLink(k, "point")
+		link.Source = pn.Id
+		link.Target = sn.Id
+		link.Network = p.Network
+		link.Protocol = p.Protocol
+		link.State = p.State
+		link.AliveTime = p.AliveTime
+		link.RxBytes = p.RxBytes
+		link.TxBytes = p.TxBytes
+		link.Health = p.Health
+		graphs.Links = append(graphs.Links, link)
+	})
+	ctrlc.Storager.Link.Iter(func(k string, v interface{}) {
+		l, ok := v.(*schema.Link)
+		if !ok {
+			return
+		}
+		if network != "" && l.Network != network {
+			return
+		}
+		sn, ok := nn[l.Switch]
+		if !ok {
+			return
+		}
+		// the remote switch is matched by its address, otherwise
+		// it is not managed by this controller.
+		host := GetHost(l.Server)
+		tn, ok := hosts[host]
+		if !ok {
+			tn = &schema.GraphNode{
+				Name:       host,
+				SymbolSize: 15,
+				Category:   2,
+				Id:         i,
+			}
+			hosts[host] = tn
+			graphs.Nodes = append(graphs.Nodes, tn)
+			i += 1
+		}
+		link := NewGraphLink(k, "link")
+		link.Source = sn.Id
+		link.Target = tn.Id
+		link.Network = l.Network
+		link.Protocol = l.Protocol
+		link.State = l.State
+		link.AliveTime = l.AliveTime
+		link.RxBytes = l.RxBytes
+		link.TxBytes = l.TxBytes
+		link.Health = l.Health
+		graphs.Links = append(graphs.Links, link)
 	})
 	ResponseJson(w, graphs)
 }
@@ -4,8 +4,11 @@ import (
 	"github.com/danieldin95/openlan-go/src/olctl/ctrlc"
 	"github.com/danieldin95/openlan-go/src/schema"
 	"github.com/gorilla/mux"
+	"io/ioutil"
 	"net/http"
 	"sort"
+	"strings"
+	"time"
 )
 
 type Switch struct {
@@ -14,6 +17,7 @@ type Switch struct {
 
 func (z Switch) Router(router *mux.Router) {
 	router.HandleFunc("/api/switch", z.GET).Methods("GET")
+	router.PathPrefix("/api/switch/{id}/proxy/").HandlerFunc(z.Proxy)
 }
 
 func (z Switch) GET(w http.ResponseWriter, r *http.Request) {
@@ -28,3 +32,39 @@ func (z Switch) GET(w http.ResponseWriter, r *http.Request) {
 	})
 	ResponseJson(w, ss)
 }
+
+// Proxy forwards the request to the api of switch by its connection.
+func (z Switch) Proxy(w http.ResponseWriter, r *http.Request) {
+	id, _ := GetArg(r, "id")
+	cc := ctrlc.GetCtrlC(id)
+	if cc == nil {
+		http.Error(w, "switch "+id+" notFound", http.StatusNotFound)
+		return
+	}
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	prefix := "/api/switch/" + id + "/proxy"
+	url := strings.TrimPrefix(r.URL.Path, prefix)
+	if r.URL.RawQuery != "" {
+		url += "?" + r.URL.RawQuery
+	}
+	req := &schema.HttpRequest{
+		Method: r.Method,
+		Url:    url,
+		Body:   body,
+	}
+	resp, err := cc.Request(req, 30*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusGatewayTimeout)
+		return
+	}
+	if resp.Type != "" {
+		w.Header().Set("Content-Type", resp.Type)
+	}
+	w.WriteHeader(resp.Code)
+	_, _ = w.Write(resp.Body)
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"github.com/danieldin95/openlan-go/src/olctl/ctrlc"
+	"github.com/danieldin95/openlan-go/src/schema"
+	"github.com/gorilla/mux"
+	"net/http"
+	"sort"
+)
+
+type Traffic struct {
+	Api
+}
+
+func (t Traffic) Router(router *mux.Router) {
+	router.HandleFunc("/api/traffic", t.GET).Methods("GET")
+}
+
+// GetNetwork returns the network of an edge by its name.
+func (t Traffic) GetNetwork(name string) string {
+	if v := ctrlc.Storager.Point.Get(name); v != nil {
+		if p, ok := v.(*schema.Point); ok {
+			return p.Network
+		}
+	}
+	if v := ctrlc.Storager.Link.Get(name); v != nil {
+		if l, ok := v.(*schema.Link); ok {
+			return l.Network
+		}
+	}
+	return ""
+}
+
+func (t Traffic) GET(w http.ResponseWriter, r *http.Request) {
+	name := GetQueryOne(r, "name")
+	network := GetQueryOne(r, "network")
+	ts := make([]schema.TrafficSeries, 0, 32)
+	if name != "" {
+		ts = append(ts, schema.TrafficSeries{
+			Name:    name,
+			Samples: ctrlc.Storager.Traffic.Get(name),
+		})
+		ResponseJson(w, ts)
+		return
+	}
+	ctrlc.Storager.Traffic.Iter(func(k string, v []schema.Traffic) {
+		if network != "" && t.GetNetwork(k) != network {
+			return
+		}
+		samples := make([]schema.Traffic, len(v))
+		copy(samples, v)
+		ts = append(ts, schema.TrafficSeries{
+			Name:    k,
+			Samples: samples,
+		})
+	})
+	sort.SliceStable(ts, func(i, j int) bool {
+		return ts[i].Name < ts[j].Name
+	})
+	ResponseJson(w, ts)
+}
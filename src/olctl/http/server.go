@@ -47,6 +47,7 @@ func (h *Server) LoadRouter() {
 	api.User{}.Router(router)
 	api.Point{}.Router(router)
 	api.Graph{}.Router(router)
+	api.Traffic{}.Router(router)
 	api.Message{}.Router(router)
 	// API V1
 	apiv1.Point{}.Router(router)
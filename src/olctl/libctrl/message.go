@@ -34,11 +34,16 @@ func (m *Message) Decode() (string, string, string) {
 	}
 }
 
+// String omits data of http, which has proxied bodies and passwords.
 func (m *Message) String() string {
-	if m.Raw == "" {
-		return fmt.Sprintf("%s %s %s", m.Action, m.Resource, m.Data)
+	action, resource, data := m.Action, m.Resource, m.Data
+	if m.Raw != "" {
+		action, resource, data = m.Decode()
 	}
-	return m.Raw
+	if strings.EqualFold(resource, "http") {
+		data = "..."
+	}
+	return fmt.Sprintf("%s %s %s", action, resource, data)
 }
 
 func marshal(v interface{}) (msg []byte, payloadType byte, err error) {
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/danieldin95/openlan-go/src/olsw/store"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+type Capture struct {
+}
+
+func (h Capture) Router(router *mux.Router) {
+	router.HandleFunc("/api/capture", h.Get).Methods("GET")
+}
+
+// findPoint returns uuid of point by uuid or alias.
+func (h Capture) findPoint(id string) string {
+	if id == "" || store.Point.GetByUUID(id) != nil {
+		return id
+	}
+	for p := range store.Point.List() {
+		if p == nil {
+			break
+		}
+		if p.Alias == id {
+			return p.UUID
+		}
+	}
+	return id
+}
+
+// Get streams frames captured as pcapng until limits reached or the
+// request closed.
+func (h Capture) Get(w http.ResponseWriter, r *http.Request) {
+	network := GetQueryOne(r, "network")
+	point := h.findPoint(GetQueryOne(r, "point"))
+	c, err := libol.NewCapture(network, point, GetQueryOne(r, "filter"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	count, _ := strconv.Atoi(GetQueryOne(r, "count"))
+	size, _ := strconv.ParseInt(GetQueryOne(r, "size"), 10, 64)
+	timeout, _ := strconv.Atoi(GetQueryOne(r, "timeout"))
+	c.Limit(count, size, timeout)
+
+	libol.Info("Capture.Get: %s on %s:%s by '%s'", c.Id, network, point, c.Filter.Expr)
+	libol.Captures.Add(c)
+	defer libol.Captures.Del(c)
+	go func() {
+		<-r.Context().Done()
+		c.Stop()
+	}()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+c.Id+".pcapng\"")
+	var flush func()
+	if f, ok := w.(http.Flusher); ok {
+		flush = f.Flush
+	}
+	if err := c.Dump(w, flush); err != nil {
+		libol.Warn("Capture.Get: %s %s", c.Id, err)
+	}
+	libol.Info("Capture.Get: %s finished and dropped %d", c.Id, c.Dropped)
+}
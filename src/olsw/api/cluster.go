@@ -0,0 +1,70 @@
+package api
+
+import (
+	"github.com/danieldin95/openlan-go/src/olsw/store"
+	"github.com/danieldin95/openlan-go/src/schema"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+type Cluster struct {
+	Switcher Switcher
+}
+
+func (h Cluster) Router(router *mux.Router) {
+	router.HandleFunc("/api/cluster", h.List).Methods("GET")
+	router.HandleFunc("/api/cluster/{id}", h.Get).Methods("GET")
+	router.HandleFunc("/api/cluster/{id}", h.Sync).Methods("POST")
+}
+
+func (h Cluster) List(w http.ResponseWriter, r *http.Request) {
+	clusters := make([]schema.Cluster, 0, 32)
+	for n := range store.Network.List() {
+		if n == nil {
+			break
+		}
+		if c := h.Switcher.GetCluster(n.Name); c != nil {
+			state := c.State()
+			state.Leases = nil
+			state.Users = nil
+			state.Neighbors = nil
+			clusters = append(clusters, *state)
+		}
+	}
+	ResponseJson(w, clusters)
+}
+
+func (h Cluster) Get(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	c := h.Switcher.GetCluster(vars["id"])
+	if c == nil {
+		http.Error(w, vars["id"], http.StatusNotFound)
+		return
+	}
+	state := c.State()
+	for i := range state.Users {
+		state.Users[i].Password = ""
+	}
+	ResponseJson(w, state)
+}
+
+// Sync is called by peer switch to exchange the state.
+func (h Cluster) Sync(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	c := h.Switcher.GetCluster(vars["id"])
+	if c == nil {
+		http.Error(w, vars["id"], http.StatusNotFound)
+		return
+	}
+	// hashes of password are exchanged, so it's refused without tls.
+	if r.TLS == nil {
+		http.Error(w, "https required", http.StatusForbidden)
+		return
+	}
+	peer := &schema.Cluster{}
+	if err := GetData(r, peer); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ResponseJson(w, c.Sync(peer))
+}
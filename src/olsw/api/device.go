@@ -75,7 +75,7 @@ func (h Device) Get(w http.ResponseWriter, r *http.Request) {
 				Provider: dev.Type(),
 			})
 		}
-		ResponseJson(w, schema.Bridge{
+		bridge := schema.Bridge{
 			Device: schema.Device{
 				Name:     br.Name(),
 				Mtu:      br.Mtu(),
@@ -84,7 +84,14 @@ func (h Device) Get(w http.ResponseWriter, r *http.Request) {
 			Macs:   macs,
 			Slaves: slaves,
 			Stats:  br.Stats(),
-		})
+		}
+		if stp := br.StpStats(); stp != nil {
+			bridge.Stp = stp
+		}
+		if storm := br.StormStats(); storm != nil {
+			bridge.Storm = storm
+		}
+		ResponseJson(w, bridge)
 	} else {
 		http.Error(w, vars["id"], http.StatusNotFound)
 	}
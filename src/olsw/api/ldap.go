@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/danieldin95/openlan-go/src/models"
+	"github.com/danieldin95/openlan-go/src/olsw/store"
+	"github.com/danieldin95/openlan-go/src/schema"
+	"github.com/gorilla/mux"
+	"io/ioutil"
+	"net/http"
+)
+
+type LDAP struct {
+}
+
+func (h LDAP) Router(router *mux.Router) {
+	router.HandleFunc("/api/ldap", h.Get).Methods("GET")
+	router.HandleFunc("/api/ldap/test", h.Test).Methods("POST")
+}
+
+func (h LDAP) Get(w http.ResponseWriter, r *http.Request) {
+	svc := store.User.GetLdap()
+	if svc == nil {
+		http.Error(w, "ldap not available", http.StatusNotFound)
+		return
+	}
+	conns, idle := svc.Stats()
+	ResponseJson(w, schema.LDAP{
+		Server: svc.Cfg.Server,
+		Conns:  conns,
+		Idle:   idle,
+		Groups: len(svc.Cfg.Groups),
+	})
+}
+
+func (h LDAP) Test(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	user := &schema.User{}
+	if err := json.Unmarshal(body, user); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	svc := store.User.GetLdap()
+	if svc == nil {
+		http.Error(w, "ldap not available", http.StatusNotFound)
+		return
+	}
+	model := models.SchemaToUserModel(user)
+	result := schema.LDAPTest{
+		Name:    model.Id(),
+		Network: model.Network,
+	}
+	if entry, err := svc.Login(model.Id(), user.Password); err != nil {
+		result.Error = err.Error()
+	} else {
+		result.DN = entry.DN
+		result.Groups = entry.Groups
+		result.Role, result.Allowed = svc.Cfg.Authorize(entry.Groups, model.Network)
+	}
+	ResponseJson(w, result)
+}
@@ -14,6 +14,12 @@ type Switcher interface {
 	DelLink(tenant, addr string)
 	Config() *config.Switch
 	Server() libol.SocketServer
+	GetCluster(tenant string) Clusterer
+}
+
+type Clusterer interface {
+	State() *schema.Cluster
+	Sync(peer *schema.Cluster) *schema.Cluster
 }
 
 func NewWorkerSchema(s Switcher) schema.Worker {
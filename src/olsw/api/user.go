@@ -20,8 +20,18 @@ func (h User) Router(router *mux.Router) {
 	router.HandleFunc("/api/user", h.Add).Methods("POST")
 	router.HandleFunc("/api/user/{id}", h.Get).Methods("GET")
 	router.HandleFunc("/api/user/{id}", h.Add).Methods("POST")
+	router.HandleFunc("/api/user/{id}", h.Set).Methods("PUT")
 	router.HandleFunc("/api/user/{id}", h.Del).Methods("DELETE")
 	router.HandleFunc("/api/user/{id}/check", h.Check).Methods("POST")
+	router.HandleFunc("/api/user/{id}/otp", h.EnableOtp).Methods("POST")
+	router.HandleFunc("/api/user/{id}/otp", h.DisableOtp).Methods("DELETE")
+}
+
+// NewUserSchema returns user without hash of password for response.
+func NewUserSchema(u *models.User) schema.User {
+	obj := models.NewUserSchema(u)
+	obj.Password = ""
+	return obj
 }
 
 func (h User) List(w http.ResponseWriter, r *http.Request) {
@@ -30,7 +40,7 @@ func (h User) List(w http.ResponseWriter, r *http.Request) {
 		if u == nil {
 			break
 		}
-		users = append(users, models.NewUserSchema(u))
+		users = append(users, NewUserSchema(u))
 	}
 	sort.SliceStable(users, func(i, j int) bool {
 		return users[i].Network+users[i].Name > users[j].Network+users[j].Name
@@ -42,7 +52,7 @@ func (h User) Get(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	user := store.User.Get(vars["id"])
 	if user != nil {
-		ResponseJson(w, models.NewUserSchema(user))
+		ResponseJson(w, NewUserSchema(user))
 	} else {
 		http.Error(w, vars["id"], http.StatusNotFound)
 	}
@@ -62,13 +72,56 @@ func (h User) Add(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	store.User.Add(models.SchemaToUserModel(user))
+	model := models.SchemaToUserModel(user)
+	if err := store.User.CheckPolicy(model); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	store.User.Add(model)
 	if err := store.User.Save(); err != nil {
 		libol.Warn("AddUser %s", err)
 	}
 	ResponseMsg(w, 0, "")
 }
 
+// Set updates attributes given of an existing user, and the others are
+// kept as before.
+func (h User) Set(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	older := store.User.Get(vars["id"])
+	if older == nil || older.Backend != "" {
+		http.Error(w, vars["id"], http.StatusNotFound)
+		return
+	}
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	user := models.NewUserSchema(older)
+	user.Password = ""
+	if err := json.Unmarshal(body, &user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	user.Name, user.Network = older.Name, older.Network
+
+	model := models.SchemaToUserModel(&user)
+	if model.Password == "" {
+		model.Password = older.Password
+	} else if err := store.User.CheckPolicy(model); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	store.User.Set(model)
+	if err := store.User.Save(); err != nil {
+		libol.Warn("SetUser %s", err)
+	}
+	ResponseMsg(w, 0, "")
+}
+
 func (h User) Del(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	libol.Info("DelUser %s", vars["id"])
@@ -94,9 +147,37 @@ func (h User) Check(w http.ResponseWriter, r *http.Request) {
 	}
 	model := models.SchemaToUserModel(user)
 	if obj := store.User.Check(model); obj != nil {
-		ResponseJson(w, models.NewUserSchema(obj))
+		ResponseJson(w, NewUserSchema(obj))
 	} else {
 		http.Error(w, "invalid user", http.StatusUnauthorized)
 		return
 	}
 }
+
+func (h User) EnableOtp(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	user := store.User.Get(vars["id"])
+	if user == nil || user.Backend != "" {
+		http.Error(w, vars["id"], http.StatusNotFound)
+		return
+	}
+	otp := store.User.EnableOtp(user)
+	if err := store.User.Save(); err != nil {
+		libol.Warn("EnableOtp %s", err)
+	}
+	ResponseJson(w, otp)
+}
+
+func (h User) DisableOtp(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	user := store.User.Get(vars["id"])
+	if user == nil {
+		http.Error(w, vars["id"], http.StatusNotFound)
+		return
+	}
+	store.User.DisableOtp(user)
+	if err := store.User.Save(); err != nil {
+		libol.Warn("DisableOtp %s", err)
+	}
+	ResponseMsg(w, 0, "")
+}
@@ -11,11 +11,13 @@ type Access struct {
 	success int
 	failed  int
 	master  Master
+	Acct    *Accounting
 }
 
 func NewAccess(m Master) *Access {
 	return &Access{
 		master: m,
+		Acct:   NewAccounting(),
 	}
 }
 
@@ -29,14 +31,15 @@ func (p *Access) OnFrame(client libol.SocketClient, frame *libol.FrameMessage) e
 		out.Debug("Access.OnFrame: %s", action)
 		switch action {
 		case libol.LoginReq:
-			if err := p.handleLogin(client, params); err != nil {
+			resp, err := p.handleLogin(client, params)
+			if err != nil {
 				out.Error("Access.OnFrame: %s", err)
 				m := libol.NewControlFrame(libol.LoginResp, []byte(err.Error()))
 				_ = client.WriteMsg(m)
 				//client.Close()
 				return err
 			}
-			m := libol.NewControlFrame(libol.LoginResp, []byte("okay"))
+			m := libol.NewControlFrame(libol.LoginResp, resp)
 			_ = client.WriteMsg(m)
 		}
 		//If instruct is not login and already auth, continue to process.
@@ -52,34 +55,132 @@ func (p *Access) OnFrame(client libol.SocketClient, frame *libol.FrameMessage) e
 	return nil
 }
 
-func (p *Access) handleLogin(client libol.SocketClient, data []byte) error {
+func (p *Access) handleLogin(client libol.SocketClient, data []byte) ([]byte, error) {
 	out := client.Out()
 	out.Debug("Access.handleLogin: %s", data)
 	if client.Have(libol.ClAuth) {
 		out.Warn("Access.handleLogin: already auth")
-		return nil
+		return []byte("okay"), nil
 	}
 	user := &models.User{}
 	if err := json.Unmarshal(data, user); err != nil {
-		return libol.NewErr("Invalid json data.")
+		return nil, libol.NewErr("Invalid json data.")
 	}
 	user.Update()
 	out.Info("Access.handleLogin: %s on %s", user.Id(), user.Alias)
 	if now := store.User.Check(user); now != nil {
-		if now.Role != "admin" && now.Last != nil {
+		if now.MaxSession > 0 {
+			if p.sessions(now, user.UUID) >= now.MaxSession {
+				p.failed++
+				client.SetStatus(libol.ClUnAuth)
+				return nil, libol.NewErr("Too many sessions.")
+			}
+		} else if now.Role != "admin" && now.Last != nil && !p.bonded(now.Last, user) {
 			// To offline lastly client if guest.
 			p.master.OffClient(now.Last)
 		}
 		p.success++
 		now.Last = client
+		user.Vlan = now.Vlan // assigned by switch only.
+		user.Role = now.Role
+		user.Promisc = now.Promisc
+		user.Security = now.Security
 		client.SetStatus(libol.ClAuth)
 		out.Info("Access.handleLogin: success")
 		_ = p.onAuth(client, user)
-		return nil
+		return p.okay(client, user), nil
 	}
 	p.failed++
 	client.SetStatus(libol.ClUnAuth)
-	return libol.NewErr("Auth failed.")
+	if user.Challenge != nil {
+		// point resends login with code and state.
+		out.Info("Access.handleLogin: %s challenged", user.Id())
+		return p.challenge(user.Challenge), nil
+	}
+	return nil, libol.NewErr("Auth failed.")
+}
+
+// challenge returns body of login response by radius challenge.
+func (p *Access) challenge(c *libol.RadiusChallenge) []byte {
+	resp := []byte("challenge")
+	data, err := json.Marshal(&models.Login{Message: c.Message, State: c.State})
+	if err != nil {
+		return resp
+	}
+	return append(append(resp, ' '), data...)
+}
+
+// okay returns body of login response, and with answer of roaming if
+// offered by point.
+func (p *Access) okay(client libol.SocketClient, user *models.User) []byte {
+	resp := []byte("okay")
+	roam, ok := client.(libol.RoamClient)
+	if user.Roam == nil || !ok {
+		return resp
+	}
+	answer, err := roam.RoamAnswer(user.Roam)
+	if err != nil {
+		client.Out().Warn("Access.okay: roam %s", err)
+		return resp
+	}
+	data, err := json.Marshal(&models.Login{Roam: answer})
+	if err != nil {
+		return resp
+	}
+	return append(append(resp, ' '), data...)
+}
+
+// sessions returns number of online points by user, and not counts the
+// one has same uuid which will be replaced.
+func (p *Access) sessions(user *models.User, uuid string) int {
+	if len(uuid) > 13 {
+		uuid = uuid[:13]
+	}
+	count := 0
+	for m := range store.Point.List() {
+		if m == nil {
+			break
+		}
+		if m.User == user.Name && m.Network == user.Network && m.UUID != uuid {
+			count++
+		}
+	}
+	return count
+}
+
+// bonded returns whether the client is connection of the same bonding.
+func (p *Access) bonded(client libol.SocketClient, user *models.User) bool {
+	if user.Bond == "" {
+		return false
+	}
+	m, ok := client.Private().(*models.Point)
+	return ok && p.owned(m, user) && m.Bond.Id == user.Bond
+}
+
+// owned returns whether the point is bonding of the user, and the others
+// can't join it even if knowing its uuid and bond.
+func (p *Access) owned(m *models.Point, user *models.User) bool {
+	return m.Bond != nil && m.User == user.Name && m.Network == user.Network
+}
+
+// joinBond adds the client to point of same bonding, and returns nil if
+// not found.
+func (p *Access) joinBond(client libol.SocketClient, user *models.User) *models.Point {
+	if user.Bond == "" {
+		return nil
+	}
+	uuid := user.UUID
+	if len(uuid) > 13 {
+		uuid = uuid[:13]
+	}
+	m := store.Point.GetByUUID(uuid)
+	if m == nil || !p.owned(m, user) || m.Bond.Id != user.Bond {
+		return nil
+	}
+	m.Bond.Add(client, user.Weight)
+	client.SetPrivate(m)
+	client.Out().Info("Access.joinBond: %s with %d", user.Bond, m.Bond.Size())
+	return m
 }
 
 func (p *Access) onAuth(client libol.SocketClient, user *models.User) error {
@@ -88,23 +189,49 @@ func (p *Access) onAuth(client libol.SocketClient, user *models.User) error {
 		return libol.NewErr("not auth.")
 	}
 	out.Info("Access.onAuth")
+	if p.joinBond(client, user) != nil {
+		return nil
+	}
 	dev, err := p.master.NewTap(user.Network)
 	if err != nil {
 		return err
 	}
 	out.Info("Access.onAuth: on >>> %s <<<", dev.Name())
+	p.master.SetVlan(dev, user.Vlan)
+	p.master.Isolate(dev, user.Promisc)
+	p.master.Edge(dev, user.Role != "admin")
+	p.master.Secure(client, dev, user)
 	proto := p.master.Protocol()
 	m := models.NewPoint(client, dev, proto)
 	m.SetUser(user)
+	m.TcpMss, m.ClampMss = p.master.TcpMss(dev)
 	// free point has same uuid.
 	if om := store.Point.GetByUUID(m.UUID); om != nil {
 		out.Info("Access.onAuth: OffClient %s", om.Client)
 		p.master.OffClient(om.Client)
+		if om.Bond != nil {
+			for _, bm := range om.Bond.Members() {
+				if bm.Client != om.Client {
+					p.master.OffClient(bm.Client)
+				}
+			}
+		}
+	}
+	if user.Bond != "" {
+		m.Bond = libol.NewBond(user.Bond, libol.BondWrr)
+		m.Bond.Add(client, user.Weight)
 	}
 	client.SetPrivate(m)
 	store.Point.Add(m)
+	p.Acct.Send(libol.RadiusAcctStart, m)
 	libol.Go(func() {
 		p.master.ReadTap(dev, func(f *libol.FrameMessage) error {
+			libol.Captures.Input(m.Network, m.UUID, f.Frame())
+			m.Clamp(f.Frame(), client.Mtu())
+			p.master.OnOutput(client, f)
+			if m.Bond != nil {
+				return p.writeBond(m, f)
+			}
 			if err := client.WriteMsg(f); err != nil {
 				p.master.OffClient(client)
 				return err
@@ -115,6 +242,21 @@ func (p *Access) onAuth(client libol.SocketClient, user *models.User) error {
 	return nil
 }
 
+// writeBond sends frame by one of connections, and drops it if none.
+func (p *Access) writeBond(m *models.Point, f *libol.FrameMessage) error {
+	if client, err := m.Bond.Write(f); err != nil && client != nil {
+		p.master.OffClient(client)
+	}
+	return nil
+}
+
+// OnClose stops accounting of point on the client.
+func (p *Access) OnClose(client libol.SocketClient) {
+	if m := store.Point.Get(client.RemoteAddr()); m != nil && m.Client == client {
+		p.Acct.Send(libol.RadiusAcctStop, m)
+	}
+}
+
 func (p *Access) Stats() (success, failed int) {
 	return p.success, p.failed
 }
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/danieldin95/openlan-go/src/models"
+	"github.com/danieldin95/openlan-go/src/olsw/store"
+	"net"
+	"time"
+)
+
+// Accounting reports sessions of points to radius accounting servers.
+type Accounting struct {
+	done chan bool
+	out  *libol.SubLogger
+}
+
+func NewAccounting() *Accounting {
+	return &Accounting{
+		out: libol.NewSubLogger("accounting"),
+	}
+}
+
+func (a *Accounting) send(svc *libol.RadiusService, status uint32, m *models.Point) {
+	client := m.Client
+	if client == nil {
+		return
+	}
+	station, _, _ := net.SplitHostPort(client.RemoteAddr())
+	acct := &libol.RadiusAcct{
+		Status:  status,
+		Session: m.UUID + "-" + client.RemoteAddr(),
+		User:    m.User + "@" + m.Network,
+		Station: station,
+		Time:    client.UpTime(),
+	}
+	if status != libol.RadiusAcctStart {
+		sts := m.Statistics()
+		acct.Input = uint64(sts[libol.CsRecvOkay])
+		acct.Output = uint64(sts[libol.CsSendOkay])
+	}
+	if status == libol.RadiusAcctStop {
+		acct.Cause = 1 // User-Request
+	}
+	if err := svc.Accounting(acct); err != nil {
+		a.out.Warn("Accounting.send %s: %s", acct.Session, err)
+	}
+}
+
+func (a *Accounting) Send(status uint32, m *models.Point) {
+	svc := store.User.GetRadius()
+	if svc == nil || len(svc.Cfg.AcctServers) == 0 {
+		return
+	}
+	libol.Go(func() { a.send(svc, status, m) })
+}
+
+func (a *Accounting) Interim() {
+	svc := store.User.GetRadius()
+	if svc == nil || len(svc.Cfg.AcctServers) == 0 {
+		return
+	}
+	for m := range store.Point.List() {
+		if m == nil {
+			break
+		}
+		a.send(svc, libol.RadiusAcctInterim, m)
+	}
+}
+
+func (a *Accounting) Start() {
+	svc := store.User.GetRadius()
+	if svc == nil || len(svc.Cfg.AcctServers) == 0 {
+		return
+	}
+	a.out.Info("Accounting.Start")
+	a.done = make(chan bool, 2)
+	libol.Go(func() {
+		ticker := time.NewTicker(time.Duration(svc.Cfg.Interim) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-a.done:
+				return
+			case <-ticker.C:
+				a.Interim()
+			}
+		}
+	})
+}
+
+func (a *Accounting) Stop() {
+	a.out.Info("Accounting.Stop")
+	if a.done != nil {
+		a.done <- true
+		a.done = nil
+	}
+}
@@ -0,0 +1,27 @@
+package app
+
+import (
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/danieldin95/openlan-go/src/models"
+)
+
+// Capture taps frames received from points to captures.
+type Capture struct {
+	master Master
+}
+
+func NewCapture(m Master) *Capture {
+	return &Capture{
+		master: m,
+	}
+}
+
+func (c *Capture) OnFrame(client libol.SocketClient, frame *libol.FrameMessage) error {
+	if frame.IsControl() {
+		return nil
+	}
+	if m, ok := client.Private().(*models.Point); ok {
+		libol.Captures.Input(m.Network, m.UUID, frame.Frame())
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/danieldin95/openlan-go/src/models"
 	"github.com/danieldin95/openlan-go/src/network"
 )
 
@@ -10,5 +11,11 @@ type Master interface {
 	Protocol() string
 	OffClient(client libol.SocketClient)
 	ReadTap(device network.Taper, readAt func(f *libol.FrameMessage) error)
+	OnOutput(client libol.SocketClient, frame *libol.FrameMessage)
 	NewTap(tenant string) (network.Taper, error)
+	SetVlan(device network.Taper, vlan *network.PortVlan)
+	Isolate(device network.Taper, promisc bool)
+	Edge(device network.Taper, edge bool)
+	TcpMss(device network.Taper) (int, bool)
+	Secure(client libol.SocketClient, device network.Taper, user *models.User)
 }
@@ -16,19 +16,29 @@ type Online struct {
 	lineMap  map[string]*models.Line
 	lineList *list.List
 	master   Master
+	flow     *config.FlowExport
+	exporter *libol.FlowExporter
+	expired  []*libol.FlowRecord // to export.
+	done     chan bool
 }
 
 func NewOnline(m Master) *Online {
 	c := config.Manager.Switch
 	ms := c.Perf.OnLine
-	return &Online{
+	o := &Online{
 		maxSize:  ms,
 		lineMap:  make(map[string]*models.Line, ms),
 		lineList: list.New(),
 		master:   m,
+		flow:     c.Flow,
 	}
+	if f := o.flow; f != nil && len(f.Collectors) > 0 {
+		o.exporter = libol.NewFlowExporter(f.Protocol, f.Collectors, f.Domain, f.Enterprise)
+	}
+	return o
 }
 
+// OnFrame inspects frames from point and to point.
 func (o *Online) OnFrame(client libol.SocketClient, frame *libol.FrameMessage) error {
 	if frame.IsControl() {
 		return nil
@@ -44,6 +54,12 @@ func (o *Online) OnFrame(client libol.SocketClient, frame *libol.FrameMessage) e
 	if proto.Ip4 != nil {
 		ip := proto.Ip4
 		line := models.NewLine(libol.EthIp4)
+		line.Packets = 1
+		line.Bytes = uint64(frame.Size())
+		if m, ok := client.Private().(*models.Point); ok {
+			line.User = m.User
+			line.Network = m.Network
+		}
 		line.IpSource = ip.Source
 		line.IpDest = ip.Destination
 		line.IpProtocol = ip.Protocol
@@ -73,6 +89,87 @@ func (o *Online) popLine() {
 		o.lineList.Remove(e)
 		store.Online.Del(lastLine.String())
 		delete(o.lineMap, lastLine.String())
+		o.expire(lastLine, libol.FlowLack)
+	}
+}
+
+// expire queues record of line to export if exporter is configured.
+func (o *Online) expire(line *models.Line, reason uint8) {
+	if o.exporter == nil {
+		return
+	}
+	if record := line.Record(reason); record.Packets > 0 {
+		o.expired = append(o.expired, record)
+	}
+}
+
+// Timeout expires idle lines, and exports long lines then resets counters.
+func (o *Online) Timeout() {
+	if o.flow == nil {
+		return
+	}
+	o.lock.Lock()
+	now := time.Now().Unix()
+	for e := o.lineList.Front(); e != nil; {
+		next := e.Next()
+		line := e.Value.(*models.Line)
+		if now-line.HitTime >= int64(o.flow.Inactive) {
+			o.lineList.Remove(e)
+			store.Online.Del(line.String())
+			delete(o.lineMap, line.String())
+			o.expire(line, libol.FlowIdle)
+		} else if now-line.ExportAt >= int64(o.flow.Active) {
+			o.expire(line, libol.FlowActive)
+			line.Exported(now)
+		}
+		e = next
+	}
+	expired := o.expired
+	o.expired = nil
+	o.lock.Unlock()
+	if o.exporter != nil {
+		o.exporter.Export(expired)
+	}
+}
+
+func (o *Online) Start() {
+	if o.flow == nil {
+		return
+	}
+	libol.Info("Online.Start: export %s to %v", o.flow.Protocol, o.flow.Collectors)
+	o.done = make(chan bool, 2)
+	libol.Go(func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-o.done:
+				return
+			case <-ticker.C:
+				o.Timeout()
+			}
+		}
+	})
+}
+
+// Stop exports all lines as ended.
+func (o *Online) Stop() {
+	if o.done == nil {
+		return
+	}
+	libol.Info("Online.Stop")
+	o.done <- true
+	o.done = nil
+	o.lock.Lock()
+	for e := o.lineList.Front(); e != nil; e = e.Next() {
+		o.expire(e.Value.(*models.Line), libol.FlowForced)
+	}
+	expired := o.expired
+	o.expired = nil
+	o.lock.Unlock()
+	if o.exporter != nil {
+		o.exporter.Export(expired)
+		o.exporter.Close()
 	}
 }
 
@@ -89,7 +186,6 @@ func (o *Online) AddLine(line *models.Line) {
 		o.lineMap[key] = line
 		store.Online.Add(line)
 	} else if find != nil {
-		find.HitTime = time.Now().Unix()
-		store.Online.Update(find)
+		find.Hit(line.Packets, line.Bytes, time.Now().Unix())
 	}
 }
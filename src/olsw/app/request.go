@@ -10,7 +10,8 @@ import (
 )
 
 type Request struct {
-	master Master
+	master    Master
+	Threshold *libol.HealthThreshold // to mark point degraded.
 }
 
 func NewRequest(m Master) *Request {
@@ -40,6 +41,8 @@ func (r *Request) OnFrame(client libol.SocketClient, frame *libol.FrameMessage)
 		r.onLeave(client, body)
 	case libol.LoginReq:
 		out.Debug("Request.OnFrame %s: %s", action, body)
+	case libol.PingReq:
+		r.onPing(client, body)
 	default:
 		r.onDefault(client, body)
 	}
@@ -51,6 +54,41 @@ func (r *Request) onDefault(client libol.SocketClient, data []byte) {
 	_ = client.WriteMsg(m)
 }
 
+// healthMsg is stats of link measured and reported by point in ping.
+type healthMsg struct {
+	Seq    uint64 `json:"seq"`
+	Rtt    int64  `json:"rtt"`
+	Jitter int64  `json:"jitter"`
+	Loss   int    `json:"loss"`
+}
+
+func (r *Request) onPing(client libol.SocketClient, data []byte) {
+	r.onDefault(client, data)
+	point, ok := client.Private().(*models.Point)
+	if !ok || point.Health == nil {
+		return
+	}
+	recv := &healthMsg{}
+	if err := json.Unmarshal(data, recv); err != nil || recv.Seq == 0 {
+		return
+	}
+	point.Health.Report(libol.HealthStats{
+		Rtt:    recv.Rtt,
+		Jitter: recv.Jitter,
+		Loss:   recv.Loss,
+	})
+	if point.Health.Check(r.Threshold) {
+		out := client.Out()
+		stats := point.Health.Stats()
+		if stats.Degraded {
+			out.Warn("Request.onPing: %s degraded rtt %dms jitter %dms loss %d%%",
+				point.UUID, stats.Rtt, stats.Jitter, stats.Loss)
+		} else {
+			out.Info("Request.onPing: %s recovered score %d", point.UUID, stats.Score)
+		}
+	}
+}
+
 func (r *Request) onNeighbor(client libol.SocketClient, data []byte) {
 	resp := make([]schema.Neighbor, 0, 32)
 	for obj := range store.Neighbor.List() {
@@ -93,6 +131,9 @@ func (r *Request) getLease(ifAddr string, p *models.Point, n *models.Network) *s
 		}
 	}
 	if lease != nil {
+		if lease.Type == store.PeerLease { // owned by this switch now.
+			lease.Type = ""
+		}
 		lease.Network = network
 		lease.Client = p.Client.String()
 	}
@@ -120,6 +161,9 @@ func (r *Request) onIpAddr(client libol.SocketClient, data []byte) {
 	}
 	out.Cmd("Request.onIpAddr: find %s", n)
 	p := store.Point.Get(client.String())
+	if p == nil { // maybe a connection of bonding.
+		p, _ = client.Private().(*models.Point)
+	}
 	if p == nil {
 		out.Error("Request.onIpAddr: point notFound")
 		return
@@ -0,0 +1,345 @@
+package olsw
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"github.com/danieldin95/openlan-go/src/config"
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/danieldin95/openlan-go/src/models"
+	"github.com/danieldin95/openlan-go/src/olsw/store"
+	"github.com/danieldin95/openlan-go/src/schema"
+	"github.com/vishvananda/netlink"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cluster pairs two switches for a same network, and they synchronize
+// leases, users and neighbors by a peer channel over http api.
+type Cluster struct {
+	lock   sync.RWMutex
+	cfg    *config.Cluster
+	alias  string
+	active bool
+	peer   *schema.Cluster
+	peerAt int64
+	done   chan bool
+	out    *libol.SubLogger
+}
+
+func NewCluster(alias string, c *config.Cluster) *Cluster {
+	return &Cluster{
+		cfg:   c,
+		alias: alias,
+		out:   libol.NewSubLogger(c.Network),
+	}
+}
+
+func (c *Cluster) Initialize() {
+	c.out.Info("Cluster.Initialize: %s with %s", c.cfg.Mode, c.cfg.Peer)
+	if !strings.HasPrefix(c.cfg.Peer, "https://") {
+		c.out.Error("Cluster.Initialize: peer %s is not https, and refused", c.cfg.Peer)
+	}
+}
+
+func (c *Cluster) isAlive() bool {
+	return c.peer != nil && time.Now().Unix()-c.peerAt <= int64(c.cfg.Timeout)
+}
+
+// State returns the local state with leases, users and neighbors.
+func (c *Cluster) State() *schema.Cluster {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	name := c.cfg.Network
+	obj := &schema.Cluster{
+		Switch:    c.alias,
+		Network:   name,
+		Mode:      c.cfg.Mode,
+		Priority:  c.cfg.Priority,
+		Active:    c.active,
+		Peer:      c.cfg.Peer,
+		PeerAlive: c.isAlive(),
+	}
+	for l := range store.Network.ListLease() {
+		if l == nil {
+			break
+		}
+		if l.Network != name || l.Type == store.PeerLease {
+			continue
+		}
+		obj.Leases = append(obj.Leases, *l)
+	}
+	for u := range store.User.List() {
+		if u == nil {
+			break
+		}
+		if u.Network != name || u.Backend != "" {
+			continue
+		}
+		// the secret of totp isn't exported, and a user with two-factor
+		// synchronized without it would skip the code on peer.
+		if u.OtpKey != "" {
+			continue
+		}
+		obj.Users = append(obj.Users, models.NewUserSchema(u))
+	}
+	for key, at := range store.User.ListDeleted() {
+		u := &models.User{Name: key}
+		u.Update()
+		if u.Network != name {
+			continue
+		}
+		obj.Deleted = append(obj.Deleted, schema.User{Name: u.Name, Network: u.Network, UpdateAt: at})
+	}
+	for n := range store.Neighbor.List() {
+		if n == nil {
+			break
+		}
+		if n.Network != name {
+			continue
+		}
+		obj.Neighbors = append(obj.Neighbors, models.NewNeighborSchema(n))
+	}
+	return obj
+}
+
+func (c *Cluster) mergeLease(peer *schema.Cluster) {
+	leases := make(map[string]bool, 32)
+	for _, l := range peer.Leases {
+		leases[l.UUID] = true
+		if store.Network.GetLease(l.UUID) != nil {
+			continue
+		}
+		if _, ok := store.Network.Addr.GetEx(l.Address); ok {
+			c.out.Warn("Cluster.mergeLease: %s already used", l.Address)
+			continue
+		}
+		if obj := store.Network.AddLease(l.UUID, l.Address); obj != nil {
+			obj.Alias = l.Alias
+			obj.Client = l.Client
+			obj.Network = l.Network
+			obj.Type = store.PeerLease
+		}
+	}
+	// release leases which already released by peer.
+	expired := make([]string, 0, 32)
+	for l := range store.Network.ListLease() {
+		if l == nil {
+			break
+		}
+		if l.Network != c.cfg.Network || l.Type != store.PeerLease {
+			continue
+		}
+		if _, ok := leases[l.UUID]; !ok {
+			expired = append(expired, l.UUID)
+		}
+	}
+	for _, uuid := range expired {
+		store.Network.DelLease(uuid)
+	}
+}
+
+// mergeUser takes the user updated lately by peer, and deletes the user
+// if its tombstone is newer.
+func (c *Cluster) mergeUser(peer *schema.Cluster) {
+	changed := false
+	deleted := store.User.ListDeleted()
+	for _, u := range peer.Users {
+		if u.Otp {
+			c.out.Warn("Cluster.mergeUser: %s@%s with otp not synchronized", u.Name, u.Network)
+			continue
+		}
+		obj := models.SchemaToUserModel(&u)
+		obj.UpdateAt = u.UpdateAt
+		if at, ok := deleted[obj.Id()]; ok && at >= obj.UpdateAt {
+			continue
+		}
+		if older := store.User.Get(obj.Id()); older != nil {
+			if older.Backend != "" || older.UpdateAt >= obj.UpdateAt {
+				continue
+			}
+			// bytes transferred are counted by itself.
+			obj.Used = older.Used
+		}
+		store.User.Set(obj)
+		changed = true
+	}
+	for _, u := range peer.Deleted {
+		key := u.Name + "@" + u.Network
+		older := store.User.Get(key)
+		if older == nil || older.Backend != "" || older.UpdateAt > u.UpdateAt {
+			continue
+		}
+		c.out.Info("Cluster.mergeUser: %s deleted by peer", key)
+		store.User.Del(key)
+		changed = true
+	}
+	if changed {
+		if err := store.User.Save(); err != nil {
+			c.out.Warn("Cluster.mergeUser: %s", err)
+		}
+	}
+}
+
+func (c *Cluster) mergeNeighbor(peer *schema.Cluster) {
+	now := time.Now().Unix()
+	for _, n := range peer.Neighbors {
+		if store.Neighbor.Get(n.IpAddr) != nil {
+			continue
+		}
+		hwAddr, err := net.ParseMAC(n.HwAddr)
+		if err != nil {
+			continue
+		}
+		store.Neighbor.Add(&models.Neighbor{
+			Network: n.Network,
+			Client:  n.Client,
+			HwAddr:  hwAddr,
+			IpAddr:  net.ParseIP(n.IpAddr),
+			NewTime: now,
+			HitTime: now,
+		})
+	}
+}
+
+// Sync merges the state from peer, and returns the local state.
+func (c *Cluster) Sync(peer *schema.Cluster) *schema.Cluster {
+	c.lock.Lock()
+	c.peer = peer
+	c.peerAt = time.Now().Unix()
+	c.lock.Unlock()
+
+	c.mergeLease(peer)
+	c.mergeUser(peer)
+	c.mergeNeighbor(peer)
+	c.elect()
+	return c.State()
+}
+
+func (c *Cluster) elect() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	active := true
+	if c.cfg.Mode == "active-standby" && c.isAlive() {
+		peer := c.peer
+		if peer.Priority > c.cfg.Priority {
+			active = false
+		} else if peer.Priority == c.cfg.Priority && peer.Switch < c.alias {
+			active = false
+		}
+	}
+	if active != c.active {
+		c.out.Info("Cluster.elect: active %t", active)
+		c.active = active
+		c.setAddress(active)
+	}
+}
+
+func (c *Cluster) setAddress(active bool) {
+	if c.cfg.Address == "" || c.cfg.Device == "" {
+		return
+	}
+	link, err := netlink.LinkByName(c.cfg.Device)
+	if err != nil {
+		c.out.Warn("Cluster.setAddress: %s", err)
+		return
+	}
+	addr, err := netlink.ParseAddr(c.cfg.Address)
+	if err != nil {
+		c.out.Warn("Cluster.setAddress: %s", err)
+		return
+	}
+	if active {
+		err = netlink.AddrAdd(link, addr)
+	} else {
+		err = netlink.AddrDel(link, addr)
+	}
+	if err != nil {
+		c.out.Warn("Cluster.setAddress: %s %s", c.cfg.Address, err)
+		return
+	}
+	c.out.Info("Cluster.setAddress: %s on %s %t", c.cfg.Address, c.cfg.Device, active)
+}
+
+// push sends the local state to peer, and merges the state replied.
+// The peer is verified by ca given, and hashes of password are never sent
+// in plaintext.
+func (c *Cluster) push() error {
+	if !strings.HasPrefix(c.cfg.Peer, "https://") {
+		return libol.NewErr("peer %s is not https", c.cfg.Peer)
+	}
+	data, err := json.Marshal(c.State())
+	if err != nil {
+		return err
+	}
+	client := &libol.HttpClient{
+		Method:  "POST",
+		Url:     c.cfg.Peer + "/api/cluster/" + c.cfg.Network,
+		Payload: bytes.NewReader(data),
+		Auth: libol.Auth{
+			Type:     "basic",
+			Username: c.cfg.Token,
+		},
+		TlsConfig: &tls.Config{
+			RootCAs: (&config.Cert{CaFile: c.cfg.CaFile}).GetCertPool(),
+		},
+	}
+	defer client.Close()
+	r, err := client.Do()
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return libol.NewErr("%s", r.Status)
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	peer := &schema.Cluster{}
+	if err := json.Unmarshal(body, peer); err != nil {
+		return err
+	}
+	c.Sync(peer)
+	return nil
+}
+
+func (c *Cluster) Start() {
+	c.out.Info("Cluster.Start")
+	c.done = make(chan bool, 2)
+	c.elect()
+	libol.Go(func() {
+		ticker := time.NewTicker(time.Duration(c.cfg.Interval) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.done:
+				return
+			case <-ticker.C:
+				if err := c.push(); err != nil {
+					c.out.Debug("Cluster.push: %s", err)
+				}
+				// peer maybe already timeout.
+				c.elect()
+			}
+		}
+	})
+}
+
+func (c *Cluster) Stop() {
+	c.out.Info("Cluster.Stop")
+	if c.done != nil {
+		c.done <- true
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.active {
+		c.active = false
+		c.setAddress(false)
+	}
+}
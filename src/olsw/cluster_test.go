@@ -0,0 +1,64 @@
+package olsw
+
+import (
+	"github.com/danieldin95/openlan-go/src/config"
+	"github.com/danieldin95/openlan-go/src/olsw/store"
+	"github.com/danieldin95/openlan-go/src/schema"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCluster_Sync(t *testing.T) {
+	cfg := &config.Cluster{Network: "ha"}
+	cfg.Correct()
+	c := NewCluster("sw-b", cfg)
+	c.elect()
+	assert.Equal(t, true, c.active, "active without peer.")
+
+	peer := &schema.Cluster{
+		Switch:   "sw-a",
+		Network:  "ha",
+		Priority: 100,
+		Leases: []schema.Lease{
+			{UUID: "p1", Alias: "p1", Address: "172.16.1.11", Network: "ha"},
+		},
+		Users: []schema.User{
+			{Name: "hi", Network: "ha", Password: "123", Role: "guest"},
+		},
+	}
+	state := c.Sync(peer)
+	assert.Equal(t, false, state.Active, "standby by alias.")
+	assert.Equal(t, 0, len(state.Leases), "not echo leases of peer.")
+	lease := store.Network.GetLease("p1")
+	assert.Equal(t, "172.16.1.11", lease.Address, "be the same.")
+	assert.Equal(t, store.PeerLease, lease.Type, "be the same.")
+	assert.NotNil(t, store.User.Get("hi@ha"), "user synced.")
+
+	// released by peer.
+	peer.Leases = nil
+	peer.Priority = 50
+	state = c.Sync(peer)
+	assert.Equal(t, true, state.Active, "active by priority.")
+	assert.Nil(t, store.Network.GetLease("p1"), "lease released.")
+
+	// updated lately by peer.
+	user := store.User.Get("hi@ha")
+	peer.Users[0].Role = "admin"
+	peer.Users[0].UpdateAt = user.UpdateAt + 1
+	c.Sync(peer)
+	assert.Equal(t, "admin", user.Role, "be updated.")
+	peer.Users[0].Role = "guest"
+	c.Sync(peer)
+	assert.Equal(t, "admin", user.Role, "not older.")
+
+	// deleted by peer.
+	peer.Users = nil
+	peer.Deleted = []schema.User{{Name: "hi", Network: "ha", UpdateAt: user.UpdateAt + 1}}
+	c.Sync(peer)
+	assert.Nil(t, store.User.Get("hi@ha"), "user deleted.")
+	peer.Deleted = nil
+	peer.Users = []schema.User{{Name: "hi", Network: "ha", Password: "123", UpdateAt: user.UpdateAt}}
+	state = c.Sync(peer)
+	assert.Nil(t, store.User.Get("hi@ha"), "not older than tombstone.")
+	assert.Equal(t, 1, len(state.Deleted), "be tombstone.")
+}
@@ -3,6 +3,7 @@ package ctrls
 import (
 	"github.com/danieldin95/openlan-go/src/libol"
 	"github.com/danieldin95/openlan-go/src/olctl/libctrl"
+	"github.com/danieldin95/openlan-go/src/olsw/store"
 	"time"
 )
 
@@ -20,9 +21,13 @@ func (cc *CtrlC) Handle() {
 		return
 	}
 	cc.Conn.Listener("point", &Point{cc: cc})
+	link := &Link{cc: cc}
+	cc.Conn.Listener("link", link)
+	store.Link.Listen("ctrl", link)
 	cc.Conn.Listener("neighbor", &Neighbor{cc: cc})
 	cc.Conn.Listener("online", &OnLine{cc: cc})
 	cc.Conn.Listener("switch", &Switch{cc: cc})
+	cc.Conn.Listener("http", &Http{cc: cc})
 }
 
 func (cc *CtrlC) Open() error {
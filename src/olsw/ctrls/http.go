@@ -0,0 +1,93 @@
+package ctrls
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/danieldin95/openlan-go/src/olctl/libctrl"
+	"github.com/danieldin95/openlan-go/src/schema"
+	"net/http"
+)
+
+// Proxy serves the http request forwarded by controller
+// on the api of switch.
+type Proxy struct {
+	Handler http.Handler
+	Token   string
+}
+
+var Api = &Proxy{}
+
+type recorder struct {
+	code   int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	return r.body.Write(b)
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
+
+func (p *Proxy) Serve(req *schema.HttpRequest) *schema.HttpResponse {
+	resp := &schema.HttpResponse{
+		Id:   req.Id,
+		Code: http.StatusNotImplemented,
+	}
+	if p.Handler == nil {
+		return resp
+	}
+	r, err := http.NewRequest(req.Method, req.Url, bytes.NewReader(req.Body))
+	if err != nil {
+		resp.Code = http.StatusBadRequest
+		resp.Body = []byte(err.Error())
+		return resp
+	}
+	r.RemoteAddr = "controller"
+	r.Header.Set("Authorization", libol.BasicAuth(p.Token, ""))
+	w := &recorder{header: make(http.Header)}
+	p.Handler.ServeHTTP(w, r)
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	resp.Code = w.code
+	resp.Type = w.header.Get("Content-Type")
+	resp.Body = w.body.Bytes()
+	return resp
+}
+
+type Http struct {
+	libctrl.Listen
+	cc *CtrlC
+}
+
+func (h *Http) AddCtl(id string, m libctrl.Message) error {
+	req := &schema.HttpRequest{}
+	if err := json.Unmarshal([]byte(m.Data), req); err != nil {
+		return err
+	}
+	libol.Cmd("Http.AddCtl %s %s %s", id, req.Method, req.Url)
+	libol.Go(func() {
+		resp := Api.Serve(req)
+		if d, e := json.Marshal(resp); e == nil {
+			h.cc.Send(libctrl.Message{
+				Action:   "mod",
+				Resource: "http",
+				Data:     string(d),
+			})
+		}
+	})
+	return nil
+}
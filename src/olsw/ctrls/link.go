@@ -0,0 +1,49 @@
+package ctrls
+
+import (
+	"encoding/json"
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/danieldin95/openlan-go/src/models"
+	"github.com/danieldin95/openlan-go/src/olctl/libctrl"
+	"github.com/danieldin95/openlan-go/src/olsw/store"
+)
+
+type Link struct {
+	libctrl.Listen
+	cc *CtrlC
+}
+
+func (p *Link) Add(key string, value interface{}) {
+	libol.Cmd("Link.Add %s", key)
+	if value == nil {
+		return
+	}
+	if obj, ok := value.(*models.Point); ok {
+		if d, e := json.Marshal(models.NewLinkSchema(obj)); e == nil {
+			p.cc.Send(libctrl.Message{
+				Action:   "add",
+				Resource: "link",
+				Data:     string(d),
+			})
+		}
+	}
+}
+
+func (p *Link) Del(key string) {
+	libol.Cmd("Link.Del %s", key)
+	p.cc.Send(libctrl.Message{
+		Action:   "del",
+		Resource: "link",
+		Data:     key,
+	})
+}
+
+func (p *Link) GetCtl(id string, m libctrl.Message) error {
+	for u := range store.Link.List() {
+		if u == nil {
+			break
+		}
+		p.Add(u.UUID, u)
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/danieldin95/openlan-go/src/libol"
 	"github.com/danieldin95/openlan-go/src/models"
 	"github.com/danieldin95/openlan-go/src/olsw/api"
+	"github.com/danieldin95/openlan-go/src/olsw/ctrls"
 	"github.com/danieldin95/openlan-go/src/olsw/store"
 	"github.com/danieldin95/openlan-go/src/schema"
 	"github.com/gorilla/mux"
@@ -55,7 +56,7 @@ func (h *Http) Initialize() {
 			Addr:         h.listen,
 			Handler:      r,
 			ReadTimeout:  5 * time.Minute,
-			WriteTimeout: 10 * time.Minute,
+			WriteTimeout: (libol.CaptureMaxTime + 60) * time.Second,
 		}
 	}
 	if h.adminToken == "" {
@@ -67,6 +68,9 @@ func (h *Http) Initialize() {
 
 	_ = h.SaveToken()
 	h.LoadRouter()
+	// controller calls api by proxy.
+	ctrls.Api.Handler = r
+	ctrls.Api.Token = h.adminToken
 }
 
 func (h *Http) PProf(r *mux.Router) {
@@ -142,6 +146,9 @@ func (h *Http) LoadRouter() {
 	api.Device{}.Router(router)
 	api.VPNClient{}.Router(router)
 	api.PProf{}.Router(router)
+	api.Cluster{Switcher: h.switcher}.Router(router)
+	api.LDAP{}.Router(router)
+	api.Capture{}.Router(router)
 }
 
 func (h *Http) LoadToken() error {
@@ -31,6 +31,7 @@ type OpenLANWorker struct {
 	bridge    network.Bridger
 	out       *libol.SubLogger
 	openVPN   []*OpenVPN
+	cluster   *Cluster
 }
 
 func NewOpenLANWorker(c *config.Network) *OpenLANWorker {
@@ -89,6 +90,9 @@ func (w *OpenLANWorker) Initialize() {
 		}
 	}
 	w.bridge = network.NewBridger(brCfg.Provider, brCfg.Name, brCfg.IfMtu)
+	if br, ok := w.bridge.(*network.OvsBridge); ok {
+		br.SetRemote(brCfg.Ovsdb, brCfg.OvsCtl)
+	}
 	vCfg := w.cfg.OpenVPN
 	if vCfg != nil {
 		obj := NewOpenVPN(vCfg)
@@ -103,6 +107,10 @@ func (w *OpenLANWorker) Initialize() {
 			w.openVPN = append(w.openVPN, obj)
 		}
 	}
+	if w.cfg.Cluster != nil {
+		w.cluster = NewCluster(w.alias, w.cfg.Cluster)
+		w.cluster.Initialize()
+	}
 }
 
 func (w *OpenLANWorker) ID() string {
@@ -212,6 +220,14 @@ func (w *OpenLANWorker) UpBridge(cfg *config.Bridge) {
 	if err := master.Delay(cfg.Delay); err != nil {
 		w.out.Warn("OpenLANWorker.UpBridge: Delay %s", err)
 	}
+	w.upFlood(cfg)
+	// the bridge itself is in default vlan of points.
+	if cfg.Vlan != nil && master.Kernel() != "" {
+		vlan := network.NewPortVlan(cfg.Vlan)
+		if err := master.SetVlan(master.Kernel(), vlan); err != nil {
+			w.out.Warn("OpenLANWorker.UpBridge: SetVlan %s", err)
+		}
+	}
 	w.connectPeer(cfg)
 	call := 1
 	if w.cfg.Acl == "" {
@@ -222,6 +238,32 @@ func (w *OpenLANWorker) UpBridge(cfg *config.Bridge) {
 	}
 }
 
+// upFlood configures storm control, multicast snooping and arp proxy.
+func (w *OpenLANWorker) upFlood(cfg *config.Bridge) {
+	master := w.bridge
+	if cfg.Storm > 0 {
+		if err := master.SetStorm(cfg.Storm); err != nil {
+			w.out.Warn("OpenLANWorker.upFlood: Storm %s", err)
+		}
+	}
+	if cfg.Snooping {
+		if err := master.SetSnooping(true); err != nil {
+			w.out.Warn("OpenLANWorker.upFlood: Snooping %s", err)
+		}
+	}
+	if cfg.ArpProxy {
+		err := master.SetArpProxy(func(ip net.IP) net.HardwareAddr {
+			if n := store.Neighbor.Get(ip.String()); n != nil && n.Network == w.cfg.Name {
+				return n.HwAddr
+			}
+			return nil
+		})
+		if err != nil {
+			w.out.Warn("OpenLANWorker.upFlood: ArpProxy %s", err)
+		}
+	}
+}
+
 func (w *OpenLANWorker) connectPeer(cfg *config.Bridge) {
 	if cfg.Peer == "" {
 		return
@@ -268,6 +310,9 @@ func (w *OpenLANWorker) Start(v api.Switcher) {
 	for _, vpn := range w.openVPN {
 		vpn.Start()
 	}
+	if w.cluster != nil {
+		w.cluster.Start()
+	}
 	w.startTime = time.Now().Unix()
 }
 
@@ -294,6 +339,9 @@ func (w *OpenLANWorker) closePeer(cfg *config.Bridge) {
 
 func (w *OpenLANWorker) Stop() {
 	w.out.Info("OpenLANWorker.Close")
+	if w.cluster != nil {
+		w.cluster.Stop()
+	}
 	for _, vpn := range w.openVPN {
 		vpn.Stop()
 	}
@@ -362,3 +410,7 @@ func (w *OpenLANWorker) GetBridge() network.Bridger {
 func (w *OpenLANWorker) GetConfig() *config.Network {
 	return w.cfg
 }
+
+func (w *OpenLANWorker) GetCluster() *Cluster {
+	return w.cluster
+}
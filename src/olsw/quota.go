@@ -0,0 +1,99 @@
+package olsw
+
+import (
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/danieldin95/openlan-go/src/models"
+	"github.com/danieldin95/openlan-go/src/olsw/store"
+	"time"
+)
+
+// Quota accounts traffic of online points to their users, and kicks
+// points whose user is disabled, expired or out of quota.
+type Quota struct {
+	interval int64
+	saveAt   int64
+	done     chan bool
+	kick     func(client libol.SocketClient)
+	out      *libol.SubLogger
+}
+
+func NewQuota(kick func(client libol.SocketClient)) *Quota {
+	return &Quota{
+		interval: 10,
+		kick:     kick,
+		out:      libol.NewSubLogger("quota"),
+	}
+}
+
+func (q *Quota) Check() {
+	now := time.Now().Unix()
+	changed := false
+	kicks := make([]*models.Point, 0, 32)
+	for p := range store.Point.List() {
+		if p == nil {
+			break
+		}
+		user := store.User.Get(p.User + "@" + p.Network)
+		if user == nil || p.Client == nil {
+			continue
+		}
+		sts := p.Statistics()
+		total := sts[libol.CsRecvOkay] + sts[libol.CsSendOkay]
+		used := int64(0)
+		if total > p.Counted {
+			used = total - p.Counted
+			p.Counted = total
+			changed = true
+		}
+		if err := store.User.AddUsed(user, used); err != nil {
+			q.out.Info("Quota.Check: %s %s", user.Id(), err)
+			kicks = append(kicks, p)
+		}
+	}
+	for _, p := range kicks {
+		if p.Bond != nil {
+			for _, m := range p.Bond.Members() {
+				q.kick(m.Client)
+			}
+			continue
+		}
+		q.kick(p.Client)
+	}
+	// save used bytes not frequently.
+	if changed && now-q.saveAt >= 300 {
+		q.Save()
+	}
+}
+
+func (q *Quota) Save() {
+	q.saveAt = time.Now().Unix()
+	if err := store.User.Save(); err != nil {
+		q.out.Warn("Quota.Save: %s", err)
+	}
+}
+
+func (q *Quota) Start() {
+	q.out.Info("Quota.Start")
+	q.done = make(chan bool, 2)
+	q.saveAt = time.Now().Unix()
+	libol.Go(func() {
+		ticker := time.NewTicker(time.Duration(q.interval) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-q.done:
+				return
+			case <-ticker.C:
+				q.Check()
+			}
+		}
+	})
+}
+
+func (q *Quota) Stop() {
+	q.out.Info("Quota.Stop")
+	if q.done != nil {
+		q.done <- true
+	}
+	q.Save()
+}
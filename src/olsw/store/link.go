@@ -4,10 +4,37 @@ import (
 	"github.com/danieldin95/openlan-go/src/libol"
 	"github.com/danieldin95/openlan-go/src/models"
 	"github.com/danieldin95/openlan-go/src/olap"
+	"sync"
 )
 
+// Listener is called back after an object added or deleted.
+type Listener interface {
+	Add(key string, value interface{})
+	Del(key string)
+}
+
 type _link struct {
-	Links *libol.SafeStrMap
+	Links     *libol.SafeStrMap
+	lock      sync.RWMutex
+	listeners map[string]Listener
+}
+
+// Listen registers listener by name, and the older one is replaced.
+func (p *_link) Listen(name string, listener Listener) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.listeners == nil {
+		p.listeners = make(map[string]Listener, 4)
+	}
+	p.listeners[name] = listener
+}
+
+func (p *_link) notify(call func(l Listener)) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	for _, l := range p.listeners {
+		call(l)
+	}
 }
 
 func (p *_link) Init(size int) {
@@ -27,8 +54,12 @@ func (p *_link) Add(m *olap.Point) {
 		Device:   m.Device(),
 		IfName:   m.IfName(),
 		UUID:     m.UUID(),
+		Health:   m.Health(),
 	}
 	_ = p.Links.Set(m.UUID(), link)
+	p.notify(func(l Listener) {
+		l.Add(link.UUID, link)
+	})
 }
 
 func (p *_link) Get(key string) *models.Point {
@@ -42,7 +73,13 @@ func (p *_link) Get(key string) *models.Point {
 }
 
 func (p *_link) Del(key string) {
+	if _, ok := p.Links.GetEx(key); !ok {
+		return
+	}
 	p.Links.Del(key)
+	p.notify(func(l Listener) {
+		l.Del(key)
+	})
 }
 
 func (p *_link) List() <-chan *models.Point {
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"github.com/danieldin95/openlan-go/src/config"
+	"github.com/danieldin95/openlan-go/src/olap"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type linkEvents struct {
+	added   []string
+	deleted []string
+}
+
+func (e *linkEvents) Add(key string, value interface{}) {
+	e.added = append(e.added, key)
+}
+
+func (e *linkEvents) Del(key string) {
+	e.deleted = append(e.deleted, key)
+}
+
+func TestLink_Listen(t *testing.T) {
+	events := &linkEvents{}
+	Link.Listen("test", events)
+	c := &config.Point{
+		Network:    "example",
+		Connection: "1.1.1.1",
+		Queue:      &config.Queue{},
+	}
+	c.Correct(nil)
+	p := olap.NewPoint(c)
+	p.Initialize()
+	Link.Add(p)
+	assert.Equal(t, []string{p.UUID()}, events.added, "be added.")
+	Link.Del(p.UUID())
+	Link.Del(p.UUID())
+	assert.Equal(t, []string{p.UUID()}, events.deleted, "be deleted once.")
+}
@@ -8,6 +8,11 @@ import (
 	"net"
 )
 
+const (
+	// lease synchronized from peer switch of cluster.
+	PeerLease = "peer"
+)
+
 type _network struct {
 	Networks *libol.SafeStrMap
 	UUID     *libol.SafeStrMap // TODO with network
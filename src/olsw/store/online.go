@@ -17,17 +17,10 @@ func (p *_online) Add(m *models.Line) {
 	_ = p.Lines.Set(m.String(), m)
 }
 
-func (p *_online) Update(m *models.Line) *models.Line {
-	if v := p.Lines.Get(m.String()); v != nil {
-		l := v.(*models.Line)
-		l.HitTime = m.HitTime
-	}
-	return nil
-}
-
+// Get returns a copy of line.
 func (p *_online) Get(key string) *models.Line {
 	if v := p.Lines.Get(key); v != nil {
-		return v.(*models.Line)
+		return v.(*models.Line).Copy()
 	}
 	return nil
 }
@@ -42,7 +35,7 @@ func (p *_online) List() <-chan *models.Line {
 	go func() {
 
 		p.Lines.Iter(func(k string, v interface{}) {
-			c <- v.(*models.Line)
+			c <- v.(*models.Line).Copy()
 		})
 		c <- nil //Finish channel by nil.
 	}()
@@ -21,6 +21,15 @@ func (p *_point) Add(m *models.Point) {
 	_ = p.Clients.Set(m.Client.String(), m)
 }
 
+// Promote replaces client of point by another connection of bonding.
+func (p *_point) Promote(m *models.Point, client libol.SocketClient) {
+	addr := m.Client.String()
+	p.AddrUUID.Del(addr)
+	p.Clients.Del(addr)
+	m.Client = client
+	p.Add(m)
+}
+
 func (p *_point) Get(addr string) *models.Point {
 	if v := p.Clients.Get(addr); v != nil {
 		m := v.(*models.Point)
@@ -3,6 +3,10 @@ package store
 import (
 	"github.com/danieldin95/openlan-go/src/libol"
 	"github.com/danieldin95/openlan-go/src/models"
+	"github.com/danieldin95/openlan-go/src/network"
+	"github.com/danieldin95/openlan-go/src/schema"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -11,8 +15,103 @@ type _user struct {
 	Lock    sync.RWMutex
 	File    string
 	Users   *libol.SafeStrMap
+	Deleted map[string]int64 // tombstones of users deleted at.
 	LdapCfg *libol.LDAPConfig
 	LdapSvc *libol.LDAPService
+	Radius  *libol.RadiusService
+	Policy  *libol.PassPolicy
+}
+
+// UserToLine formats user to a line likes
+// name@network:password:role:passExpire:notBefore:notAfter:disabled:maxSession:quota:used:otpKey:recovery:vlan:promisc:security:otpStep:updateAt,
+// and the trailing zero or empty columns are omitted.
+func UserToLine(obj *models.User) string {
+	disabled := "0"
+	if obj.Disabled {
+		disabled = "1"
+	}
+	promisc := "0"
+	if obj.Promisc {
+		promisc = "1"
+	}
+	columns := []string{
+		obj.Id(),
+		obj.Password,
+		obj.Role,
+		strconv.FormatInt(obj.PassExpire, 10),
+		strconv.FormatInt(obj.NotBefore, 10),
+		strconv.FormatInt(obj.NotAfter, 10),
+		disabled,
+		strconv.Itoa(obj.MaxSession),
+		strconv.FormatInt(obj.Quota, 10),
+		strconv.FormatInt(obj.Used, 10),
+		obj.OtpKey,
+		strings.Join(obj.Recovery, ","),
+		obj.Vlan.String(),
+		promisc,
+		obj.Security.String(),
+		strconv.FormatInt(obj.OtpStep, 10),
+		strconv.FormatInt(obj.UpdateAt, 10),
+	}
+	size := len(columns)
+	for size > 3 && (columns[size-1] == "0" || columns[size-1] == "") {
+		size--
+	}
+	return strings.Join(columns[:size], ":")
+}
+
+// LineToUser parses user from line formatted by UserToLine.
+func LineToUser(line string) *models.User {
+	columns := strings.SplitN(line, ":", 17)
+	if len(columns) < 2 {
+		return nil
+	}
+	user := &models.User{
+		Name:     columns[0],
+		Password: columns[1],
+		Role:     "guest",
+	}
+	if len(columns) > 2 {
+		user.Role = columns[2]
+	}
+	values := make([]int64, 7)
+	for i := range values {
+		if len(columns) > i+3 {
+			values[i], _ = strconv.ParseInt(columns[i+3], 10, 64)
+		}
+	}
+	user.PassExpire = values[0]
+	user.NotBefore = values[1]
+	user.NotAfter = values[2]
+	user.Disabled = values[3] != 0
+	user.MaxSession = int(values[4])
+	user.Quota = values[5]
+	user.Used = values[6]
+	if len(columns) > 10 {
+		user.OtpKey = columns[10]
+	}
+	if len(columns) > 11 && columns[11] != "" {
+		user.Recovery = strings.Split(columns[11], ",")
+	}
+	if len(columns) > 12 {
+		user.Vlan = network.ParseVlan(columns[12])
+	}
+	if len(columns) > 13 {
+		user.Promisc = columns[13] == "1"
+	}
+	if len(columns) > 14 {
+		user.Security = network.ParseSecurity(columns[14])
+	}
+	if len(columns) > 15 {
+		user.OtpStep, _ = strconv.ParseInt(columns[15], 10, 64)
+	}
+	user.Update()
+	// zero is older than any update.
+	user.UpdateAt = 0
+	if len(columns) > 16 {
+		user.UpdateAt, _ = strconv.ParseInt(columns[16], 10, 64)
+	}
+	return user
 }
 
 func (w *_user) Save() error {
@@ -23,14 +122,17 @@ func (w *_user) Save() error {
 	if err != nil {
 		return err
 	}
+	defer fp.Close()
 	for obj := range w.List() {
 		if obj == nil {
 			break
 		}
-		if obj.Role == "ldap" {
+		if obj.Backend != "" {
 			continue
 		}
-		line := obj.Id() + ":" + obj.Password + ":" + obj.Role
+		w.Lock.RLock()
+		line := UserToLine(obj)
+		w.Lock.RUnlock()
 		_, _ = fp.WriteString(line + "\n")
 	}
 	return nil
@@ -44,23 +146,118 @@ func (w *_user) Init(size int) {
 	w.Users = libol.NewSafeStrMap(size)
 }
 
+// Add adds a new user, or updates password and role of the existing
+// one, and its other attributes are kept.
 func (w *_user) Add(user *models.User) {
 	libol.Debug("_user.Add %v", user)
+	if !libol.IsHashed(user.Password) {
+		user.Password = libol.HashPassword(user.Password)
+	}
 	key := user.Id()
 	older := w.Get(key)
 	if older == nil {
+		w.undelete(key)
 		_ = w.Users.Set(key, user)
 	} else { // Update pass and role.
+		w.Lock.Lock()
 		older.Role = user.Role
 		older.Password = user.Password
 		older.Alias = user.Alias
 		older.UpdateAt = user.UpdateAt
+		older.PassExpire = user.PassExpire
+		w.Lock.Unlock()
+	}
+}
+
+// Set adds a new user, or replaces all attributes of the existing one,
+// and the secret of totp is kept if not given.
+func (w *_user) Set(user *models.User) {
+	libol.Debug("_user.Set %v", user)
+	if !libol.IsHashed(user.Password) {
+		user.Password = libol.HashPassword(user.Password)
+	}
+	key := user.Id()
+	older := w.Get(key)
+	if older == nil {
+		w.undelete(key)
+		_ = w.Users.Set(key, user)
+		return
+	}
+	w.Lock.Lock()
+	defer w.Lock.Unlock()
+	older.Role = user.Role
+	older.Password = user.Password
+	older.Alias = user.Alias
+	older.UpdateAt = user.UpdateAt
+	older.PassExpire = user.PassExpire
+	older.NotBefore = user.NotBefore
+	older.NotAfter = user.NotAfter
+	older.Disabled = user.Disabled
+	older.MaxSession = user.MaxSession
+	older.Quota = user.Quota
+	older.Used = user.Used
+	older.Vlan = user.Vlan
+	older.Promisc = user.Promisc
+	older.Security = user.Security
+	if user.OtpKey != "" {
+		older.OtpKey = user.OtpKey
+		older.OtpStep = user.OtpStep
+		older.Recovery = user.Recovery
 	}
 }
 
+// Sticky binds address learned by sticky security to user, and saves it
+// asynchronously.
+func (w *_user) Sticky(key, addr string) error {
+	user := w.Get(key)
+	if user == nil || user.Security == nil {
+		return libol.NewErr("%s notFound", key)
+	}
+	w.Lock.Lock()
+	if !user.Security.Has(addr) {
+		user.Security.Macs = append(user.Security.Macs, addr)
+	}
+	w.Lock.Unlock()
+	w.saveAsync("_user.Sticky")
+	return nil
+}
+
+// Del deletes user, and keeps its tombstone to synchronize with peer.
 func (w *_user) Del(key string) {
-	libol.Debug("_user.Add %s", key)
+	libol.Debug("_user.Del %s", key)
+	if w.Get(key) == nil {
+		return
+	}
 	w.Users.Del(key)
+	w.Lock.Lock()
+	defer w.Lock.Unlock()
+	if w.Deleted == nil {
+		w.Deleted = make(map[string]int64, 32)
+	}
+	now := time.Now().Unix()
+	w.Deleted[key] = now
+	for k, at := range w.Deleted {
+		if now-at > UserTombstone {
+			delete(w.Deleted, k)
+		}
+	}
+}
+
+func (w *_user) undelete(key string) {
+	w.Lock.Lock()
+	defer w.Lock.Unlock()
+	delete(w.Deleted, key)
+}
+
+// ListDeleted returns tombstones of users deleted.
+func (w *_user) ListDeleted() map[string]int64 {
+	w.Lock.RLock()
+	defer w.Lock.RUnlock()
+	deleted := make(map[string]int64, len(w.Deleted))
+	for k, at := range w.Deleted {
+		deleted[k] = at
+	}
+	return deleted
 }
 
 func (w *_user) Get(key string) *models.User {
@@ -90,17 +287,67 @@ func (w *_user) CheckLdap(obj *models.User) *models.User {
 	}
 	u := w.Get(obj.Id())
 	libol.Debug("CheckLdap %s", u)
-	if u != nil && u.Role != "ldap" {
+	if u != nil && u.Backend != "ldap" {
 		return nil
 	}
-	if ok, err := svc.Login(obj.Id(), obj.Password); !ok {
+	entry, err := svc.Login(obj.Id(), obj.Password)
+	if err != nil {
 		libol.Warn("CheckLdap %s", err)
 		return nil
 	}
+	role, ok := svc.Cfg.Authorize(entry.Groups, obj.Network)
+	if !ok {
+		libol.Warn("CheckLdap %s not allowed by groups", obj.Id())
+		return nil
+	}
+	if role == "" {
+		role = "guest"
+	}
+	user := &models.User{
+		Name:     obj.Id(),
+		Password: obj.Password,
+		Role:     role,
+		Backend:  "ldap",
+		Alias:    obj.Alias,
+	}
+	user.Update()
+	w.Add(user)
+	return user
+}
+
+func (w *_user) CheckRadius(obj *models.User) *models.User {
+	svc := w.GetRadius()
+	if svc == nil {
+		return nil
+	}
+	u := w.Get(obj.Id())
+	if u != nil && u.Backend != "radius" {
+		return nil
+	}
+	password, state := obj.Password, []byte(nil)
+	if obj.State != nil && obj.Code != "" {
+		// responds the challenge by code.
+		password, state = obj.Code, obj.State
+	}
+	result, err := svc.Login(obj.Id(), password, state)
+	if challenge, ok := err.(*libol.RadiusChallenge); ok {
+		libol.Info("CheckRadius %s challenged", obj.Id())
+		obj.Challenge = challenge
+		return nil
+	}
+	if err != nil {
+		libol.Warn("CheckRadius %s: %s", obj.Id(), err)
+		return nil
+	}
+	role := "guest"
+	if result.Role == "admin" {
+		role = "admin"
+	}
 	user := &models.User{
 		Name:     obj.Id(),
 		Password: obj.Password,
-		Role:     "ldap",
+		Role:     role,
+		Backend:  "radius",
 		Alias:    obj.Alias,
 	}
 	user.Update()
@@ -109,25 +356,177 @@ func (w *_user) CheckLdap(obj *models.User) *models.User {
 }
 
 func (w *_user) Timeout(user *models.User) bool {
-	if user.Role == "ldap" {
+	if user.Backend == "ldap" {
 		return time.Now().Unix()-user.UpdateAt > w.LdapCfg.Timeout
 	}
 	return true
 }
 
+// Check authenticates user by password, ldap or radius, and the stored
+// entry is checked whether allowed for every backend.
 func (w *_user) Check(obj *models.User) *models.User {
+	u := w.check(obj)
+	if u == nil {
+		return nil
+	}
+	if older := w.Get(u.Id()); older != nil {
+		u = older
+	}
+	if err := w.Allowed(u); err != nil {
+		libol.Warn("_user.Check %s %s", u.Id(), err)
+		return nil
+	}
+	return u
+}
+
+func (w *_user) check(obj *models.User) *models.User {
 	if u := w.Get(obj.Id()); u != nil {
-		if u.Role == "ldap" {
-			// check it by ldap.
+		if u.Backend != "" {
+			// check it by ldap or radius.
 		} else {
-			if u.Password == obj.Password {
-				return u
+			password, code := libol.SplitChallenge(obj.Password)
+			if obj.Code != "" {
+				code = obj.Code
+			}
+			if !libol.CheckPassword(u.Password, password) {
+				// maybe code is appended to password.
+				size := len(password) - libol.TotpDigits
+				if u.OtpKey == "" || code != "" || size <= 0 {
+					return nil
+				}
+				password, code = password[:size], password[size:]
+				if !libol.CheckPassword(u.Password, password) {
+					return nil
+				}
 			}
+			if u.OtpKey != "" && !w.CheckCode(u, code) {
+				libol.Warn("_user.Check %s invalid code", u.Id())
+				return nil
+			}
+			if u.PassExpire > 0 && time.Now().Unix() > u.PassExpire {
+				libol.Warn("_user.Check %s password expired", u.Id())
+				return nil
+			}
+			return u
 		}
 	}
 	if u := w.CheckLdap(obj); u != nil {
 		return u
 	}
+	if u := w.CheckRadius(obj); u != nil {
+		return u
+	}
+	return nil
+}
+
+// Allowed checks user under lock, as its used bytes is updated by quota.
+func (w *_user) Allowed(user *models.User) error {
+	w.Lock.RLock()
+	defer w.Lock.RUnlock()
+	return user.Allowed(time.Now().Unix())
+}
+
+// AddUsed accounts bytes transferred to user, and returns error if it's
+// not allowed anymore.
+func (w *_user) AddUsed(user *models.User, bytes int64) error {
+	w.Lock.Lock()
+	defer w.Lock.Unlock()
+	user.Used += bytes
+	return user.Allowed(time.Now().Unix())
+}
+
+// CheckCode verifies totp code of user which is not reused, or a
+// recovery code which is removed after used. The step accepted is
+// saved, so a used code can't be replayed after restart.
+func (w *_user) CheckCode(user *models.User, code string) bool {
+	if code == "" {
+		return false
+	}
+	w.Lock.Lock()
+	defer w.Lock.Unlock()
+	if step := libol.CheckTotp(user.OtpKey, code, time.Now().Unix()); step > 0 {
+		if step <= user.OtpStep {
+			return false
+		}
+		user.OtpStep = step
+		w.saveAsync("_user.CheckCode")
+		return true
+	}
+	hash := libol.HashRecovery(code)
+	for i, value := range user.Recovery {
+		if value != hash {
+			continue
+		}
+		user.Recovery = append(user.Recovery[:i:i], user.Recovery[i+1:]...)
+		libol.Info("_user.CheckCode %s recovery used, %d left", user.Id(), len(user.Recovery))
+		w.saveAsync("_user.CheckCode")
+		return true
+	}
+	return false
+}
+
+func (w *_user) saveAsync(caller string) {
+	libol.Go(func() {
+		if err := w.Save(); err != nil {
+			libol.Warn("%s %s", caller, err)
+		}
+	})
+}
+
+// EnableOtp generates totp secret and recovery codes of user.
+func (w *_user) EnableOtp(user *models.User) *schema.UserOtp {
+	w.Lock.Lock()
+	defer w.Lock.Unlock()
+	codes := libol.GenRecovery(8)
+	user.OtpKey = libol.GenTotpKey()
+	user.OtpStep = 0
+	user.UpdateAt = time.Now().Unix()
+	user.Recovery = make([]string, 0, len(codes))
+	for _, code := range codes {
+		user.Recovery = append(user.Recovery, libol.HashRecovery(code))
+	}
+	return &schema.UserOtp{
+		Name:     user.Id(),
+		Secret:   user.OtpKey,
+		Uri:      libol.TotpUri("OpenLAN", user.Id(), user.OtpKey),
+		Recovery: codes,
+	}
+}
+
+func (w *_user) DisableOtp(user *models.User) {
+	w.Lock.Lock()
+	defer w.Lock.Unlock()
+	user.OtpKey = ""
+	user.OtpStep = 0
+	user.Recovery = nil
+	user.UpdateAt = time.Now().Unix()
+}
+
+func (w *_user) SetPolicy(policy *libol.PassPolicy) {
+	w.Lock.Lock()
+	defer w.Lock.Unlock()
+	w.Policy = policy
+}
+
+// CheckPolicy checks plain password of user by policy, and set its
+// expired time if not given. The hashed password is only loaded from
+// file, and it's rejected by api as it can't be checked.
+func (w *_user) CheckPolicy(user *models.User) error {
+	if libol.IsHashed(user.Password) {
+		return libol.NewErr("hashed password notAllowed")
+	}
+	w.Lock.RLock()
+	defer w.Lock.RUnlock()
+	if w.Policy == nil {
+		return nil
+	}
+	if err := w.Policy.Check(user.Password); err != nil {
+		return err
+	}
+	if user.PassExpire == 0 && w.Policy.MaxAge > 0 {
+		age := int64(w.Policy.MaxAge) * 86400
+		user.PassExpire = time.Now().Unix() + age
+	}
 	return nil
 }
 
@@ -137,7 +536,7 @@ func (w *_user) GetLdap() *libol.LDAPService {
 	if w.LdapCfg == nil {
 		return nil
 	}
-	if w.LdapSvc == nil || w.LdapSvc.Conn.IsClosing() {
+	if w.LdapSvc == nil { // connections are kept alive by pool.
 		if l, err := libol.NewLDAPService(*w.LdapCfg); err != nil {
 			libol.Warn("_user.GetLdap %s", err)
 			w.LdapSvc = nil
@@ -158,10 +557,29 @@ func (w *_user) SetLdap(cfg *libol.LDAPConfig) {
 		libol.Warn("_user.SetLdap %s", err)
 	} else {
 		libol.Info("_user.SetLdap %s", w.LdapCfg.Server)
+		if w.LdapSvc != nil {
+			w.LdapSvc.Close()
+		}
 		w.LdapSvc = l
 	}
 }
 
+func (w *_user) GetRadius() *libol.RadiusService {
+	w.Lock.RLock()
+	defer w.Lock.RUnlock()
+	return w.Radius
+}
+
+func (w *_user) SetRadius(cfg *libol.RadiusConfig) {
+	w.Lock.Lock()
+	defer w.Lock.Unlock()
+	libol.Info("_user.SetRadius %s", cfg.Servers)
+	w.Radius = libol.NewRadiusService(*cfg)
+}
+
+// UserTombstone is seconds to keep a user deleted.
+var UserTombstone = int64(7 * 86400)
+
 var User = _user{
 	Users: libol.NewSafeStrMap(1024),
 }
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/danieldin95/openlan-go/src/models"
+	"github.com/danieldin95/openlan-go/src/network"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUser_Line(t *testing.T) {
+	user := LineToUser("hi@example:123")
+	assert.Equal(t, "hi", user.Name, "be the same.")
+	assert.Equal(t, "example", user.Network, "be the same.")
+	assert.Equal(t, "guest", user.Role, "be the same.")
+	assert.Equal(t, "hi@example:123:guest", UserToLine(user), "be the same.")
+
+	user.NotAfter = 1600000000
+	user.Quota = 1024
+	line := UserToLine(user)
+	assert.Equal(t, "hi@example:123:guest:0:0:1600000000:0:0:1024", line, "be the same.")
+	assert.Equal(t, user.NotAfter, LineToUser(line).NotAfter, "be the same.")
+	assert.Equal(t, user.Quota, LineToUser(line).Quota, "be the same.")
+	assert.Nil(t, LineToUser("hi"), "be nil.")
+
+	user.Vlan = network.ParseVlan("trunk/1/10,20")
+	line = UserToLine(user)
+	assert.Equal(t, "hi@example:123:guest:0:0:1600000000:0:0:1024:0:::trunk/1/10,20", line, "be the same.")
+	assert.Equal(t, user.Vlan, LineToUser(line).Vlan, "be the same.")
+	user.Promisc = true
+	assert.True(t, LineToUser(UserToLine(user)).Promisc, "be promiscuous.")
+	user.Security = network.ParseSecurity("1/disconnect/sticky/00:16:3e:00:00:01")
+	line = UserToLine(user)
+	assert.True(t, strings.HasSuffix(line, ":1:1/disconnect/sticky/00-16-3e-00-00-01"), "be the same.")
+	assert.Equal(t, user.Security, LineToUser(line).Security, "be the same.")
+}
+
+func TestUser_Allowed(t *testing.T) {
+	now := time.Now().Unix()
+	user := &models.User{Name: "hi", Network: "example"}
+	assert.Nil(t, user.Allowed(now), "be allowed.")
+	user.NotAfter = now - 1
+	assert.NotNil(t, user.Allowed(now), "be expired.")
+	user.NotAfter = 0
+	user.Quota = 1024
+	user.Used = 1024
+	assert.NotNil(t, user.Allowed(now), "be out of quota.")
+	user.Used = 0
+	user.Disabled = true
+	assert.NotNil(t, user.Allowed(now), "be disabled.")
+}
+
+func TestUser_AddUsed(t *testing.T) {
+	user := &models.User{Name: "used", Network: "example", Quota: 1024}
+	assert.Nil(t, User.AddUsed(user, 1000), "be allowed.")
+	assert.NotNil(t, User.AddUsed(user, 24), "be out of quota.")
+	assert.Equal(t, int64(1024), user.Used, "be the same.")
+}
+
+func TestUser_CheckOtp(t *testing.T) {
+	User.Add(&models.User{Name: "otp", Network: "example", Password: "123"})
+	user := User.Get("otp@example")
+	otp := User.EnableOtp(user)
+	defer User.Del("otp@example")
+
+	line := UserToLine(user)
+	assert.Equal(t, otp.Secret, LineToUser(line).OtpKey, "be the same.")
+	assert.Equal(t, 8, len(LineToUser(line).Recovery), "be the same.")
+
+	login := &models.User{Name: "otp", Network: "example", Password: "123"}
+	assert.Nil(t, User.Check(login), "need code.")
+	code := libol.Totp(otp.Secret, time.Now().Unix()/libol.TotpPeriod)
+	login.Code = code
+	assert.NotNil(t, User.Check(login), "with code.")
+	assert.Nil(t, User.Check(login), "code reused.")
+
+	user.OtpStep = 0
+	login.Code = ""
+	login.Password = "123" + code
+	assert.NotNil(t, User.Check(login), "code appended.")
+
+	login.Password = "123"
+	login.Code = otp.Recovery[0]
+	assert.NotNil(t, User.Check(login), "by recovery.")
+	assert.Nil(t, User.Check(login), "recovery reused.")
+}
+
+func TestUser_CheckPolicy(t *testing.T) {
+	user := &models.User{Name: "hi", Network: "example", Password: libol.HashPassword("hi:123")}
+	assert.NotNil(t, User.CheckPolicy(user), "hashed not allowed.")
+	user.Password = "Hi:123456"
+	assert.Nil(t, User.CheckPolicy(user), "be allowed.")
+	User.SetPolicy(&libol.PassPolicy{MinLength: 8, Classes: 3, MaxAge: 1})
+	defer User.SetPolicy(nil)
+	user.Password = "hi123"
+	assert.NotNil(t, User.CheckPolicy(user), "be too short.")
+	user.Password = "Hi:123456"
+	assert.Nil(t, User.CheckPolicy(user), "be allowed.")
+	assert.NotEqual(t, int64(0), user.PassExpire, "be expired later.")
+}
+
+func TestUser_AddSet(t *testing.T) {
+	User.Add(&models.User{Name: "add", Network: "example", Password: "123", Quota: 1024, Disabled: true})
+	defer User.Del("add@example")
+	user := User.Get("add@example")
+	User.Add(&models.User{Name: "add", Network: "example", Password: "456", Role: "admin"})
+	assert.Equal(t, "admin", user.Role, "be the same.")
+	assert.True(t, libol.CheckPassword(user.Password, "456"), "password changed.")
+	assert.Equal(t, int64(1024), user.Quota, "be kept.")
+	assert.True(t, user.Disabled, "be kept.")
+	User.Set(&models.User{Name: "add", Network: "example", Password: user.Password, Role: "admin"})
+	assert.Equal(t, int64(0), user.Quota, "be replaced.")
+	assert.False(t, user.Disabled, "be replaced.")
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/danieldin95/openlan-go/src/libol"
 	"github.com/danieldin95/openlan-go/src/models"
 	"github.com/danieldin95/openlan-go/src/network"
+	"github.com/danieldin95/openlan-go/src/olsw/api"
 	"github.com/danieldin95/openlan-go/src/olsw/app"
 	"github.com/danieldin95/openlan-go/src/olsw/ctrls"
 	"github.com/danieldin95/openlan-go/src/olsw/store"
@@ -16,6 +17,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -63,6 +65,16 @@ func GetSocketServer(s *config.Switch) libol.SocketServer {
 			}
 		}
 		return libol.NewWebServer(s.Listen, c)
+	case "quic":
+		c := &libol.QuicConfig{
+			Timeout: time.Duration(s.Timeout) * time.Second,
+			RdQus:   s.Queue.SockRd,
+			WrQus:   s.Queue.SockWr,
+		}
+		if s.Cert != nil {
+			c.Tls = s.Cert.GetTlsCfg()
+		}
+		return libol.NewQuicServer(s.Listen, c)
 	default:
 		c := &libol.TcpConfig{
 			Block:   config.GetBlock(s.Crypt),
@@ -82,6 +94,7 @@ type Apps struct {
 	Request  *app.Request
 	Neighbor *app.Neighbors
 	OnLines  *app.Online
+	Capture  *app.Capture
 }
 
 type Hook func(client libol.SocketClient, frame *libol.FrameMessage) error
@@ -92,7 +105,9 @@ type Switch struct {
 	cfg      *config.Switch
 	apps     Apps
 	firewall *FireWall
+	quota    *Quota
 	hooks    []Hook
+	outputs  []Hook // of frames from tap to point.
 	http     *Http
 	server   libol.SocketServer
 	worker   map[string]Networker
@@ -112,6 +127,7 @@ func NewSwitch(c *config.Switch) *Switch {
 		hooks:    make([]Hook, 0, 64),
 		out:      libol.NewSubLogger(c.Alias),
 	}
+	v.quota = NewQuota(v.OffClient)
 	return &v
 }
 
@@ -303,7 +319,11 @@ func (v *Switch) preApplication() {
 	v.hooks = append(v.hooks, v.apps.Auth.OnFrame)
 	// Append request process
 	v.apps.Request = app.NewRequest(v)
+	v.apps.Request.Threshold = v.cfg.Health.Threshold()
 	v.hooks = append(v.hooks, v.apps.Request.OnFrame)
+	// Append capture of frames from points
+	v.apps.Capture = app.NewCapture(v)
+	v.hooks = append(v.hooks, v.apps.Capture.OnFrame)
 
 	inspect := ""
 	for _, v := range v.cfg.Inspect {
@@ -314,10 +334,12 @@ func (v *Switch) preApplication() {
 		v.apps.Neighbor = app.NewNeighbors(v)
 		v.hooks = append(v.hooks, v.apps.Neighbor.OnFrame)
 	}
-	// Check whether inspect online flow by five-tuple.
-	if strings.Contains(inspect, "online") {
+	// Check whether inspect online flow by five-tuple, and flows are
+	// inspected to export.
+	if strings.Contains(inspect, "online") || v.cfg.Flow != nil {
 		v.apps.OnLines = app.NewOnline(v)
 		v.hooks = append(v.hooks, v.apps.OnLines.OnFrame)
+		v.outputs = append(v.outputs, v.apps.OnLines.OnFrame)
 	}
 	for i, h := range v.hooks {
 		v.out.Debug("Switch.preApplication: id %d, func %s", i, libol.FunName(h))
@@ -412,10 +434,50 @@ func (v *Switch) SetLdap(ldap *config.LDAP) {
 		Attr:      ldap.Attribute,
 		Filter:    ldap.Filter,
 		EnableTls: ldap.EnableTls,
+		CaFile:    ldap.CaFile,
+		Insecure:  ldap.Insecure,
+		PoolSize:  ldap.PoolSize,
+
+		GroupBase:   ldap.GroupBase,
+		GroupFilter: ldap.GroupFilter,
+	}
+	for _, g := range ldap.Groups {
+		cfg.Groups = append(cfg.Groups, libol.LDAPGroup{
+			Name:     g.Name,
+			Networks: g.Networks,
+			Role:     g.Role,
+		})
 	}
 	store.User.SetLdap(&cfg)
 }
 
+func (v *Switch) SetRadius(radius *config.Radius) {
+	if radius == nil || len(radius.Servers) == 0 {
+		return
+	}
+	store.User.SetRadius(&libol.RadiusConfig{
+		Servers:     radius.Servers,
+		AcctServers: radius.AcctServers,
+		Secret:      radius.Secret,
+		Auth:        radius.Auth,
+		NasId:       radius.NasId,
+		Timeout:     radius.Timeout,
+		Retries:     radius.Retries,
+		Interim:     radius.Interim,
+	})
+}
+
+func (v *Switch) SetPolicy(policy *config.PassPolicy) {
+	if policy == nil {
+		return
+	}
+	store.User.SetPolicy(&libol.PassPolicy{
+		MinLength: policy.MinLength,
+		Classes:   policy.Classes,
+		MaxAge:    policy.MaxAge,
+	})
+}
+
 func (v *Switch) LoadPass(file string) {
 	if file == "" {
 		return
@@ -428,30 +490,27 @@ func (v *Switch) LoadPass(file string) {
 		return
 	}
 	defer reader.Close()
+	migrate := false
 	scanner := bufio.NewScanner(reader)
 	for scanner.Scan() {
-		line := scanner.Text()
-		columns := strings.SplitN(line, ":", 4)
-		if len(columns) < 2 {
+		userObj := store.LineToUser(scanner.Text())
+		if userObj == nil {
 			continue
 		}
-		user := columns[0]
-		pass := columns[1]
-		role := "guest"
-		if len(columns) > 2 {
-			role = columns[2]
+		if !libol.IsHashed(userObj.Password) {
+			migrate = true
 		}
-		userObj := &models.User{
-			Name:     user,
-			Password: pass,
-			Role:     role,
-		}
-		userObj.Update()
-		store.User.Add(userObj)
+		store.User.Set(userObj)
 	}
 	if err := scanner.Err(); err != nil {
 		v.out.Warn("Switch.LoadPass %v", err)
 	}
+	if migrate && file == store.User.File {
+		v.out.Info("Switch.LoadPass: hash plaintext passwords in %s", file)
+		if err := store.User.Save(); err != nil {
+			v.out.Warn("Switch.LoadPass %v", err)
+		}
+	}
 }
 
 func (v *Switch) Initialize() {
@@ -475,8 +534,10 @@ func (v *Switch) Initialize() {
 	}
 	// Load password for guest access
 
+	v.SetPolicy(v.cfg.Policy)
 	v.LoadPass(v.cfg.Password)
 	v.SetLdap(v.cfg.Ldap)
+	v.SetRadius(v.cfg.Radius)
 }
 
 func (v *Switch) onFrame(client libol.SocketClient, frame *libol.FrameMessage) error {
@@ -493,6 +554,15 @@ func (v *Switch) onFrame(client libol.SocketClient, frame *libol.FrameMessage) e
 	return nil
 }
 
+// OnOutput calls hooks of frame read from tap to point.
+func (v *Switch) OnOutput(client libol.SocketClient, frame *libol.FrameMessage) {
+	for _, h := range v.outputs {
+		if err := h(client, frame); err != nil {
+			v.out.Debug("Switch.OnOutput: %s", err)
+		}
+	}
+}
+
 func (v *Switch) OnClient(client libol.SocketClient) error {
 	client.SetStatus(libol.ClConnected)
 	v.out.Info("Switch.onClient: %s", client.String())
@@ -552,6 +622,7 @@ func (v *Switch) ReadClient(client libol.SocketClient, frame *libol.FrameMessage
 	if point == nil || device == nil {
 		return libol.NewErr("Tap devices is nil")
 	}
+	point.Clamp(frame.Frame(), client.Mtu())
 	if _, err := device.Write(frame.Frame()); err != nil {
 		v.out.Error("Switch.ReadClient: %s", err)
 		return err
@@ -562,11 +633,23 @@ func (v *Switch) ReadClient(client libol.SocketClient, frame *libol.FrameMessage
 func (v *Switch) OnClose(client libol.SocketClient) error {
 	addr := client.RemoteAddr()
 	v.out.Info("Switch.OnClose: %s", addr)
+	if m, ok := client.Private().(*models.Point); ok && m.Bond != nil {
+		// keep point if other connections of bonding remained.
+		if m.Bond.Del(client) > 0 {
+			if m.Client == client {
+				store.Point.Promote(m, m.Bond.Members()[0].Client)
+			}
+			return nil
+		}
+	}
 	// already not need support free list for device.
 	uuid := store.Point.GetUUID(addr)
 	if store.Point.GetAddr(uuid) == addr { // not has newer
 		store.Network.DelLease(uuid)
 	}
+	if v.apps.Auth != nil {
+		v.apps.Auth.OnClose(client)
+	}
 	store.Point.Del(addr)
 	return nil
 }
@@ -594,6 +677,13 @@ func (v *Switch) Start() {
 	}
 	libol.Go(ctrls.Ctrl.Start)
 	libol.Go(v.firewall.Start)
+	v.quota.Start()
+	if v.apps.Auth != nil {
+		v.apps.Auth.Acct.Start()
+	}
+	if v.apps.OnLines != nil {
+		v.apps.OnLines.Start()
+	}
 }
 
 func (v *Switch) Stop() {
@@ -610,6 +700,13 @@ func (v *Switch) Stop() {
 		v.leftClient(p.Client)
 	}
 	v.firewall.Stop()
+	v.quota.Stop()
+	if v.apps.Auth != nil {
+		v.apps.Auth.Acct.Stop()
+	}
+	if v.apps.OnLines != nil {
+		v.apps.OnLines.Stop()
+	}
 	if v.http != nil {
 		v.http.Shutdown()
 		v.http = nil
@@ -641,6 +738,15 @@ func (v *Switch) GetBridge(tenant string) (network.Bridger, error) {
 	return w.GetBridge(), nil
 }
 
+func (v *Switch) GetCluster(tenant string) api.Clusterer {
+	if w, ok := v.worker[tenant].(*OpenLANWorker); ok {
+		if c := w.GetCluster(); c != nil {
+			return c
+		}
+	}
+	return nil
+}
+
 func (v *Switch) NewTap(tenant string) (network.Taper, error) {
 	v.lock.Lock()
 	defer v.lock.Unlock()
@@ -658,6 +764,7 @@ func (v *Switch) NewTap(tenant string) (network.Taper, error) {
 		Type:     network.TAP,
 		VirBuf:   v.cfg.Queue.VirWrt,
 		KernBuf:  v.cfg.Queue.VirSnd,
+		Queues:   v.cfg.Queue.TapQus,
 		Name:     "auto",
 	})
 	if err != nil {
@@ -671,6 +778,101 @@ func (v *Switch) NewTap(tenant string) (network.Taper, error) {
 	return dev, nil
 }
 
+// SetVlan assigns vlan to tap on bridge, and the default of bridge if nil.
+func (v *Switch) SetVlan(dev network.Taper, vlan *network.PortVlan) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	w, ok := v.worker[dev.Tenant()]
+	if !ok || w.GetBridge() == nil {
+		return
+	}
+	if vlan == nil && w.GetConfig().Bridge != nil {
+		vlan = network.NewPortVlan(w.GetConfig().Bridge.Vlan)
+	}
+	if vlan == nil {
+		return
+	}
+	if err := w.GetBridge().SetVlan(dev.Name(), vlan); err != nil {
+		v.out.Warn("Switch.SetVlan: %s %s", dev, err)
+	}
+}
+
+// Isolate isolates tap from other points if isolation of network, and
+// not for the promiscuous one.
+func (v *Switch) Isolate(dev network.Taper, promisc bool) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	w, ok := v.worker[dev.Tenant()]
+	if !ok || w.GetBridge() == nil || !w.GetConfig().Isolation || promisc {
+		return
+	}
+	if err := w.GetBridge().SetIsolated(dev.Name(), true); err != nil {
+		v.out.Warn("Switch.Isolate: %s %s", dev, err)
+	}
+}
+
+// Edge sets tap as edge with bpdu guard for points, and links by admin
+// take part in spanning tree.
+func (v *Switch) Edge(dev network.Taper, edge bool) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	w, ok := v.worker[dev.Tenant()]
+	if !ok || w.GetBridge() == nil {
+		return
+	}
+	if err := w.GetBridge().SetEdge(dev.Name(), edge); err != nil {
+		v.out.Warn("Switch.Edge: %s %s", dev, err)
+	}
+}
+
+// TcpMss returns mss and whether clamped by mtu of bridge for tap.
+func (v *Switch) TcpMss(dev network.Taper) (int, bool) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	w, ok := v.worker[dev.Tenant()]
+	if !ok || w.GetConfig().Bridge == nil {
+		return 0, false
+	}
+	br := w.GetConfig().Bridge
+	return br.TcpMss, br.ClampMss
+}
+
+// Secure limits source addresses of tap by security of user, and saves
+// sticky addresses to the user.
+func (v *Switch) Secure(client libol.SocketClient, dev network.Taper, user *models.User) {
+	sec := user.Security
+	if sec == nil {
+		return
+	}
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	w, ok := v.worker[dev.Tenant()]
+	if !ok || w.GetBridge() == nil {
+		return
+	}
+	id := user.Id()
+	off := int32(0)
+	// called in path of frames, so sticky is saved and client is offline
+	// asynchronously.
+	handler := func(port, addr string, violated bool) {
+		if !violated {
+			if err := store.User.Sticky(id, addr); err != nil {
+				v.out.Warn("Switch.Secure: sticky %s %s", addr, err)
+			}
+			return
+		}
+		if sec.Action == network.SecureDisconnect && atomic.CompareAndSwapInt32(&off, 0, 1) {
+			v.out.Warn("Switch.Secure: %s violated by %s", client, addr)
+			libol.Go(func() {
+				v.OffClient(client)
+			})
+		}
+	}
+	if err := w.GetBridge().SetSecurity(dev.Name(), sec, handler); err != nil {
+		v.out.Warn("Switch.Secure: %s %s", dev, err)
+	}
+}
+
 func (v *Switch) FreeTap(dev network.Taper) error {
 	v.lock.Lock()
 	defer v.lock.Unlock()
@@ -702,39 +904,75 @@ func (v *Switch) DelLink(tenant, addr string) {
 	//TODO dynamic configure
 }
 
+// ReadTap reads frames from every queue of device, and dispatches them to
+// workers by hash of flow, that frames of a flow are processed in order.
 func (v *Switch) ReadTap(device network.Taper, readAt func(f *libol.FrameMessage) error) {
 	name := device.Name()
-	v.out.Info("Switch.ReadTap: %s", name)
-	done := make(chan bool, 2)
-	queue := make(chan *libol.FrameMessage, v.cfg.Queue.TapWr)
-	libol.Go(func() {
-		for {
-			frame := libol.NewFrameMessage(0)
-			n, err := device.Read(frame.Frame())
-			if err != nil {
-				v.out.Error("Switch.ReadTap: %s", err)
-				done <- true
-				break
+	queues := device.Queues()
+	workers := make([]chan *libol.FrameMessage, v.cfg.Queue.Worker)
+	if len(workers) == 0 {
+		workers = make([]chan *libol.FrameMessage, 1)
+	}
+	v.out.Info("Switch.ReadTap: %s with %d queues and %d workers", name, queues, len(workers))
+	done := make(chan bool, queues+len(workers))
+	stop := make(chan bool)
+	wait := sync.WaitGroup{}
+	for i := range workers {
+		queue := make(chan *libol.FrameMessage, v.cfg.Queue.TapWr)
+		workers[i] = queue
+		wait.Add(1)
+		libol.Go(func() {
+			defer wait.Done()
+			for {
+				select {
+				case frame := <-queue:
+					err := readAt(frame)
+					frame.Free()
+					if err != nil {
+						v.out.Error("Switch.ReadTap: readAt %s %s", name, err)
+						done <- true
+						return
+					}
+				case <-stop:
+					return
+				}
 			}
-			frame.SetSize(n)
-			if v.out.Has(libol.LOG) {
-				v.out.Log("Switch.ReadTap: %x\n", frame.Frame()[:n])
-			}
-			queue <- frame
-		}
-	})
-	defer device.Close()
-	for {
-		select {
-		case frame := <-queue:
-			if err := readAt(frame); err != nil {
-				v.out.Error("Switch.ReadTap: readAt %s %s", name, err)
-				return
+		})
+	}
+	for i := 0; i < queues; i++ {
+		q := i
+		libol.Go(func() {
+			for {
+				frame := libol.NewFrameMessage(0)
+				n, err := device.ReadQueue(q, frame.Frame())
+				if err != nil {
+					frame.Free()
+					v.out.Error("Switch.ReadTap: %s", err)
+					done <- true
+					break
+				}
+				frame.SetSize(n)
+				if v.out.Has(libol.LOG) {
+					v.out.Log("Switch.ReadTap: %x\n", frame.Frame()[:n])
+				}
+				queue := workers[0]
+				if len(workers) > 1 {
+					hash := libol.FlowHash(frame.Frame()[:n])
+					queue = workers[hash%uint32(len(workers))]
+				}
+				select {
+				case queue <- frame:
+				case <-stop:
+					frame.Free()
+					return
+				}
 			}
-		case <-done:
-			return
-		}
+		})
 	}
+	<-done
+	close(stop)
+	wait.Wait()
+	_ = device.Close()
 }
 
 func (v *Switch) OffClient(client libol.SocketClient) {
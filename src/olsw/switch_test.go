@@ -2,9 +2,14 @@ package olsw
 
 import (
 	"fmt"
+	"github.com/danieldin95/openlan-go/src/config"
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/danieldin95/openlan-go/src/network"
 	"github.com/danieldin95/openlan-go/src/olsw/store"
 	"github.com/stretchr/testify/assert"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestSwitch_LoadPass(t *testing.T) {
@@ -19,3 +24,54 @@ func TestSwitch_LoadPass(t *testing.T) {
 	}
 	assert.Equal(t, 2, store.User.Users.Len(), "notEqual")
 }
+
+func TestSwitch_ReadTap(t *testing.T) {
+	sw := &Switch{
+		cfg: &config.Switch{},
+		out: libol.NewSubLogger("test"),
+	}
+	sw.cfg.Queue.Default()
+	sw.cfg.Queue.Worker = 4
+	tap, _ := network.NewVirtualTap("test", network.TapConfig{VirBuf: 64, KernBuf: 64})
+	tap.Up()
+
+	lock := sync.Mutex{}
+	flows := make(map[byte][]byte, 8)
+	total := 0
+	done := make(chan bool)
+	go func() {
+		sw.ReadTap(tap, func(f *libol.FrameMessage) error {
+			data := f.Frame()
+			lock.Lock()
+			flows[data[11]] = append(flows[data[11]], data[20])
+			total++
+			lock.Unlock()
+			return nil
+		})
+		done <- true
+	}()
+	for seq := 0; seq < 8; seq++ {
+		for flow := 0; flow < 4; flow++ {
+			frame := make([]byte, 64)
+			copy(frame, libol.EthAll)
+			frame[11] = byte(flow)
+			frame[20] = byte(seq)
+			_, _ = tap.Send(frame)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		lock.Lock()
+		n := total
+		lock.Unlock()
+		if n == 32 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_ = tap.Close()
+	<-done
+	assert.Equal(t, 4, len(flows), "be the same.")
+	for _, seqs := range flows {
+		assert.Equal(t, []byte{0, 1, 2, 3, 4, 5, 6, 7}, seqs, "in order.")
+	}
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"github.com/danieldin95/openlan-go/src/config"
+	"github.com/danieldin95/openlan-go/src/libol"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func echoServer(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err, "listen echo.")
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return ln
+}
+
+func echoVia(t *testing.T, proxy, addr string) {
+	conn, err := libol.Dial(proxy, addr, time.Second)
+	if !assert.Nil(t, err, "dial by %s.", proxy) {
+		return
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte("hi openlan"))
+	assert.Nil(t, err, "write.")
+	buf := make([]byte, 10)
+	_, err = io.ReadFull(conn, buf)
+	assert.Nil(t, err, "read.")
+	assert.Equal(t, "hi openlan", string(buf), "be the same.")
+}
+
+func TestHttpProxy_Dial(t *testing.T) {
+	echo := echoServer(t)
+	defer echo.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err, "listen proxy.")
+	defer ln.Close()
+	h := NewHttpProxy(&config.HttpProxy{
+		Listen: ln.Addr().String(),
+		Auth:   config.Password{Username: "hi", Password: "123"},
+	})
+	go http.Serve(ln, h)
+
+	echoVia(t, "http://hi:123@"+ln.Addr().String(), echo.Addr().String())
+	_, err = libol.Dial("http://hi:456@"+ln.Addr().String(), echo.Addr().String(), time.Second)
+	assert.NotNil(t, err, "auth failed.")
+}
+
+func TestSocksProxy_Dial(t *testing.T) {
+	echo := echoServer(t)
+	defer echo.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err, "listen proxy.")
+	defer ln.Close()
+	s := NewSocksProxy(&config.SocksProxy{
+		Listen: ln.Addr().String(),
+		Auth:   config.Password{Username: "hi", Password: "123"},
+	})
+	go s.server.Serve(ln)
+
+	echoVia(t, "socks5://hi:123@"+ln.Addr().String(), echo.Addr().String())
+	_, err = libol.Dial("socks5://hi:456@"+ln.Addr().String(), echo.Addr().String(), time.Second)
+	assert.NotNil(t, err, "auth failed.")
+}
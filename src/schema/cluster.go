@@ -0,0 +1,15 @@
+package schema
+
+type Cluster struct {
+	Switch    string     `json:"switch"`
+	Network   string     `json:"network"`
+	Mode      string     `json:"mode"`
+	Priority  int        `json:"priority"`
+	Active    bool       `json:"active"`
+	Peer      string     `json:"peer,omitempty"`
+	PeerAlive bool       `json:"peerAlive"`
+	Leases    []Lease    `json:"leases,omitempty"`
+	Users     []User     `json:"users,omitempty"`
+	Deleted   []User     `json:"deleted,omitempty"` // tombstones of users.
+	Neighbors []Neighbor `json:"neighbors,omitempty"`
+}
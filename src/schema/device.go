@@ -20,4 +20,6 @@ type Bridge struct {
 	Macs   []HwMacInfo `json:"macs"`
 	Slaves []Device    `json:"slaves"`
 	Stats  interface{} `json:"stats"`
+	Stp    interface{} `json:"stp,omitempty"`
+	Storm  interface{} `json:"storm,omitempty"` // dropped of ports.
 }
@@ -18,7 +18,31 @@ type GraphNode struct {
 }
 
 type GraphLink struct {
-	Source int `json:"source"`
-	Target int `json:"target"`
-	Weight int `json:"weight"`
+	Source    int     `json:"source"`
+	Target    int     `json:"target"`
+	Weight    int     `json:"weight"`
+	Name      string  `json:"name"`
+	Type      string  `json:"type"` // point or link
+	Network   string  `json:"network"`
+	Protocol  string  `json:"protocol"`
+	State     string  `json:"state"`
+	AliveTime int64   `json:"aliveTime"`
+	RxBytes   int64   `json:"rxBytes"`
+	TxBytes   int64   `json:"txBytes"`
+	RxSpeed   int64   `json:"rxSpeed"` // bytes per second
+	TxSpeed   int64   `json:"txSpeed"`
+	Health    *Health `json:"health,omitempty"`
+}
+
+type Traffic struct {
+	Time    int64 `json:"time"`
+	RxBytes int64 `json:"rxBytes"`
+	TxBytes int64 `json:"txBytes"`
+	RxSpeed int64 `json:"rxSpeed"`
+	TxSpeed int64 `json:"txSpeed"`
+}
+
+type TrafficSeries struct {
+	Name    string    `json:"name"`
+	Samples []Traffic `json:"samples"`
 }
@@ -0,0 +1,15 @@
+package schema
+
+type HttpRequest struct {
+	Id     string `json:"id"`
+	Method string `json:"method"`
+	Url    string `json:"url"`
+	Body   []byte `json:"body,omitempty"`
+}
+
+type HttpResponse struct {
+	Id   string `json:"id"`
+	Code int    `json:"code"`
+	Type string `json:"type,omitempty"`
+	Body []byte `json:"body,omitempty"`
+}
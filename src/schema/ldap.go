@@ -0,0 +1,18 @@
+package schema
+
+type LDAP struct {
+	Server string `json:"server"`
+	Conns  int    `json:"conns"`
+	Idle   int    `json:"idle"`
+	Groups int    `json:"groups"`
+}
+
+type LDAPTest struct {
+	Name    string   `json:"name"`
+	Network string   `json:"network"`
+	DN      string   `json:"dn,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+	Role    string   `json:"role,omitempty"`
+	Allowed bool     `json:"allowed"`
+	Error   string   `json:"error,omitempty"`
+}
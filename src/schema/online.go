@@ -9,4 +9,8 @@ type OnLine struct {
 	IpProto    string `json:"ipProtocol"`
 	PortSource uint16 `json:"portSource"`
 	PortDest   uint16 `json:"portDestination"`
+	Packets    uint64 `json:"packets"`
+	Bytes      uint64 `json:"bytes"`
+	User       string `json:"user,omitempty"`
+	Network    string `json:"network,omitempty"`
 }
@@ -1,19 +1,40 @@
 package schema
 
 type Point struct {
-	Uptime    int64  `json:"uptime"`
-	UUID      string `json:"uuid"`
-	Network   string `json:"network"`
-	User      string `json:"user"`
-	Alias     string `json:"alias"`
-	Protocol  string `json:"protocol"`
-	Remote    string `json:"remote"`
-	Switch    string `json:"switch,omitempty"`
-	Device    string `json:"device"`
-	RxBytes   int64  `json:"rxBytes"`
-	TxBytes   int64  `json:"txBytes"`
-	ErrPkt    int64  `json:"errors"`
-	State     string `json:"state"`
-	AliveTime int64  `json:"aliveTime"`
-	System    string `json:"system"`
+	Uptime    int64    `json:"uptime"`
+	UUID      string   `json:"uuid"`
+	Network   string   `json:"network"`
+	User      string   `json:"user"`
+	Alias     string   `json:"alias"`
+	Protocol  string   `json:"protocol"`
+	Remote    string   `json:"remote"`
+	Switch    string   `json:"switch,omitempty"`
+	Device    string   `json:"device"`
+	RxBytes   int64    `json:"rxBytes"`
+	TxBytes   int64    `json:"txBytes"`
+	ErrPkt    int64    `json:"errors"`
+	State     string   `json:"state"`
+	AliveTime int64    `json:"aliveTime"`
+	System    string   `json:"system"`
+	Mtu       int      `json:"mtu,omitempty"`
+	Bond      string   `json:"bond,omitempty"`
+	Links     []string `json:"links,omitempty"` // remote addresses of bonding.
+	Health    *Health  `json:"health,omitempty"`
+}
+
+type Health struct {
+	Rtt      int64 `json:"rtt"`    // ms
+	Jitter   int64 `json:"jitter"` // ms
+	Loss     int   `json:"loss"`   // percent
+	Score    int   `json:"score"`
+	Degraded bool  `json:"degraded"`
+}
+
+type PointPath struct {
+	Connection string `json:"connection"`
+	Protocol   string `json:"protocol"`
+	Active     bool   `json:"active"`
+	Alive      bool   `json:"alive"`
+	ProbeAt    int64  `json:"probeAt,omitempty"`
+	FailAt     int64  `json:"failAt,omitempty"`
 }
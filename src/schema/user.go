@@ -1,9 +1,29 @@
 package schema
 
 type User struct {
-	Alias    string `json:"alias,omitempty"`
-	Role     string `json:"role,omitempty"` // admin, guest or other
-	Name     string `json:"name"`
-	Password string `json:"password"`
-	Network  string `json:"network"`
+	Alias      string `json:"alias,omitempty"`
+	Role       string `json:"role,omitempty"` // admin, guest or other
+	Name       string `json:"name"`
+	Password   string `json:"password"`
+	Network    string `json:"network"`
+	PassExpire int64  `json:"passExpire,omitempty"`
+	NotBefore  int64  `json:"notBefore,omitempty"`
+	NotAfter   int64  `json:"notAfter,omitempty"`
+	Disabled   bool   `json:"disabled,omitempty"`
+	MaxSession int    `json:"maxSession,omitempty"`
+	Quota      int64  `json:"quota,omitempty"`
+	Used       int64  `json:"used,omitempty"`
+	Otp        bool   `json:"otp,omitempty"`  // two-factor enabled.
+	Code       string `json:"code,omitempty"` // one time password to check.
+	Vlan       string `json:"vlan,omitempty"` // likes access/10 or trunk/1/10,20.
+	Promisc    bool   `json:"promisc,omitempty"`
+	Security   string `json:"security,omitempty"` // likes 2/drop or 1/disconnect/sticky.
+	UpdateAt   int64  `json:"updateAt,omitempty"`
+}
+
+type UserOtp struct {
+	Name     string   `json:"name"`
+	Secret   string   `json:"secret"`
+	Uri      string   `json:"uri"`
+	Recovery []string `json:"recovery"`
 }